@@ -0,0 +1,129 @@
+// /cmd/migrar-imagens/main.go
+//
+// Copia as imagens de cupcakes (incluindo a galeria) e kits gravadas em ./uploads para o armazenamento
+// configurado (IMAGEM_STORE, S3_*) e atualiza o ImagemURL de cada produto.
+// Imagens antigas, de arquivo único, ganham as rendições thumb/card/full.
+//
+//	go run ./cmd/migrar-imagens -dry-run
+//	go run ./cmd/migrar-imagens -remover-locais
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+)
+
+func main() {
+	dir := flag.String("dir", "uploads", "pasta local com as imagens atuais")
+	urlBase := flag.String("url-base", "/uploads", "caminho público da pasta local")
+	dryRun := flag.Bool("dry-run", false, "apenas lista o que seria migrado")
+	removerLocais := flag.Bool("remover-locais", false, "apaga os arquivos locais depois de migrar cada imagem")
+	flag.Parse()
+
+	// Só o banco e o armazenamento de imagens importam aqui: a validação completa do
+	// servidor (Mercado Pago, sessão) fica de fora
+	conf, err := config.Ler(os.Getenv("CONFIG_FILE"), os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Erro na configuração: %v", err)
+	}
+
+	destino, err := service.NewImageStore(conf.Imagens)
+	if err != nil {
+		log.Fatalf("Erro ao configurar o armazenamento de imagens: %v", err)
+	}
+	origem := &service.LocalImageStore{Dir: *dir, URLBase: *urlBase}
+	imagens := service.NewProcessadorImagens(destino, 0)
+
+	database.ConnectDB(conf.Banco)
+	ctx := context.Background()
+
+	// Inclui produtos excluídos: pedidos antigos e relatórios ainda mostram suas imagens
+	var cupcakes []model.Cupcake
+	if err := database.DB.Unscoped().Order("id").Find(&cupcakes).Error; err != nil {
+		log.Fatalf("Erro ao buscar cupcakes: %v", err)
+	}
+	var fotos []model.CupcakeImagem
+	if err := database.DB.Order("id").Find(&fotos).Error; err != nil {
+		log.Fatalf("Erro ao buscar a galeria dos cupcakes: %v", err)
+	}
+	var kits []model.Kit
+	if err := database.DB.Unscoped().Order("id").Find(&kits).Error; err != nil {
+		log.Fatalf("Erro ao buscar kits: %v", err)
+	}
+
+	// A imagem principal do cupcake também é a primeira foto da galeria: cada URL é
+	// copiada uma vez só e os arquivos locais saem no fim
+	migradasPorURL := map[string]string{}
+	migradas, falhas := 0, 0
+	migrar := func(tipo string, id uint, imagemURL string, atualizar func(string) error) {
+		if _, ok := destino.Chave(imagemURL); ok {
+			return
+		}
+		if _, ok := origem.Chave(imagemURL); !ok {
+			return // Imagem padrão ou URL externa
+		}
+		if *dryRun {
+			fmt.Printf("%s %d: %s\n", tipo, id, imagemURL)
+			migradas++
+			return
+		}
+
+		novaURL, ok := migradasPorURL[imagemURL]
+		var err error
+		if !ok {
+			novaURL, err = imagens.Migrar(ctx, origem, imagemURL)
+		}
+		if err == nil && novaURL != imagemURL {
+			err = atualizar(novaURL)
+		}
+		if err != nil {
+			log.Printf("ERRO: %s %d (%s): %v", tipo, id, imagemURL, err)
+			falhas++
+			return
+		}
+		fmt.Printf("%s %d: %s -> %s\n", tipo, id, imagemURL, novaURL)
+		migradas++
+		migradasPorURL[imagemURL] = novaURL
+	}
+
+	for _, c := range cupcakes {
+		migrar("Cupcake", c.ID, c.ImagemURL, func(url string) error {
+			return database.DB.Unscoped().Model(&model.Cupcake{}).Where("id = ?", c.ID).UpdateColumn("imagem_url", url).Error
+		})
+	}
+	for _, f := range fotos {
+		migrar("Foto", f.ID, f.URL, func(url string) error {
+			return database.DB.Model(&model.CupcakeImagem{}).Where("id = ?", f.ID).UpdateColumn("url", url).Error
+		})
+	}
+	for _, k := range kits {
+		migrar("Kit", k.ID, k.ImagemURL, func(url string) error {
+			return database.DB.Unscoped().Model(&model.Kit{}).Where("id = ?", k.ID).UpdateColumn("imagem_url", url).Error
+		})
+	}
+
+	if *dryRun {
+		fmt.Printf("%d imagens seriam migradas.\n", migradas)
+		return
+	}
+	if *removerLocais {
+		locais := service.NewProcessadorImagens(origem, 0)
+		for antiga, nova := range migradasPorURL {
+			if nova != antiga {
+				locais.Remover(ctx, antiga)
+			}
+		}
+	}
+	fmt.Printf("%d imagens migradas, %d falhas.\n", migradas, falhas)
+	if falhas > 0 {
+		log.Fatal("Migração incompleta; rode o comando novamente depois de corrigir os erros.")
+	}
+}
@@ -2,100 +2,220 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/handler"
+	"github.com/ericoliveiras/meu-cupcake/internal/logging"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/ericoliveiras/meu-cupcake/internal/view"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
-	"github.com/joho/godotenv"
-	"github.com/mercadopago/sdk-go/pkg/config"
+	mpconfig "github.com/mercadopago/sdk-go/pkg/config"
+	"github.com/mercadopago/sdk-go/pkg/payment"
 )
 
-var store *sessions.CookieStore
-
 func main() {
-	gob.Register(map[uint]int{})
+	// Subcomandos ("config print", "convite") fazem o trabalho e saem, sem subir o servidor
+	if len(os.Args) > 1 {
+		os.Exit(executarComando(os.Args[1:]))
+	}
+
+	gob.Register(service.Carrinho{})
+	gob.Register(map[uint]int{}) // Carrinho antigo, convertido por carrinhoDaSessao
 
-	err := godotenv.Load()
+	// Configuração: ambiente e o arquivo CONFIG_FILE (padrão .env), validada antes de tudo
+	conf, err := config.Carregar(os.Getenv("CONFIG_FILE"), os.LookupEnv)
 	if err != nil {
-		fmt.Println("Aviso: Erro ao carregar o arquivo .env:", err)
-		fmt.Println("Continuando execução, esperando variáveis de ambiente do sistema/segredos.")
-	} else {
-		fmt.Println("Arquivo .env carregado com sucesso.")
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		os.Exit(1)
 	}
-	// ----------------------
+	logging.Setup(logging.Config{Nivel: conf.Log.Nivel, Formato: conf.Log.Formato})
+
+	cfg, err := mpconfig.New(conf.MercadoPago.AccessToken)
+	if err != nil {
+		fatal("Erro ao criar configuração do Mercado Pago", "erro", err)
+	}
+	slog.Info("SDK do Mercado Pago v2 configurado...")
+
+	// Provedor de CEP (ViaCEP por padrão; "static" usa um arquivo JSON local)
+	var cepProvider service.CEPProvider
+	switch conf.CEP.Provedor {
+	case "static":
+		staticProvider, err := service.NewStaticCEPProvider(conf.CEP.ArquivoEstatico)
+		if err != nil {
+			fatal("Erro ao carregar provedor de CEP estático", "erro", err)
+		}
+		cepProvider = staticProvider
+	default:
+		cepProvider = service.NewViaCEPProvider(conf.CEP.ViaCEPURL)
+	}
+	cepProvider = service.NewCachedCEPProvider(cepProvider, conf.CEP.TamanhoCache)
+
+	// Datas de entrega, "hoje" da produção e filtros por dia seguem o fuso da loja (LOJA_TZ)
+	service.FusoLoja = conf.Loja.Localizacao()
 
-	mpAccessToken := os.Getenv("MP_ACCESS_TOKEN")
-	if mpAccessToken == "" {
-		log.Fatal("FATAL: MP_ACCESS_TOKEN não encontrado no ambiente.")
+	// Imagens enviadas pelo lojista (validadas e convertidas em rendições). IMAGEM_STORE=s3
+	// grava em um bucket; o padrão é a pasta ./uploads
+	imageStore, err := service.NewImageStore(conf.Imagens)
+	if err != nil {
+		fatal("Erro ao configurar o armazenamento de imagens", "erro", err)
 	}
-	cfg, err := config.New(mpAccessToken)
+	imagens := service.NewProcessadorImagens(imageStore, int64(conf.Imagens.TamanhoMaximoMB)<<20)
+
+	// E-mails aos clientes (redefinição de senha). Sem SMTP_HOST eles só vão para o log
+	mailer, err := service.NewMailer(conf.Email)
 	if err != nil {
-		log.Fatalf("Erro ao criar configuração do Mercado Pago: %v", err)
+		fatal("Erro ao configurar o envio de e-mails", "erro", err)
 	}
-	log.Println("SDK do Mercado Pago v2 configurado...")
 
-	sessionSecret := os.Getenv("SESSION_SECRET")
-	if sessionSecret == "" {
-		log.Fatal("FATAL: SESSION_SECRET não encontrado no ambiente.")
+	database.ConnectDB(conf.Banco)
+
+	// Sessões no servidor (Postgres ou Redis); o cookie leva só o ID assinado e criptografado
+	armazemSessoes, err := service.NewArmazemSessoes(conf.Sessao, database.DB)
+	if err != nil {
+		fatal("Erro ao configurar o armazenamento de sessões", "erro", err)
 	}
-	store = sessions.NewCookieStore([]byte(sessionSecret))
+	store := service.NewSessaoStore(armazemSessoes, sessions.Options{
+		Path:     "/",
+		Domain:   conf.Sessao.Dominio,
+		MaxAge:   int(conf.Sessao.Duracao.Seconds()),
+		Secure:   conf.Sessao.Secure,
+		HttpOnly: conf.Sessao.HTTPOnly,
+		SameSite: conf.Sessao.SameSiteHTTP(),
+	}, conf.Sessao.Segredo, conf.Sessao.SegredosAnteriores...)
+	database.SeedLojista(conf.Loja.LojistaEmail, store)
 
 	// Cria instâncias dos handlers
 	authHandler := &handler.AuthHandler{Store: store}
-	homeHandler := &handler.HomeHandler{Store: store, MPCfg: cfg}
-	lojistaHandler := &handler.LojistaHandler{Store: store, MPCfg: cfg}
-	cartHandler := &handler.CartHandler{Store: store, MPCfg: cfg}
+	// Fechado no início do desligamento, para encerrar os streams de eventos dos pedidos
+	encerrando := make(chan struct{})
+	homeHandler := &handler.HomeHandler{Store: store, MPCfg: cfg, Imagens: imagens, Encerrando: encerrando}
+	lojistaHandler := &handler.LojistaHandler{Store: store, MPCfg: cfg, Imagens: imagens, Mailer: mailer}
+	cartHandler := &handler.CartHandler{Store: store, MPCfg: cfg, MPPublicKey: conf.MercadoPago.PublicKey}
+	cepHandler := &handler.CEPHandler{Provider: cepProvider}
+	apiHandler := &handler.APIHandler{Store: store, MPCfg: cfg}
 
-	// Conecta ao DB (ConnectDB deve ler DATABASE_URL do ambiente)
-	database.ConnectDB()
-	database.SeedLojista()
+	// Tarefas em segundo plano: param quando workers é cancelado, depois que o servidor
+	// termina as requisições em andamento
+	workers, pararWorkers := context.WithCancel(context.Background())
+	var tarefas sync.WaitGroup
 
-	router := gin.Default()
+	// Mudanças de status dos pedidos (SSE). EVENTOS_PEDIDOS=postgres usa LISTEN/NOTIFY
+	// para que todas as instâncias recebam; o padrão entrega só dentro deste processo
+	if conf.Recursos.EventosPedidos == "postgres" {
+		hub := service.NewHubPostgres(database.DB, conf.Banco.URL)
+		tarefas.Go(func() { hub.Escutar(workers) })
+		service.Eventos = hub
+	}
 
-	// Configura GIN_MODE (lendo do ambiente ou padrão)
-	ginMode := os.Getenv("GIN_MODE")
-	if ginMode == "release" {
-		gin.SetMode(gin.ReleaseMode)
-	} else {
-		gin.SetMode(gin.DebugMode)
+	// Retenção da auditoria: AUDITORIA_RETENCAO_DIAS (padrão 365; 0 guarda tudo)
+	tarefas.Go(func() { service.ManterRetencaoAuditoria(workers, database.DB, conf.Loja.RetencaoAuditoria()) })
+	tarefas.Go(func() { service.ManterLimpezaSessoes(workers, armazemSessoes) })
+	// PIX pendentes: uma conferência para todos, em vez de uma por página aberta
+	tarefas.Go(func() { service.ManterConferenciaPix(workers, database.DB, payment.NewClient(cfg)) })
+
+	// Monitoramento: /readyz confere o Postgres, o Redis das sessões (se usado) e, com
+	// READYZ_MERCADO_PAGO=true, o Mercado Pago; /metrics exige METRICS_TOKEN como Bearer
+	// quando ele está definido
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		fatal("Erro ao acessar o pool do banco", "erro", err)
+	}
+	metricas.RegistrarPoolDB(metricas.Padrao, sqlDB)
+	verificacoes := []handler.Verificacao{{Nome: "postgres", Verificar: func(ctx context.Context) error {
+		return service.PingPostgres(ctx, database.DB)
+	}}}
+	if redis, ok := armazemSessoes.(*service.ArmazemSessoesRedis); ok {
+		verificacoes = append(verificacoes, handler.Verificacao{Nome: "redis", Verificar: redis.Ping})
+	}
+	if conf.Recursos.VerificarMercadoPago {
+		verificacoes = append(verificacoes, handler.Verificacao{Nome: "mercado_pago", Verificar: func(ctx context.Context) error {
+			return service.PingHTTP(ctx, http.DefaultClient, service.MercadoPagoAPIURL)
+		}})
 	}
+	saudeHandler := &handler.SaudeHandler{Verificacoes: verificacoes, MetricsToken: conf.Monitoramento.MetricsToken}
 
-	router.LoadHTMLGlob("internal/view/templates/*") // Caminho dentro do container
+	gin.SetMode(conf.Servidor.GinMode)
+
+	// O middleware de logging faz o log de acesso e o recovery no lugar dos do gin.Default;
+	// Identificar resolve o usuário logado uma vez por requisição (handler.UsuarioAtual) e
+	// ExigirCSRF confere o token dos formulários
+	router := gin.New()
+	// c.ClientIP (log de acesso e auditoria) só confia no proxy configurado; sem isso o
+	// gin aceitaria o X-Forwarded-For de qualquer cliente
+	if err := configurarIPCliente(router, conf.Servidor); err != nil {
+		fatal("Erro ao configurar os proxies confiáveis", "erro", err)
+	}
+	router.Use(logging.Middleware(), metricas.Middleware(), authHandler.Identificar(), authHandler.ExigirCSRF())
+
+	router.LoadHTMLFS(http.FS(view.Templates), view.PadraoTemplates) // Embutidos no binário
 
 	// Servir arquivos estáticos (caminhos dentro do container)
-	router.Static("/uploads", "./uploads")
+	router.Static("/uploads", "./uploads") // Armazenamento local e imagens ainda não migradas para o S3
 	router.Static("/static", "./static")
 
+	// --- Monitoramento ---
+	router.GET("/healthz", saudeHandler.Healthz)
+	router.GET("/readyz", saudeHandler.Readyz)
+	router.GET("/metrics", saudeHandler.Metricas)
+
+	// --- Notificações do Mercado Pago (assinadas com MP_WEBHOOK_SECRET, sem CSRF) ---
+	webhookHandler := &handler.WebhookHandler{Pagamentos: payment.NewClient(cfg), Segredo: conf.MercadoPago.WebhookSecret}
+	router.POST("/webhooks/mercadopago", webhookHandler.NotificacaoMercadoPago)
+
 	// --- Rotas Públicas ---
 	router.GET("/", homeHandler.ShowHomePage)
 	router.GET("/vitrine", homeHandler.ShowVitrinePage)
 	router.POST("/carrinho/adicionar/:id", cartHandler.AddToCart)
+	router.POST("/carrinho/kit/:id", cartHandler.AddKitToCart)
+	router.POST("/favoritos/:id", homeHandler.ToggleFavorito) // Responde 401 em JSON sem login
 	router.GET("/carrinho", cartHandler.ShowCartPage)
-	router.POST("/carrinho/remover/:id", cartHandler.RemoveFromCart)
-	router.POST("/carrinho/diminuir/:id", cartHandler.DecreaseQuantity)
+	router.POST("/carrinho/aumentar/:linha", cartHandler.IncreaseQuantity)
+	router.POST("/carrinho/remover/:linha", cartHandler.RemoveFromCart)
+	router.POST("/carrinho/diminuir/:linha", cartHandler.DecreaseQuantity)
 	router.POST("/carrinho/limpar", cartHandler.ClearCart)
+	router.POST("/carrinho/cupom", cartHandler.ApplyCoupon)
+	router.POST("/carrinho/cupom/remover", cartHandler.RemoveCoupon)
 	router.GET("/pagamento/sucesso", homeHandler.ShowPagamentoSucessoPage)
+	router.GET("/api/cep/:cep", cepHandler.BuscarCEP)
+
+	// --- API JSON (contrato em /api/v1/openapi.json) ---
+	apiHandler.Registrar(router.Group("/api/v1"))
 
 	// --- Rotas de Autenticação ---
 	router.GET("/cadastro", authHandler.ShowCadastroPage)     // Assumindo método
 	router.POST("/cadastro", authHandler.ProcessCadastroForm) // Assumindo método
 	router.GET("/login", authHandler.ShowLoginPage)
 	router.POST("/login", authHandler.ProcessLoginForm)
-	router.GET("/logout", authHandler.Logout)
+	router.GET("/convite/:token", authHandler.ShowConvitePage)
+	router.POST("/convite/:token", authHandler.ProcessConviteForm)
+	router.GET("/redefinir-senha/:token", authHandler.ShowRedefinirSenhaPage)
+	router.POST("/redefinir-senha/:token", authHandler.ProcessRedefinirSenhaForm)
+	router.POST("/logout", authHandler.Logout)
 
 	// --- Rotas Protegidas Gerais ---
 	protected := router.Group("/")
 	protected.Use(authHandler.AuthRequired())
 	{
 		protected.GET("/perfil", homeHandler.ShowProfilePage)
-		protected.GET("/perfil/editar", homeHandler.ShowEditProfilePage)   // Rota para mostrar o formulário
+		protected.GET("/perfil/editar", homeHandler.ShowEditProfilePage)     // Rota para mostrar o formulário
 		protected.POST("/perfil/editar", homeHandler.ProcessEditProfileForm) // Rota para processar o formulário
+		protected.POST("/perfil/sair-de-todos", authHandler.LogoutTodos)
 	}
 
 	// --- Rotas Protegidas do Cliente ---
@@ -105,35 +225,221 @@ func main() {
 		clienteRoutes.GET("/dashboard", homeHandler.ShowClienteDashboard)
 		clienteRoutes.GET("/checkout", cartHandler.ShowCheckoutPage)
 		clienteRoutes.GET("/pedidos", homeHandler.ShowClientePedidosPage)
+		clienteRoutes.GET("/pedidos/:id", homeHandler.ShowPedidoDetalhePage)
+		clienteRoutes.GET("/pedidos/:id/recibo", homeHandler.ReciboPedido)
+		clienteRoutes.POST("/pedidos/:id/repetir", homeHandler.RepetirPedido)
+		clienteRoutes.GET("/pedidos/:id/eventos", homeHandler.StreamEventosPedido)
 		clienteRoutes.POST("/processar-pagamento", cartHandler.ProcessPayment)
 		clienteRoutes.POST("/processar-pagamento-pix", cartHandler.ProcessPixPayment)
 		clienteRoutes.GET("/pedido/pagamento/:id", homeHandler.ShowPedidoPagamentoPage)
+		clienteRoutes.GET("/avaliar/:id", homeHandler.ShowAvaliacaoPage)
+		clienteRoutes.POST("/avaliar/:id", homeHandler.ProcessAvaliacao)
+		clienteRoutes.GET("/favoritos", homeHandler.ShowFavoritosPage)
+		clienteRoutes.POST("/favoritos/carrinho", homeHandler.MoverFavoritosParaCarrinho)
 	}
 
 	// --- Rotas Protegidas do Lojista ---
+	// Toda a equipe entra no painel; cada área exige a permissão do papel (model.Permissao)
 	lojistaRoutes := router.Group("/lojista")
 	lojistaRoutes.Use(authHandler.AuthRequired())
 	lojistaRoutes.Use(authHandler.RoleRequired(model.RoleLojista))
 	{
 		lojistaRoutes.GET("/dashboard", lojistaHandler.ShowLojistaDashboard)
-		lojistaRoutes.GET("/cupcakes", lojistaHandler.ShowCupcakesPage)
-		lojistaRoutes.POST("/cupcakes/novo", lojistaHandler.ProcessNewCupcakeForm)
-		lojistaRoutes.POST("/cupcakes/editar/:id", lojistaHandler.ProcessEditCupcakeForm)
-		lojistaRoutes.GET("/cupcakes/excluir/:id", lojistaHandler.DeleteCupcake)
-		lojistaRoutes.GET("/vendas", lojistaHandler.ShowLojistaVendasPage)
-		lojistaRoutes.POST("/vendas/status/:id", lojistaHandler.UpdatePedidoStatus)
+	}
+	catalogoRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoCatalogo))
+	{
+		catalogoRoutes.GET("/cupcakes", lojistaHandler.ShowCupcakesPage)
+		catalogoRoutes.POST("/cupcakes/novo", lojistaHandler.ProcessNewCupcakeForm)
+		catalogoRoutes.POST("/cupcakes/editar/:id", lojistaHandler.ProcessEditCupcakeForm)
+		catalogoRoutes.POST("/cupcakes/excluir/:id", lojistaHandler.DeleteCupcake)
+		catalogoRoutes.GET("/cupcakes/opcoes/:id", lojistaHandler.ShowOpcoesCupcakePage)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/grupos/novo", lojistaHandler.ProcessNovoGrupoOpcao)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/grupos/editar/:grupo", lojistaHandler.ProcessEditGrupoOpcao)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/grupos/excluir/:grupo", lojistaHandler.DeleteGrupoOpcao)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/opcoes/nova/:grupo", lojistaHandler.ProcessNovaOpcao)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/opcoes/ativa/:opcao", lojistaHandler.ToggleOpcao)
+		catalogoRoutes.POST("/cupcakes/opcoes/:id/opcoes/excluir/:opcao", lojistaHandler.DeleteOpcao)
+		catalogoRoutes.GET("/kits", lojistaHandler.ShowKitsPage)
+		catalogoRoutes.POST("/kits/novo", lojistaHandler.ProcessNovoKit)
+		catalogoRoutes.POST("/kits/editar/:id", lojistaHandler.ProcessEditKit)
+		catalogoRoutes.POST("/kits/excluir/:id", lojistaHandler.DeleteKit)
+	}
+	pedidosRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoPedidos))
+	{
+		pedidosRoutes.GET("/vendas", lojistaHandler.ShowLojistaVendasPage)
+		pedidosRoutes.POST("/vendas/status/:id", lojistaHandler.UpdatePedidoStatus)
+		pedidosRoutes.GET("/vendas/:id/recibo", lojistaHandler.ReciboVenda)
+	}
+	cozinhaRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoCozinha))
+	{
+		cozinhaRoutes.GET("/cozinha", lojistaHandler.ShowCozinhaPage)
+		cozinhaRoutes.POST("/cozinha/:id/avancar", lojistaHandler.AvancarPedidoCozinha)
+	}
+	entregasRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoEntregas))
+	{
+		entregasRoutes.GET("/entregas", lojistaHandler.ShowEntregasPage)
+		entregasRoutes.POST("/entregas/janelas/nova", lojistaHandler.ProcessNovaJanela)
+		entregasRoutes.POST("/entregas/janelas/ativa/:id", lojistaHandler.ToggleJanela)
+		entregasRoutes.POST("/entregas/janelas/excluir/:id", lojistaHandler.DeleteJanela)
+		entregasRoutes.POST("/entregas/bloqueios/novo", lojistaHandler.ProcessNovoBloqueio)
+		entregasRoutes.POST("/entregas/bloqueios/excluir/:id", lojistaHandler.DeleteBloqueio)
+	}
+	cuponsRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoCupons))
+	{
+		cuponsRoutes.GET("/cupons", lojistaHandler.ShowCuponsPage)
+		cuponsRoutes.POST("/cupons/novo", lojistaHandler.ProcessNovoCupom)
+		cuponsRoutes.POST("/cupons/editar/:id", lojistaHandler.ProcessEditCupom)
+		cuponsRoutes.POST("/cupons/excluir/:id", lojistaHandler.DeleteCupom)
+	}
+	avaliacoesRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoAvaliacoes))
+	{
+		avaliacoesRoutes.GET("/avaliacoes", lojistaHandler.ShowAvaliacoesPage)
+		avaliacoesRoutes.POST("/avaliacoes/:id/status", lojistaHandler.ModerarAvaliacao)
+		avaliacoesRoutes.POST("/avaliacoes/:id/responder", lojistaHandler.ResponderAvaliacao)
+	}
+	clientesRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoClientes))
+	{
+		clientesRoutes.GET("/clientes", lojistaHandler.ShowClientesPage)
+		clientesRoutes.GET("/clientes/:id", lojistaHandler.ShowClientePage)
+		clientesRoutes.POST("/clientes/:id/ativo", lojistaHandler.AlterarAtivoCliente)
+		clientesRoutes.POST("/clientes/:id/redefinir-senha", lojistaHandler.RedefinirSenhaCliente)
+	}
+	auditoriaRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoAuditoria))
+	{
+		auditoriaRoutes.GET("/auditoria", lojistaHandler.ShowAuditoriaPage)
+	}
+	equipeRoutes := lojistaRoutes.Group("", authHandler.RoleRequired(model.RoleLojista, model.PermissaoEquipe))
+	{
+		equipeRoutes.GET("/equipe", lojistaHandler.ShowEquipePage)
+		equipeRoutes.POST("/equipe/convidar", lojistaHandler.ProcessConviteEquipe)
+		equipeRoutes.POST("/equipe/:id/papel", lojistaHandler.AlterarPapelMembro)
+		equipeRoutes.POST("/equipe/:id/acesso", lojistaHandler.RedefinirAcessoMembro)
+		equipeRoutes.POST("/equipe/:id/excluir", lojistaHandler.DeleteMembro)
 	}
 
 	// --- Inicialização do Servidor ---
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // Padrão
-	}
 	// Importante para Fly.io: Ouvir em 0.0.0.0
-	listenAddr := fmt.Sprintf("0.0.0.0:%s", port)
-	log.Printf("Servidor rodando em %s (Modo Gin: %s)", listenAddr, gin.Mode())
-	err = router.Run(listenAddr) // Usa listenAddr
+	listenAddr := fmt.Sprintf("0.0.0.0:%s", conf.Servidor.Porta)
+	srv := &http.Server{
+		Addr:              listenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: conf.Servidor.ReadHeaderTimeout,
+		ReadTimeout:       conf.Servidor.ReadTimeout,
+		WriteTimeout:      conf.Servidor.WriteTimeout,
+		IdleTimeout:       conf.Servidor.IdleTimeout,
+	}
+	srv.RegisterOnShutdown(func() { close(encerrando) })
+
+	// SIGTERM (deploy) e SIGINT (padrão do Fly.io ao parar a máquina, Ctrl+C) iniciam o desligamento
+	sinal, pararSinais := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer pararSinais()
+	context.AfterFunc(sinal, pararSinais) // Um segundo sinal encerra na hora
+
+	slog.Info("Servidor rodando", "endereco", listenAddr, "modo_gin", gin.Mode())
+	if err := servir(sinal, srv, conf.Servidor.ShutdownTimeout); err != nil {
+		fatal("Falha no servidor HTTP", "erro", err)
+	}
+
+	// Desliga na ordem inversa da subida: servidor, tarefas em segundo plano, banco
+	pararWorkers()
+	tarefas.Wait()
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Erro ao fechar as conexões com o banco", "erro", err)
+	}
+	slog.Info("Servidor encerrado")
+}
+
+// servir atende até ctx ser cancelado e então para de aceitar conexões, esperando até
+// tempoLimite pelas requisições em andamento (um checkout no meio do pagamento, por
+// exemplo). Passado o limite, fecha as conexões que restarem.
+func servir(ctx context.Context, srv *http.Server, tempoLimite time.Duration) error {
+	erroServidor := make(chan error, 1)
+	go func() { erroServidor <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-erroServidor:
+		return err // Nem chegou a subir (porta ocupada, por exemplo)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Desligando: aguardando as requisições em andamento", "tempo_limite", tempoLimite)
+	encerrar, cancelar := context.WithTimeout(context.Background(), tempoLimite)
+	defer cancelar()
+	if err := srv.Shutdown(encerrar); err != nil {
+		slog.Warn("Requisições ainda em andamento no tempo limite; fechando as conexões", "erro", err)
+		srv.Close()
+	}
+	if err := <-erroServidor; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// configurarIPCliente diz ao gin de onde tirar o IP do cliente: do cabeçalho da
+// plataforma, do X-Forwarded-For dos proxies listados ou, sem nenhum, da conexão.
+func configurarIPCliente(router *gin.Engine, servidor config.Servidor) error {
+	switch servidor.PlataformaProxy {
+	case "fly":
+		router.TrustedPlatform = gin.PlatformFlyIO
+	case "cloudflare":
+		router.TrustedPlatform = gin.PlatformCloudflare
+	}
+	return router.SetTrustedProxies(servidor.ProxiesConfiaveis)
+}
+
+// fatal registra o erro e encerra o processo (slog não tem Fatal).
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// executarComando atende os subcomandos do binário e devolve o código de saída.
+func executarComando(args []string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		// Mostra o que foi lido mesmo com erros, para ajudar a corrigi-los
+		conf, err := config.Carregar(os.Getenv("CONFIG_FILE"), os.LookupEnv)
+		config.Imprimir(os.Stdout, conf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nConfiguração inválida:\n%v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if len(args) >= 1 && len(args) <= 2 && args[0] == "convite" {
+		return gerarConvite(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\nUso: %s [config print | convite [email]]\n", strings.Join(args, " "), os.Args[0])
+	return 2
+}
+
+// gerarConvite renova o convite do membro da equipe (padrão: LOJISTA_EMAIL) que ainda
+// não definiu a senha e escreve o link só na saída padrão: o token não passa pelo log.
+func gerarConvite(args []string) int {
+	conf, err := config.Carregar(os.Getenv("CONFIG_FILE"), os.LookupEnv)
 	if err != nil {
-		log.Fatalf("Falha ao iniciar o servidor Gin: %v", err)
+		fmt.Fprintf(os.Stderr, "Configuração inválida:\n%v\n", err)
+		return 1
+	}
+	email := conf.Loja.LojistaEmail
+	if len(args) == 1 {
+		email = args[0]
+	}
+
+	// O log padrão vai para a saída de erros, longe do link
+	database.ConnectDB(conf.Banco)
+	membro, token, err := service.RenovarConvite(database.DB, email)
+	switch {
+	case errors.Is(err, service.ErrNaoEhEquipe):
+		fmt.Fprintf(os.Stderr, "%s não faz parte da equipe da loja.\n", email)
+		return 1
+	case errors.Is(err, service.ErrAcessoAtivo):
+		fmt.Fprintf(os.Stderr, "%s já definiu a senha. Para trocá-la, um administrador usa Redefinir senha na página Equipe.\n", email)
+		return 1
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Erro ao gerar o convite: %v\n", err)
+		return 1
 	}
+	fmt.Fprintf(os.Stderr, "Convite de %s, válido por 7 dias (o link anterior deixou de valer):\n", membro.Email)
+	fmt.Println("/convite/" + token)
+	return 0
 }
@@ -0,0 +1,168 @@
+// /internal/config/carregar.go
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Oculto substitui os segredos em "config print".
+const Oculto = "[oculto]"
+
+var (
+	tipoDuracao      = reflect.TypeOf(time.Duration(0))
+	tipoTextoDecodif = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// lerFonte junta o arquivo e o ambiente em uma função de busca; o ambiente vence.
+func lerFonte(arquivo string, ambiente func(string) (string, bool)) (func(string) (string, bool), error) {
+	obrigatorio := arquivo != ""
+	if !obrigatorio {
+		arquivo = ArquivoPadrao
+	}
+	valores, err := godotenv.Read(arquivo)
+	if err != nil {
+		if obrigatorio || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("ler o arquivo de configuração %s: %w", arquivo, err)
+		}
+		valores = map[string]string{}
+	}
+	return func(nome string) (string, bool) {
+		if v, ok := ambiente(nome); ok {
+			return v, true
+		}
+		v, ok := valores[nome]
+		return v, ok
+	}, nil
+}
+
+// campo é uma configuração folha, com o valor refletido para leitura e escrita.
+type campo struct {
+	env, padrao, segredo string
+	valor                reflect.Value
+}
+
+// campos lista as folhas da Config na ordem da declaração.
+func campos(cfg *Config) []campo {
+	var lista []campo
+	var visitar func(v reflect.Value)
+	visitar = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			tipo := v.Type().Field(i)
+			env, ok := tipo.Tag.Lookup("env")
+			if !ok {
+				visitar(v.Field(i))
+				continue
+			}
+			lista = append(lista, campo{env: env, padrao: tipo.Tag.Get("padrao"), segredo: tipo.Tag.Get("segredo"), valor: v.Field(i)})
+		}
+	}
+	visitar(reflect.ValueOf(cfg).Elem())
+	return lista
+}
+
+// preencher grava em cfg o padrão ou o valor da fonte de cada campo. Devolve as
+// variáveis definidas na fonte e um erro por valor que não pôde ser convertido.
+func preencher(cfg *Config, fonte func(string) (string, bool)) (map[string]bool, []error) {
+	definidas := map[string]bool{}
+	var erros []error
+	for _, c := range campos(cfg) {
+		texto := c.padrao
+		if v, ok := fonte(c.env); ok && v != "" {
+			texto, definidas[c.env] = v, true
+		}
+		if texto == "" {
+			continue
+		}
+		if err := converter(c.valor, texto); err != nil {
+			erros = append(erros, fmt.Errorf("%s: valor inválido %q (%v)", c.env, texto, err))
+		}
+	}
+	return definidas, erros
+}
+
+// converter interpreta texto conforme o tipo do campo.
+func converter(v reflect.Value, texto string) error {
+	if v.Addr().Type().Implements(tipoTextoDecodif) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(texto))
+	}
+	switch {
+	case v.Type() == tipoDuracao:
+		d, err := time.ParseDuration(texto)
+		if err != nil {
+			return errors.New("use uma duração como 30m ou 720h")
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(texto)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(texto)
+		if err != nil {
+			return errors.New("use um número inteiro")
+		}
+		v.SetInt(int64(n))
+	case v.Type() == reflect.TypeOf([]string(nil)):
+		// Lista separada por vírgulas
+		var lista []string
+		for _, item := range strings.Split(texto, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				lista = append(lista, item)
+			}
+		}
+		v.Set(reflect.ValueOf(lista))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(texto)
+		if err != nil {
+			return errors.New("use true ou false")
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("tipo %s não suportado", v.Type())
+	}
+	return nil
+}
+
+// Imprimir escreve a configuração efetiva, uma variável por linha (NOME=valor), com os
+// segredos escondidos.
+func Imprimir(w io.Writer, cfg Config) {
+	for _, c := range campos(&cfg) {
+		fmt.Fprintf(w, "%s=%s\n", c.env, textoCampo(c))
+	}
+}
+
+// textoCampo formata o valor do campo para exibição.
+func textoCampo(c campo) string {
+	var texto string
+	switch v := c.valor.Interface().(type) {
+	case fmt.Stringer:
+		texto = v.String()
+	case []string:
+		texto = strings.Join(v, ",")
+	default:
+		texto = fmt.Sprint(v)
+	}
+	if texto == "" {
+		return ""
+	}
+	switch c.segredo {
+	case "true":
+		return Oculto
+	case "url":
+		// Mostra o host e o banco, sem a senha; DSN fora do formato de URL sai inteiro oculto
+		if u, err := url.Parse(texto); err == nil && u.Scheme != "" && u.Host != "" {
+			return u.Redacted()
+		}
+		return Oculto
+	}
+	return texto
+}
@@ -0,0 +1,319 @@
+// /internal/config/config.go
+//
+// Configuração da aplicação: lida do ambiente e, opcionalmente, de um arquivo no formato
+// .env, validada na inicialização e repassada aos handlers e serviços.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // LOJA_TZ funciona mesmo sem os fusos instalados no sistema
+)
+
+// Config reúne toda a configuração. Cada campo folha tem a tag env com o nome da
+// variável e, quando há, padrao com o valor usado se ela não estiver definida; segredo
+// esconde o valor em "config print" ("url" esconde só a senha da URL).
+type Config struct {
+	Servidor      Servidor
+	Banco         Banco
+	Sessao        Sessao
+	MercadoPago   MercadoPago
+	Imagens       Imagens
+	Email         Email
+	CEP           CEP
+	Log           Log
+	Monitoramento Monitoramento
+	Recursos      Recursos
+	Loja          Loja
+}
+
+// Servidor configura o HTTP.
+type Servidor struct {
+	Porta   string `env:"PORT" padrao:"8080"`
+	GinMode string `env:"GIN_MODE" padrao:"debug"`
+	// Limites de cada conexão (os campos de mesmo nome do http.Server). O stream de
+	// eventos dos pedidos não tem limite de escrita
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" padrao:"10s"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" padrao:"30s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" padrao:"60s"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" padrao:"120s"`
+	// ShutdownTimeout é quanto o servidor espera as requisições em andamento ao receber
+	// SIGTERM/SIGINT; deve caber no kill_timeout do fly.toml
+	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" padrao:"25s"`
+	// IP do cliente (logs e auditoria): PlataformaProxy (fly ou cloudflare) usa o cabeçalho
+	// que a plataforma preenche; ProxiesConfiaveis (IPs ou CIDRs) são os proxies cujo
+	// X-Forwarded-For vale. Sem nenhum dos dois, vale o IP da conexão
+	PlataformaProxy   string   `env:"TRUSTED_PLATFORM"`
+	ProxiesConfiaveis []string `env:"TRUSTED_PROXIES"`
+}
+
+// Banco configura a conexão com o Postgres e o pool.
+type Banco struct {
+	URL                string        `env:"DATABASE_URL" segredo:"url"`
+	MaxConexoesAbertas int           `env:"DB_MAX_CONEXOES_ABERTAS" padrao:"10"`
+	MaxConexoesOciosas int           `env:"DB_MAX_CONEXOES_OCIOSAS" padrao:"5"`
+	VidaMaximaConexao  time.Duration `env:"DB_VIDA_MAXIMA_CONEXAO" padrao:"30m"`
+}
+
+// Sessao configura o cookie e o armazenamento das sessões.
+type Sessao struct {
+	// Segredo gera as chaves que assinam e criptografam o cookie. Na troca, o segredo
+	// antigo vai para SegredosAnteriores (separados por vírgula) até as sessões vencerem
+	Segredo            string   `env:"SESSION_SECRET" segredo:"true"`
+	SegredosAnteriores []string `env:"SESSION_SECRETS_ANTERIORES" segredo:"true"`
+	// Store é onde ficam os dados: postgres (tabela sessaos) ou redis (REDIS_URL)
+	Store    string `env:"SESSION_STORE" padrao:"postgres"`
+	RedisURL string `env:"REDIS_URL" segredo:"url"`
+	// Duração do cookie; 0 faz o cookie acabar ao fechar o navegador
+	Duracao  time.Duration `env:"SESSION_DURACAO" padrao:"720h"`
+	Dominio  string        `env:"SESSION_DOMINIO"`
+	HTTPOnly bool          `env:"SESSION_HTTP_ONLY" padrao:"true"`
+	// Secure liga sozinho com GIN_MODE=release quando SESSION_SECURE não está definido
+	Secure   bool   `env:"SESSION_SECURE"`
+	SameSite string `env:"SESSION_SAME_SITE" padrao:"lax"`
+}
+
+// SameSiteHTTP converte SameSite (lax, strict ou none) para o cookie.
+func (s Sessao) SameSiteHTTP() http.SameSite {
+	switch s.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// MercadoPago guarda as chaves do checkout.
+type MercadoPago struct {
+	AccessToken string `env:"MP_ACCESS_TOKEN" segredo:"true"`
+	PublicKey   string `env:"MP_PUBLIC_KEY"`
+	// WebhookSecret é a assinatura secreta das notificações, gerada no painel do Mercado
+	// Pago; sem ela, as notificações são aceitas sem conferir a assinatura
+	WebhookSecret string `env:"MP_WEBHOOK_SECRET" segredo:"true"`
+}
+
+// Imagens configura o armazenamento e os limites dos envios do lojista.
+type Imagens struct {
+	Store             string `env:"IMAGEM_STORE" padrao:"local"` // local ou s3
+	TamanhoMaximoMB   int    `env:"IMAGEM_TAMANHO_MAXIMO_MB" padrao:"5"`
+	S3Endpoint        string `env:"S3_ENDPOINT"`
+	S3Region          string `env:"S3_REGION" padrao:"us-east-1"`
+	S3Bucket          string `env:"S3_BUCKET"`
+	S3AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY" segredo:"true"`
+	S3PublicURL       string `env:"S3_PUBLIC_URL"`
+}
+
+// Email configura o SMTP. Sem Host, os e-mails só aparecem no log.
+type Email struct {
+	Host      string `env:"SMTP_HOST"`
+	Porta     string `env:"SMTP_PORT" padrao:"587"`
+	Usuario   string `env:"SMTP_USUARIO"`
+	Senha     string `env:"SMTP_SENHA" segredo:"true"`
+	Remetente string `env:"SMTP_REMETENTE"`
+}
+
+// CEP escolhe o provedor de endereços.
+type CEP struct {
+	Provedor        string `env:"CEP_PROVIDER" padrao:"viacep"` // viacep ou static
+	ArquivoEstatico string `env:"CEP_STATIC_FILE"`
+	ViaCEPURL       string `env:"VIACEP_URL" padrao:"https://viacep.com.br"`
+	TamanhoCache    int    `env:"CEP_CACHE_SIZE" padrao:"1000"`
+}
+
+// Log configura o logger.
+type Log struct {
+	Nivel slog.Level `env:"LOG_LEVEL" padrao:"info"`
+	// Formato (json ou text) segue o GIN_MODE quando LOG_FORMAT não está definido
+	Formato string `env:"LOG_FORMAT"`
+}
+
+// Monitoramento protege o /metrics.
+type Monitoramento struct {
+	MetricsToken string `env:"METRICS_TOKEN" segredo:"true"`
+}
+
+// Recursos são as funcionalidades opcionais.
+type Recursos struct {
+	// EventosPedidos escolhe o barramento das mudanças de status: memoria (só esta
+	// instância) ou postgres (LISTEN/NOTIFY, para várias instâncias)
+	EventosPedidos string `env:"EVENTOS_PEDIDOS" padrao:"memoria"`
+	// VerificarMercadoPago inclui a API do Mercado Pago no /readyz
+	VerificarMercadoPago bool `env:"READYZ_MERCADO_PAGO"`
+}
+
+// Loja guarda dados da operação.
+type Loja struct {
+	LojistaEmail          string `env:"LOJISTA_EMAIL" padrao:"lojista@meucupcake.com"`
+	AuditoriaRetencaoDias int    `env:"AUDITORIA_RETENCAO_DIAS" padrao:"365"` // 0 guarda tudo
+	// Fuso é o fuso horário da loja (nome IANA): define o "hoje" e o horário das janelas
+	Fuso string `env:"LOJA_TZ" padrao:"America/Sao_Paulo"`
+}
+
+// Producao indica GIN_MODE=release.
+func (c Config) Producao() bool {
+	return c.Servidor.GinMode == "release"
+}
+
+// RetencaoAuditoria é AuditoriaRetencaoDias como duração.
+func (l Loja) RetencaoAuditoria() time.Duration {
+	return time.Duration(l.AuditoriaRetencaoDias) * 24 * time.Hour
+}
+
+// Localizacao é o Fuso carregado; UTC se ele for inválido (o Validar recusa).
+func (l Loja) Localizacao() *time.Location {
+	loc, err := time.LoadLocation(l.Fuso)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ArquivoPadrao é lido quando CONFIG_FILE não está definido; a falta dele não é erro.
+const ArquivoPadrao = ".env"
+
+// Ler monta a configuração sem validá-la: padrões, depois o arquivo (formato .env) e
+// por fim o ambiente (ambiente, normalmente os.LookupEnv), que vence. arquivo vazio usa
+// ArquivoPadrao, se existir. Ferramentas que só usam parte da configuração (como
+// cmd/migrar-imagens) param aqui.
+func Ler(arquivo string, ambiente func(string) (string, bool)) (Config, error) {
+	var cfg Config
+	fonte, err := lerFonte(arquivo, ambiente)
+	if err != nil {
+		return cfg, err
+	}
+	definidas, erros := preencher(&cfg, fonte)
+	cfg.completar(definidas)
+	return cfg, errors.Join(erros...)
+}
+
+// Carregar lê e valida a configuração do servidor, juntando todos os problemas em um
+// erro. A Config volta preenchida mesmo com erros, para "config print" mostrar o que
+// foi lido.
+func Carregar(arquivo string, ambiente func(string) (string, bool)) (Config, error) {
+	cfg, err := Ler(arquivo, ambiente)
+	return cfg, errors.Join(err, errors.Join(cfg.Validar()...))
+}
+
+// completar aplica os padrões que dependem de outros campos.
+func (c *Config) completar(definidas map[string]bool) {
+	if !definidas["SESSION_SECURE"] {
+		c.Sessao.Secure = c.Producao()
+	}
+	if c.Log.Formato == "" {
+		c.Log.Formato = "text"
+		if c.Producao() {
+			c.Log.Formato = "json"
+		}
+	}
+}
+
+// Validar confere os valores e devolve um erro por problema, com o nome da variável.
+func (c Config) Validar() []error {
+	var erros []error
+	falta := func(nome, motivo string) {
+		erros = append(erros, fmt.Errorf("%s é obrigatório%s", nome, motivo))
+	}
+	umDe := func(nome, valor string, opcoes ...string) {
+		for _, o := range opcoes {
+			if valor == o {
+				return
+			}
+		}
+		erros = append(erros, fmt.Errorf("%s deve ser %s (veio %q)", nome, strings.Join(opcoes, ", "), valor))
+	}
+	minimo := func(nome string, valor, min int) {
+		if valor < min {
+			erros = append(erros, fmt.Errorf("%s deve ser ao menos %d (veio %d)", nome, min, valor))
+		}
+	}
+
+	umDe("GIN_MODE", c.Servidor.GinMode, "debug", "release", "test")
+	if c.Servidor.PlataformaProxy != "" {
+		umDe("TRUSTED_PLATFORM", c.Servidor.PlataformaProxy, "fly", "cloudflare")
+	}
+	for _, proxy := range c.Servidor.ProxiesConfiaveis {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			erros = append(erros, fmt.Errorf("TRUSTED_PROXIES: %q não é um IP ou CIDR", proxy))
+		}
+	}
+	for _, v := range []struct {
+		nome  string
+		valor time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", c.Servidor.ReadHeaderTimeout}, {"HTTP_READ_TIMEOUT", c.Servidor.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.Servidor.WriteTimeout}, {"HTTP_IDLE_TIMEOUT", c.Servidor.IdleTimeout},
+		{"HTTP_SHUTDOWN_TIMEOUT", c.Servidor.ShutdownTimeout},
+	} {
+		if v.valor <= 0 {
+			erros = append(erros, fmt.Errorf("%s deve ser positivo (veio %s)", v.nome, v.valor))
+		}
+	}
+	if c.Banco.URL == "" {
+		falta("DATABASE_URL", "")
+	}
+	minimo("DB_MAX_CONEXOES_ABERTAS", c.Banco.MaxConexoesAbertas, 1)
+	minimo("DB_MAX_CONEXOES_OCIOSAS", c.Banco.MaxConexoesOciosas, 0)
+	if c.Banco.MaxConexoesOciosas > c.Banco.MaxConexoesAbertas {
+		erros = append(erros, errors.New("DB_MAX_CONEXOES_OCIOSAS não pode passar de DB_MAX_CONEXOES_ABERTAS"))
+	}
+
+	if c.Sessao.Segredo == "" {
+		falta("SESSION_SECRET", "")
+	}
+	umDe("SESSION_SAME_SITE", c.Sessao.SameSite, "lax", "strict", "none")
+	umDe("SESSION_STORE", c.Sessao.Store, "postgres", "redis")
+	if c.Sessao.Store == "redis" && c.Sessao.RedisURL == "" {
+		falta("REDIS_URL", " com SESSION_STORE=redis")
+	}
+	if c.Sessao.SameSite == "none" && !c.Sessao.Secure {
+		erros = append(erros, errors.New("SESSION_SAME_SITE=none exige SESSION_SECURE=true"))
+	}
+	if c.Sessao.Duracao < 0 {
+		erros = append(erros, errors.New("SESSION_DURACAO não pode ser negativa"))
+	}
+
+	if c.MercadoPago.AccessToken == "" {
+		falta("MP_ACCESS_TOKEN", "")
+	}
+	if c.MercadoPago.PublicKey == "" {
+		falta("MP_PUBLIC_KEY", " (usada no checkout)")
+	}
+
+	umDe("IMAGEM_STORE", c.Imagens.Store, "local", "s3")
+	minimo("IMAGEM_TAMANHO_MAXIMO_MB", c.Imagens.TamanhoMaximoMB, 1)
+	if c.Imagens.Store == "s3" {
+		for _, v := range [][2]string{
+			{"S3_ENDPOINT", c.Imagens.S3Endpoint}, {"S3_BUCKET", c.Imagens.S3Bucket},
+			{"S3_ACCESS_KEY_ID", c.Imagens.S3AccessKeyID}, {"S3_SECRET_ACCESS_KEY", c.Imagens.S3SecretAccessKey},
+		} {
+			if v[1] == "" {
+				falta(v[0], " com IMAGEM_STORE=s3")
+			}
+		}
+	}
+
+	if c.Email.Host != "" && c.Email.Remetente == "" {
+		falta("SMTP_REMETENTE", " com SMTP_HOST")
+	}
+
+	umDe("CEP_PROVIDER", c.CEP.Provedor, "viacep", "static")
+	if c.CEP.Provedor == "static" && c.CEP.ArquivoEstatico == "" {
+		falta("CEP_STATIC_FILE", " com CEP_PROVIDER=static")
+	}
+	minimo("CEP_CACHE_SIZE", c.CEP.TamanhoCache, 1)
+
+	umDe("LOG_FORMAT", c.Log.Formato, "json", "text")
+	umDe("EVENTOS_PEDIDOS", c.Recursos.EventosPedidos, "memoria", "postgres")
+	minimo("AUDITORIA_RETENCAO_DIAS", c.Loja.AuditoriaRetencaoDias, 0)
+	if _, err := time.LoadLocation(c.Loja.Fuso); err != nil || c.Loja.Fuso == "" {
+		erros = append(erros, fmt.Errorf("LOJA_TZ: %q não é um fuso horário (ex.: America/Sao_Paulo)", c.Loja.Fuso))
+	}
+	return erros
+}
@@ -0,0 +1,185 @@
+// /internal/config/config_test.go
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ambienteDe simula os.LookupEnv com as variáveis dadas.
+func ambienteDe(vars map[string]string) func(string) (string, bool) {
+	return func(nome string) (string, bool) {
+		v, ok := vars[nome]
+		return v, ok
+	}
+}
+
+// minimo tem só as variáveis obrigatórias.
+func minimo() map[string]string {
+	return map[string]string{
+		"DATABASE_URL":    "postgres://app:s3nh4@db:5432/cupcake",
+		"SESSION_SECRET":  "segredo-da-sessao",
+		"MP_ACCESS_TOKEN": "APP_USR-123",
+		"MP_PUBLIC_KEY":   "APP_USR-pub",
+	}
+}
+
+// semArquivo aponta para um arquivo vazio, para o .env da máquina não interferir.
+func semArquivo(t *testing.T) string {
+	t.Helper()
+	arquivo := filepath.Join(t.TempDir(), "vazio.env")
+	if err := os.WriteFile(arquivo, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return arquivo
+}
+
+func TestCarregarPadroes(t *testing.T) {
+	cfg, err := Carregar(semArquivo(t), ambienteDe(minimo()))
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if cfg.Servidor.Porta != "8080" || cfg.Servidor.GinMode != "debug" || cfg.Servidor.WriteTimeout != time.Minute || cfg.Servidor.ShutdownTimeout != 25*time.Second {
+		t.Errorf("Servidor = %+v", cfg.Servidor)
+	}
+	if cfg.Banco.MaxConexoesAbertas != 10 || cfg.Banco.MaxConexoesOciosas != 5 || cfg.Banco.VidaMaximaConexao != 30*time.Minute {
+		t.Errorf("Banco = %+v", cfg.Banco)
+	}
+	if cfg.Sessao.Duracao != 720*time.Hour || !cfg.Sessao.HTTPOnly || cfg.Sessao.Secure || cfg.Sessao.SameSite != "lax" || cfg.Sessao.Store != "postgres" {
+		t.Errorf("Sessao = %+v", cfg.Sessao)
+	}
+	if cfg.Log.Nivel != slog.LevelInfo || cfg.Log.Formato != "text" {
+		t.Errorf("Log = %+v", cfg.Log)
+	}
+	if cfg.Loja.RetencaoAuditoria() != 365*24*time.Hour {
+		t.Errorf("RetencaoAuditoria = %v", cfg.Loja.RetencaoAuditoria())
+	}
+}
+
+func TestCarregarProducao(t *testing.T) {
+	vars := minimo()
+	vars["GIN_MODE"] = "release"
+	cfg, err := Carregar(semArquivo(t), ambienteDe(vars))
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if !cfg.Sessao.Secure || cfg.Log.Formato != "json" {
+		t.Errorf("Em produção, Secure = %v e Formato = %q; esperado true e json", cfg.Sessao.Secure, cfg.Log.Formato)
+	}
+
+	vars["SESSION_SECURE"] = "false"
+	vars["LOG_FORMAT"] = "text"
+	cfg, err = Carregar(semArquivo(t), ambienteDe(vars))
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if cfg.Sessao.Secure || cfg.Log.Formato != "text" {
+		t.Error("Os valores definidos devem vencer os padrões de produção")
+	}
+}
+
+func TestCarregarArquivo(t *testing.T) {
+	dir := t.TempDir()
+	arquivo := filepath.Join(dir, "app.env")
+	conteudo := "DATABASE_URL=postgres://arquivo@db/cupcake\nSESSION_SECRET=do-arquivo\nMP_ACCESS_TOKEN=do-arquivo\nMP_PUBLIC_KEY=do-arquivo\nPORT=9000\n"
+	if err := os.WriteFile(arquivo, []byte(conteudo), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("o ambiente vence o arquivo", func(t *testing.T) {
+		cfg, err := Carregar(arquivo, ambienteDe(map[string]string{"PORT": "7000"}))
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if cfg.Servidor.Porta != "7000" || cfg.Sessao.Segredo != "do-arquivo" {
+			t.Errorf("Porta = %q, Segredo = %q", cfg.Servidor.Porta, cfg.Sessao.Segredo)
+		}
+	})
+
+	t.Run("arquivo informado precisa existir", func(t *testing.T) {
+		if _, err := Carregar(filepath.Join(dir, "nao-existe.env"), ambienteDe(minimo())); err == nil {
+			t.Error("Esperava erro com CONFIG_FILE apontando para um arquivo ausente")
+		}
+	})
+}
+
+func TestCarregarErros(t *testing.T) {
+	vars := map[string]string{
+		"DB_MAX_CONEXOES_ABERTAS": "muitas",
+		"SESSION_DURACAO":         "um mes",
+		"SESSION_SAME_SITE":       "none",
+		"IMAGEM_STORE":            "s3",
+		"LOG_LEVEL":               "barulhento",
+		"EVENTOS_PEDIDOS":         "kafka",
+		"HTTP_IDLE_TIMEOUT":       "0s",
+		"SESSION_STORE":           "redis",
+		"TRUSTED_PLATFORM":        "heroku",
+		"TRUSTED_PROXIES":         "10.0.0.0/8,proxy.interno",
+		"LOJA_TZ":                 "Brasil/Curitiba",
+	}
+	_, err := Carregar(semArquivo(t), ambienteDe(vars))
+	if err == nil {
+		t.Fatal("Esperava erro de validação")
+	}
+	for _, trecho := range []string{
+		`DB_MAX_CONEXOES_ABERTAS: valor inválido "muitas"`,
+		`SESSION_DURACAO: valor inválido "um mes"`,
+		`LOG_LEVEL: valor inválido "barulhento"`,
+		"DATABASE_URL é obrigatório",
+		"SESSION_SECRET é obrigatório",
+		"MP_ACCESS_TOKEN é obrigatório",
+		"MP_PUBLIC_KEY é obrigatório",
+		"SESSION_SAME_SITE=none exige SESSION_SECURE=true",
+		"S3_BUCKET é obrigatório com IMAGEM_STORE=s3",
+		`EVENTOS_PEDIDOS deve ser memoria, postgres (veio "kafka")`,
+		"HTTP_IDLE_TIMEOUT deve ser positivo (veio 0s)",
+		"REDIS_URL é obrigatório com SESSION_STORE=redis",
+		`TRUSTED_PLATFORM deve ser fly, cloudflare (veio "heroku")`,
+		`TRUSTED_PROXIES: "proxy.interno" não é um IP ou CIDR`,
+		`LOJA_TZ: "Brasil/Curitiba" não é um fuso horário`,
+	} {
+		if !strings.Contains(err.Error(), trecho) {
+			t.Errorf("Faltou %q em:\n%v", trecho, err)
+		}
+	}
+}
+
+func TestImprimir(t *testing.T) {
+	vars := minimo()
+	vars["SMTP_SENHA"] = "senha-smtp"
+	vars["SESSION_SECRETS_ANTERIORES"] = "velho-1, velho-2,"
+	cfg, err := Carregar(semArquivo(t), ambienteDe(vars))
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if len(cfg.Sessao.SegredosAnteriores) != 2 || cfg.Sessao.SegredosAnteriores[1] != "velho-2" {
+		t.Errorf("SegredosAnteriores = %q", cfg.Sessao.SegredosAnteriores)
+	}
+	var buf bytes.Buffer
+	Imprimir(&buf, cfg)
+	saida := buf.String()
+
+	for _, segredo := range []string{"s3nh4", "segredo-da-sessao", "APP_USR-123", "senha-smtp", "velho-1"} {
+		if strings.Contains(saida, segredo) {
+			t.Errorf("Segredo %q apareceu na saída:\n%s", segredo, saida)
+		}
+	}
+	for _, linha := range []string{
+		"DATABASE_URL=postgres://app:xxxxx@db:5432/cupcake",
+		"SESSION_SECRET=" + Oculto,
+		"SESSION_SECRETS_ANTERIORES=" + Oculto,
+		"MP_PUBLIC_KEY=APP_USR-pub",
+		"DB_VIDA_MAXIMA_CONEXAO=30m0s",
+		"LOG_LEVEL=INFO",
+		"SMTP_HOST=\n",
+	} {
+		if !strings.Contains(saida, linha) {
+			t.Errorf("Faltou %q em:\n%s", linha, saida)
+		}
+	}
+}
@@ -2,10 +2,10 @@
 package database
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,30 +13,46 @@ import (
 
 var DB *gorm.DB
 
-func ConnectDB() {
+// ConnectDB abre a conexão (cfg.URL), ajusta o pool e roda as migrações.
+func ConnectDB(cfg config.Banco) {
 	var err error
 
-	// Lê a URL completa do ambiente
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("DATABASE_URL não encontrado no .env")
+	if cfg.URL == "" {
+		slog.Error("DATABASE_URL não encontrado no .env")
+		os.Exit(1)
 	}
 
 	// Tenta abrir a conexão com GORM usando a URL completa
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(cfg.URL), &gorm.Config{})
 	if err != nil {
-		log.Fatalf("Falha ao conectar ao banco de dados Neon usando URL: %v\nURL usada: %s", err, dsn)
+		// O DSN traz a senha do banco: não entra no log (o erro passa pela máscara do logger)
+		slog.Error("Falha ao conectar ao banco de dados Neon", "erro", err)
+		os.Exit(1)
 	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		slog.Error("Falha ao acessar o pool do banco de dados", "erro", err)
+		os.Exit(1)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxConexoesAbertas)
+	sqlDB.SetMaxIdleConns(cfg.MaxConexoesOciosas)
+	sqlDB.SetConnMaxLifetime(cfg.VidaMaximaConexao)
 
-	fmt.Println("Conexão com o banco de dados Neon (via URL) estabelecida com sucesso.")
+	slog.Info("Conexão com o banco de dados Neon (via URL) estabelecida com sucesso.")
 
 	// --- Auto Migration  ---
-	fmt.Println("Executando migrações do banco de dados...")
+	slog.Info("Executando migrações do banco de dados...")
 	err = DB.AutoMigrate(
 		&model.Usuario{}, &model.Cupcake{}, &model.Order{}, &model.ItemOrder{},
+		&model.JanelaEntrega{}, &model.DataBloqueada{}, &model.OcupacaoJanela{},
+		&model.Cupom{}, &model.GrupoOpcao{}, &model.Opcao{}, &model.ItemOrderOpcao{},
+		&model.Kit{}, &model.KitItem{}, &model.CupcakeImagem{}, &model.Avaliacao{},
+		&model.Favorito{}, &model.HistoricoStatusPedido{}, &model.AuditLog{},
+		&model.Sessao{}, &model.TokenAPI{},
 	)
 	if err != nil {
-		log.Fatal("Falha ao executar migrações:", err)
+		slog.Error("Falha ao executar migrações", "erro", err)
+		os.Exit(1)
 	}
-	fmt.Println("Migrações concluídas com sucesso.")
+	slog.Info("Migrações concluídas com sucesso.")
 }
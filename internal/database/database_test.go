@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"testing" // Pacote de testes padrão
 
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
 	"github.com/joho/godotenv" // Para carregar .env
 	// Import GORM só se precisar interagir com DB diretamente no teste (não necessário aqui)
 	// "gorm.io/gorm"
@@ -56,7 +57,7 @@ func TestConnectDB(t *testing.T) {
 	// Não precisamos mais mockar log.Fatalf nem usar goroutine aqui.
 	// Se ConnectDB falhar e chamar log.Fatalf, o processo de teste inteiro vai parar,
 	// o que é um resultado aceitável para um erro crítico de conexão.
-	ConnectDB()
+	ConnectDB(config.Banco{URL: os.Getenv("DATABASE_URL")})
 
 	// 3. Verifica se a variável global DB foi inicializada
 	if DB == nil {
@@ -2,38 +2,87 @@
 package database
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 )
 
-func SeedLojista() {
-	var user model.Usuario
-	result := DB.Where("email = ?", "lojista@meucupcake.com").First(&user)
+// senhaPadraoAntiga é a senha que versões anteriores criavam para o lojista; por ser
+// pública, contas que ainda a usam são bloqueadas até aceitarem um novo convite.
+const senhaPadraoAntiga = "senhaforte123"
 
-	if result.Error != nil && result.Error == gorm.ErrRecordNotFound {
-		log.Println("Usuário lojista não encontrado, criando um novo...")
+// SeedLojista garante que a loja tenha um administrador. Sem nenhum, cria a conta de
+// email (LOJISTA_EMAIL) sem senha; o link de convite para defini-la sai do subcomando
+// "convite", nunca do log. As sessões de quem perde a senha aqui são encerradas em store.
+func SeedLojista(email string, store *service.SessaoStore) {
+	// Lojistas de antes dos papéis são administradores
+	if err := DB.Model(&model.Usuario{}).
+		Where("tipo = ? AND (papel IS NULL OR papel = '')", model.RoleLojista).
+		Update("papel", model.PapelAdmin).Error; err != nil {
+		slog.Error("Falha ao definir o papel dos lojistas", "erro", err)
+		os.Exit(1)
+	}
 
-		senhaHash, err := bcrypt.GenerateFromPassword([]byte("senhaforte123"), bcrypt.DefaultCost)
-		if err != nil {
-			log.Fatalf("Falha ao criar hash da senha do lojista: %v", err)
+	var equipe []model.Usuario
+	if err := DB.Where("tipo = ?", model.RoleLojista).Order("id").Find(&equipe).Error; err != nil {
+		slog.Error("Falha ao buscar a equipe da loja", "erro", err)
+		os.Exit(1)
+	}
+	for i, membro := range equipe {
+		if bcrypt.CompareHashAndPassword([]byte(membro.SenhaHash), []byte(senhaPadraoAntiga)) == nil {
+			equipe[i] = bloquearAcesso(membro, store)
+			slog.Warn("Conta com a senha padrão antiga bloqueada. Gere o link para uma nova senha com o subcomando convite.", "email", membro.Email)
 		}
+	}
 
-		lojista := model.Usuario{
-			Nome:      "Lojista Principal",
-			Email:     "lojista@meucupcake.com",
-			SenhaHash: string(senhaHash),
-			Tipo:      model.RoleLojista, 
+	for _, membro := range equipe {
+		if membro.Papel != model.PapelAdmin {
+			continue
 		}
-
-		if err := DB.Create(&lojista).Error; err != nil {
-			log.Fatalf("Falha ao criar o usuário lojista: %v", err)
+		if !membro.ConvitePendente() {
+			slog.Info("Administrador da loja já existe.")
+			return
+		}
+	}
+	for _, membro := range equipe {
+		if membro.Papel == model.PapelAdmin {
+			// O convite vale até ser aceito ou renovado pelo subcomando: reiniciar não o troca
+			slog.Warn("O administrador ainda não definiu a senha. Gere o link com o subcomando convite.", "email", membro.Email)
+			return
 		}
-		log.Println("Usuário lojista criado com sucesso.")
-	} else {
-		log.Println("Usuário lojista já existe.")
 	}
-}
\ No newline at end of file
+
+	email = strings.TrimSpace(email)
+	slog.Info("Nenhum administrador encontrado, criando um novo...")
+	admin, _, err := service.ConvidarMembro(DB, "Lojista Principal", email, model.PapelAdmin)
+	if err != nil {
+		slog.Error("Falha ao criar o administrador", "email", email, "erro", err)
+		os.Exit(1)
+	}
+	slog.Info("Administrador criado sem senha. Gere o link para defini-la com o subcomando convite.", "email", admin.Email)
+}
+
+// bloquearAcesso apaga a senha do membro e desconecta quem entrou com ela (sessões e
+// tokens da API). O link para uma nova senha sai do subcomando "convite".
+func bloquearAcesso(membro model.Usuario, store *service.SessaoStore) model.Usuario {
+	membro, _, err := service.RedefinirAcesso(DB, membro.ID)
+	if err != nil {
+		slog.Error("Falha ao bloquear o acesso", "email", membro.Email, "erro", err)
+		os.Exit(1)
+	}
+	if err := store.EncerrarDoUsuario(context.Background(), membro.ID); err != nil {
+		slog.Error("Falha ao encerrar as sessões do membro", "email", membro.Email, "erro", err)
+		os.Exit(1)
+	}
+	if err := service.RevogarTokensAPIDoUsuario(DB, membro.ID); err != nil {
+		slog.Error("Falha ao revogar os tokens de API do membro", "email", membro.Email, "erro", err)
+		os.Exit(1)
+	}
+	return membro
+}
@@ -0,0 +1,317 @@
+// /internal/handler/api_carrinho_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+// maxQuantidadeLinhaAPI limita a quantidade de uma linha do carrinho definida pela API.
+const maxQuantidadeLinhaAPI = 99
+
+// carrinhoAPI é o carrinho com os preços conferidos no catálogo de agora. Linhas que não
+// podem mais ser vendidas ficam de fora e são contadas em itens_indisponiveis.
+type carrinhoAPI struct {
+	Itens              []itemCarrinhoAPI `json:"itens"`
+	Subtotal           float64           `json:"subtotal"`
+	Cupom              *cupomAPI         `json:"cupom"`
+	CupomErro          string            `json:"cupom_erro,omitempty"` // Cupom guardado que deixou de valer
+	Total              float64           `json:"total"`
+	QuantidadeItens    int               `json:"quantidade_itens"` // Cupcakes, contando os dos kits
+	ItensIndisponiveis int               `json:"itens_indisponiveis"`
+}
+
+// itemCarrinhoAPI é uma linha do carrinho; chave identifica a linha nas outras rotas.
+type itemCarrinhoAPI struct {
+	Chave         string  `json:"chave"`
+	Tipo          string  `json:"tipo"`
+	CupcakeID     uint    `json:"cupcake_id,omitempty"`
+	KitID         uint    `json:"kit_id,omitempty"`
+	Nome          string  `json:"nome"`
+	Descricao     string  `json:"descricao"` // Opções escolhidas ou composição do kit
+	ImagemURL     string  `json:"imagem_url"`
+	PrecoUnitario float64 `json:"preco_unitario"`
+	Quantidade    int     `json:"quantidade"`
+	Subtotal      float64 `json:"subtotal"`
+}
+
+type cupomAPI struct {
+	Codigo   string  `json:"codigo"`
+	Desconto float64 `json:"desconto"`
+}
+
+// novoItemCarrinhoAPI converte uma linha montada por loadCartItems.
+func novoItemCarrinhoAPI(item CartItemView) itemCarrinhoAPI {
+	dto := itemCarrinhoAPI{
+		Chave: item.Chave, Tipo: "cupcake", Nome: item.Nome(), Descricao: item.DescricaoOpcoes(),
+		ImagemURL: item.ImagemThumb(), PrecoUnitario: item.PrecoUnitario, Quantidade: item.Quantity, Subtotal: item.Subtotal,
+	}
+	if item.Kit != nil {
+		dto.Tipo, dto.KitID = "kit", item.Kit.ID
+	} else {
+		dto.CupcakeID = item.Cupcake.ID
+	}
+	return dto
+}
+
+// montarCarrinhoAPI confere o carrinho da sessão e o cupom guardado nela.
+func montarCarrinhoAPI(c *gin.Context, session *sessions.Session) (carrinhoAPI, error) {
+	itens, subtotal, invalidos, err := loadCartItems(carrinhoDaSessao(session))
+	if err != nil {
+		return carrinhoAPI{}, err
+	}
+	dto := carrinhoAPI{
+		Itens: make([]itemCarrinhoAPI, len(itens)), Subtotal: subtotal, Total: subtotal,
+		QuantidadeItens: quantidadeItens(itens), ItensIndisponiveis: invalidos,
+	}
+	for i, item := range itens {
+		dto.Itens[i] = novoItemCarrinhoAPI(item)
+	}
+
+	user, _ := UsuarioAtual(c)
+	cupom, desconto, err := cupomDaSessao(session, user.ID, itens)
+	switch {
+	case err != nil && !service.ErroDeCupom(err):
+		return carrinhoAPI{}, err
+	case err != nil:
+		dto.CupomErro = service.MensagemCupom(err)
+	case cupom != nil:
+		dto.Cupom = &cupomAPI{Codigo: cupom.Codigo, Desconto: desconto}
+		dto.Total -= desconto
+	}
+	return dto, nil
+}
+
+// responderCarrinho responde com o carrinho atual da sessão.
+func responderCarrinho(c *gin.Context, session *sessions.Session, status int) {
+	dto, err := montarCarrinhoAPI(c, session)
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao carregar o carrinho", err)
+		return
+	}
+	c.JSON(status, dto)
+}
+
+// alterarCarrinho carrega a sessão de quem chama, aplica a mudança e grava. mudar devolve
+// false quando já respondeu (erro do cliente); nesse caso nada é gravado.
+func (h *APIHandler) alterarCarrinho(c *gin.Context, status int, mudar func(session *sessions.Session, cart service.Carrinho) bool) {
+	session, err := h.sessaoAPI(c)
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao ler a sessão", err)
+		return
+	}
+	cart := carrinhoDaSessao(session)
+	if !mudar(session, cart) {
+		return
+	}
+	session.Values[CartSessionKey] = cart
+	if err := h.salvarSessaoAPI(c, session); err != nil {
+		erroInternoAPI(c, "API: erro ao salvar o carrinho", err)
+		return
+	}
+	responderCarrinho(c, session, status)
+}
+
+// VerCarrinho mostra o carrinho de quem chama (visitante, sessão do site ou token).
+func (h *APIHandler) VerCarrinho(c *gin.Context) {
+	session, err := h.sessaoAPI(c)
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao ler a sessão", err)
+		return
+	}
+	responderCarrinho(c, session, http.StatusOK)
+}
+
+// LimparCarrinho remove todos os itens e o cupom.
+func (h *APIHandler) LimparCarrinho(c *gin.Context) {
+	h.alterarCarrinho(c, http.StatusOK, func(session *sessions.Session, cart service.Carrinho) bool {
+		clear(cart)
+		delete(session.Values, CupomSessionKey)
+		return true
+	})
+}
+
+// novoItemCarrinho é o corpo de POST /carrinho/itens: um cupcake (cupcake_id, com opcoes e
+// textos) ou um kit (kit_id, com caixa na "monte sua caixa").
+type novoItemCarrinho struct {
+	CupcakeID  uint            `json:"cupcake_id"`
+	Opcoes     []uint          `json:"opcoes"` // IDs das opções dos grupos "escolha"
+	Textos     map[uint]string `json:"textos"` // ID do grupo "texto" -> texto
+	KitID      uint            `json:"kit_id"`
+	Caixa      map[uint]int    `json:"caixa"` // ID do cupcake -> quantidade na caixa
+	Quantidade int             `json:"quantidade"`
+}
+
+// AdicionarItemCarrinho adiciona um cupcake com as opções escolhidas ou um kit. A mesma
+// combinação soma na linha que já existe.
+func (h *APIHandler) AdicionarItemCarrinho(c *gin.Context) {
+	var req novoItemCarrinho
+	if err := c.ShouldBindJSON(&req); err != nil || (req.CupcakeID == 0) == (req.KitID == 0) {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Informe cupcake_id (com opcoes e textos, se houver) ou kit_id (com caixa, se houver).")
+		return
+	}
+	if req.Quantidade == 0 {
+		req.Quantidade = 1
+	}
+	if req.Quantidade < 1 || req.Quantidade > maxQuantidadeLinhaAPI {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, fmt.Sprintf("A quantidade vai de 1 a %d.", maxQuantidadeLinhaAPI))
+		return
+	}
+
+	linha, tipo, ok := linhaCupcakeAPI(c, req)
+	if req.KitID != 0 {
+		linha, tipo, ok = linhaKitAPI(c, req)
+	}
+	if !ok {
+		return
+	}
+
+	h.alterarCarrinho(c, http.StatusCreated, func(_ *sessions.Session, cart service.Carrinho) bool {
+		chave := linha.Chave()
+		if cart[chave].Quantidade+req.Quantidade > maxQuantidadeLinhaAPI {
+			responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, fmt.Sprintf("A quantidade vai de 1 a %d.", maxQuantidadeLinhaAPI))
+			return false
+		}
+		cart.Adicionar(linha, req.Quantidade)
+		metricas.AdicoesCarrinho.WithLabelValues(tipo).Inc()
+		return true
+	})
+}
+
+// linhaCupcakeAPI confere o cupcake e as opções do pedido; em erro, já respondeu.
+func linhaCupcakeAPI(c *gin.Context, req novoItemCarrinho) (service.LinhaCarrinho, string, bool) {
+	var cupcake model.Cupcake
+	err := service.ComOpcoes(database.DB.WithContext(c.Request.Context())).Where("id = ? AND disponivel = ?", req.CupcakeID, true).First(&cupcake).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Cupcake não encontrado ou indisponível.")
+		return service.LinhaCarrinho{}, "", false
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar cupcake", err)
+		return service.LinhaCarrinho{}, "", false
+	}
+	linha := service.LinhaCarrinho{CupcakeID: cupcake.ID, Opcoes: req.Opcoes, Textos: req.Textos}
+	if _, _, err := service.PrecoLinha(cupcake, linha); err != nil {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, err.Error())
+		return service.LinhaCarrinho{}, "", false
+	}
+	return linha, "cupcake", true
+}
+
+// linhaKitAPI confere o kit e, na "monte sua caixa", as escolhas; em erro, já respondeu.
+func linhaKitAPI(c *gin.Context, req novoItemCarrinho) (service.LinhaCarrinho, string, bool) {
+	var kit model.Kit
+	err := service.ComComponentes(database.DB.WithContext(c.Request.Context())).Where("id = ? AND disponivel = ?", req.KitID, true).First(&kit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Kit não encontrado ou indisponível.")
+		return service.LinhaCarrinho{}, "", false
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar kit", err)
+		return service.LinhaCarrinho{}, "", false
+	}
+	linha := service.LinhaCarrinho{KitID: kit.ID, Caixa: req.Caixa}
+	if _, err := service.ComponentesKit(kit, linha); err != nil {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, err.Error())
+		return service.LinhaCarrinho{}, "", false
+	}
+	return linha, "kit", true
+}
+
+// quantidadeItemCarrinho é o corpo de PATCH /carrinho/itens/{chave}.
+type quantidadeItemCarrinho struct {
+	Quantidade *int `json:"quantidade" binding:"required"`
+}
+
+// AlterarItemCarrinho troca a quantidade de uma linha; zero remove a linha.
+func (h *APIHandler) AlterarItemCarrinho(c *gin.Context) {
+	var req quantidadeItemCarrinho
+	if err := c.ShouldBindJSON(&req); err != nil || *req.Quantidade < 0 || *req.Quantidade > maxQuantidadeLinhaAPI {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, fmt.Sprintf("Informe a quantidade, de 0 a %d.", maxQuantidadeLinhaAPI))
+		return
+	}
+	h.alterarCarrinho(c, http.StatusOK, func(_ *sessions.Session, cart service.Carrinho) bool {
+		if !cart.DefinirQuantidade(c.Param("chave"), *req.Quantidade) {
+			responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Item não encontrado no carrinho.")
+			return false
+		}
+		return true
+	})
+}
+
+// RemoverItemCarrinho remove uma linha do carrinho.
+func (h *APIHandler) RemoverItemCarrinho(c *gin.Context) {
+	h.alterarCarrinho(c, http.StatusOK, func(_ *sessions.Session, cart service.Carrinho) bool {
+		if !cart.DefinirQuantidade(c.Param("chave"), 0) {
+			responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Item não encontrado no carrinho.")
+			return false
+		}
+		return true
+	})
+}
+
+// cupomCarrinho é o corpo de PUT /carrinho/cupom.
+type cupomCarrinho struct {
+	Codigo string `json:"codigo" binding:"required"`
+}
+
+// AplicarCupomCarrinho valida o cupom para os itens atuais e o guarda no carrinho. Ele é
+// validado de novo a cada leitura do carrinho e na criação do pedido.
+func (h *APIHandler) AplicarCupomCarrinho(c *gin.Context) {
+	var req cupomCarrinho
+	codigo := ""
+	if err := c.ShouldBindJSON(&req); err == nil {
+		codigo = service.NormalizarCodigoCupom(req.Codigo)
+	}
+	if codigo == "" {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Informe o código do cupom.")
+		return
+	}
+
+	h.alterarCarrinho(c, http.StatusOK, func(session *sessions.Session, cart service.Carrinho) bool {
+		itens, _, _, err := loadCartItems(cart)
+		if err != nil {
+			erroInternoAPI(c, "API: erro ao carregar o carrinho", err)
+			return false
+		}
+		user, _ := UsuarioAtual(c)
+		if _, _, err := service.ValidarCupom(database.DB, codigo, user.ID, itensParaDesconto(itens), time.Now()); err != nil {
+			if !service.ErroDeCupom(err) {
+				erroInternoAPI(c, "API: erro ao validar cupom", err)
+				return false
+			}
+			responderErroAPI(c, http.StatusUnprocessableEntity, ErroAPICupomInvalido, service.MensagemCupom(err))
+			return false
+		}
+		session.Values[CupomSessionKey] = codigo
+		return true
+	})
+}
+
+// RemoverCupomCarrinho tira o cupom do carrinho.
+func (h *APIHandler) RemoverCupomCarrinho(c *gin.Context) {
+	h.alterarCarrinho(c, http.StatusOK, func(session *sessions.Session, _ service.Carrinho) bool {
+		delete(session.Values, CupomSessionKey)
+		return true
+	})
+}
+
+// limparCarrinhoAposPedido esvazia o carrinho depois de um pagamento aprovado.
+func (h *APIHandler) limparCarrinhoAposPedido(c *gin.Context, session *sessions.Session) {
+	session.Values[CartSessionKey] = service.Carrinho{}
+	delete(session.Values, CupomSessionKey)
+	if err := h.salvarSessaoAPI(c, session); err != nil {
+		slog.ErrorContext(c.Request.Context(), "API: erro ao esvaziar o carrinho após o pagamento", "erro", err)
+	}
+}
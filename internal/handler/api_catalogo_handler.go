@@ -0,0 +1,130 @@
+// /internal/handler/api_catalogo_handler.go
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// cupcakeAPI é um cupcake do catálogo na API.
+type cupcakeAPI struct {
+	ID           uint            `json:"id"`
+	Nome         string          `json:"nome"`
+	Descricao    string          `json:"descricao"`
+	Preco        float64         `json:"preco"`
+	ImagemURL    string          `json:"imagem_url"`
+	Imagens      []string        `json:"imagens"`
+	Avaliacao    avaliacaoAPI    `json:"avaliacao"`
+	GruposOpcoes []grupoOpcaoAPI `json:"grupos_opcoes"`
+}
+
+type avaliacaoAPI struct {
+	Media float64 `json:"media"`
+	Total int     `json:"total"`
+}
+
+// grupoOpcaoAPI é um grupo de personalização. Grupos "escolha" recebem IDs de opções em
+// opcoes; grupos "texto" recebem o texto em textos, pelo ID do grupo.
+type grupoOpcaoAPI struct {
+	ID             uint       `json:"id"`
+	Nome           string     `json:"nome"`
+	Tipo           string     `json:"tipo"`
+	Obrigatorio    bool       `json:"obrigatorio"`
+	MinEscolhas    int        `json:"min_escolhas"`
+	MaxEscolhas    int        `json:"max_escolhas"` // 0 = sem limite
+	MaxCaracteres  int        `json:"max_caracteres,omitempty"`
+	PrecoAdicional float64    `json:"preco_adicional,omitempty"`
+	Opcoes         []opcaoAPI `json:"opcoes"`
+}
+
+type opcaoAPI struct {
+	ID             uint    `json:"id"`
+	Nome           string  `json:"nome"`
+	PrecoAdicional float64 `json:"preco_adicional"`
+}
+
+// novoCupcakeAPI converte o cupcake (carregado com ComOpcoes e ComGaleria). Opções
+// indisponíveis ficam de fora, como na vitrine.
+func novoCupcakeAPI(cp model.Cupcake, avaliacao service.ResumoAvaliacao) cupcakeAPI {
+	dto := cupcakeAPI{
+		ID: cp.ID, Nome: cp.Nome, Descricao: cp.Descricao, Preco: cp.Preco, ImagemURL: cp.ImagemURL,
+		Imagens:      service.URLsGaleria(cp),
+		Avaliacao:    avaliacaoAPI{Media: avaliacao.Media, Total: avaliacao.Total},
+		GruposOpcoes: []grupoOpcaoAPI{},
+	}
+	if dto.Imagens == nil {
+		dto.Imagens = []string{}
+	}
+	for _, g := range cp.GruposOpcoes {
+		grupo := grupoOpcaoAPI{
+			ID: g.ID, Nome: g.Nome, Tipo: string(g.Tipo), Obrigatorio: g.Obrigatorio,
+			MinEscolhas: service.EscolhasMinimas(g), MaxEscolhas: g.MaxEscolhas,
+			MaxCaracteres: g.MaxCaracteres, PrecoAdicional: g.PrecoAdicional, Opcoes: []opcaoAPI{},
+		}
+		for _, o := range g.Opcoes {
+			if o.Disponivel {
+				grupo.Opcoes = append(grupo.Opcoes, opcaoAPI{ID: o.ID, Nome: o.Nome, PrecoAdicional: o.PrecoAdicional})
+			}
+		}
+		dto.GruposOpcoes = append(dto.GruposOpcoes, grupo)
+	}
+	return dto
+}
+
+// resumosCatalogo busca as notas dos cupcakes; sem elas o catálogo continua respondendo.
+func resumosCatalogo(c *gin.Context, ids []uint) map[uint]service.ResumoAvaliacao {
+	resumos, err := service.ResumosAvaliacoes(database.DB, ids)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "API: erro ao carregar avaliações do catálogo", "erro", err)
+	}
+	return resumos
+}
+
+// ListarCupcakes lista os cupcakes à venda, dos mais novos para os mais antigos.
+func (h *APIHandler) ListarCupcakes(c *gin.Context) {
+	var cupcakes []model.Cupcake
+	err := service.ComGaleria(service.ComOpcoes(database.DB.WithContext(c.Request.Context()))).
+		Where("disponivel = ?", true).Order("created_at desc").Find(&cupcakes).Error
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao listar cupcakes", err)
+		return
+	}
+
+	ids := make([]uint, len(cupcakes))
+	for i, cp := range cupcakes {
+		ids[i] = cp.ID
+	}
+	resumos := resumosCatalogo(c, ids)
+	lista := make([]cupcakeAPI, len(cupcakes))
+	for i, cp := range cupcakes {
+		lista[i] = novoCupcakeAPI(cp, resumos[cp.ID])
+	}
+	c.JSON(http.StatusOK, gin.H{"cupcakes": lista})
+}
+
+// BuscarCupcake devolve um cupcake à venda com as opções de personalização.
+func (h *APIHandler) BuscarCupcake(c *gin.Context) {
+	id, ok := idDoParametro(c, "id", "Cupcake")
+	if !ok {
+		return
+	}
+	var cupcake model.Cupcake
+	err := service.ComGaleria(service.ComOpcoes(database.DB.WithContext(c.Request.Context()))).
+		Where("id = ? AND disponivel = ?", id, true).First(&cupcake).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Cupcake não encontrado ou indisponível.")
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar cupcake", err)
+		return
+	}
+	c.JSON(http.StatusOK, novoCupcakeAPI(cupcake, resumosCatalogo(c, []uint{id})[id]))
+}
@@ -0,0 +1,311 @@
+// /internal/handler/api_handler.go
+package handler
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/mercadopago/sdk-go/pkg/config"
+	"gorm.io/gorm"
+)
+
+// APIHandler atende a API JSON em /api/v1 (catálogo, carrinho e pedidos). Quem chama se
+// identifica com a sessão do site (cookie) ou com um token de acesso pessoal no cabeçalho
+// Authorization: Bearer. O contrato está em openapi.json, servido em /api/v1/openapi.json.
+type APIHandler struct {
+	Store *service.SessaoStore
+	MPCfg *config.Config
+}
+
+//go:embed openapi.json
+var documentoOpenAPI []byte
+
+// Códigos de erro da API, no campo erro.codigo das respostas.
+const (
+	ErroAPINaoAutenticado      = "nao_autenticado"
+	ErroAPITokenInvalido       = "token_invalido"
+	ErroAPICSRF                = "csrf_invalido"
+	ErroAPIProibido            = "proibido"
+	ErroAPINaoEncontrado       = "nao_encontrado"
+	ErroAPIDadosInvalidos      = "dados_invalidos"
+	ErroAPICarrinhoVazio       = "carrinho_vazio"
+	ErroAPIItensIndisponiveis  = "itens_indisponiveis"
+	ErroAPICupomInvalido       = "cupom_invalido"
+	ErroAPIHorarioIndisponivel = "horario_indisponivel"
+	ErroAPITotalDivergente     = "total_divergente"
+	ErroAPILimiteTokens        = "limite_tokens"
+	ErroAPIPagamento           = "pagamento_indisponivel"
+	ErroAPIInterno             = "erro_interno"
+)
+
+// ErroAPI é o corpo de toda resposta de erro da API: {"erro": {"codigo", "mensagem"}}.
+type ErroAPI struct {
+	Codigo   string `json:"codigo"`
+	Mensagem string `json:"mensagem"`
+}
+
+// responderErroAPI encerra a requisição com o envelope de erro.
+func responderErroAPI(c *gin.Context, status int, codigo, mensagem string) {
+	c.AbortWithStatusJSON(status, gin.H{"erro": ErroAPI{Codigo: codigo, Mensagem: mensagem}})
+}
+
+// erroInternoAPI registra o erro e responde 500 sem expor o detalhe ao cliente.
+func erroInternoAPI(c *gin.Context, msg string, err error) {
+	slog.ErrorContext(c.Request.Context(), msg, "rota", c.FullPath(), "erro", err)
+	responderErroAPI(c, http.StatusInternalServerError, ErroAPIInterno, "Erro interno. Tente novamente.")
+}
+
+// chaveTokenAPIContexto guarda no gin.Context o token usado na requisição, se houver.
+const chaveTokenAPIContexto = "tokenAPI"
+
+// Registrar monta as rotas da API no grupo (normalmente /api/v1).
+func (h *APIHandler) Registrar(rg *gin.RouterGroup) {
+	rg.GET("/openapi.json", h.DocumentoOpenAPI)
+	rg.POST("/tokens", h.CriarToken)
+
+	api := rg.Group("", h.AutenticarAPI())
+	api.GET("/cupcakes", h.ListarCupcakes)
+	api.GET("/cupcakes/:id", h.BuscarCupcake)
+
+	api.GET("/carrinho", h.VerCarrinho)
+	api.DELETE("/carrinho", h.LimparCarrinho)
+	api.POST("/carrinho/itens", h.AdicionarItemCarrinho)
+	api.PATCH("/carrinho/itens/:chave", h.AlterarItemCarrinho)
+	api.DELETE("/carrinho/itens/:chave", h.RemoverItemCarrinho)
+	api.PUT("/carrinho/cupom", h.AplicarCupomCarrinho)
+	api.DELETE("/carrinho/cupom", h.RemoverCupomCarrinho)
+	api.GET("/carrinho/horarios", h.HorariosCarrinho)
+
+	cliente := api.Group("", exigirClienteAPI())
+	cliente.POST("/pedidos", h.CriarPedido)
+	cliente.GET("/pedidos", h.ListarPedidos)
+	cliente.GET("/pedidos/:id", h.BuscarPedido)
+	cliente.GET("/pedidos/:id/status", h.StatusPedido)
+	cliente.DELETE("/tokens/atual", h.RevogarTokenAtual)
+}
+
+// AutenticarAPI identifica quem chama a API. Com Authorization: Bearer, o token define o
+// usuário (no lugar do cookie) e tem que ser válido. Sem ele vale a sessão do site, já
+// resolvida por Identificar: a resposta leva o token CSRF em X-CSRF-Token, e requisições
+// que alteram dados com o cookie de sessão precisam devolvê-lo no mesmo cabeçalho.
+func (h *APIHandler) AutenticarAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if autorizacao := c.GetHeader("Authorization"); autorizacao != "" {
+			h.autenticarToken(c, autorizacao)
+			return
+		}
+
+		c.Header("X-CSRF-Token", tokenCSRF(c, h.Store.Options))
+		if metodoAlteraDados(c.Request.Method) && temCookieSessao(c) && !CSRFValido(c) {
+			responderErroAPI(c, http.StatusForbidden, ErroAPICSRF, "Token CSRF ausente ou inválido. Envie o valor de X-CSRF-Token no mesmo cabeçalho.")
+			return
+		}
+		c.Next()
+	}
+}
+
+// autenticarToken valida o token Bearer e coloca o dono dele como usuário da requisição.
+func (h *APIHandler) autenticarToken(c *gin.Context, autorizacao string) {
+	token, ok := strings.CutPrefix(autorizacao, "Bearer ")
+	if !ok {
+		responderErroAPI(c, http.StatusUnauthorized, ErroAPITokenInvalido, "Use o cabeçalho Authorization: Bearer <token>.")
+		return
+	}
+	registro, err := service.BuscarTokenAPI(database.DB, strings.TrimSpace(token), time.Now())
+	if errors.Is(err, service.ErrTokenAPIInvalido) {
+		responderErroAPI(c, http.StatusUnauthorized, ErroAPITokenInvalido, "Token inválido ou expirado.")
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar o token", err)
+		return
+	}
+
+	user, err := service.Usuarios.Buscar(database.DB, registro.UsuarioID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !user.Ativo()) {
+		slog.WarnContext(c.Request.Context(), "API: token de conta apagada ou desativada", "usuario_id", registro.UsuarioID, "token_id", registro.ID)
+		responderErroAPI(c, http.StatusUnauthorized, ErroAPITokenInvalido, "Token inválido ou expirado.")
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar o dono do token", err)
+		return
+	}
+
+	c.Set(chaveUsuarioContexto, user)
+	c.Set(chaveTokenAPIContexto, registro)
+	c.Next()
+}
+
+// metodoAlteraDados indica os métodos que exigem CSRF nas requisições com cookie.
+func metodoAlteraDados(metodo string) bool {
+	switch metodo {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// temCookieSessao indica se a requisição traz o cookie de sessão do site.
+func temCookieSessao(c *gin.Context) bool {
+	_, err := c.Request.Cookie("meu-cupcake-session")
+	return err == nil
+}
+
+// tokenAPIAtual devolve o token usado na requisição, se ela veio com um.
+func tokenAPIAtual(c *gin.Context) (model.TokenAPI, bool) {
+	valor, existe := c.Get(chaveTokenAPIContexto)
+	if !existe {
+		return model.TokenAPI{}, false
+	}
+	token, ok := valor.(model.TokenAPI)
+	return token, ok
+}
+
+// exigirClienteAPI barra visitantes (401) e contas da equipe (403) nas rotas de pedidos.
+func exigirClienteAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := UsuarioAtual(c)
+		if !ok {
+			responderErroAPI(c, http.StatusUnauthorized, ErroAPINaoAutenticado, "Faça login ou envie um token de acesso.")
+			return
+		}
+		if user.Tipo != model.RoleCliente {
+			responderErroAPI(c, http.StatusForbidden, ErroAPIProibido, "Disponível só para contas de cliente.")
+			return
+		}
+		c.Next()
+	}
+}
+
+// sessaoAPI devolve a sessão que guarda o carrinho de quem chama: a do cookie ou, com
+// token, uma sessão no servidor só daquele token.
+func (h *APIHandler) sessaoAPI(c *gin.Context) (*sessions.Session, error) {
+	token, ok := tokenAPIAtual(c)
+	if !ok {
+		return h.Store.Get(c.Request, "meu-cupcake-session")
+	}
+	session, err := h.Store.Carregar(c.Request.Context(), "meu-cupcake-session", idSessaoToken(token.ID))
+	if err != nil {
+		return session, err
+	}
+	// O dono fica gravado para a sessão cair junto com as outras da conta
+	session.Values[service.ChaveUsuarioSessao] = token.UsuarioID
+	return session, nil
+}
+
+// idSessaoToken é o ID da sessão do carrinho de um token. Não vem de cookie: um cookie só
+// chega a esse ID se for assinado com o segredo das sessões.
+func idSessaoToken(tokenID uint) string {
+	return fmt.Sprintf("api-token:%d", tokenID)
+}
+
+// salvarSessaoAPI grava a sessão de sessaoAPI (o cookie só é renovado sem token).
+func (h *APIHandler) salvarSessaoAPI(c *gin.Context, session *sessions.Session) error {
+	if _, ok := tokenAPIAtual(c); ok {
+		return h.Store.Gravar(c.Request.Context(), session)
+	}
+	return session.Save(c.Request, c.Writer)
+}
+
+// idDoParametro lê um ID numérico da rota; inválido responde 404.
+func idDoParametro(c *gin.Context, nome, recurso string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(nome), 10, 32)
+	if err != nil || id == 0 {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, recurso+" não encontrado.")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// DocumentoOpenAPI serve o contrato da API (OpenAPI 3).
+func (h *APIHandler) DocumentoOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", documentoOpenAPI)
+}
+
+// --- Tokens de acesso ---
+
+// pedidoTokenAPI é o corpo de POST /tokens.
+type pedidoTokenAPI struct {
+	Email string `json:"email" binding:"required"`
+	Senha string `json:"senha" binding:"required"`
+	Nome  string `json:"nome"` // Identifica o app ou script que vai usar o token
+}
+
+// tokenCriadoAPI é a resposta de POST /tokens; o token não aparece de novo.
+type tokenCriadoAPI struct {
+	ID       uint      `json:"id"`
+	Token    string    `json:"token"`
+	Nome     string    `json:"nome"`
+	Prefixo  string    `json:"prefixo"`
+	ExpiraEm time.Time `json:"expira_em"`
+}
+
+// CriarToken troca e-mail e senha de um cliente por um token de acesso pessoal.
+func (h *APIHandler) CriarToken(c *gin.Context) {
+	var req pedidoTokenAPI
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Informe email e senha.")
+		return
+	}
+
+	db := database.DB.WithContext(c.Request.Context())
+	usuario, err := service.AutenticarSenha(db, req.Email, req.Senha)
+	switch {
+	case errors.Is(err, service.ErrLoginInvalido):
+		responderErroAPI(c, http.StatusUnauthorized, ErroAPINaoAutenticado, "E-mail ou senha inválidos.")
+		return
+	case errors.Is(err, service.ErrContaDesativada):
+		responderErroAPI(c, http.StatusForbidden, ErroAPIProibido, "Conta desativada. Fale com a loja para reativá-la.")
+		return
+	case err != nil:
+		erroInternoAPI(c, "API: erro ao conferir a senha", err)
+		return
+	}
+	if usuario.Tipo != model.RoleCliente {
+		responderErroAPI(c, http.StatusForbidden, ErroAPIProibido, "Tokens de acesso são só para contas de cliente.")
+		return
+	}
+
+	token, registro, err := service.CriarTokenAPI(db, usuario.ID, req.Nome, time.Now())
+	if errors.Is(err, service.ErrLimiteTokensAPI) {
+		responderErroAPI(c, http.StatusConflict, ErroAPILimiteTokens, fmt.Sprintf("A conta já tem %d tokens válidos. Revogue um antes de criar outro.", service.MaxTokensAPI))
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao criar o token", err)
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Token de API criado", "usuario_id", usuario.ID, "token_id", registro.ID, "prefixo", registro.Prefixo)
+	c.JSON(http.StatusCreated, tokenCriadoAPI{ID: registro.ID, Token: token, Nome: registro.Nome, Prefixo: registro.Prefixo, ExpiraEm: registro.ExpiraEm})
+}
+
+// RevogarTokenAtual apaga o token usado na requisição (o "logout" de um app).
+func (h *APIHandler) RevogarTokenAtual(c *gin.Context) {
+	token, ok := tokenAPIAtual(c)
+	if !ok {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "A requisição não usa um token de acesso.")
+		return
+	}
+	if err := service.RevogarTokenAPI(database.DB.WithContext(c.Request.Context()), token.UsuarioID, token.ID); err != nil {
+		erroInternoAPI(c, "API: erro ao revogar o token", err)
+		return
+	}
+	// O carrinho guardado para o token não serve a mais ninguém
+	if err := h.Store.Descartar(c.Request.Context(), idSessaoToken(token.ID)); err != nil {
+		slog.ErrorContext(c.Request.Context(), "API: erro ao apagar a sessão do token", "token_id", token.ID, "erro", err)
+	}
+	slog.InfoContext(c.Request.Context(), "Token de API revogado", "usuario_id", token.UsuarioID, "token_id", token.ID)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+// /internal/handler/api_handler_test.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+func TestAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	api := &APIHandler{Store: service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "segredo")}
+
+	novoRouter := func(antes ...gin.HandlerFunc) *gin.Engine {
+		router := gin.New()
+		router.Use(antes...)
+		api.Registrar(router.Group("/api/v1"))
+		return router
+	}
+	chamar := func(router *gin.Engine, req *http.Request) (*httptest.ResponseRecorder, ErroAPI) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var corpo struct {
+			Erro ErroAPI `json:"erro"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &corpo)
+		return w, corpo.Erro
+	}
+
+	t.Run("Token Bearer malformado é recusado com o envelope de erro", func(t *testing.T) {
+		router := novoRouter()
+		for _, autorizacao := range []string{"Basic dXNlcjpzZW5oYQ==", "Bearer ", "Bearer lixo", "Bearer mc_curto"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/carrinho", nil)
+			req.Header.Set("Authorization", autorizacao)
+			w, erro := chamar(router, req)
+			if w.Code != http.StatusUnauthorized || erro.Codigo != ErroAPITokenInvalido || erro.Mensagem == "" {
+				t.Errorf("%q: status = %d, erro = %+v; esperado 401 %s", autorizacao, w.Code, erro, ErroAPITokenInvalido)
+			}
+		}
+	})
+
+	t.Run("Cookie de sessão sem token CSRF não altera dados", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/carrinho", nil)
+		req.AddCookie(&http.Cookie{Name: "meu-cupcake-session", Value: "qualquer"})
+		w, erro := chamar(novoRouter(), req)
+		if w.Code != http.StatusForbidden || erro.Codigo != ErroAPICSRF {
+			t.Errorf("Status = %d, erro = %+v; esperado 403 %s", w.Code, erro, ErroAPICSRF)
+		}
+	})
+
+	t.Run("Visitante recebe o token CSRF e 401 nas rotas de pedidos", func(t *testing.T) {
+		w, erro := chamar(novoRouter(), httptest.NewRequest(http.MethodGet, "/api/v1/pedidos", nil))
+		if w.Code != http.StatusUnauthorized || erro.Codigo != ErroAPINaoAutenticado {
+			t.Errorf("Status = %d, erro = %+v; esperado 401 %s", w.Code, erro, ErroAPINaoAutenticado)
+		}
+		if w.Header().Get("X-CSRF-Token") == "" {
+			t.Error("A resposta deveria trazer o cabeçalho X-CSRF-Token")
+		}
+	})
+
+	t.Run("Conta da equipe não usa as rotas de pedidos", func(t *testing.T) {
+		lojista := func(c *gin.Context) { c.Set(chaveUsuarioContexto, model.Usuario{ID: 1, Tipo: model.RoleLojista}) }
+		w, erro := chamar(novoRouter(lojista), httptest.NewRequest(http.MethodGet, "/api/v1/pedidos", nil))
+		if w.Code != http.StatusForbidden || erro.Codigo != ErroAPIProibido {
+			t.Errorf("Status = %d, erro = %+v; esperado 403 %s", w.Code, erro, ErroAPIProibido)
+		}
+	})
+
+	t.Run("Item do carrinho é um cupcake ou um kit, não os dois", func(t *testing.T) {
+		for _, corpo := range []string{`{}`, `{"cupcake_id": 1, "kit_id": 2}`, `{"quantidade": 2}`} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/carrinho/itens", strings.NewReader(corpo))
+			req.Header.Set("Content-Type", "application/json")
+			w, erro := chamar(novoRouter(), req)
+			if w.Code != http.StatusBadRequest || erro.Codigo != ErroAPIDadosInvalidos {
+				t.Errorf("%s: status = %d, erro = %+v; esperado 400 %s", corpo, w.Code, erro, ErroAPIDadosInvalidos)
+			}
+		}
+	})
+
+	t.Run("O documento OpenAPI descreve exatamente as rotas registradas", func(t *testing.T) {
+		router := novoRouter()
+		w, _ := chamar(router, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+		var doc struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("openapi.json inválido: %v", err)
+		}
+
+		var documentadas, registradas []string
+		for caminho, operacoes := range doc.Paths {
+			for metodo := range operacoes {
+				documentadas = append(documentadas, strings.ToUpper(metodo)+" "+caminho)
+			}
+		}
+		parametro := regexp.MustCompile(`:(\w+)`)
+		for _, rota := range router.Routes() {
+			caminho := parametro.ReplaceAllString(strings.TrimPrefix(rota.Path, "/api/v1"), "{$1}")
+			registradas = append(registradas, rota.Method+" "+caminho)
+		}
+		slices.Sort(documentadas)
+		slices.Sort(registradas)
+		if !slices.Equal(documentadas, registradas) {
+			t.Errorf("Rotas documentadas:\n%v\nRotas registradas:\n%v", documentadas, registradas)
+		}
+	})
+}
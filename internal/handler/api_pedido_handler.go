@@ -0,0 +1,365 @@
+// /internal/handler/api_pedido_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PedidosPorPaginaAPI é o tamanho da página de GET /pedidos.
+const PedidosPorPaginaAPI = 20
+
+// pedidoResumoAPI é um pedido na listagem.
+type pedidoResumoAPI struct {
+	ID              uint      `json:"id"`
+	Status          string    `json:"status"`
+	StatusTitulo    string    `json:"status_titulo"`
+	Total           float64   `json:"total"`
+	MetodoPagamento string    `json:"metodo_pagamento"`
+	TipoEntrega     string    `json:"tipo_entrega"`
+	DataEntrega     string    `json:"data_entrega,omitempty"` // AAAA-MM-DD
+	CriadoEm        time.Time `json:"criado_em"`
+}
+
+// pedidoAPI é o detalhe do pedido, com itens e linha do tempo.
+type pedidoAPI struct {
+	pedidoResumoAPI
+	Subtotal     float64           `json:"subtotal"`
+	Desconto     float64           `json:"desconto"`
+	CodigoCupom  string            `json:"codigo_cupom,omitempty"`
+	Pagamento    string            `json:"pagamento"`
+	Entrega      string            `json:"entrega"`
+	Endereco     string            `json:"endereco,omitempty"`
+	Itens        []itemPedidoAPI   `json:"itens"`
+	LinhaDoTempo []eventoPedidoAPI `json:"linha_do_tempo"`
+}
+
+// itemPedidoAPI é uma linha do pedido como no recibo (kits voltam a ser uma linha só).
+type itemPedidoAPI struct {
+	Descricao     string  `json:"descricao"`
+	Detalhe       string  `json:"detalhe,omitempty"`
+	Quantidade    int     `json:"quantidade"`
+	PrecoUnitario float64 `json:"preco_unitario"`
+	Subtotal      float64 `json:"subtotal"`
+}
+
+type eventoPedidoAPI struct {
+	Status string    `json:"status"`
+	Titulo string    `json:"titulo"`
+	Quando time.Time `json:"quando"`
+}
+
+// statusPedidoAPI é a resposta de GET /pedidos/{id}/status, para acompanhar o pedido.
+type statusPedidoAPI struct {
+	ID           uint              `json:"id"`
+	Status       string            `json:"status"`
+	StatusTitulo string            `json:"status_titulo"`
+	AtualizadoEm time.Time         `json:"atualizado_em"`
+	LinhaDoTempo []eventoPedidoAPI `json:"linha_do_tempo"`
+}
+
+func novoPedidoResumoAPI(p model.Order) pedidoResumoAPI {
+	dto := pedidoResumoAPI{
+		ID: p.ID, Status: string(p.Status), StatusTitulo: service.TituloStatus(p.Status, p.TipoEntrega),
+		Total: p.Total, MetodoPagamento: p.MetodoPagamento, TipoEntrega: string(p.TipoEntrega), CriadoEm: p.CreatedAt,
+	}
+	if p.DataEntrega != nil {
+		dto.DataEntrega = p.DataEntrega.Format(service.FormatoData)
+	}
+	return dto
+}
+
+func linhaDoTempoAPI(p model.Order) []eventoPedidoAPI {
+	eventos := service.LinhaDoTempo(p)
+	dto := make([]eventoPedidoAPI, len(eventos))
+	for i, e := range eventos {
+		dto[i] = eventoPedidoAPI{Status: string(e.Status), Titulo: e.Titulo, Quando: e.Quando}
+	}
+	return dto
+}
+
+// novoPedidoAPI converte o pedido carregado com service.ComDetalhes.
+func novoPedidoAPI(p model.Order) pedidoAPI {
+	dto := pedidoAPI{
+		pedidoResumoAPI: novoPedidoResumoAPI(p),
+		Subtotal:        p.Total + p.Desconto, Desconto: p.Desconto, CodigoCupom: p.CodigoCupom,
+		Pagamento: service.DescreverPagamento(p), Entrega: service.DescreverEntrega(p), Endereco: service.EnderecoEntrega(p),
+		Itens: []itemPedidoAPI{}, LinhaDoTempo: linhaDoTempoAPI(p),
+	}
+	for _, l := range service.LinhasRecibo(p.Items) {
+		dto.Itens = append(dto.Itens, itemPedidoAPI{Descricao: l.Descricao, Detalhe: l.Detalhe, Quantidade: l.Quantidade, PrecoUnitario: l.PrecoUnitario, Subtotal: l.Subtotal})
+	}
+	return dto
+}
+
+// ListarPedidos lista os pedidos do cliente, dos mais novos para os mais antigos, em
+// páginas (?pagina=1).
+func (h *APIHandler) ListarPedidos(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	pagina, err := strconv.Atoi(c.DefaultQuery("pagina", "1"))
+	if err != nil || pagina < 1 {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "A página começa em 1.")
+		return
+	}
+
+	db := database.DB.WithContext(c.Request.Context())
+	var total int64
+	if err := db.Model(&model.Order{}).Where("usuario_id = ?", user.ID).Count(&total).Error; err != nil {
+		erroInternoAPI(c, "API: erro ao contar pedidos", err)
+		return
+	}
+	var pedidos []model.Order
+	err = db.Where("usuario_id = ?", user.ID).Order("created_at desc, id desc").
+		Offset((pagina - 1) * PedidosPorPaginaAPI).Limit(PedidosPorPaginaAPI).Find(&pedidos).Error
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao listar pedidos", err)
+		return
+	}
+
+	lista := make([]pedidoResumoAPI, len(pedidos))
+	for i, p := range pedidos {
+		lista[i] = novoPedidoResumoAPI(p)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"pedidos":       lista,
+		"pagina":        pagina,
+		"total_paginas": service.TotalPaginas(total, PedidosPorPaginaAPI),
+		"total":         total,
+	})
+}
+
+// BuscarPedido devolve um pedido do cliente com itens, pagamento, entrega e linha do tempo.
+func (h *APIHandler) BuscarPedido(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	pedido, err := carregarPedido(c, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Pedido não encontrado.")
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar pedido", err)
+		return
+	}
+	c.JSON(http.StatusOK, novoPedidoAPI(pedido))
+}
+
+// StatusPedido devolve só o status e a linha do tempo, para consultas frequentes.
+func (h *APIHandler) StatusPedido(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	id, ok := idDoParametro(c, "id", "Pedido")
+	if !ok {
+		return
+	}
+	var pedido model.Order
+	err := database.DB.WithContext(c.Request.Context()).
+		Preload("Historico", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		Where("id = ? AND usuario_id = ?", id, user.ID).First(&pedido).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responderErroAPI(c, http.StatusNotFound, ErroAPINaoEncontrado, "Pedido não encontrado.")
+		return
+	}
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao buscar status do pedido", err)
+		return
+	}
+	c.JSON(http.StatusOK, statusPedidoAPI{
+		ID: pedido.ID, Status: string(pedido.Status), StatusTitulo: service.TituloStatus(pedido.Status, pedido.TipoEntrega),
+		AtualizadoEm: pedido.UpdatedAt, LinhaDoTempo: linhaDoTempoAPI(pedido),
+	})
+}
+
+// HorariosCarrinho lista os horários de entrega ou retirada (?tipo=entrega|retirada) com
+// capacidade para o carrinho atual. O campo valor vai em agendamento.opcao do pedido.
+func (h *APIHandler) HorariosCarrinho(c *gin.Context) {
+	tipo := model.TipoEntrega(c.DefaultQuery("tipo", string(model.TipoEntregaDelivery)))
+	if tipo != model.TipoEntregaDelivery && tipo != model.TipoEntregaRetirada {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "O tipo é entrega ou retirada.")
+		return
+	}
+	session, err := h.sessaoAPI(c)
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao ler a sessão", err)
+		return
+	}
+	itens, _, _, err := loadCartItems(carrinhoDaSessao(session))
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao carregar o carrinho", err)
+		return
+	}
+	dias, err := service.ListarOpcoesEntrega(database.DB.WithContext(c.Request.Context()), tipo, time.Now(), service.DiasAgendamento, quantidadeItens(itens))
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao listar horários", err)
+		return
+	}
+
+	type horarioAPI struct {
+		Valor   string `json:"valor"`
+		Data    string `json:"data"`
+		Horario string `json:"horario"`
+	}
+	horarios := []horarioAPI{}
+	for _, dia := range dias {
+		for _, o := range dia.Opcoes {
+			horarios = append(horarios, horarioAPI{Valor: o.Valor(), Data: o.Data.Format(service.FormatoData), Horario: o.Horario()})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"tipo": tipo, "horarios": horarios})
+}
+
+// novoPedidoCheckout é o corpo de POST /pedidos. total é o valor que o cliente mostrou a
+// quem compra; se o carrinho mudou desde então, o pedido não é criado.
+type novoPedidoCheckout struct {
+	MetodoPagamento string              `json:"metodo_pagamento" binding:"required,oneof=pix cartao"`
+	Total           float64             `json:"total" binding:"required"`
+	Entrega         EnderecoEntregaData `json:"entrega"`
+	Agendamento     AgendamentoData     `json:"agendamento"`
+	Cartao          *cartaoCheckout     `json:"cartao"`
+}
+
+// cartaoCheckout leva o token do cartão gerado pelo SDK do Mercado Pago no cliente.
+type cartaoCheckout struct {
+	Token           string `json:"token"`
+	PaymentMethodID string `json:"payment_method_id"`
+	IssuerID        string `json:"issuer_id"`
+	Parcelas        int    `json:"parcelas"`
+	Documento       struct {
+		Tipo   string `json:"tipo"`
+		Numero string `json:"numero"`
+	} `json:"documento"`
+}
+
+// pagamentoAPI é o resultado da cobrança devolvido com o pedido criado.
+type pagamentoAPI struct {
+	Status      string  `json:"status"` // approved, pending ou rejected
+	Mensagem    string  `json:"mensagem"`
+	PagamentoID *int64  `json:"pagamento_id"`
+	Pix         *pixAPI `json:"pix,omitempty"`
+}
+
+type pixAPI struct {
+	QRCode       string `json:"qr_code"`
+	QRCodeBase64 string `json:"qr_code_base64"`
+}
+
+// CriarPedido fecha o carrinho de quem chama: confere itens, cupom e total, grava o
+// pedido com o horário reservado e cobra no Mercado Pago (cartão ou PIX). Responde 201
+// com o pedido e o resultado do pagamento; pagamento recusado deixa o pedido como falhou.
+func (h *APIHandler) CriarPedido(c *gin.Context) {
+	var req novoPedidoCheckout
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Informe metodo_pagamento (pix ou cartao), total, agendamento e, para entrega, o endereço.")
+		return
+	}
+	cartao := req.MetodoPagamento == "cartao"
+	if cartao && (req.Cartao == nil || req.Cartao.Token == "" || req.Cartao.PaymentMethodID == "") {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Para cartão, informe cartao.token e cartao.payment_method_id.")
+		return
+	}
+	if h.MPCfg == nil {
+		responderErroAPI(c, http.StatusServiceUnavailable, ErroAPIPagamento, "Pagamentos indisponíveis no momento.")
+		return
+	}
+	agendamento, err := validarAgendamento(req.Agendamento, req.Entrega)
+	if err != nil {
+		responderErroAPI(c, http.StatusBadRequest, ErroAPIDadosInvalidos, "Dados de entrega inválidos: "+err.Error()+".")
+		return
+	}
+
+	user, _ := UsuarioAtual(c)
+	session, err := h.sessaoAPI(c)
+	if err != nil {
+		erroInternoAPI(c, "API: erro ao ler a sessão", err)
+		return
+	}
+	codigoCupom, _ := session.Values[CupomSessionKey].(string)
+	co, err := prepararCheckout(carrinhoDaSessao(session), codigoCupom, user.ID)
+	switch {
+	case errors.Is(err, errCarrinhoVazio):
+		responderErroAPI(c, http.StatusConflict, ErroAPICarrinhoVazio, "O carrinho está vazio.")
+		return
+	case errors.Is(err, errItensIndisponiveis):
+		responderErroAPI(c, http.StatusConflict, ErroAPIItensIndisponiveis, "Um ou mais itens do carrinho não estão mais disponíveis.")
+		return
+	case service.ErroDeCupom(err):
+		responderErroAPI(c, http.StatusConflict, ErroAPICupomInvalido, service.MensagemCupom(err))
+		return
+	case err != nil:
+		erroInternoAPI(c, "API: erro ao validar o carrinho", err)
+		return
+	}
+	if !totalConfere(co.Total, req.Total) {
+		responderErroAPI(c, http.StatusConflict, ErroAPITotalDivergente, fmt.Sprintf("O total do carrinho agora é R$ %.2f. Confira o carrinho e envie o novo total.", co.Total))
+		return
+	}
+
+	metodo, parcelas := "pix", 1
+	if cartao {
+		metodo, parcelas = req.Cartao.PaymentMethodID, max(req.Cartao.Parcelas, 1)
+	}
+	pedido, err := gravarPedido(c.Request.Context(), user.ID, co, agendamento, metodo, parcelas)
+	switch {
+	case errors.Is(err, service.ErrJanelaLotada), errors.Is(err, service.ErrJanelaIndisponivel):
+		responderErroAPI(c, http.StatusConflict, ErroAPIHorarioIndisponivel, "O horário escolhido não está mais disponível. Escolha outro horário.")
+		return
+	case service.ErroDeCupom(err):
+		responderErroAPI(c, http.StatusConflict, ErroAPICupomInvalido, service.MensagemCupom(err))
+		return
+	case err != nil:
+		erroInternoAPI(c, "API: erro ao gravar o pedido", err)
+		return
+	}
+
+	descricao := fmt.Sprintf("Pedido #%d Meu Cupcake", pedido.ID)
+	var pagamento pagamentoAPI
+	if cartao {
+		metricas.PedidosCriados.WithLabelValues("cartao").Inc()
+		dados := PaymentRequestData{
+			Token: req.Cartao.Token, IssuerID: req.Cartao.IssuerID, PaymentMethodID: req.Cartao.PaymentMethodID,
+			Installments: parcelas, Description: descricao,
+		}
+		dados.Payer.Email = user.Email
+		dados.Payer.Identification.Type = req.Cartao.Documento.Tipo
+		dados.Payer.Identification.Number = req.Cartao.Documento.Numero
+		resultado := cobrarCartao(c.Request.Context(), h.MPCfg, &pedido, dados)
+		pagamento = pagamentoAPI{Status: resultado.Status, Mensagem: resultado.Mensagem, PagamentoID: resultado.PagamentoID}
+		if resultado.Status == "approved" {
+			h.limparCarrinhoAposPedido(c, session)
+		}
+	} else {
+		metricas.PedidosCriados.WithLabelValues("pix").Inc()
+		resource, err := gerarPix(c.Request.Context(), h.MPCfg, &pedido, user, user.Email, descricao)
+		if err != nil {
+			responderErroAPI(c, http.StatusBadGateway, ErroAPIPagamento, fmt.Sprintf("Não foi possível gerar o PIX do pedido #%d. Tente novamente.", pedido.ID))
+			return
+		}
+		pagamento = pagamentoAPI{
+			Status: "pending", Mensagem: "PIX gerado, aguardando pagamento.", PagamentoID: pedido.PagamentoMPID,
+			Pix: &pixAPI{QRCode: resource.PointOfInteraction.TransactionData.QRCode, QRCodeBase64: resource.PointOfInteraction.TransactionData.QRCodeBase64},
+		}
+	}
+
+	// Relê o pedido para devolver o status que o pagamento deixou
+	if err := service.ComDetalhes(database.DB.WithContext(c.Request.Context())).First(&pedido, pedido.ID).Error; err != nil {
+		erroInternoAPI(c, "API: erro ao reler o pedido criado", err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("%s/pedidos/%d", prefixoRotaAPI(c), pedido.ID))
+	c.JSON(http.StatusCreated, gin.H{"pedido": novoPedidoAPI(pedido), "pagamento": pagamento})
+}
+
+// prefixoRotaAPI devolve o prefixo em que a API foi montada (ex.: /api/v1), a partir da
+// rota de POST /pedidos, para montar o link do pedido criado.
+func prefixoRotaAPI(c *gin.Context) string {
+	return strings.TrimSuffix(c.FullPath(), "/pedidos")
+}
@@ -1,37 +1,29 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	Store *sessions.CookieStore
+	Store *service.SessaoStore
 }
 
 // ShowCadastroPage renderiza a página de cadastro e exibe flash messages.
 func (h *AuthHandler) ShowCadastroPage(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	flashesSuccess := session.Flashes("success")
-	flashesError := session.Flashes("error")
-	err := session.Save(c.Request, c.Writer)
-	if err != nil {
-		fmt.Printf("AVISO: Erro ao salvar sessão em ShowCadastroPage: %v\n", err)
-	}
-
-	c.HTML(http.StatusOK, "cadastro.html", gin.H{
-		"IsLoggedIn":     false,
-		"FlashesSuccess": flashesSuccess,
-		"FlashesError":   flashesError,
-	})
+	c.HTML(http.StatusOK, "cadastro.html", novaPagina(c, session, "cadastro", nil))
 }
 
 // ProcessCadastroForm processa os dados submetidos pelo formulário de cadastro.
@@ -43,7 +35,7 @@ func (h *AuthHandler) ProcessCadastroForm(c *gin.Context) {
 	confirmarSenha := c.PostForm("confirmar_senha")
 
 	if senha != confirmarSenha {
-		session.AddFlash("As senhas não conferem!", "error")
+		adicionarFlash(session, FlashErro, "As senhas não conferem!")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/cadastro")
 		return
@@ -51,7 +43,7 @@ func (h *AuthHandler) ProcessCadastroForm(c *gin.Context) {
 
 	senhaHash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
 	if err != nil {
-		session.AddFlash("Erro ao processar a senha. Tente novamente.", "error")
+		adicionarFlash(session, FlashErro, "Erro ao processar a senha. Tente novamente.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/cadastro")
 		return
@@ -67,16 +59,16 @@ func (h *AuthHandler) ProcessCadastroForm(c *gin.Context) {
 	result := database.DB.Create(&novoUsuario)
 	if result.Error != nil {
 		if strings.Contains(result.Error.Error(), "unique constraint") || strings.Contains(result.Error.Error(), "duplicate key") {
-			session.AddFlash("Este e-mail já está cadastrado.", "error")
+			adicionarFlash(session, FlashErro, "Este e-mail já está cadastrado.")
 		} else {
-			session.AddFlash("Erro ao criar usuário. Tente novamente.", "error")
+			adicionarFlash(session, FlashErro, "Erro ao criar usuário. Tente novamente.")
 		}
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/cadastro")
 		return
 	}
 
-	session.AddFlash("Cadastro realizado com sucesso! Faça o login.", "success")
+	adicionarFlash(session, FlashSucesso, "Cadastro realizado com sucesso! Faça o login.")
 	session.Save(c.Request, c.Writer)
 	c.Redirect(http.StatusFound, "/login")
 }
@@ -84,18 +76,7 @@ func (h *AuthHandler) ProcessCadastroForm(c *gin.Context) {
 // ShowLoginPage renderiza a página de login e exibe flash messages.
 func (h *AuthHandler) ShowLoginPage(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	flashesSuccess := session.Flashes("success")
-	flashesError := session.Flashes("error")
-	err := session.Save(c.Request, c.Writer)
-	if err != nil {
-		fmt.Printf("AVISO: Erro ao salvar sessão em ShowLoginPage: %v\n", err)
-	}
-
-	c.HTML(http.StatusOK, "login.html", gin.H{
-		"IsLoggedIn":     false,
-		"FlashesSuccess": flashesSuccess,
-		"FlashesError":   flashesError,
-	})
+	c.HTML(http.StatusOK, "login.html", novaPagina(c, session, "login", nil))
 }
 
 // ProcessLoginForm processa os dados do formulário de login.
@@ -104,50 +85,55 @@ func (h *AuthHandler) ProcessLoginForm(c *gin.Context) {
 	email := c.PostForm("email")
 	senha := c.PostForm("senha")
 
-	var usuario model.Usuario
-	result := database.DB.Where("email = ?", email).First(&usuario)
-
-	if result.Error != nil && result.Error == gorm.ErrRecordNotFound {
-		session.AddFlash("E-mail ou senha inválidos.", "error")
+	usuario, err := service.AutenticarSenha(database.DB, email, senha)
+	switch {
+	case errors.Is(err, service.ErrLoginInvalido):
+		adicionarFlash(session, FlashErro, "E-mail ou senha inválidos.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/login")
 		return
-	}
-
-	if result.Error != nil {
-		session.AddFlash("Ocorreu um erro interno. Tente novamente.", "error")
+	case errors.Is(err, service.ErrContaDesativada):
+		adicionarFlash(session, FlashErro, "Conta desativada. Fale com a loja para reativá-la.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/login")
 		return
-	}
-
-	err := bcrypt.CompareHashAndPassword([]byte(usuario.SenhaHash), []byte(senha))
-	if err != nil {
-		session.AddFlash("E-mail ou senha inválidos.", "error")
+	case err != nil:
+		adicionarFlash(session, FlashErro, "Ocorreu um erro interno. Tente novamente.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
 
+	// Novo ID no login: quem conhecia o ID anterior (fixação de sessão) não herda o acesso
+	if err := h.Store.Regenerar(c.Request, session); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao renovar a sessão no login", "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao iniciar a sessão. Tente novamente.")
+		_ = session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
 	session.Values["userID"] = usuario.ID
 	session.Values["userName"] = usuario.Nome
 
 	err = session.Save(c.Request, c.Writer)
 	if err != nil {
-		fmt.Printf("ERRO ao salvar sessão de login: %v\n", err)
-		session.AddFlash("Erro ao iniciar a sessão. Tente novamente.", "error")
+		slog.ErrorContext(c.Request.Context(), "Erro ao salvar sessão de login", "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao iniciar a sessão. Tente novamente.")
 		_ = session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
 
-	if usuario.Tipo == model.RoleLojista {
+	if usuario.Tipo == model.RoleLojista && usuario.Papel == model.PapelCozinha {
+		c.Redirect(http.StatusFound, "/lojista/cozinha")
+	} else if usuario.Tipo == model.RoleLojista {
 		c.Redirect(http.StatusFound, "/lojista/dashboard")
 	} else { // Assume cliente
 		c.Redirect(http.StatusFound, "/cliente/dashboard")
 	}
 }
 
+// Logout apaga a sessão no servidor, o que invalida o cookie mesmo se ele foi copiado.
 func (h *AuthHandler) Logout(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
 	session.Values["userID"] = nil
@@ -156,61 +142,192 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	session.Options.MaxAge = -1
 	err := session.Save(c.Request, c.Writer)
 	if err != nil {
-		fmt.Printf("Erro ao salvar sessão de logout: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "Erro ao salvar sessão de logout", "erro", err)
 		c.String(http.StatusInternalServerError, "Erro ao fazer logout.")
 		return
 	}
-	fmt.Println("Logout realizado com sucesso.")
-	c.Redirect(http.StatusFound, "/login")
+	slog.InfoContext(c.Request.Context(), "Logout realizado com sucesso.")
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// LogoutTodos encerra as sessões do usuário em todos os dispositivos, inclusive esta.
+func (h *AuthHandler) LogoutTodos(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	if err := h.Store.EncerrarDoUsuario(c.Request.Context(), user.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao encerrar as sessões do usuário", "usuario_id", user.ID, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao encerrar as sessões. Tente novamente.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/perfil")
+		return
+	}
+	if err := service.RevogarTokensAPIDoUsuario(database.DB.WithContext(c.Request.Context()), user.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao revogar os tokens de API do usuário", "usuario_id", user.ID, "erro", err)
+	}
+	slog.InfoContext(c.Request.Context(), "Sessões encerradas em todos os dispositivos", "usuario_id", user.ID)
+
+	// A sessão atual já foi apagada; uma nova, sem login, leva o aviso até a tela de login
+	session.ID = ""
+	session.Values = map[interface{}]interface{}{}
+	adicionarFlash(session, FlashSucesso, "Você saiu de todos os dispositivos. Entre novamente.")
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/login")
 }
 
+// AuthRequired barra quem não está logado; o usuário já vem resolvido por Identificar.
 func (h *AuthHandler) AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-		userID, ok := session.Values["userID"].(uint)
+		user, ok := UsuarioAtual(c)
 		if !ok {
-			fmt.Println("AuthRequired: UserID não encontrado ou inválido na sessão. Redirecionando para /login.") // Log
+			slog.DebugContext(c.Request.Context(), "AuthRequired: sessão sem usuário, redirecionando para /login")
 			c.Redirect(http.StatusFound, "/login")
 			c.Abort()
 			return
 		}
-
-		var user model.Usuario
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			fmt.Printf("AuthRequired: Usuário ID %d não encontrado no DB. Forçando logout.\n", userID)
-			session.Values["userID"] = nil
-			session.Values["userName"] = nil
-			session.Options.MaxAge = -1
-			session.Save(c.Request, c.Writer)
-			c.Redirect(http.StatusFound, "/login")
-			c.Abort()
-			return
-		}
-
-		c.Set("user", user)
-		fmt.Printf("AuthRequired: Usuário ID %d autenticado (%s).\n", userID, user.Email)
+		slog.DebugContext(c.Request.Context(), "AuthRequired: usuário autenticado", "usuario_id", user.ID)
 		c.Next()
 	}
 }
 
-// RoleRequired é um middleware para verificar se o usuário logado tem o papel necessário.
-func (h *AuthHandler) RoleRequired(requiredRole string) gin.HandlerFunc {
+// RoleRequired é um middleware para verificar se o usuário logado tem o papel necessário
+// e, na equipe, as permissões da área (model.Permissao).
+func (h *AuthHandler) RoleRequired(requiredRole string, permissoes ...model.Permissao) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userData, exists := c.Get("user")
-		if !exists {
+		user, ok := UsuarioAtual(c)
+		if !ok {
 			c.Redirect(http.StatusFound, "/login")
 			c.Abort()
 			return
 		}
 
-		user := userData.(model.Usuario)
-
 		if user.Tipo != requiredRole {
-			fmt.Printf("RoleRequired: Acesso negado para usuário ID %d (%s). Role requerido: %s, Role do usuário: %s\n", user.ID, user.Email, requiredRole, user.Tipo) // Log
+			slog.WarnContext(c.Request.Context(), "RoleRequired: acesso negado", "usuario_id", user.ID, "role_requerido", requiredRole, "role", user.Tipo)
 			c.String(http.StatusForbidden, "Acesso negado.")
 			c.Abort()
 			return
 		}
+		for _, permissao := range permissoes {
+			if !user.Pode(permissao) {
+				slog.WarnContext(c.Request.Context(), "RoleRequired: usuário sem a permissão", "usuario_id", user.ID, "papel", user.Papel, "permissao", permissao)
+				session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+				adicionarFlash(session, FlashErro, "Seu papel na equipe não dá acesso a esta área.")
+				session.Save(c.Request, c.Writer)
+				c.Redirect(http.StatusSeeOther, "/lojista/dashboard")
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
+
+// ShowConvitePage mostra o formulário para o membro da equipe definir a senha.
+func (h *AuthHandler) ShowConvitePage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	membro, err := service.BuscarConvite(database.DB, c.Param("token"), time.Now())
+	if err != nil && !errors.Is(err, service.ErrConviteInvalido) {
+		c.String(http.StatusInternalServerError, "Erro ao buscar o convite.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "convite.html", novaPagina(c, session, "convite", gin.H{
+		"Valido":   err == nil,
+		"Membro":   membro,
+		"Token":    c.Param("token"),
+		"MinSenha": service.MinSenha,
+	}))
+}
+
+// ProcessConviteForm define a senha do membro convidado e o manda para o login.
+func (h *AuthHandler) ProcessConviteForm(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	token := c.Param("token")
+	voltar := func(msg string) {
+		adicionarFlash(session, FlashErro, msg)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/convite/"+url.PathEscape(token))
+	}
+
+	senha := c.PostForm("senha")
+	if senha != c.PostForm("confirmar_senha") {
+		voltar("As senhas não conferem!")
+		return
+	}
+	membro, err := service.AceitarConvite(database.DB, token, senha)
+	switch {
+	case errors.Is(err, service.ErrSenhaCurta):
+		voltar(fmt.Sprintf("A senha precisa ter ao menos %d caracteres.", service.MinSenha))
+		return
+	case errors.Is(err, service.ErrConviteInvalido):
+		voltar("Este convite não é mais válido. Peça um novo link ao administrador da loja.")
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao aceitar convite", "erro", err)
+		voltar("Erro ao definir a senha. Tente novamente.")
+		return
+	}
+
+	adicionarFlash(session, FlashSucesso, fmt.Sprintf("Senha definida! Entre com %s.", membro.Email))
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// ShowRedefinirSenhaPage mostra o formulário do link de redefinição de senha do cliente.
+func (h *AuthHandler) ShowRedefinirSenhaPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cliente, err := service.BuscarRedefinicao(database.DB, c.Param("token"), time.Now())
+	if err != nil && !errors.Is(err, service.ErrRedefinicaoInvalida) {
+		c.String(http.StatusInternalServerError, "Erro ao buscar o link de redefinição.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "redefinir_senha.html", novaPagina(c, session, "redefinir_senha", gin.H{
+		"Valido":   err == nil,
+		"Cliente":  cliente,
+		"Token":    c.Param("token"),
+		"MinSenha": service.MinSenha,
+	}))
+}
+
+// ProcessRedefinirSenhaForm troca a senha do cliente e o manda para o login.
+func (h *AuthHandler) ProcessRedefinirSenhaForm(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	token := c.Param("token")
+	voltar := func(msg string) {
+		adicionarFlash(session, FlashErro, msg)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/redefinir-senha/"+url.PathEscape(token))
+	}
+
+	senha := c.PostForm("senha")
+	if senha != c.PostForm("confirmar_senha") {
+		voltar("As senhas não conferem!")
+		return
+	}
+	cliente, err := service.RedefinirSenha(database.DB, token, senha)
+	switch {
+	case errors.Is(err, service.ErrSenhaCurta):
+		voltar(fmt.Sprintf("A senha precisa ter ao menos %d caracteres.", service.MinSenha))
+		return
+	case errors.Is(err, service.ErrRedefinicaoInvalida):
+		voltar("Este link não é mais válido. Peça um novo à loja.")
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao redefinir senha", "erro", err)
+		voltar("Erro ao redefinir a senha. Tente novamente.")
+		return
+	}
+
+	// Quem tinha a senha antiga não continua logado
+	if err := h.Store.EncerrarDoUsuario(c.Request.Context(), cliente.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao encerrar as sessões após redefinir a senha", "usuario_id", cliente.ID, "erro", err)
+	}
+	if err := service.RevogarTokensAPIDoUsuario(database.DB.WithContext(c.Request.Context()), cliente.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao revogar os tokens de API após redefinir a senha", "usuario_id", cliente.ID, "erro", err)
+	}
+
+	adicionarFlash(session, FlashSucesso, fmt.Sprintf("Senha redefinida! Entre com %s.", cliente.Email))
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/login")
+}
@@ -14,8 +14,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/ericoliveiras/meu-cupcake/internal/view"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv" // Import godotenv
@@ -40,29 +43,10 @@ func TestShowLoginPage(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
-	// --- Carregar Templates de Forma Robusta ---
-	projectRoot := getProjectRoot()
-	templatePattern := filepath.Join(projectRoot, "internal", "view", "templates", "*.html") // Usa *.html
-	templateDir := filepath.Dir(templatePattern)
-
-	fmt.Printf("DEBUG (TestShowLoginPage): Tentando carregar templates de: %s\n", templatePattern)
-
-	// Verifica se o diretório existe
-	if _, err := os.Stat(templateDir); os.IsNotExist(err) {
-		t.Fatalf("Diretório de templates não encontrado em: %s", templateDir)
-	}
-
-	// Tenta carregar os templates. LoadHTMLGlob causa pânico se o padrão não achar NADA.
-	// Se der pânico aqui, verifique se existem arquivos .html na pasta templates.
-	router.LoadHTMLGlob(templatePattern)
-	// Adiciona uma verificação básica (não perfeita) se o renderer foi setado.
-	if router.HTMLRender == nil {
-		t.Fatal("Falha ao carregar templates HTML. Verifique o caminho e se existem arquivos .html.")
-	}
-	fmt.Println("DEBUG (TestShowLoginPage): Templates carregados com sucesso.")
-	// ---------------------------------------------
+	// Os mesmos templates embutidos que o servidor usa
+	router.LoadHTMLFS(http.FS(view.Templates), view.PadraoTemplates)
 
-	store := sessions.NewCookieStore([]byte("secret-key-for-test"))
+	store := service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "secret-key-for-test")
 	authHandler := &AuthHandler{Store: store}
 	router.GET("/login", authHandler.ShowLoginPage)
 
@@ -109,7 +93,7 @@ func TestShowLoginPage(t *testing.T) {
 func setupLoginTestRouter() (*gin.Engine, *AuthHandler) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	store := sessions.NewCookieStore([]byte("secret-key-for-test-login"))
+	store := service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "secret-key-for-test-login")
 	authHandler := &AuthHandler{Store: store}
 
 	// Registra rotas necessárias
@@ -137,7 +121,7 @@ func TestProcessLoginForm(t *testing.T) {
 	}
 
 	// --- Conectar ao Banco de Dados ---
-	database.ConnectDB()
+	database.ConnectDB(config.Banco{URL: os.Getenv("DATABASE_URL")})
 	if database.DB == nil {
 		t.Fatal("Erro crítico: A conexão com o banco de dados (database.DB) é nula.")
 	}
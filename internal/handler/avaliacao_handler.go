@@ -0,0 +1,131 @@
+// /internal/handler/avaliacao_handler.go
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// cupcakeAvaliavel carrega o cupcake do parâmetro :id e confere se o cliente pode
+// avaliá-lo. Em caso de erro, grava o flash, redireciona e retorna false.
+func (h *HomeHandler) cupcakeAvaliavel(c *gin.Context, user model.Usuario) (model.Cupcake, bool) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	falhar := func(msg string) (model.Cupcake, bool) {
+		adicionarFlash(session, FlashErro, msg)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/cliente/pedidos")
+		return model.Cupcake{}, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return falhar("Cupcake não encontrado.")
+	}
+	var cupcake model.Cupcake
+	if err := database.DB.First(&cupcake, uint(id)).Error; err != nil {
+		return falhar("Este cupcake não está mais disponível para avaliação.")
+	}
+	permitido, err := service.PodeAvaliar(database.DB, user.ID, cupcake.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao verificar pedidos do cliente", "usuario_id", user.ID, "erro", err)
+		return falhar(service.MensagemAvaliacao(err))
+	}
+	if !permitido {
+		return falhar(service.MensagemAvaliacao(service.ErrAvaliacaoNaoPermitida))
+	}
+	return cupcake, true
+}
+
+// ShowAvaliacaoPage mostra o formulário de avaliação, preenchido se o cliente já avaliou.
+func (h *HomeHandler) ShowAvaliacaoPage(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	cupcake, ok := h.cupcakeAvaliavel(c, user)
+	if !ok {
+		return
+	}
+
+	var avaliacao model.Avaliacao
+	err := database.DB.Where("usuario_id = ? AND cupcake_id = ?", user.ID, cupcake.ID).First(&avaliacao).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusInternalServerError, "Erro ao carregar a avaliação.")
+		return
+	}
+
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	c.HTML(http.StatusOK, "cliente_avaliacao.html", novaPagina(c, session, "pedidos", gin.H{
+		"Cupcake":   cupcake,
+		"Avaliacao": avaliacao,
+		"Notas":     []int{5, 4, 3, 2, 1},
+		"MaxTexto":  service.MaxTextoAvaliacao,
+	}))
+}
+
+// ProcessAvaliacao grava (ou atualiza) a avaliação do cliente, que volta para a
+// fila de moderação do lojista.
+func (h *HomeHandler) ProcessAvaliacao(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	cupcake, ok := h.cupcakeAvaliavel(c, user)
+	if !ok {
+		return
+	}
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	voltar := "/cliente/avaliar/" + strconv.FormatUint(uint64(cupcake.ID), 10)
+	falhar := func(msg string) {
+		adicionarFlash(session, FlashErro, msg)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, voltar)
+	}
+
+	nota, _ := strconv.Atoi(c.PostForm("nota"))
+	texto, err := service.ValidarAvaliacao(nota, c.PostForm("texto"))
+	if err != nil {
+		falhar(service.MensagemAvaliacao(err))
+		return
+	}
+
+	var avaliacao model.Avaliacao
+	err = database.DB.Where("usuario_id = ? AND cupcake_id = ?", user.ID, cupcake.ID).First(&avaliacao).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar avaliação do cliente", "usuario_id", user.ID, "erro", err)
+		falhar(service.MensagemAvaliacao(err))
+		return
+	}
+
+	fotoNova, err := salvarImagemDoCampo(c, h.Imagens, "foto")
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao processar foto da avaliação", "erro", err)
+		falhar(h.Imagens.MensagemImagem(err))
+		return
+	}
+	fotoAntiga := avaliacao.FotoURL
+	if fotoNova != "" || c.PostForm("remover_foto") == "true" {
+		avaliacao.FotoURL = fotoNova
+	}
+
+	avaliacao.CupcakeID = cupcake.ID
+	avaliacao.UsuarioID = user.ID
+	avaliacao.Nota = nota
+	avaliacao.Texto = texto
+	avaliacao.Status = model.AvaliacaoPendente
+	if err := database.DB.Omit("Cupcake", "Usuario").Save(&avaliacao).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao salvar avaliação do cliente", "usuario_id", user.ID, "erro", err)
+		h.Imagens.Remover(c.Request.Context(), fotoNova)
+		falhar(service.MensagemAvaliacao(err))
+		return
+	}
+	if fotoAntiga != "" && fotoAntiga != avaliacao.FotoURL {
+		h.Imagens.Remover(c.Request.Context(), fotoAntiga)
+	}
+
+	adicionarFlash(session, FlashSucesso, "Obrigado pela avaliação! Ela aparecerá na vitrine depois de revisada pela loja.")
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/cliente/pedidos")
+}
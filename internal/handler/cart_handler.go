@@ -1,24 +1,43 @@
 package handler
 
 import (
-	"context"
 	"errors"
-	"fmt" // Import log
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
 	"sort"
 	"strconv" // Import strings
+	"strings"
 	"time"
 
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/mercadopago/sdk-go/pkg/config"
-	"github.com/mercadopago/sdk-go/pkg/payment"
 	"gorm.io/gorm"
 )
 
+// EnderecoEntregaData espelha o endereço de entrega enviado pelo checkout.
+type EnderecoEntregaData struct {
+	CEP         string `json:"cep"`
+	Rua         string `json:"rua"`
+	Numero      string `json:"numero"`
+	Complemento string `json:"complemento"`
+	Bairro      string `json:"bairro"`
+	Cidade      string `json:"cidade"`
+	Estado      string `json:"estado"`
+}
+
+// AgendamentoData espelha a escolha de entrega/retirada e do horário feita no checkout.
+type AgendamentoData struct {
+	Tipo  string `json:"tipo"`  // "entrega" ou "retirada"
+	Opcao string `json:"opcao"` // "<janelaID>|<AAAA-MM-DD>", ver service.OpcaoEntrega.Valor
+}
+
 // PaymentRequestData espelha a estrutura do JSON enviado pelo frontend (CARTÃO).
 type PaymentRequestData struct {
 	Token             string  `json:"token"`
@@ -34,6 +53,8 @@ type PaymentRequestData struct {
 			Number string `json:"number"`
 		} `json:"identification"`
 	} `json:"payer"`
+	Entrega     EnderecoEntregaData `json:"entrega"`
+	Agendamento AgendamentoData     `json:"agendamento"`
 }
 
 // PixRequestData espelha a estrutura do JSON enviado pelo frontend (PIX).
@@ -44,24 +65,33 @@ type PixRequestData struct {
 		Email string `json:"email"`
 		// (Campos de identificação removidos para o teste do PIX)
 	} `json:"payer"`
+	Entrega     EnderecoEntregaData `json:"entrega"`
+	Agendamento AgendamentoData     `json:"agendamento"`
 }
 
 // Estrutura auxiliar para passar dados do item do carrinho para o template
 type CartItemView struct {
-	Cupcake  model.Cupcake
-	Quantity int
-	Subtotal float64
+	Chave         string // Chave da linha no carrinho (service.LinhaCarrinho.Chave)
+	Cupcake       model.Cupcake
+	Opcoes        []model.ItemOrderOpcao
+	Kit           *model.Kit              // Preenchido nas linhas de kit (Cupcake fica zerado)
+	Componentes   []service.ComponenteKit // Cupcakes de uma unidade do kit
+	PrecoUnitario float64                 // Preço do cupcake já com as opções escolhidas, ou do kit
+	Quantity      int
+	Subtotal      float64
 }
 
 // CartHandler agrupa os handlers do carrinho.
 type CartHandler struct {
-	Store *sessions.CookieStore
-	MPCfg *config.Config
+	Store       *service.SessaoStore
+	MPCfg       *config.Config
+	MPPublicKey string // Chave pública do Mercado Pago, usada pelo checkout no navegador
 }
 
 const CartSessionKey = "shopping_cart"
 
-// AddToCart adiciona um item ao carrinho e retorna JSON (sem recarregar a página)
+// AddToCart adiciona um item ao carrinho e retorna JSON (sem recarregar a página).
+// As opções de personalização chegam como opcao_<grupoID> (pode repetir) e texto_<grupoID>.
 func (h *CartHandler) AddToCart(c *gin.Context) {
 	idStr := c.Param("id")
 	id64, err := strconv.ParseUint(idStr, 10, 32)
@@ -72,20 +102,20 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	cupcakeID := uint(id64)
 
 	var cupcake model.Cupcake
-	if err := database.DB.Where("id = ? AND disponivel = ?", cupcakeID, true).First(&cupcake).Error; err != nil {
+	if err := service.ComOpcoes(database.DB).Where("id = ? AND disponivel = ?", cupcakeID, true).First(&cupcake).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Cupcake não encontrado ou indisponível."})
 		return
 	}
 
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-
-	cart, ok := cartData.(map[uint]int)
-	if !ok {
-		cart = make(map[uint]int)
+	linha := linhaDoFormulario(c, cupcake)
+	if _, _, err := service.PrecoLinha(cupcake, linha); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
 	}
 
-	cart[cupcakeID]++
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cart := carrinhoDaSessao(session)
+	cart.Adicionar(linha, 1)
 
 	session.Values[CartSessionKey] = cart
 	if err := session.Save(c.Request, c.Writer); err != nil {
@@ -93,6 +123,7 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		return
 	}
 
+	metricas.AdicoesCarrinho.WithLabelValues("cupcake").Inc()
 	newTotalQuantity := getTotalCartQuantityHelper(cart)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -102,94 +133,102 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	})
 }
 
-// ShowCartPage exibe o conteúdo do carrinho de compras.
-func (h *CartHandler) ShowCartPage(c *gin.Context) {
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	user, isLoggedIn := h.getUserFromSession(c)
-
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
-		flashesSuccess := session.Flashes("success")
-		flashesError := session.Flashes("error")
-		session.Save(c.Request, c.Writer)
-		c.HTML(http.StatusOK, "carrinho.html", gin.H{
-			"Items":          []CartItemView{},
-			"Total":          0.0,
-			"IsLoggedIn":     isLoggedIn,
-			"User":           user,
-			"CartItemCount":  0,
-			"FlashesSuccess": flashesSuccess,
-			"FlashesError":   flashesError,
-		})
+// AddKitToCart adiciona um kit ao carrinho e retorna JSON. Na "monte sua caixa" as
+// escolhas chegam como qtd_<cupcakeID>.
+func (h *CartHandler) AddKitToCart(c *gin.Context) {
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "ID do kit inválido."})
 		return
 	}
 
-	cupcakeIDs := make([]uint, 0, len(cart))
-	for id := range cart {
-		cupcakeIDs = append(cupcakeIDs, id)
-	}
-
-	var cupcakes []model.Cupcake
-	database.DB.Where("id IN ? AND disponivel = ?", cupcakeIDs, true).Find(&cupcakes)
-
-	var total float64
-	cartItemsView := make([]CartItemView, 0, len(cupcakes))
-	cupcakeMap := make(map[uint]model.Cupcake)
-	for _, cp := range cupcakes {
-		cupcakeMap[cp.ID] = cp
+	var kit model.Kit
+	if err := service.ComComponentes(database.DB).Where("id = ? AND disponivel = ?", uint(id64), true).First(&kit).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Kit não encontrado ou indisponível."})
+		return
 	}
 
-	finalCart := make(map[uint]int)
-	for id, quantity := range cart {
-		if cupcake, found := cupcakeMap[id]; found {
-			subtotal := cupcake.Preco * float64(quantity)
-			cartItemsView = append(cartItemsView, CartItemView{
-				Cupcake: cupcake, Quantity: quantity, Subtotal: subtotal,
-			})
-			total += subtotal
-			finalCart[id] = quantity
+	linha := service.LinhaCarrinho{KitID: kit.ID}
+	if kit.MonteSuaCaixa() {
+		linha.Caixa = make(map[uint]int)
+		for _, cp := range kit.Permitidos {
+			if qtd, err := strconv.Atoi(c.PostForm(fmt.Sprintf("qtd_%d", cp.ID))); err == nil && qtd > 0 {
+				linha.Caixa[cp.ID] = qtd
+			}
 		}
 	}
+	if _, err := service.ComponentesKit(kit, linha); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
 
-	sort.Slice(cartItemsView, func(i, j int) bool {
-		return cartItemsView[i].Cupcake.Nome < cartItemsView[j].Cupcake.Nome
-	})
-
-	cartCount := getTotalCartQuantityHelper(finalCart)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cart := carrinhoDaSessao(session)
+	cart.Adicionar(linha, 1)
 
-	flashesSuccess := session.Flashes("success")
-	flashesError := session.Flashes("error")
-	session.Save(c.Request, c.Writer)
+	session.Values[CartSessionKey] = cart
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao salvar o carrinho."})
+		return
+	}
 
-	c.HTML(http.StatusOK, "carrinho.html", gin.H{
-		"Items":          cartItemsView,
-		"Total":          total,
-		"IsLoggedIn":     isLoggedIn,
-		"User":           user,
-		"CartItemCount":  cartCount,
-		"FlashesSuccess": flashesSuccess,
-		"FlashesError":   flashesError,
+	metricas.AdicoesCarrinho.WithLabelValues("kit").Inc()
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"message":      "Kit adicionado com sucesso!",
+		"newCartCount": getTotalCartQuantityHelper(cart),
 	})
 }
 
-func (h *CartHandler) RemoveFromCart(c *gin.Context) {
-	idStr := c.Param("id")
-	id64, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "ID inválido."})
+// ShowCartPage exibe o conteúdo do carrinho de compras.
+func (h *CartHandler) ShowCartPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cart := carrinhoDaSessao(session)
+	user, _ := UsuarioAtual(c)
+
+	if len(cart) == 0 {
+		c.HTML(http.StatusOK, "carrinho.html", novaPagina(c, session, "carrinho", gin.H{
+			"Items": []CartItemView{},
+			"Total": 0.0,
+		}))
 		return
 	}
-	cupcakeID := uint(id64)
+
+	cartItemsView, total, _, err := loadCartItems(cart)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao carregar itens do carrinho", "erro", err)
+	}
+	cartCount := quantidadeItens(cartItemsView)
+
+	cupom, desconto, cupomErr := cupomDaSessao(session, user.ID, cartItemsView)
+	cupomErro := ""
+	if cupomErr != nil {
+		cupomErro = service.MensagemCupom(cupomErr)
+	}
+
+	c.HTML(http.StatusOK, "carrinho.html", novaPagina(c, session, "carrinho", gin.H{
+		"Items":            cartItemsView,
+		"Total":            total,
+		"Cupom":            cupom,
+		"CodigoCupom":      session.Values[CupomSessionKey],
+		"CupomErro":        cupomErro,
+		"Desconto":         desconto,
+		"TotalComDesconto": total - desconto,
+		"CartItemCount":    cartCount, // Só os itens ainda válidos
+	}))
+}
+
+// RemoveFromCart remove uma linha do carrinho.
+func (h *CartHandler) RemoveFromCart(c *gin.Context) {
+	chave := c.Param("linha")
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
+	cart := carrinhoDaSessao(session)
+	if len(cart) == 0 {
 		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Carrinho já vazio.", "newCartCount": 0})
 		return
 	}
 
-	delete(cart, cupcakeID) // Remove o item
+	delete(cart, chave) // Remove o item
 	session.Values[CartSessionKey] = cart
 	if err := session.Save(c.Request, c.Writer); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao atualizar o carrinho."})
@@ -201,29 +240,37 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Item removido.", "newCartCount": newTotalQuantity})
 }
 
-// DecreaseQuantity diminui a quantidade de um item no carrinho.
-func (h *CartHandler) DecreaseQuantity(c *gin.Context) {
-	idStr := c.Param("id")
-	id64, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "ID inválido."})
+// IncreaseQuantity aumenta em um a quantidade de uma linha do carrinho, mantendo as opções escolhidas.
+func (h *CartHandler) IncreaseQuantity(c *gin.Context) {
+	chave := c.Param("linha")
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cart := carrinhoDaSessao(session)
+	if !cart.Aumentar(chave) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Item não encontrado no carrinho."})
 		return
 	}
-	cupcakeID := uint(id64)
+	session.Values[CartSessionKey] = cart
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao atualizar o carrinho."})
+		return
+	}
+
+	newTotalQuantity := getTotalCartQuantityHelper(cart)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Quantidade atualizada.", "newCartCount": newTotalQuantity})
+}
+
+// DecreaseQuantity diminui a quantidade de um item no carrinho.
+func (h *CartHandler) DecreaseQuantity(c *gin.Context) {
+	chave := c.Param("linha")
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
+	cart := carrinhoDaSessao(session)
+	if len(cart) == 0 {
 		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Carrinho já vazio.", "newCartCount": 0})
 		return
 	}
 
-	if quantity, exists := cart[cupcakeID]; exists {
-		if quantity > 1 {
-			cart[cupcakeID]--
-		} else {
-			delete(cart, cupcakeID)
-		}
+	if _, exists := cart[chave]; exists {
+		cart.Diminuir(chave)
 		session.Values[CartSessionKey] = cart
 		if err := session.Save(c.Request, c.Writer); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao atualizar o carrinho."})
@@ -239,7 +286,8 @@ func (h *CartHandler) DecreaseQuantity(c *gin.Context) {
 // ClearCart remove todos os itens do carrinho.
 func (h *CartHandler) ClearCart(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	session.Values[CartSessionKey] = make(map[uint]int) // Define como vazio
+	session.Values[CartSessionKey] = service.Carrinho{} // Define como vazio
+	delete(session.Values, CupomSessionKey)
 	if err := session.Save(c.Request, c.Writer); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao limpar o carrinho."})
 		return
@@ -252,242 +300,121 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 // ShowCheckoutPage exibe a página de resumo do pedido antes do pagamento.
 func (h *CartHandler) ShowCheckoutPage(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
+	user, _ := UsuarioAtual(c)
 
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
+	cart := carrinhoDaSessao(session)
+	if len(cart) == 0 {
 		c.Redirect(http.StatusFound, "/carrinho")
 		return
 	}
 
-	cupcakeIDs := make([]uint, 0, len(cart))
-	for id := range cart {
-		cupcakeIDs = append(cupcakeIDs, id)
-	}
-
-	var cupcakes []model.Cupcake
-	result := database.DB.Where("id IN ? AND disponivel = ?", cupcakeIDs, true).Find(&cupcakes)
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+	cartItemsView, total, invalidos, err := loadCartItems(cart)
+	if err != nil {
 		c.String(http.StatusInternalServerError, "Erro ao buscar detalhes dos produtos.")
 		return
 	}
 
-	if len(cupcakes) != len(cart) {
-		fmt.Printf("Checkout inválido: Itens na sessão (%d) != Itens válidos no DB (%d)\n", len(cart), len(cupcakes))
-		session.AddFlash("Alguns itens no seu carrinho não estão mais disponíveis. Verifique seu carrinho.", "error")
+	if invalidos > 0 {
+		slog.WarnContext(c.Request.Context(), "Checkout inválido: linhas do carrinho não são mais válidas", "invalidos", invalidos, "linhas", len(cart))
+		adicionarFlash(session, FlashErro, "Alguns itens no seu carrinho não estão mais disponíveis. Verifique seu carrinho.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/carrinho")
 		return
 	}
+	cartCount := quantidadeItens(cartItemsView)
 
-	var total float64
-	cartItemsView := make([]CartItemView, 0, len(cupcakes))
-	cupcakeMap := make(map[uint]model.Cupcake)
-	for _, cp := range cupcakes {
-		cupcakeMap[cp.ID] = cp
-	}
-	finalCart := make(map[uint]int)
-	for id, quantity := range cart {
-		if cupcake, found := cupcakeMap[id]; found {
-			subtotal := cupcake.Preco * float64(quantity)
-			cartItemsView = append(cartItemsView, CartItemView{
-				Cupcake: cupcake, Quantity: quantity, Subtotal: subtotal,
-			})
-			total += subtotal
-			finalCart[id] = quantity
-		}
+	cupom, desconto, err := cupomDaSessao(session, user.ID, cartItemsView)
+	if err != nil {
+		adicionarFlash(session, FlashErro, service.MensagemCupom(err)+" Remova o cupom ou ajuste o carrinho.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusFound, "/carrinho")
+		return
 	}
-	cartCount := getTotalCartQuantityHelper(finalCart)
 
-	mpPublicKey := os.Getenv("MP_PUBLIC_KEY")
-	if mpPublicKey == "" {
-		fmt.Println("AVISO: MP_PUBLIC_KEY não encontrada no .env")
+	qtdCupcakes := cartCount
+	opcoesEntrega, err := service.ListarOpcoesEntrega(database.DB, model.TipoEntregaDelivery, time.Now(), service.DiasAgendamento, qtdCupcakes)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao listar janelas de entrega", "erro", err)
+	}
+	opcoesRetirada, err := service.ListarOpcoesEntrega(database.DB, model.TipoEntregaRetirada, time.Now(), service.DiasAgendamento, qtdCupcakes)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao listar janelas de retirada", "erro", err)
 	}
 
-	c.HTML(http.StatusOK, "checkout.html", gin.H{
+	c.HTML(http.StatusOK, "checkout.html", novaPagina(c, session, "checkout", gin.H{
 		"Items":                cartItemsView,
-		"Total":                total,
-		"IsLoggedIn":           true,
-		"User":                 user,
+		"Subtotal":             total,
+		"Cupom":                cupom,
+		"Desconto":             desconto,
+		"Total":                total - desconto,
 		"CartItemCount":        cartCount,
-		"MercadoPagoPublicKey": mpPublicKey,
-	})
+		"MercadoPagoPublicKey": h.MPPublicKey,
+		"OpcoesEntrega":        opcoesEntrega,
+		"OpcoesRetirada":       opcoesRetirada,
+	}))
 }
 
 // ProcessPayment (Pagamento com Cartão)
 func (h *CartHandler) ProcessPayment(c *gin.Context) {
 	if h.MPCfg == nil {
-		fmt.Println("FATAL: Configuração do Mercado Pago é nula (nil) no handler.")
+		slog.ErrorContext(c.Request.Context(), "Configuração do Mercado Pago é nula (nil) no handler.")
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Erro interno: config pagamento."})
 		return
 	}
 
 	var reqData PaymentRequestData
 	if err := c.ShouldBindJSON(&reqData); err != nil {
-		fmt.Printf("Erro Bind JSON: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "Erro Bind JSON", "erro", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos.", "details": err.Error()})
 		return
 	}
-	fmt.Printf("Dados Pagamento Recebidos: %+v\n", reqData)
+	// Só dados sem valor para fraude: o token do cartão e o documento do pagador ficam fora do log
+	slog.DebugContext(c.Request.Context(), "Pagamento com cartão recebido", "valor", reqData.TransactionAmount, "metodo", reqData.PaymentMethodID, "parcelas", reqData.Installments)
 
-	userData, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado."})
-		return
-	}
-	user := userData.(model.Usuario)
-
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Carrinho vazio ou inválido."})
+	agendamento, err := validarAgendamento(reqData.Agendamento, reqData.Entrega)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados de entrega inválidos.", "details": err.Error()})
 		return
 	}
 
-	// --- LÓGICA DE VALIDAÇÃO DO CARRINHO ---
-	var currentTotal float64
-	cupcakeIDs := make([]uint, 0, len(cart))
-	for id := range cart {
-		cupcakeIDs = append(cupcakeIDs, id)
-	}
-
-	var cupcakesFromDB []model.Cupcake
-	result := database.DB.Where("id IN ? AND disponivel = ?", cupcakeIDs, true).Find(&cupcakesFromDB)
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		fmt.Printf("Erro DB ao buscar cupcakes: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar produtos."})
+	user, ok := UsuarioAtual(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado."})
 		return
 	}
 
-	cupcakeMap := make(map[uint]model.Cupcake)
-	for _, cp := range cupcakesFromDB {
-		cupcakeMap[cp.ID] = cp
-	}
-
-	validItems := make([]CartItemView, 0, len(cart))
-	validItemCount := 0
-	for id, quantity := range cart {
-		cupcake, found := cupcakeMap[id]
-		if found {
-			subtotal := cupcake.Preco * float64(quantity)
-			validItems = append(validItems, CartItemView{
-				Cupcake: cupcake, Quantity: quantity, Subtotal: subtotal,
-			})
-			currentTotal += subtotal
-			validItemCount++
-		}
-	}
-
-	if validItemCount != len(cart) || len(validItems) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Um ou mais itens no seu carrinho não estão mais disponíveis."})
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	codigoCupom, _ := session.Values[CupomSessionKey].(string)
+	co, err := prepararCheckout(carrinhoDaSessao(session), codigoCupom, user.ID)
+	if err != nil {
+		responderErroCheckout(c, err, "Carrinho vazio ou inválido.")
 		return
 	}
-	// --------------------------------------------------------
 
 	// --- Validação de Segurança do Total ---
-	tolerance := 0.01
-	if (currentTotal-reqData.TransactionAmount) > tolerance || (reqData.TransactionAmount-currentTotal) > tolerance {
-		fmt.Printf("ALERTA SEGURANÇA: Total Backend (%.2f) != Total Frontend (%.2f)\n", currentTotal, reqData.TransactionAmount)
+	if !totalConfere(co.Total, reqData.TransactionAmount) {
+		slog.WarnContext(c.Request.Context(), "ALERTA SEGURANÇA: total do backend diferente do frontend", "total_backend", co.Total, "total_frontend", reqData.TransactionAmount)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "O valor total do pedido foi modificado."})
 		return
 	}
-	fmt.Printf("Validação Total OK: Backend=%.2f, Frontend=%.2f\n", currentTotal, reqData.TransactionAmount)
-
-	// --- Criação do Pedido no DB (Transação) ---
-	var pedidoCriado model.Order // Corrigido para model.Pedido
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		externalRef := fmt.Sprintf("pedido_%d_%d", user.ID, time.Now().UnixNano())
-		pedido := model.Order{ // Corrigido para model.Pedido
-			UsuarioID: user.ID, Status: model.StatusPendente, Total: currentTotal,
-			MetodoPagamento: reqData.PaymentMethodID, Parcelas: reqData.Installments, ExternalReference: externalRef,
-		}
-		if err := tx.Create(&pedido).Error; err != nil {
-			return errors.New("erro ao criar o cabeçalho do pedido")
-		}
-		pedidoCriado = pedido
-		for _, item := range validItems {
-			itemPedido := model.ItemOrder{ // Corrigido para model.ItemPedido
-				PedidoID: pedido.ID, CupcakeID: item.Cupcake.ID, Quantidade: item.Quantity,
-				PrecoUnitario: item.Cupcake.Preco, Subtotal: item.Subtotal,
-			}
-			if err := tx.Create(&itemPedido).Error; err != nil {
-				return errors.New("erro ao salvar os itens do pedido")
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Não foi possível registrar seu pedido.", "details": err.Error()})
-		return
-	}
-	fmt.Printf("Pedido %d criado no DB (Ref: %s)\n", pedidoCriado.ID, pedidoCriado.ExternalReference)
-
-	// --- Chamada à API do Mercado Pago ---
-	fmt.Println("Tentando criar pagamento MP...")
-	client := payment.NewClient(h.MPCfg)
-	request := payment.Request{
-		TransactionAmount: currentTotal, // USA O VALOR DO BACKEND
-		Token:             reqData.Token,
-		Description:       reqData.Description,
-		Installments:      reqData.Installments,
-		PaymentMethodID:   reqData.PaymentMethodID,
-		IssuerID:          reqData.IssuerID,
-		ExternalReference: pedidoCriado.ExternalReference,
-		Payer: &payment.PayerRequest{
-			Email: reqData.Payer.Email,
-			Identification: &payment.IdentificationRequest{
-				Type:   reqData.Payer.Identification.Type,
-				Number: reqData.Payer.Identification.Number,
-			},
-		},
-		// notification_url: "SUA_URL_WEBHOOK"
-	}
-
-	// --- CORREÇÃO AQUI: REMOVIDO 'var resource ...' ---
-	resource, err := client.Create(context.Background(), request) // Usa := aqui
-
-	// --- Tratamento da Resposta e Atualização do Pedido no DB ---
-	var finalPedidoStatus model.StatusOrder = model.StatusFalhou // Corrigido
-	var responseStatus string = "rejected"
-	var message string = "Pagamento recusado ou pendente."
-	var mpPaymentID *int64 = nil
+	slog.DebugContext(c.Request.Context(), "Validação do total OK", "total_backend", co.Total, "total_frontend", reqData.TransactionAmount)
 
+	pedido, err := gravarPedido(c.Request.Context(), user.ID, co, agendamento, reqData.PaymentMethodID, reqData.Installments)
 	if err != nil {
-		fmt.Printf("Erro MP: %v\n", err)
-		message = "Erro ao processar pagamento com o provedor."
-	} else {
-		fmt.Printf("Resposta MP: Status=%s, Detail=%s, ID=%d\n", resource.Status, resource.StatusDetail, resource.ID)
-		tempID := int64(resource.ID)
-		mpPaymentID = &tempID
-		switch resource.Status {
-		case "approved":
-			finalPedidoStatus = model.StatusPago // Corrigido
-			responseStatus = "approved"
-			message = "Pagamento aprovado!"
-			session.Values[CartSessionKey] = make(map[uint]int)
-			session.Save(c.Request, c.Writer)
-		case "in_process", "pending":
-			finalPedidoStatus = model.StatusPendente // Corrigido
-			responseStatus = "pending"
-			message = "Pagamento pendente."
-		default:
-			finalPedidoStatus = model.StatusFalhou // Corrigido
-			responseStatus = "rejected"
-			message = fmt.Sprintf("Pagamento não aprovado (%s).", resource.StatusDetail)
+		if !respostaErroPedido(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Não foi possível registrar seu pedido.", "details": err.Error()})
 		}
+		return
 	}
+	metricas.PedidosCriados.WithLabelValues("cartao").Inc()
 
-	updateResult := database.DB.Model(&pedidoCriado).Updates(model.Order{ // Corrigido
-		Status: finalPedidoStatus, PagamentoMPID: mpPaymentID,
-	})
-	if updateResult.Error != nil {
-		fmt.Printf("ERRO CRÍTICO DB UPDATE Pedido %d: %v\n", pedidoCriado.ID, updateResult.Error)
+	resultado := cobrarCartao(c.Request.Context(), h.MPCfg, &pedido, reqData)
+	if resultado.Status == "approved" {
+		session.Values[CartSessionKey] = service.Carrinho{}
+		delete(session.Values, CupomSessionKey)
+		session.Save(c.Request, c.Writer)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"status": responseStatus, "message": message, "paymentId": mpPaymentID})
+	c.JSON(http.StatusOK, gin.H{"status": resultado.Status, "message": resultado.Mensagem, "paymentId": resultado.PagamentoID})
 }
 
 // ProcessPixPayment recebe os dados do pagador, cria o pedido e gera um pagamento PIX.
@@ -497,186 +424,374 @@ func (h *CartHandler) ProcessPixPayment(c *gin.Context) {
 		return
 	}
 
-	// 1. Usa a nova struct PixRequestData
 	var pixReqData PixRequestData
 	if err := c.ShouldBindJSON(&pixReqData); err != nil {
-		fmt.Printf("Erro Bind JSON PIX: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "Erro Bind JSON PIX", "erro", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados do pagador inválidos."})
 		return
 	}
-	fmt.Printf("Dados Pagamento PIX Recebidos: %+v\n", pixReqData)
+	slog.DebugContext(c.Request.Context(), "Pagamento PIX recebido", "valor", pixReqData.TransactionAmount)
+
+	agendamento, err := validarAgendamento(pixReqData.Agendamento, pixReqData.Entrega)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados de entrega inválidos.", "details": err.Error()})
+		return
+	}
 
-	userData, exists := c.Get("user")
-	if !exists {
+	user, ok := UsuarioAtual(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado."})
 		return
 	}
-	user := userData.(model.Usuario)
 
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, ok := cartData.(map[uint]int)
-	if !ok || len(cart) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Carrinho vazio."})
+	codigoCupom, _ := session.Values[CupomSessionKey].(string)
+	co, err := prepararCheckout(carrinhoDaSessao(session), codigoCupom, user.ID)
+	if err != nil {
+		responderErroCheckout(c, err, "Carrinho vazio.")
 		return
 	}
 
-	// --- LÓGICA DE RECÁLCULO (COPIADA DO ProcessPayment) ---
-	var currentTotal float64
-	cupcakeIDs := make([]uint, 0, len(cart))
-	for id := range cart {
-		cupcakeIDs = append(cupcakeIDs, id)
+	if !totalConfere(co.Total, pixReqData.TransactionAmount) {
+		slog.WarnContext(c.Request.Context(), "ALERTA SEGURANÇA (PIX): total do backend diferente do frontend", "total_backend", co.Total, "total_frontend", pixReqData.TransactionAmount)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O valor total do pedido foi modificado."})
+		return
 	}
+	slog.DebugContext(c.Request.Context(), "Validação do total PIX OK", "total_backend", co.Total, "total_frontend", pixReqData.TransactionAmount)
 
-	var cupcakesFromDB []model.Cupcake
-	result := database.DB.Where("id IN ? AND disponivel = ?", cupcakeIDs, true).Find(&cupcakesFromDB)
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		fmt.Printf("Erro DB ao buscar cupcakes: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar produtos."})
+	pedido, err := gravarPedido(c.Request.Context(), user.ID, co, agendamento, "pix", 1)
+	if err != nil {
+		if !respostaErroPedido(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar o pedido no banco."})
+		}
 		return
 	}
+	metricas.PedidosCriados.WithLabelValues("pix").Inc()
 
-	cupcakeMap := make(map[uint]model.Cupcake)
-	for _, cp := range cupcakesFromDB {
-		cupcakeMap[cp.ID] = cp
-	}
-
-	validItems := make([]CartItemView, 0, len(cart)) // 'validItems' DECLARADA E PREENCHIDA
-	validItemCount := 0
-	for id, quantity := range cart {
-		cupcake, found := cupcakeMap[id]
-		if found {
-			subtotal := cupcake.Preco * float64(quantity)
-			validItems = append(validItems, CartItemView{
-				Cupcake: cupcake, Quantity: quantity, Subtotal: subtotal,
-			})
-			currentTotal += subtotal
-			validItemCount++
-		}
+	resource, err := gerarPix(c.Request.Context(), h.MPCfg, &pedido, user, pixReqData.Payer.Email, pixReqData.Description)
+	if errors.Is(err, errPixStatus) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Status inesperado do provedor de pagamento."})
+		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PIX com o provedor."})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "pending",
+		"payment_id":     resource.ID,
+		"qr_code_base64": resource.PointOfInteraction.TransactionData.QRCodeBase64,
+		"qr_code":        resource.PointOfInteraction.TransactionData.QRCode,
+	})
+}
 
-	if validItemCount != len(cart) || len(validItems) == 0 {
+// totalConfere compara o total calculado no servidor com o enviado pelo frontend.
+func totalConfere(totalServidor, totalEnviado float64) bool {
+	return math.Abs(totalServidor-totalEnviado) <= 0.01
+}
+
+// responderErroCheckout responde (no JSON do checkout do site) a um erro de prepararCheckout.
+func responderErroCheckout(c *gin.Context, err error, msgCarrinhoVazio string) {
+	switch {
+	case errors.Is(err, errCarrinhoVazio):
+		c.JSON(http.StatusBadRequest, gin.H{"error": msgCarrinhoVazio})
+	case errors.Is(err, errItensIndisponiveis):
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Um ou mais itens no seu carrinho não estão mais disponíveis."})
-		return
+	case service.ErroDeCupom(err):
+		c.JSON(http.StatusConflict, gin.H{"error": service.MensagemCupom(err)})
+	default:
+		slog.ErrorContext(c.Request.Context(), "Erro DB ao validar o carrinho", "erro", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar produtos."})
 	}
+}
 
-	tolerance := 0.01
-	if (currentTotal-pixReqData.TransactionAmount) > tolerance || (pixReqData.TransactionAmount-currentTotal) > tolerance {
-		fmt.Printf("ALERTA SEGURANÇA (PIX): Total Backend (%.2f) != Total Frontend (%.2f)\n", currentTotal, pixReqData.TransactionAmount)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "O valor total do pedido foi modificado."})
-		return
+// respostaErroPedido responde aos erros de gravarPedido que são do cliente (horário
+// lotado, cupom esgotado). Retorna false para os erros internos, que cada checkout responde.
+func respostaErroPedido(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrJanelaLotada), errors.Is(err, service.ErrJanelaIndisponivel):
+		c.JSON(http.StatusConflict, gin.H{"error": "O horário escolhido não está mais disponível. Escolha outro horário."})
+	case service.ErroDeCupom(err):
+		c.JSON(http.StatusConflict, gin.H{"error": service.MensagemCupom(err)})
+	default:
+		return false
+	}
+	return true
+}
+
+// --- Funções Auxiliares ---
+
+// loadCartItems busca os cupcakes e kits do carrinho e monta os itens com o preço final.
+// Linhas de cupcakes indisponíveis, com opções que não valem mais ou de kits com algum
+// componente indisponível são ignoradas e contadas em invalidos.
+func loadCartItems(cart service.Carrinho) (itens []CartItemView, total float64, invalidos int, err error) {
+	itens = []CartItemView{}
+	if len(cart) == 0 {
+		return itens, 0, 0, nil
 	}
-	fmt.Printf("Validação Total PIX OK: Backend=%.2f, Frontend=%.2f\n", currentTotal, pixReqData.TransactionAmount)
-	// --- Fim da lógica de validação ---
-
-	// 4. CRIAR PEDIDO E ITENS NO BANCO DE DADOS (Status Pendente)
-	var pedidoCriado model.Order // Corrigido
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		externalRef := fmt.Sprintf("pedido_%d_%d", user.ID, time.Now().UnixNano())
-		pedido := model.Order{ // Corrigido
-			UsuarioID:         user.ID,
-			Status:            model.StatusPendente,
-			Total:             currentTotal,
-			MetodoPagamento:   "pix",
-			Parcelas:          1,
-			ExternalReference: externalRef,
+
+	cupcakeMap := make(map[uint]model.Cupcake)
+	if ids := cart.CupcakeIDs(); len(ids) > 0 {
+		var cupcakes []model.Cupcake
+		if err := service.ComOpcoes(database.DB).Where("id IN ? AND disponivel = ?", ids, true).Find(&cupcakes).Error; err != nil {
+			return nil, 0, 0, err
 		}
-		if err := tx.Create(&pedido).Error; err != nil {
-			return err
+		for _, cp := range cupcakes {
+			cupcakeMap[cp.ID] = cp
 		}
-		pedidoCriado = pedido
-
-		for _, item := range validItems {
-			itemPedido := model.ItemOrder{ // Corrigido
-				PedidoID:      pedido.ID,
-				CupcakeID:     item.Cupcake.ID,
-				Quantidade:    item.Quantity,
-				PrecoUnitario: item.Cupcake.Preco,
-				Subtotal:      item.Subtotal,
+	}
+	kitMap := make(map[uint]model.Kit)
+	if ids := cart.KitIDs(); len(ids) > 0 {
+		var kits []model.Kit
+		if err := service.ComComponentes(database.DB).Where("id IN ? AND disponivel = ?", ids, true).Find(&kits).Error; err != nil {
+			return nil, 0, 0, err
+		}
+		for _, k := range kits {
+			kitMap[k.ID] = k
+		}
+	}
+
+	for chave, linha := range cart {
+		if linha.Quantidade <= 0 {
+			invalidos++
+			continue
+		}
+		if linha.EhKit() {
+			kit, found := kitMap[linha.KitID]
+			if !found {
+				invalidos++
+				continue
 			}
-			if err := tx.Create(&itemPedido).Error; err != nil {
-				fmt.Printf("Erro ao criar item %d do pedido %d no DB: %v\n", item.Cupcake.ID, pedido.ID, err)
-				return errors.New("erro ao salvar os itens do pedido")
+			componentes, err := service.ComponentesKit(kit, linha)
+			if err != nil {
+				invalidos++
+				continue
 			}
+			subtotal := kit.Preco * float64(linha.Quantidade)
+			itens = append(itens, CartItemView{
+				Chave: chave, Kit: &kit, Componentes: componentes,
+				PrecoUnitario: kit.Preco, Quantity: linha.Quantidade, Subtotal: subtotal,
+			})
+			total += subtotal
+			continue
 		}
 
-		return nil
+		cupcake, found := cupcakeMap[linha.CupcakeID]
+		if !found {
+			invalidos++
+			continue
+		}
+		preco, opcoes, err := service.PrecoLinha(cupcake, linha)
+		if err != nil {
+			invalidos++
+			continue
+		}
+		subtotal := preco * float64(linha.Quantidade)
+		itens = append(itens, CartItemView{
+			Chave: chave, Cupcake: cupcake, Opcoes: opcoes,
+			PrecoUnitario: preco, Quantity: linha.Quantidade, Subtotal: subtotal,
+		})
+		total += subtotal
+	}
+
+	sort.Slice(itens, func(i, j int) bool {
+		if itens[i].Nome() != itens[j].Nome() {
+			return itens[i].Nome() < itens[j].Nome()
+		}
+		return itens[i].Chave < itens[j].Chave
 	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar o pedido no banco."})
-		return
+	return itens, total, invalidos, nil
+}
+
+// Nome é o nome exibido da linha (cupcake ou kit).
+func (item CartItemView) Nome() string {
+	if item.Kit != nil {
+		return item.Kit.Nome
 	}
-	fmt.Printf("Pedido PIX %d criado no DB (Ref: %s)\n", pedidoCriado.ID, pedidoCriado.ExternalReference)
+	return item.Cupcake.Nome
+}
 
-	// 5. CHAMAR A API DO MERCADO PAGO PARA GERAR O PIX
-	fmt.Println("Tentando criar pagamento PIX via API Mercado Pago...")
-	client := payment.NewClient(h.MPCfg)
+// ImagemThumb é a miniatura exibida da linha (cupcake ou kit).
+func (item CartItemView) ImagemThumb() string {
+	if item.Kit != nil {
+		return item.Kit.ImagemThumb()
+	}
+	return item.Cupcake.ImagemThumb()
+}
 
-	request := payment.Request{
-		TransactionAmount: currentTotal,
-		Description:       pixReqData.Description,
-		PaymentMethodID:   "pix",
-		ExternalReference: pedidoCriado.ExternalReference,
-		Payer: &payment.PayerRequest{
-			Email: pixReqData.Payer.Email, // Envia SÓ o email
-			// Bloco de identificação removido
-			FirstName: user.Nome,
-		},
-		// notification_url: "SUA_URL_WEBHOOK", // IMPORTANTE!
+// itensPedido monta os ItemOrder da linha. Kits viram um item por cupcake componente,
+// com o preço do kit rateado entre eles; linha agrupa esses componentes no pedido.
+func (item CartItemView) itensPedido(pedidoID uint, linha int) []model.ItemOrder {
+	if item.Kit == nil {
+		return []model.ItemOrder{{
+			PedidoID: pedidoID, CupcakeID: item.Cupcake.ID, Quantidade: item.Quantity,
+			PrecoUnitario: item.PrecoUnitario, Subtotal: item.Subtotal, Opcoes: item.opcoesPedido(),
+		}}
+	}
+
+	kitID := item.Kit.ID
+	subtotais := service.RatearPrecoKit(item.Subtotal, item.Componentes, item.Quantity)
+	itens := make([]model.ItemOrder, 0, len(item.Componentes))
+	for i, comp := range item.Componentes {
+		quantidade := comp.Quantidade * item.Quantity
+		itens = append(itens, model.ItemOrder{
+			PedidoID: pedidoID, CupcakeID: comp.Cupcake.ID, Quantidade: quantidade,
+			PrecoUnitario: math.Round(subtotais[i]/float64(quantidade)*100) / 100, Subtotal: subtotais[i],
+			KitID: &kitID, KitNome: item.Kit.Nome, KitLinha: linha, KitQuantidade: item.Quantity,
+		})
 	}
+	return itens
+}
 
-	// --- CORREÇÃO AQUI: REMOVIDO 'var resource ...' ---
-	resource, err := client.Create(context.Background(), request) // Usa :=
+// opcoesPedido copia as opções do item para gravação em um novo ItemOrder.
+func (item CartItemView) opcoesPedido() []model.ItemOrderOpcao {
+	opcoes := make([]model.ItemOrderOpcao, len(item.Opcoes))
+	copy(opcoes, item.Opcoes)
+	return opcoes
+}
 
-	// 6. TRATAR RESPOSTA E ENVIAR QR CODE PARA O FRONTEND
-	if err != nil {
-		fmt.Printf("Erro ao criar PIX no MP: %v\n", err)
-		database.DB.Model(&pedidoCriado).Update("status", model.StatusFalhou)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PIX com o provedor."})
-		return
+// DescricaoOpcoes resume as opções escolhidas (ou a composição do kit) para exibição
+// no carrinho e no checkout.
+func (item CartItemView) DescricaoOpcoes() string {
+	if item.Kit != nil {
+		return service.ResumoComponentes(item.Componentes)
 	}
+	return service.DescreverOpcoes(item.Opcoes)
+}
+
+// quantidadeItens soma os cupcakes dos itens válidos do carrinho (kits contam pelos
+// componentes), que é o que ocupa a capacidade de produção.
+func quantidadeItens(itens []CartItemView) int {
+	total := 0
+	for _, item := range itens {
+		if item.Kit != nil {
+			total += service.UnidadesKit(item.Componentes) * item.Quantity
+			continue
+		}
+		total += item.Quantity
+	}
+	return total
+}
 
-	if resource.Status == "pending" {
-		fmt.Println("Pagamento PIX gerado com sucesso, aguardando pagamento.")
+// linhaDoFormulario lê as opções enviadas para o cupcake: opcao_<grupoID> para grupos
+// de escolha (pode repetir) e texto_<grupoID> para grupos de texto.
+func linhaDoFormulario(c *gin.Context, cupcake model.Cupcake) service.LinhaCarrinho {
+	linha := service.LinhaCarrinho{CupcakeID: cupcake.ID}
+	for _, g := range cupcake.GruposOpcoes {
+		if g.Tipo == model.GrupoTexto {
+			if texto := strings.TrimSpace(c.PostForm(fmt.Sprintf("texto_%d", g.ID))); texto != "" {
+				if linha.Textos == nil {
+					linha.Textos = make(map[uint]string)
+				}
+				linha.Textos[g.ID] = texto
+			}
+			continue
+		}
+		for _, v := range c.PostFormArray(fmt.Sprintf("opcao_%d", g.ID)) {
+			if id, err := strconv.ParseUint(v, 10, 32); err == nil {
+				linha.Opcoes = append(linha.Opcoes, uint(id))
+			}
+		}
+	}
+	return linha
+}
 
-		mpPaymentID := int64(resource.ID)
-		database.DB.Model(&pedidoCriado).Update("PagamentoMPID", mpPaymentID)
+// carrinhoDaSessao lê o carrinho da sessão, convertendo o formato antigo (map[uint]int)
+// de sessões criadas antes das opções de personalização. Nunca retorna nil.
+func carrinhoDaSessao(session *sessions.Session) service.Carrinho {
+	switch cart := session.Values[CartSessionKey].(type) {
+	case service.Carrinho:
+		if cart != nil {
+			return cart
+		}
+	case map[uint]int:
+		return service.CarrinhoLegado(cart)
+	}
+	return service.Carrinho{}
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"status":         "pending",
-			"payment_id":     resource.ID,
-			"qr_code_base64": resource.PointOfInteraction.TransactionData.QRCodeBase64,
-			"qr_code":        resource.PointOfInteraction.TransactionData.QRCode,
-		})
-	} else {
-		fmt.Printf("Status inesperado ao gerar PIX: %s\n", resource.Status)
-		database.DB.Model(&pedidoCriado).Update("status", model.StatusFalhou)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Status inesperado do provedor de pagamento."})
+// validarEnderecoEntrega normaliza CEP e UF e confere os campos obrigatórios do endereço.
+func validarEnderecoEntrega(e EnderecoEntregaData) (EnderecoEntregaData, error) {
+	cep, err := service.NormalizarCEP(e.CEP)
+	if err != nil {
+		return e, errors.New("CEP inválido")
 	}
+	uf, err := service.NormalizarUF(e.Estado)
+	if err != nil {
+		return e, errors.New("UF inválida")
+	}
+	e.CEP = service.FormatarCEP(cep)
+	e.Estado = uf
+	e.Rua = strings.TrimSpace(e.Rua)
+	e.Numero = strings.TrimSpace(e.Numero)
+	e.Complemento = strings.TrimSpace(e.Complemento)
+	e.Bairro = strings.TrimSpace(e.Bairro)
+	e.Cidade = strings.TrimSpace(e.Cidade)
+	if e.Rua == "" || e.Numero == "" || e.Bairro == "" || e.Cidade == "" {
+		return e, errors.New("rua, número, bairro e cidade são obrigatórios")
+	}
+	return e, nil
 }
 
-// --- Funções Auxiliares ---
+// pedidoAgendado é o resultado validado de AgendamentoData + endereço.
+type pedidoAgendado struct {
+	Tipo     model.TipoEntrega
+	JanelaID uint
+	Data     time.Time
+	Entrega  EnderecoEntregaData
+}
 
-func (h *CartHandler) getUserFromSession(c *gin.Context) (model.Usuario, bool) {
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	userID, ok := session.Values["userID"].(uint)
-	if !ok {
-		return model.Usuario{}, false
+// validarAgendamento confere o tipo e o horário escolhidos; o endereço só é exigido para entrega.
+func validarAgendamento(ag AgendamentoData, e EnderecoEntregaData) (pedidoAgendado, error) {
+	agendado := pedidoAgendado{Tipo: model.TipoEntrega(ag.Tipo)}
+	if agendado.Tipo != model.TipoEntregaDelivery && agendado.Tipo != model.TipoEntregaRetirada {
+		return agendado, errors.New("escolha entrega ou retirada")
 	}
-	var user model.Usuario
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		return model.Usuario{}, false
+
+	janelaID, data, err := service.ParseOpcaoEntrega(ag.Opcao)
+	if err != nil {
+		return agendado, errors.New("escolha um horário disponível")
 	}
-	return user, true
+	agendado.JanelaID = janelaID
+	agendado.Data = data
+
+	if agendado.Tipo == model.TipoEntregaDelivery {
+		entrega, err := validarEnderecoEntrega(e)
+		if err != nil {
+			return agendado, err
+		}
+		agendado.Entrega = entrega
+	}
+	return agendado, nil
 }
 
-func getTotalCartQuantityHelper(cart map[uint]int) int {
-	if cart == nil {
-		return 0
+// reservar consome a capacidade da janela (na transação tx) e preenche os dados de entrega do pedido.
+func (a pedidoAgendado) reservar(tx *gorm.DB, pedido *model.Order, qtdCupcakes int) error {
+	janela, err := service.ReservarJanela(tx, a.JanelaID, a.Data, a.Tipo, qtdCupcakes, time.Now())
+	if err != nil {
+		return err
 	}
-	totalQuantity := 0
-	for _, quantity := range cart {
-		totalQuantity += quantity
+	data := service.Dia(a.Data)
+	pedido.TipoEntrega = a.Tipo
+	pedido.JanelaEntregaID = &janela.ID
+	pedido.DataEntrega = &data
+	if a.Tipo == model.TipoEntregaDelivery {
+		a.Entrega.aplicar(pedido)
 	}
-	return totalQuantity
+	return nil
+}
+
+// aplicar copia o endereço de entrega validado para o pedido.
+func (e EnderecoEntregaData) aplicar(pedido *model.Order) {
+	pedido.EntregaCEP = e.CEP
+	pedido.EntregaRua = e.Rua
+	pedido.EntregaNumero = e.Numero
+	pedido.EntregaComplemento = e.Complemento
+	pedido.EntregaBairro = e.Bairro
+	pedido.EntregaCidade = e.Cidade
+	pedido.EntregaEstado = e.Estado
+}
+
+func getTotalCartQuantityHelper(cart service.Carrinho) int {
+	return cart.TotalItens()
 }
@@ -17,10 +17,11 @@ import (
 	"testing"
 	"time" // Para emails únicos
 
+	appconfig "github.com/ericoliveiras/meu-cupcake/internal/config"
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/securecookie" // Para decodificar o cookie de sessão
 	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
 	"github.com/mercadopago/sdk-go/pkg/config" // Importe config
@@ -63,7 +64,7 @@ func connectDBForTest(t *testing.T) {
 	// Só conecta se ainda não estiver conectado (assume que database.DB é global)
 	if database.DB == nil {
 		fmt.Println("DEBUG (connectDBForTest): Conectando ao banco de dados...")
-		database.ConnectDB() // Assume que ConnectDB lê do .env carregado
+		database.ConnectDB(appconfig.Banco{URL: os.Getenv("DATABASE_URL")}) // DATABASE_URL vem do .env carregado
 		if database.DB == nil {
 			t.Fatal("Erro crítico: A conexão com o banco de dados (database.DB) é nula após ConnectDB.")
 		}
@@ -73,7 +74,7 @@ func connectDBForTest(t *testing.T) {
 }
 
 // setupTestRouterAndHandler: Configura o router, store, e handlers para um teste.
-func setupTestRouterAndHandler(t *testing.T) (*gin.Engine, *CartHandler, *sessions.CookieStore) {
+func setupTestRouterAndHandler(t *testing.T) (*gin.Engine, *CartHandler, *service.SessaoStore) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
@@ -82,7 +83,7 @@ func setupTestRouterAndHandler(t *testing.T) (*gin.Engine, *CartHandler, *sessio
 	if sessionSecret == "" {
 		t.Fatal("SESSION_SECRET está vazia após carregar .env")
 	}
-	store := sessions.NewCookieStore([]byte(sessionSecret))
+	store := service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, sessionSecret)
 
 	// Configuração do Mercado Pago (necessário para o CartHandler)
 	mpAccessToken := os.Getenv("MP_ACCESS_TOKEN")
@@ -103,7 +104,7 @@ func setupTestRouterAndHandler(t *testing.T) (*gin.Engine, *CartHandler, *sessio
 	router.GET("/carrinho", func(c *gin.Context) { c.Status(http.StatusOK) }) // Mock da página de redirect alternativa
 
 	// Registra o tipo do carrinho para a sessão (necessário uma vez)
-	gob.Register(map[uint]int{})
+	gob.Register(service.Carrinho{})
 
 	return router, cartHandler, store
 }
@@ -123,20 +124,30 @@ func createTestCupcake(t *testing.T) uint {
 	return cupcake.ID
 }
 
-// decodeSessionCookie: Decodifica o cookie de sessão para verificar seu conteúdo.
-func decodeSessionCookie(t *testing.T, cookie *http.Cookie, store *sessions.CookieStore) map[interface{}]interface{} {
-	session := sessions.NewSession(store, "meu-cupcake-session") // Usa o mesmo nome de cookie
-	// Usa securecookie (interno do gorilla/sessions) para decodificar
-	// NOTA: Isso depende de implementação interna e pode quebrar em futuras versões do gorilla/sessions.
-	// Uma alternativa é fazer outra requisição HTTP para uma rota de teste que leia a sessão.
-	err := securecookie.DecodeMulti(session.Name(), cookie.Value, &session.Values, store.Codecs...)
-	if err != nil {
-		t.Errorf("Erro ao decodificar o cookie de sessão: %v", err)
+// decodeSessionCookie: Lê no store a sessão apontada pelo cookie (o cookie leva só o ID).
+func decodeSessionCookie(t *testing.T, cookie *http.Cookie, store *service.SessaoStore) map[interface{}]interface{} {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	session, err := store.New(req, "meu-cupcake-session")
+	if err != nil || session.IsNew {
+		t.Errorf("Erro ao ler a sessão do cookie: %v (nova: %v)", err, session.IsNew)
 		return nil
 	}
 	return session.Values
 }
 
+// encodeSessionCookie: Grava uma sessão com os valores dados e devolve o cookie dela.
+func encodeSessionCookie(t *testing.T, store *service.SessaoStore, values map[interface{}]interface{}) *http.Cookie {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	session, _ := store.New(req, "meu-cupcake-session")
+	session.Values = values
+	if err := session.Save(req, recorder); err != nil {
+		t.Fatalf("Erro ao gravar a sessão inicial: %v", err)
+	}
+	return recorder.Result().Cookies()[0]
+}
+
 // --- Teste Principal para AddToCart ---
 func TestAddToCart(t *testing.T) {
 	loadEnvForTest(t)
@@ -186,13 +197,13 @@ func TestAddToCart(t *testing.T) {
 			t.Fatalf("Chave '%s' não encontrada na sessão", CartSessionKey)
 		}
 
-		cart, ok := cartData.(map[uint]int)
+		cart, ok := cartData.(service.Carrinho)
 		if !ok {
-			t.Fatalf("Carrinho na sessão não é do tipo map[uint]int")
+			t.Fatalf("Carrinho na sessão não é do tipo service.Carrinho")
 		}
 
-		// Verifica se o item foi adicionado com quantidade 1
-		if quantity, itemExists := cart[cupcakeID]; !itemExists || quantity != 1 {
+		// Verifica se o item (sem opções, chave = ID) foi adicionado com quantidade 1
+		if linha, itemExists := cart[cupcakeIDStr]; !itemExists || linha.Quantidade != 1 {
 			t.Errorf("Item %d não foi adicionado corretamente ao carrinho. Carrinho: %v", cupcakeID, cart)
 		}
 		if len(cart) != 1 {
@@ -203,12 +214,10 @@ func TestAddToCart(t *testing.T) {
 	// --- Cenário 2: Adicionar o mesmo item novamente (incrementar) ---
 	t.Run("Incrementar Item Existente", func(t *testing.T) {
 		// Simula um estado inicial da sessão com o item já adicionado uma vez
-		initialCart := map[uint]int{cupcakeID: 1}
-		initialSession := sessions.NewSession(store, "meu-cupcake-session")
-		initialSession.Values[CartSessionKey] = initialCart
-		// Precisamos codificar este estado inicial em um cookie para enviar na requisição
-		encoded, _ := securecookie.EncodeMulti(initialSession.Name(), initialSession.Values, store.Codecs...)
-		cookieToSend := &http.Cookie{Name: initialSession.Name(), Value: encoded}
+		initialCart := service.Carrinho{}
+		initialCart.Adicionar(service.LinhaCarrinho{CupcakeID: cupcakeID}, 1)
+		// Precisamos gravar este estado inicial e enviar o cookie dele na requisição
+		cookieToSend := encodeSessionCookie(t, store, map[interface{}]interface{}{CartSessionKey: initialCart})
 
 		req := httptest.NewRequest(http.MethodPost, "/carrinho/adicionar/"+cupcakeIDStr, nil)
 		req.AddCookie(cookieToSend) // Envia o cookie com o carrinho pré-existente
@@ -233,14 +242,14 @@ func TestAddToCart(t *testing.T) {
 		if !exists {
 			t.Fatalf("Chave '%s' não encontrada na sessão", CartSessionKey)
 		}
-		cart, ok := cartData.(map[uint]int)
+		cart, ok := cartData.(service.Carrinho)
 		if !ok {
-			t.Fatalf("Carrinho na sessão não é do tipo map[uint]int")
+			t.Fatalf("Carrinho na sessão não é do tipo service.Carrinho")
 		}
 
 		// Verifica se a quantidade foi incrementada para 2
-		if quantity, itemExists := cart[cupcakeID]; !itemExists || quantity != 2 {
-			t.Errorf("Item %d não foi incrementado corretamente. Esperado: 2, Obtido: %d. Carrinho: %v", cupcakeID, quantity, cart)
+		if linha, itemExists := cart[cupcakeIDStr]; !itemExists || linha.Quantidade != 2 {
+			t.Errorf("Item %d não foi incrementado corretamente. Esperado: 2, Obtido: %d. Carrinho: %v", cupcakeID, linha.Quantidade, cart)
 		}
 		if len(cart) != 1 { // Ainda deve ter apenas 1 tipo de item
 			t.Errorf("Carrinho deveria ter 1 tipo de item, mas tem %d. Carrinho: %v", len(cart), cart)
@@ -250,11 +259,9 @@ func TestAddToCart(t *testing.T) {
 	// --- Cenário 3: Adicionar item vindo do carrinho (redirect para carrinho) ---
 	t.Run("Adicionar Item Vindo do Carrinho", func(t *testing.T) {
 		// Simula um estado inicial da sessão
-		initialCart := map[uint]int{cupcakeID: 1}
-		initialSession := sessions.NewSession(store, "meu-cupcake-session")
-		initialSession.Values[CartSessionKey] = initialCart
-		encoded, _ := securecookie.EncodeMulti(initialSession.Name(), initialSession.Values, store.Codecs...)
-		cookieToSend := &http.Cookie{Name: initialSession.Name(), Value: encoded}
+		initialCart := service.Carrinho{}
+		initialCart.Adicionar(service.LinhaCarrinho{CupcakeID: cupcakeID}, 1)
+		cookieToSend := encodeSessionCookie(t, store, map[interface{}]interface{}{CartSessionKey: initialCart})
 
 		// Simula o envio do campo oculto 'return_to=cart'
 		formData := url.Values{}
@@ -286,12 +293,12 @@ func TestAddToCart(t *testing.T) {
 		if !exists {
 			t.Fatalf("Chave '%s' não encontrada na sessão", CartSessionKey)
 		}
-		cart, ok := cartData.(map[uint]int)
+		cart, ok := cartData.(service.Carrinho)
 		if !ok {
-			t.Fatalf("Carrinho na sessão não é do tipo map[uint]int")
+			t.Fatalf("Carrinho na sessão não é do tipo service.Carrinho")
 		}
-		if quantity, itemExists := cart[cupcakeID]; !itemExists || quantity != 2 {
-			t.Errorf("Item %d não foi incrementado (vindo do carrinho). Esperado: 2, Obtido: %d. Carrinho: %v", cupcakeID, quantity, cart)
+		if linha, itemExists := cart[cupcakeIDStr]; !itemExists || linha.Quantidade != 2 {
+			t.Errorf("Item %d não foi incrementado (vindo do carrinho). Esperado: 2, Obtido: %d. Carrinho: %v", cupcakeID, linha.Quantidade, cart)
 		}
 	})
 
@@ -313,7 +320,7 @@ func TestAddToCart(t *testing.T) {
 }
 
 // Função auxiliar para criar uma sessão de teste com um carrinho
-func createTestSessionWithCart(store sessions.Store, req *http.Request, cart map[uint]int) *sessions.Session {
+func createTestSessionWithCart(store sessions.Store, req *http.Request, cart service.Carrinho) *sessions.Session {
 	session, _ := store.Get(req, "meu-cupcake-session")
 	session.Values[CartSessionKey] = cart
 	return session
@@ -321,7 +328,7 @@ func createTestSessionWithCart(store sessions.Store, req *http.Request, cart map
 
 // TestGetTotalCartQuantity testa a função getTotalCartQuantityHelper
 func TestGetTotalCartQuantityHelper(t *testing.T) {
-	store := sessions.NewCookieStore([]byte("secret-key-for-test"))
+	store := service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "secret-key-for-test")
 	req := httptest.NewRequest("GET", "/", nil)
 
 	// --- Cenário 1: Carrinho Nulo (ou não existe na sessão) ---
@@ -329,7 +336,7 @@ func TestGetTotalCartQuantityHelper(t *testing.T) {
 		session, _ := store.Get(req, "meu-cupcake-session")
 		// Extrai o carrinho (que será nil ou não ok neste caso)
 		cartData := session.Values[CartSessionKey]
-		cart, _ := cartData.(map[uint]int) // Ignora o 'ok' pois esperamos falha ou nil
+		cart, _ := cartData.(service.Carrinho) // Ignora o 'ok' pois esperamos falha ou nil
 
 		expected := 0
 		actual := getTotalCartQuantityHelper(cart) // Passa o mapa (nil)
@@ -341,11 +348,11 @@ func TestGetTotalCartQuantityHelper(t *testing.T) {
 
 	// --- Cenário 2: Carrinho Vazio ---
 	t.Run("Carrinho Vazio", func(t *testing.T) {
-		cartMap := make(map[uint]int) // Cria o mapa vazio
+		cartMap := service.Carrinho{} // Cria o mapa vazio
 		session := createTestSessionWithCart(store, req, cartMap)
 		// Extrai o carrinho da sessão
 		cartData := session.Values[CartSessionKey]
-		cart, ok := cartData.(map[uint]int)
+		cart, ok := cartData.(service.Carrinho)
 		if !ok {
 			t.Fatalf("Falha ao extrair mapa do carrinho vazio da sessão") // Segurança
 		}
@@ -360,15 +367,14 @@ func TestGetTotalCartQuantityHelper(t *testing.T) {
 
 	// --- Cenário 3: Carrinho com Itens ---
 	t.Run("Carrinho com Itens", func(t *testing.T) {
-		cartMap := map[uint]int{ // Cria o mapa com itens
-			1: 2,
-			5: 3,
-			8: 1,
-		}
+		cartMap := service.Carrinho{} // Cria o mapa com itens
+		cartMap.Adicionar(service.LinhaCarrinho{CupcakeID: 1}, 2)
+		cartMap.Adicionar(service.LinhaCarrinho{CupcakeID: 5}, 3)
+		cartMap.Adicionar(service.LinhaCarrinho{CupcakeID: 8, Opcoes: []uint{4}}, 1)
 		session := createTestSessionWithCart(store, req, cartMap)
 		// Extrai o carrinho da sessão
 		cartData := session.Values[CartSessionKey]
-		cart, ok := cartData.(map[uint]int)
+		cart, ok := cartData.(service.Carrinho)
 		if !ok {
 			t.Fatalf("Falha ao extrair mapa do carrinho com itens da sessão") // Segurança
 		}
@@ -387,7 +393,7 @@ func TestGetTotalCartQuantityHelper(t *testing.T) {
 		session.Values[CartSessionKey] = "não é um mapa" // Coloca valor inválido
 		// Extrai o carrinho (que falhará na conversão)
 		cartData := session.Values[CartSessionKey]
-		cart, _ := cartData.(map[uint]int) // Ignora o 'ok'
+		cart, _ := cartData.(service.Carrinho) // Ignora o 'ok'
 
 		expected := 0
 		actual := getTotalCartQuantityHelper(cart) // Passa o mapa (nil)
@@ -0,0 +1,43 @@
+// /internal/handler/cep_handler.go
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CEPHandler expõe a consulta de endereço por CEP para os formulários de perfil e checkout.
+type CEPHandler struct {
+	Provider service.CEPProvider
+}
+
+// BuscarCEP retorna o endereço do CEP informado em JSON (mesmo formato de AddToCart).
+func (h *CEPHandler) BuscarCEP(c *gin.Context) {
+	cep, err := service.NormalizarCEP(c.Param("cep"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "CEP inválido. Informe 8 dígitos."})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	endereco, err := h.Provider.BuscarCEP(ctx, cep)
+	if err != nil {
+		if errors.Is(err, service.ErrCEPNaoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "CEP não encontrado."})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Erro ao consultar CEP", "cep", cep, "erro", err)
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "Não foi possível consultar o CEP agora."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "endereco": endereco})
+}
@@ -0,0 +1,198 @@
+// /internal/handler/checkout.go
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/mercadopago/sdk-go/pkg/config"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// Passos do pagamento comuns ao checkout do site (cartão e PIX) e à API: validar o
+// carrinho, gravar o pedido pendente e cobrar no Mercado Pago.
+
+var (
+	errCarrinhoVazio      = errors.New("carrinho vazio")
+	errItensIndisponiveis = errors.New("um ou mais itens no seu carrinho não estão mais disponíveis")
+	// errPixStatus é um PIX que o Mercado Pago não devolveu como pendente
+	errPixStatus = errors.New("status inesperado do provedor de pagamento")
+)
+
+// checkoutValidado é o carrinho conferido contra o catálogo, com o cupom aplicado.
+type checkoutValidado struct {
+	Itens    []CartItemView
+	Total    float64 // Já com o desconto
+	Cupom    *model.Cupom
+	Desconto float64
+}
+
+// prepararCheckout confere os itens do carrinho e o cupom (codigoCupom vazio = sem cupom).
+// Cupom inválido volta com o erro de service (ver service.ErroDeCupom).
+func prepararCheckout(cart service.Carrinho, codigoCupom string, usuarioID uint) (checkoutValidado, error) {
+	if len(cart) == 0 {
+		return checkoutValidado{}, errCarrinhoVazio
+	}
+	itens, total, invalidos, err := loadCartItems(cart)
+	if err != nil {
+		return checkoutValidado{}, fmt.Errorf("buscar os produtos: %w", err)
+	}
+	if invalidos > 0 || len(itens) == 0 {
+		return checkoutValidado{}, errItensIndisponiveis
+	}
+	cupom, desconto, err := validarCodigoCupom(codigoCupom, usuarioID, itens)
+	if err != nil {
+		return checkoutValidado{}, err
+	}
+	return checkoutValidado{Itens: itens, Total: total - desconto, Cupom: cupom, Desconto: desconto}, nil
+}
+
+// gravarPedido cria o pedido pendente e os itens em uma transação, reservando o horário
+// de entrega e o uso do cupom. Horário lotado volta como service.ErrJanelaLotada ou
+// service.ErrJanelaIndisponivel.
+func gravarPedido(ctx context.Context, usuarioID uint, co checkoutValidado, agendamento pedidoAgendado, metodo string, parcelas int) (model.Order, error) {
+	var pedidoCriado model.Order
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		pedido := model.Order{
+			UsuarioID:         usuarioID,
+			Status:            model.StatusPendente,
+			Total:             co.Total,
+			MetodoPagamento:   metodo,
+			Parcelas:          parcelas,
+			ExternalReference: fmt.Sprintf("pedido_%d_%d", usuarioID, time.Now().UnixNano()),
+		}
+		if err := agendamento.reservar(tx, &pedido, quantidadeItens(co.Itens)); err != nil {
+			return err
+		}
+		if err := aplicarCupomPedido(tx, &pedido, co.Cupom, co.Desconto, co.Itens); err != nil {
+			return err
+		}
+		if err := tx.Create(&pedido).Error; err != nil {
+			return fmt.Errorf("criar o cabeçalho do pedido: %w", err)
+		}
+		pedidoCriado = pedido
+		for i, item := range co.Itens {
+			for _, itemPedido := range item.itensPedido(pedido.ID, i+1) {
+				if err := tx.Create(&itemPedido).Error; err != nil {
+					slog.ErrorContext(ctx, "Erro ao criar item do pedido no DB", "cupcake_id", itemPedido.CupcakeID, "pedido_id", pedido.ID, "erro", err)
+					return errors.New("erro ao salvar os itens do pedido")
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+	slog.InfoContext(ctx, "Pedido criado no DB", "pedido_id", pedidoCriado.ID, "referencia", pedidoCriado.ExternalReference, "metodo", metodo)
+	return pedidoCriado, nil
+}
+
+// resultadoCartao é o desfecho da cobrança no cartão, no formato devolvido ao cliente.
+type resultadoCartao struct {
+	Status      string // "approved", "pending" ou "rejected"
+	Mensagem    string
+	PagamentoID *int64
+}
+
+// cobrarCartao cria o pagamento do pedido no Mercado Pago e atualiza o pedido com o
+// resultado (histórico, eventos e liberação do horário quando falha). O valor cobrado é
+// sempre o total do pedido.
+func cobrarCartao(ctx context.Context, cfg *config.Config, pedido *model.Order, dados PaymentRequestData) resultadoCartao {
+	request := payment.Request{
+		TransactionAmount: pedido.Total,
+		Token:             dados.Token,
+		Description:       dados.Description,
+		Installments:      dados.Installments,
+		PaymentMethodID:   dados.PaymentMethodID,
+		IssuerID:          dados.IssuerID,
+		ExternalReference: pedido.ExternalReference,
+		Payer: &payment.PayerRequest{
+			Email: dados.Payer.Email,
+			Identification: &payment.IdentificationRequest{
+				Type:   dados.Payer.Identification.Type,
+				Number: dados.Payer.Identification.Number,
+			},
+		},
+	}
+	// Sem o cancelamento da requisição: um pagamento criado no provedor precisa ser
+	// registrado no pedido mesmo que o cliente desconecte
+	resource, err := payment.NewClient(cfg).Create(context.WithoutCancel(ctx), request)
+
+	statusPedido := model.StatusFalhou
+	resultado := resultadoCartao{Status: "rejected", Mensagem: "Pagamento recusado ou pendente."}
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro MP", "erro", err)
+		resultado.Mensagem = "Erro ao processar pagamento com o provedor."
+	} else {
+		slog.InfoContext(ctx, "Resposta do Mercado Pago", "status", resource.Status, "detalhe", resource.StatusDetail, "pagamento_id", resource.ID)
+		metricas.RegistrarPagamento("cartao", resource.Status)
+		id := int64(resource.ID)
+		resultado.PagamentoID = &id
+		switch resource.Status {
+		case "approved":
+			statusPedido = model.StatusPago
+			resultado.Status, resultado.Mensagem = "approved", "Pagamento aprovado!"
+		case "in_process", "pending":
+			statusPedido = model.StatusPendente
+			resultado.Status, resultado.Mensagem = "pending", "Pagamento pendente."
+		default:
+			resultado.Mensagem = fmt.Sprintf("Pagamento não aprovado (%s).", resource.StatusDetail)
+		}
+	}
+
+	if err := database.DB.Model(pedido).Updates(model.Order{Status: statusPedido, PagamentoMPID: resultado.PagamentoID}).Error; err != nil {
+		slog.ErrorContext(ctx, "Erro crítico ao atualizar o pedido no DB", "pedido_id", pedido.ID, "erro", err)
+	} else if statusPedido != model.StatusPendente {
+		if err := service.RegistrarStatusPedido(database.DB, pedido.ID, statusPedido); err != nil {
+			slog.ErrorContext(ctx, "Erro ao registrar histórico do pedido", "pedido_id", pedido.ID, "erro", err)
+		}
+		service.PublicarStatusPedido(*pedido)
+	}
+	if statusPedido == model.StatusFalhou {
+		if err := service.LiberarJanela(database.DB, *pedido); err != nil {
+			slog.ErrorContext(ctx, "Erro ao liberar janela de entrega do pedido", "pedido_id", pedido.ID, "erro", err)
+		}
+	}
+	return resultado
+}
+
+// gerarPix cria o pagamento PIX do pedido no Mercado Pago e grava o ID do pagamento.
+// Se o provedor falhar ou não devolver o PIX pendente, o pedido é marcado como falho.
+func gerarPix(ctx context.Context, cfg *config.Config, pedido *model.Order, usuario model.Usuario, email, descricao string) (*payment.Response, error) {
+	request := payment.Request{
+		TransactionAmount: pedido.Total,
+		Description:       descricao,
+		PaymentMethodID:   "pix",
+		ExternalReference: pedido.ExternalReference,
+		Payer:             &payment.PayerRequest{Email: email, FirstName: usuario.Nome},
+	}
+	resource, err := payment.NewClient(cfg).Create(context.WithoutCancel(ctx), request)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao criar PIX no MP", "pedido_id", pedido.ID, "erro", err)
+		service.AtualizarStatusPedido(database.DB, pedido, model.StatusFalhou)
+		return nil, err
+	}
+	if resource.Status != "pending" {
+		slog.WarnContext(ctx, "Status inesperado ao gerar PIX", "pedido_id", pedido.ID, "status", resource.Status)
+		metricas.RegistrarPagamento("pix", resource.Status)
+		service.AtualizarStatusPedido(database.DB, pedido, model.StatusFalhou)
+		return nil, errPixStatus
+	}
+
+	slog.InfoContext(ctx, "Pagamento PIX gerado com sucesso, aguardando pagamento.", "pedido_id", pedido.ID)
+	mpPaymentID := int64(resource.ID)
+	pedido.PagamentoMPID = &mpPaymentID
+	if err := database.DB.Model(pedido).Update("PagamentoMPID", mpPaymentID).Error; err != nil {
+		slog.ErrorContext(ctx, "Erro ao gravar o ID do pagamento PIX", "pedido_id", pedido.ID, "erro", err)
+	}
+	return resource, nil
+}
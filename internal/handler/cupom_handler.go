@@ -0,0 +1,114 @@
+// /internal/handler/cupom_handler.go
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+// CupomSessionKey guarda na sessão o código do cupom aplicado no carrinho.
+const CupomSessionKey = "cupom_codigo"
+
+// itensParaDesconto converte os itens do carrinho para o cálculo de desconto.
+func itensParaDesconto(itens []CartItemView) []service.ItemDesconto {
+	resultado := make([]service.ItemDesconto, 0, len(itens))
+	for _, item := range itens {
+		resultado = append(resultado, service.ItemDesconto{CupcakeID: item.Cupcake.ID, Subtotal: item.Subtotal})
+	}
+	return resultado
+}
+
+// cupomDaSessao valida o cupom guardado na sessão para os itens do carrinho.
+// Retorna nil (sem erro) quando nenhum cupom foi aplicado.
+func cupomDaSessao(session *sessions.Session, usuarioID uint, itens []CartItemView) (*model.Cupom, float64, error) {
+	codigo, _ := session.Values[CupomSessionKey].(string)
+	return validarCodigoCupom(codigo, usuarioID, itens)
+}
+
+// validarCodigoCupom valida um código de cupom para os itens; código vazio é "sem cupom".
+func validarCodigoCupom(codigo string, usuarioID uint, itens []CartItemView) (*model.Cupom, float64, error) {
+	if codigo == "" {
+		return nil, 0, nil
+	}
+	cupom, desconto, err := service.ValidarCupom(database.DB, codigo, usuarioID, itensParaDesconto(itens), time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+	return &cupom, desconto, nil
+}
+
+// ApplyCoupon valida o código digitado no carrinho e o guarda na sessão.
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	codigo := service.NormalizarCodigoCupom(c.PostForm("codigo"))
+	if codigo == "" {
+		adicionarFlash(session, FlashErro, "Informe o código do cupom.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/carrinho")
+		return
+	}
+
+	user, _ := UsuarioAtual(c)
+	itens, _, _, err := loadCartItems(carrinhoDaSessao(session))
+	if err != nil {
+		adicionarFlash(session, FlashErro, "Não foi possível verificar o carrinho. Tente novamente.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/carrinho")
+		return
+	}
+
+	_, desconto, err := service.ValidarCupom(database.DB, codigo, user.ID, itensParaDesconto(itens), time.Now())
+	if err != nil {
+		if !service.ErroDeCupom(err) {
+			slog.ErrorContext(c.Request.Context(), "Erro ao validar cupom", "cupom", codigo, "erro", err)
+		}
+		adicionarFlash(session, FlashErro, service.MensagemCupom(err))
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/carrinho")
+		return
+	}
+
+	session.Values[CupomSessionKey] = codigo
+	adicionarFlash(session, FlashSucesso, fmt.Sprintf("Cupom %s aplicado: R$ %.2f de desconto.", codigo, desconto))
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/carrinho")
+}
+
+// RemoveCoupon remove o cupom aplicado no carrinho.
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	delete(session.Values, CupomSessionKey)
+	adicionarFlash(session, FlashSucesso, "Cupom removido.")
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/carrinho")
+}
+
+// aplicarCupomPedido revalida o cupom dentro da transação do pedido (com a linha do cupom
+// bloqueada) e registra o desconto no pedido. O desconto precisa bater com o que foi
+// comparado ao total enviado pelo frontend.
+func aplicarCupomPedido(tx *gorm.DB, pedido *model.Order, cupom *model.Cupom, desconto float64, itens []CartItemView) error {
+	if cupom == nil {
+		return nil
+	}
+	cupomTx, descontoTx, err := service.ValidarCupom(tx, cupom.Codigo, pedido.UsuarioID, itensParaDesconto(itens), time.Now())
+	if err != nil {
+		return err
+	}
+	if math.Abs(descontoTx-desconto) > 0.009 {
+		return service.ErrCupomDescontoAlterou
+	}
+	pedido.CupomID = &cupomTx.ID
+	pedido.CodigoCupom = cupomTx.Codigo
+	pedido.Desconto = descontoTx
+	return nil
+}
@@ -0,0 +1,166 @@
+// /internal/handler/favorito_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FavoritoView é um favorito na página do cliente.
+type FavoritoView struct {
+	ID       uint // ID do cupcake, mesmo que ele já tenha sido apagado
+	Cupcake  model.Cupcake
+	Situacao service.SituacaoFavorito
+	Direto   bool // Pode ir ao carrinho sem personalização
+}
+
+// favoritosView monta a lista de favoritos com a situação de cada cupcake.
+func favoritosView(favoritos []model.Favorito) []FavoritoView {
+	views := make([]FavoritoView, len(favoritos))
+	for i, f := range favoritos {
+		views[i] = FavoritoView{ID: f.CupcakeID, Cupcake: f.Cupcake, Situacao: service.SituacaoCupcake(f.Cupcake)}
+		if views[i].Situacao == service.FavoritoDisponivel {
+			// Sem escolhas obrigatórias o cupcake entra no carrinho como está
+			_, _, err := service.PrecoLinha(f.Cupcake, service.LinhaCarrinho{CupcakeID: f.Cupcake.ID})
+			views[i].Direto = err == nil
+		}
+	}
+	return views
+}
+
+// ToggleFavorito adiciona ou retira um cupcake dos favoritos e retorna JSON, como o
+// AddToCart. Sem login responde 401 com o endereço da página de login.
+func (h *HomeHandler) ToggleFavorito(c *gin.Context) {
+	user, isLoggedIn := UsuarioAtual(c)
+	if !isLoggedIn || user.Tipo != model.RoleCliente {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Entre na sua conta de cliente para salvar favoritos.",
+			"login":   "/login",
+		})
+		return
+	}
+
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "ID do cupcake inválido."})
+		return
+	}
+
+	// Cupcakes excluídos ainda podem sair da lista, mas não entrar nela
+	cupcakeID := uint(id64)
+	var cupcake model.Cupcake
+	if err := database.DB.Unscoped().First(&cupcake, cupcakeID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao buscar o cupcake."})
+		return
+	}
+	var favoritado bool
+	if service.SituacaoCupcake(cupcake) == service.FavoritoRemovido {
+		var estava bool
+		estava, err = service.RemoverFavorito(database.DB, user.ID, cupcakeID)
+		if err == nil && !estava {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Cupcake não encontrado."})
+			return
+		}
+	} else {
+		favoritado, err = service.AlternarFavorito(database.DB, user.ID, cupcakeID)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao alterar favorito do cliente", "usuario_id", user.ID, "erro", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Erro ao salvar o favorito."})
+		return
+	}
+
+	message := "Cupcake removido dos favoritos."
+	if favoritado {
+		message = "Cupcake salvo nos favoritos!"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  message,
+		"favorito": favoritado,
+	})
+}
+
+// ShowFavoritosPage lista os favoritos do cliente, indicando os que saíram da vitrine.
+func (h *HomeHandler) ShowFavoritosPage(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+
+	favoritos, err := service.FavoritosDoCliente(database.DB, user.ID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao carregar os favoritos.")
+		return
+	}
+
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	itens := favoritosView(favoritos)
+	diretos := 0
+	for _, item := range itens {
+		if item.Direto {
+			diretos++
+		}
+	}
+
+	c.HTML(http.StatusOK, "cliente_favoritos.html", novaPagina(c, session, "favoritos", gin.H{
+		"Favoritos": itens,
+		"Diretos":   diretos,
+	}))
+}
+
+// MoverFavoritosParaCarrinho coloca no carrinho todos os favoritos que não precisam de
+// personalização e os retira da lista. Os demais continuam nos favoritos.
+func (h *HomeHandler) MoverFavoritosParaCarrinho(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	favoritos, err := service.FavoritosDoCliente(database.DB, user.ID)
+	if err != nil {
+		adicionarFlash(session, FlashErro, "Erro ao carregar os favoritos.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/cliente/favoritos")
+		return
+	}
+
+	cart := carrinhoDaSessao(session)
+	var movidos []uint
+	for _, item := range favoritosView(favoritos) {
+		if !item.Direto {
+			continue
+		}
+		cart.Adicionar(service.LinhaCarrinho{CupcakeID: item.Cupcake.ID}, 1)
+		movidos = append(movidos, item.Cupcake.ID)
+	}
+
+	if len(movidos) == 0 {
+		adicionarFlash(session, FlashErro, "Nenhum favorito pode ir direto ao carrinho.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/cliente/favoritos")
+		return
+	}
+
+	msg := fmt.Sprintf("%d favorito(s) movido(s) para o carrinho.", len(movidos))
+	if restantes := len(favoritos) - len(movidos); restantes > 0 {
+		msg += fmt.Sprintf(" %d continua(m) na lista por precisar de personalização ou estar indisponível.", restantes)
+	}
+	session.Values[CartSessionKey] = cart
+	adicionarFlash(session, FlashSucesso, msg)
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao salvar o carrinho.")
+		return
+	}
+	// O carrinho já foi salvo; se a limpeza falhar, os favoritos apenas continuam na lista
+	if err := database.DB.Where("usuario_id = ? AND cupcake_id IN ?", user.ID, movidos).Delete(&model.Favorito{}).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao limpar favoritos do cliente", "usuario_id", user.ID, "erro", err)
+	}
+
+	c.Redirect(http.StatusSeeOther, "/cliente/favoritos")
+}
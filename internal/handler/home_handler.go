@@ -3,13 +3,14 @@ package handler
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/mercadopago/sdk-go/pkg/config"
@@ -18,42 +19,22 @@ import (
 )
 
 type HomeHandler struct {
-	Store *sessions.CookieStore
-	MPCfg *config.Config
-}
-
-// getUserFromSession é uma função auxiliar para buscar os dados do usuário logado.
-func (h *HomeHandler) getUserFromSession(c *gin.Context) (model.Usuario, bool) {
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	userID, ok := session.Values["userID"].(uint)
-	if !ok {
-		return model.Usuario{}, false
-	}
-
-	var user model.Usuario
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		return model.Usuario{}, false
-	}
-	return user, true
+	Store   *service.SessaoStore
+	MPCfg   *config.Config
+	Imagens *service.ProcessadorImagens // Fotos das avaliações
+	// Encerrando é fechado quando o servidor começa a desligar: os streams de eventos
+	// terminam para não segurar o desligamento (o navegador reconecta sozinho)
+	Encerrando <-chan struct{}
 }
 
 // getTotalCartQuantity é uma função auxiliar para somar as quantidades no carrinho.
 func getTotalCartQuantity(session *sessions.Session) int {
-	cartData := session.Values[CartSessionKey]
-	cart, ok := cartData.(map[uint]int)
-	if !ok {
-		return 0
-	}
-	totalQuantity := 0
-	for _, quantity := range cart {
-		totalQuantity += quantity
-	}
-	return totalQuantity
+	return carrinhoDaSessao(session).TotalItens()
 }
 
 // ShowHomePage renderiza a página inicial ou redireciona se logado.
 func (h *HomeHandler) ShowHomePage(c *gin.Context) {
-	user, isLoggedIn := h.getUserFromSession(c)
+	user, isLoggedIn := UsuarioAtual(c)
 
 	if isLoggedIn {
 		switch user.Tipo {
@@ -67,39 +48,15 @@ func (h *HomeHandler) ShowHomePage(c *gin.Context) {
 	}
 
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartCount := getTotalCartQuantity(session)
-
-	c.HTML(http.StatusOK, "index.html", gin.H{
-		"IsLoggedIn":    isLoggedIn,
-		"User":          user,
-		"CartItemCount": cartCount,
-	})
+	c.HTML(http.StatusOK, "index.html", novaPagina(c, session, "inicio", nil))
 }
 
 // ShowProfilePage renderiza a página de perfil apropriada.
 func (h *HomeHandler) ShowProfilePage(c *gin.Context) {
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
+	user, _ := UsuarioAtual(c)
 
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, _ := cartData.(map[uint]int)
-	cartCount := getTotalCartQuantityHelper(cart)
-
-	flashesSuccess := session.Flashes("success")
-	flashesError := session.Flashes("error")
-	err := session.Save(c.Request, c.Writer)
-	if err != nil {
-		fmt.Printf("AVISO: Erro ao salvar sessão em ShowProfilePage: %v\n", err)
-	}
-
-	data := gin.H{
-		"IsLoggedIn":     true,
-		"User":           user,
-		"CartItemCount":  cartCount,
-		"FlashesSuccess": flashesSuccess,
-		"FlashesError":   flashesError,
-	}
+	data := novaPagina(c, session, "perfil", nil)
 
 	switch user.Tipo {
 	case model.RoleLojista:
@@ -111,101 +68,129 @@ func (h *HomeHandler) ShowProfilePage(c *gin.Context) {
 	}
 }
 
+// avaliacoesPorCupcake é quantas avaliações recentes aparecem no modal da vitrine.
+const avaliacoesPorCupcake = 3
+
+// CupcakeVitrine junta o cupcake com a nota média e as avaliações exibidas no modal.
+type CupcakeVitrine struct {
+	model.Cupcake
+	Avaliacao  service.ResumoAvaliacao
+	Avaliacoes []model.Avaliacao
+	Favorito   bool // Está nos favoritos do cliente logado
+}
+
+// cupcakesVitrine carrega as avaliações aprovadas dos cupcakes. Em caso de erro,
+// os cupcakes voltam sem avaliações.
+func cupcakesVitrine(cupcakes []model.Cupcake) ([]CupcakeVitrine, error) {
+	vitrine := make([]CupcakeVitrine, len(cupcakes))
+	ids := make([]uint, len(cupcakes))
+	for i, cp := range cupcakes {
+		vitrine[i].Cupcake = cp
+		ids[i] = cp.ID
+	}
+
+	resumos, err := service.ResumosAvaliacoes(database.DB, ids)
+	if err != nil {
+		return vitrine, err
+	}
+	recentes, err := service.AvaliacoesRecentes(database.DB, ids, avaliacoesPorCupcake)
+	if err != nil {
+		return vitrine, err
+	}
+	for i := range vitrine {
+		vitrine[i].Avaliacao = resumos[vitrine[i].ID]
+		vitrine[i].Avaliacoes = recentes[vitrine[i].ID]
+	}
+	return vitrine, nil
+}
+
 func (h *HomeHandler) ShowVitrinePage(c *gin.Context) {
 	var cupcakes []model.Cupcake
-	if err := database.DB.Where("disponivel = ?", true).Order("created_at desc").Find(&cupcakes).Error; err != nil {
+	if err := service.ComGaleria(service.ComOpcoes(database.DB)).Where("disponivel = ?", true).Order("created_at desc").Find(&cupcakes).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Não foi possível carregar a vitrine.")
+		return
+	}
+	var kits []model.Kit
+	if err := service.ComComponentes(database.DB).Where("disponivel = ?", true).Order("created_at desc").Find(&kits).Error; err != nil {
 		c.String(http.StatusInternalServerError, "Não foi possível carregar a vitrine.")
 		return
 	}
 
-	user, isLoggedIn := h.getUserFromSession(c)
-	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartCount := getTotalCartQuantity(session)
-
-	flashesSuccess := session.Flashes("success")
-
-	err := session.Save(c.Request, c.Writer)
+	vitrine, err := cupcakesVitrine(cupcakes)
 	if err != nil {
-		fmt.Printf("AVISO: Erro ao salvar sessão após ler flashes em ShowVitrinePage: %v\n", err)
+		// Sem as notas a vitrine continua funcionando
+		slog.ErrorContext(c.Request.Context(), "Erro ao carregar avaliações da vitrine", "erro", err)
 	}
-	// ---------------------------------------------
-
-	c.HTML(http.StatusOK, "vitrine.html", gin.H{
-		"Cupcakes":      cupcakes,
-		"IsLoggedIn":    isLoggedIn,
-		"User":          user,
-		"ActivePage":    "vitrine",
-		"CartItemCount": cartCount,
-		"Flashes":       flashesSuccess,
-	})
+	ordem := c.Query("ordem")
+	if ordem == "avaliacao" {
+		slices.SortStableFunc(vitrine, func(a, b CupcakeVitrine) int {
+			return service.CompararAvaliacoes(a.Avaliacao, b.Avaliacao)
+		})
+	}
+
+	user, isLoggedIn := UsuarioAtual(c)
+	if isLoggedIn && user.Tipo == model.RoleCliente {
+		favoritos, err := service.IDsFavoritos(database.DB, user.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao carregar favoritos do cliente", "usuario_id", user.ID, "erro", err)
+		}
+		for i := range vitrine {
+			vitrine[i].Favorito = favoritos[vitrine[i].ID]
+		}
+	}
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	c.HTML(http.StatusOK, "vitrine.html", novaPagina(c, session, "vitrine", gin.H{
+		"Cupcakes": vitrine,
+		"Ordem":    ordem,
+		"Kits":     service.KitsVendaveis(kits),
+	}))
 }
 
 // ShowClienteDashboard renderiza o painel principal do cliente.
 func (h *HomeHandler) ShowClienteDashboard(c *gin.Context) {
-	// Pega o usuário do contexto (já validado pelo middleware)
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
-
-	// Pega a sessão para calcular a quantidade no carrinho
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartCount := getTotalCartQuantity(session)
-
-	c.HTML(http.StatusOK, "cliente_dashboard.html", gin.H{
-		"IsLoggedIn":    true,
-		"User":          user,
-		"ActivePage":    "dashboard",
-		"CartItemCount": cartCount,
-	})
+	c.HTML(http.StatusOK, "cliente_dashboard.html", novaPagina(c, session, "dashboard", nil))
 }
 
 func (h *HomeHandler) ShowPagamentoSucessoPage(c *gin.Context) {
-	user, isLoggedIn := h.getUserFromSession(c)
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartCount := getTotalCartQuantity(session)
-
-	c.HTML(http.StatusOK, "pagamento_sucesso.html", gin.H{
-		"IsLoggedIn":    isLoggedIn,
-		"User":          user,
-		"CartItemCount": cartCount,
-	})
+	c.HTML(http.StatusOK, "pagamento_sucesso.html", novaPagina(c, session, "pagamento_sucesso", nil))
 }
 
 func (h *HomeHandler) ShowClientePedidosPage(c *gin.Context) {
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
+	user, _ := UsuarioAtual(c)
 
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartCount := getTotalCartQuantity(session)
 
 	var pedidos []model.Order
-	err := database.DB.Preload("Items.Cupcake").
+	err := database.DB.Preload("Items.Cupcake").Preload("Items.Opcoes").
 		Where("usuario_id = ?", user.ID).
 		Order("created_at desc").
 		Find(&pedidos).Error
 
 	if err != nil {
-		fmt.Printf("Erro ao buscar pedidos do cliente %d: %v\n", user.ID, err)
-		c.HTML(http.StatusOK, "cliente_pedidos.html", gin.H{
-			"IsLoggedIn":    true,
-			"User":          user,
-			"CartItemCount": cartCount,
-			"Pedidos":       []model.Order{}, // Lista vazia
-			"ErrorMsg":      "Erro ao carregar histórico de pedidos.",
-		})
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar pedidos do cliente", "usuario_id", user.ID, "erro", err)
+		c.HTML(http.StatusOK, "cliente_pedidos.html", novaPagina(c, session, "pedidos", gin.H{
+			"Pedidos":  []model.Order{}, // Lista vazia
+			"ErrorMsg": "Erro ao carregar histórico de pedidos.",
+		}))
 		return
 	}
 
-	c.HTML(http.StatusOK, "cliente_pedidos.html", gin.H{
-		"IsLoggedIn":    true,
-		"User":          user,
-		"CartItemCount": cartCount,
-		"Pedidos":       pedidos,
-	})
+	// Notas já dadas, para o link de avaliação dos pedidos entregues
+	notas, err := service.NotasDoCliente(database.DB, user.ID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar avaliações do cliente", "usuario_id", user.ID, "erro", err)
+	}
+
+	c.HTML(http.StatusOK, "cliente_pedidos.html", novaPagina(c, session, "pedidos", gin.H{
+		"Pedidos": pedidos,
+		"Notas":   notas,
+	}))
 }
 
 func (h *HomeHandler) ShowPedidoPagamentoPage(c *gin.Context) {
-	user, _ := c.Get("user")
-	usuario := user.(model.Usuario)
+	usuario, _ := UsuarioAtual(c)
 
 	// Pega o ID do pedido da URL
 	pedidoIDStr := c.Param("id")
@@ -234,12 +219,11 @@ func (h *HomeHandler) ShowPedidoPagamentoPage(c *gin.Context) {
 	}
 
 	// --- BUSCA OS DADOS DO PIX NO MERCADO PAGO ---
-	fmt.Printf("Buscando dados do pagamento MP ID: %d\n", *pedido.PagamentoMPID)
-	client := payment.NewClient(h.MPCfg)
-	resource, err := client.Get(context.Background(), int(*pedido.PagamentoMPID))
+	slog.DebugContext(c.Request.Context(), "Buscando dados do pagamento no MP", "pagamento_id", *pedido.PagamentoMPID)
+	resource, err := h.conferirPagamentoPix(c.Request.Context(), &pedido)
 
 	if err != nil {
-		fmt.Printf("Erro ao buscar pagamento no MP: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar pagamento no MP", "erro", err)
 		c.String(http.StatusInternalServerError, "Erro ao buscar dados do pagamento.")
 		return
 	}
@@ -247,60 +231,42 @@ func (h *HomeHandler) ShowPedidoPagamentoPage(c *gin.Context) {
 	// Verifica se o pagamento ainda está pendente no MP
 	if resource.Status == "pending" {
 		session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-		cartData := session.Values[CartSessionKey]
-		cart, _ := cartData.(map[uint]int)
-		cartCount := getTotalCartQuantityHelper(cart)
-
-		c.HTML(http.StatusOK, "pagamento_pix.html", gin.H{
-			"IsLoggedIn":       true,
-			"User":             usuario,
+		c.HTML(http.StatusOK, "pagamento_pix.html", novaPagina(c, session, "pedidos", gin.H{
 			"Pedido":           pedido,
 			"QrCodeBase64":     resource.PointOfInteraction.TransactionData.QRCodeBase64,
 			"QrCodeCopiaECola": resource.PointOfInteraction.TransactionData.QRCode,
 			"Total":            pedido.Total,
-			"CartItemCount":    cartCount,
-		})
+		}))
 	} else {
-		// O pagamento não está mais pendente (foi pago ou expirou)
-		// Atualiza nosso banco (caso o webhook tenha falhado)
-		switch resource.Status {
-		case "approved":
-			database.DB.Model(&pedido).Update("status", model.StatusPago)
-		case "cancelled", "expired":
-			database.DB.Model(&pedido).Update("status", model.StatusFalhou) // Ou "expirado"
-		}
-		// Redireciona de volta para o histórico de pedidos
+		// O pagamento não está mais pendente (foi pago ou expirou) e o pedido já foi
+		// atualizado. Redireciona de volta para o histórico de pedidos
 		session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-		session.AddFlash("O status deste pagamento mudou. Verifique seu histórico.", "success")
+		adicionarFlash(session, FlashSucesso, "O status deste pagamento mudou. Verifique seu histórico.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/cliente/pedidos")
 	}
 }
 
+// conferirPagamentoPix consulta o PIX do pedido no Mercado Pago e, se ele não está mais
+// pendente, atualiza o pedido (caso o webhook tenha falhado).
+func (h *HomeHandler) conferirPagamentoPix(ctx context.Context, pedido *model.Order) (*payment.Response, error) {
+	resource, err := service.ConferirPagamentoPedido(ctx, database.DB, payment.NewClient(h.MPCfg), pedido)
+	if err != nil && resource != nil {
+		slog.ErrorContext(ctx, "Erro ao atualizar o pedido com o status do MP", "pedido_id", pedido.ID, "erro", err)
+		return resource, nil
+	}
+	return resource, err
+}
+
 // ShowEditProfilePage exibe o formulário de edição de perfil.
 func (h *HomeHandler) ShowEditProfilePage(c *gin.Context) {
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
-
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	cartData := session.Values[CartSessionKey]
-	cart, _ := cartData.(map[uint]int)
-	cartCount := getTotalCartQuantityHelper(cart)
-
-	c.HTML(http.StatusOK, "perfil_editar.html", gin.H{
-		"IsLoggedIn":     true,
-		"User":           user,
-		"CartItemCount":  cartCount,
-		"FlashesSuccess": session.Flashes("success"),
-		"FlashesError":   session.Flashes("error"),
-	})
-	session.Save(c.Request, c.Writer)
+	c.HTML(http.StatusOK, "perfil_editar.html", novaPagina(c, session, "perfil", nil))
 }
 
 // ProcessEditProfileForm processa a atualização do perfil.
 func (h *HomeHandler) ProcessEditProfileForm(c *gin.Context) {
-	userData, _ := c.Get("user")
-	user := userData.(model.Usuario)
+	user, _ := UsuarioAtual(c)
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
 
 	// Pega TODOS os dados do formulário
@@ -317,17 +283,39 @@ func (h *HomeHandler) ProcessEditProfileForm(c *gin.Context) {
 
 	// Validação básica
 	if novoNome == "" || novoEmail == "" {
-		session.AddFlash("Nome e E-mail são obrigatórios.", "error")
+		adicionarFlash(session, FlashErro, "Nome e E-mail são obrigatórios.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/perfil/editar")
 		return
 	}
 
+	// Validação de endereço: CEP no formato 00000-000 e UF existente
+	if novoCEP != "" {
+		cep, err := service.NormalizarCEP(novoCEP)
+		if err != nil {
+			adicionarFlash(session, FlashErro, "CEP inválido. Use o formato 00000-000.")
+			session.Save(c.Request, c.Writer)
+			c.Redirect(http.StatusFound, "/perfil/editar")
+			return
+		}
+		novoCEP = service.FormatarCEP(cep)
+	}
+	if novoEstado != "" {
+		uf, err := service.NormalizarUF(novoEstado)
+		if err != nil {
+			adicionarFlash(session, FlashErro, "Estado (UF) inválido.")
+			session.Save(c.Request, c.Writer)
+			c.Redirect(http.StatusFound, "/perfil/editar")
+			return
+		}
+		novoEstado = uf
+	}
+
 	// Validação de E-mail (se foi alterado)
 	if novoEmail != user.Email {
 		var existingUser model.Usuario
 		if err := database.DB.Where("email = ?", novoEmail).First(&existingUser).Error; err == nil {
-			session.AddFlash("O e-mail informado já está em uso por outra conta.", "error")
+			adicionarFlash(session, FlashErro, "O e-mail informado já está em uso por outra conta.")
 			session.Save(c.Request, c.Writer)
 			c.Redirect(http.StatusFound, "/perfil/editar")
 			return
@@ -355,15 +343,16 @@ func (h *HomeHandler) ProcessEditProfileForm(c *gin.Context) {
 	result := database.DB.Model(&user).Updates(updateData)
 
 	if result.Error != nil {
-		log.Printf("Erro ao atualizar perfil do usuário %d: %v\n", user.ID, result.Error)
-		session.AddFlash("Erro ao salvar as alterações. Tente novamente.", "error")
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar perfil do usuário", "usuario_id", user.ID, "erro", result.Error)
+		adicionarFlash(session, FlashErro, "Erro ao salvar as alterações. Tente novamente.")
 		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusFound, "/perfil/editar")
 		return
 	}
 
-	log.Printf("Perfil do usuário %d atualizado.\n", user.ID)
-	session.AddFlash("Perfil atualizado com sucesso!", "success")
+	service.Usuarios.Invalidar(user.ID)
+	slog.InfoContext(c.Request.Context(), "Perfil do usuário atualizado.", "usuario_id", user.ID)
+	adicionarFlash(session, FlashSucesso, "Perfil atualizado com sucesso!")
 	session.Save(c.Request, c.Writer)
 
 	c.Redirect(http.StatusFound, "/perfil")
@@ -0,0 +1,82 @@
+// /internal/handler/identidade.go
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// chaveUsuarioContexto guarda no gin.Context o usuário resolvido por Identificar.
+const chaveUsuarioContexto = "user"
+
+// semSessao são os caminhos que não precisam saber quem está logado.
+func semSessao(caminho string) bool {
+	switch caminho {
+	case "/healthz", "/readyz", "/metrics":
+		return true
+	}
+	return strings.HasPrefix(caminho, "/static/") || strings.HasPrefix(caminho, "/uploads/")
+}
+
+// Identificar resolve o usuário logado uma vez por requisição: lê o ID na sessão e busca
+// a conta em service.Usuarios (cache curto em memória). Os handlers leem o resultado com
+// UsuarioAtual. Conta apagada ou desativada perde o login e segue como visitante.
+func (h *AuthHandler) Identificar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if semSessao(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+		userID, ok := session.Values[service.ChaveUsuarioSessao].(uint)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		user, err := service.Usuarios.Buscar(database.DB, userID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			// Banco fora do ar: a página decide o que fazer sem usuário, mas o login fica
+			slog.ErrorContext(c.Request.Context(), "Identificar: erro ao buscar o usuário da sessão", "usuario_id", userID, "erro", err)
+			c.Next()
+			return
+		}
+		if err != nil || !user.Ativo() {
+			if err != nil {
+				slog.InfoContext(c.Request.Context(), "Identificar: usuário não encontrado no DB, forçando logout", "usuario_id", userID)
+			} else {
+				slog.WarnContext(c.Request.Context(), "Identificar: conta desativada, forçando logout", "usuario_id", userID)
+			}
+			// Sessão nova sem o login; o resto (carrinho) continua
+			if err := h.Store.Regenerar(c.Request, session); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Identificar: erro ao descartar a sessão", "erro", err)
+			}
+			delete(session.Values, service.ChaveUsuarioSessao)
+			delete(session.Values, "userName")
+			session.Save(c.Request, c.Writer)
+			c.Next()
+			return
+		}
+
+		c.Set(chaveUsuarioContexto, user)
+		c.Next()
+	}
+}
+
+// UsuarioAtual devolve o usuário logado resolvido por Identificar. Nas rotas atrás de
+// AuthRequired ok é sempre true.
+func UsuarioAtual(c *gin.Context) (user model.Usuario, ok bool) {
+	valor, existe := c.Get(chaveUsuarioContexto)
+	if !existe {
+		return model.Usuario{}, false
+	}
+	user, ok = valor.(model.Usuario)
+	return user, ok
+}
@@ -0,0 +1,67 @@
+// /internal/handler/identidade_test.go
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+func TestIdentidade(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := &AuthHandler{Store: service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "segredo")}
+
+	novoRouter := func(antes ...gin.HandlerFunc) *gin.Engine {
+		router := gin.New()
+		router.Use(antes...)
+		router.Use(auth.Identificar())
+		router.GET("/publica", func(c *gin.Context) {
+			if user, ok := UsuarioAtual(c); ok {
+				c.String(http.StatusOK, user.Nome)
+				return
+			}
+			c.String(http.StatusOK, "visitante")
+		})
+		router.GET("/protegida", auth.AuthRequired(), func(c *gin.Context) {
+			user, _ := UsuarioAtual(c)
+			c.String(http.StatusOK, user.Nome)
+		})
+		return router
+	}
+	chamar := func(router *gin.Engine, caminho string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, caminho, nil))
+		return w
+	}
+
+	t.Run("Visitante segue sem usuário e é barrado nas rotas protegidas", func(t *testing.T) {
+		router := novoRouter()
+		if w := chamar(router, "/publica"); w.Body.String() != "visitante" {
+			t.Errorf("Corpo = %q; esperado visitante", w.Body.String())
+		}
+		if w := chamar(router, "/protegida"); w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+			t.Errorf("Status = %d, Location = %q; esperado redirecionar para /login", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("UsuarioAtual devolve o usuário tipado", func(t *testing.T) {
+		logado := func(c *gin.Context) { c.Set(chaveUsuarioContexto, model.Usuario{ID: 1, Nome: "Ana"}) }
+		router := novoRouter(logado)
+		if w := chamar(router, "/protegida"); w.Code != http.StatusOK || w.Body.String() != "Ana" {
+			t.Errorf("Status = %d, corpo = %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Valor de outro tipo não conta como login", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(chaveUsuarioContexto, "Ana")
+		if _, ok := UsuarioAtual(c); ok {
+			t.Error("UsuarioAtual deveria recusar um valor que não é model.Usuario")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// /internal/handler/lojista_auditoria_handler.go
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// auditar registra uma ação do painel feita pelo usuário logado (AuthRequired). antes e
+// depois são os estados do registro (nil na criação e na exclusão); quem altera o
+// registro no lugar deve passar service.FotoAuditoria tirada antes da mudança.
+// Falhas só vão para o log: a ação em si já foi concluída.
+func auditar(c *gin.Context, acao, entidade string, entidadeID uint, antes, depois any) {
+	autor, _ := UsuarioAtual(c)
+	err := service.RegistrarAuditoria(database.DB, service.Auditoria{
+		Autor:      autor,
+		IP:         c.ClientIP(),
+		Acao:       acao,
+		Entidade:   entidade,
+		EntidadeID: entidadeID,
+		Antes:      antes,
+		Depois:     depois,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao registrar auditoria", "acao", acao, "entidade", entidade, "entidade_id", entidadeID, "erro", err)
+	}
+}
+
+// ShowAuditoriaPage lista as ações administrativas com filtros.
+func (h *LojistaHandler) ShowAuditoriaPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	filtro := service.FiltroAuditoria{Entidade: c.Query("entidade"), Acao: c.Query("acao")}
+	filtro.Pagina, _ = strconv.Atoi(c.Query("pagina"))
+	if id, err := strconv.ParseUint(c.Query("entidade_id"), 10, 32); err == nil {
+		filtro.EntidadeID = uint(id)
+	}
+	if id, err := strconv.ParseUint(c.Query("autor"), 10, 32); err == nil {
+		filtro.AutorID = uint(id)
+	}
+	if de, err := time.Parse(service.FormatoData, c.Query("de")); err == nil {
+		filtro.De = &de
+	}
+	if ate, err := time.Parse(service.FormatoData, c.Query("ate")); err == nil {
+		filtro.Ate = &ate
+	}
+
+	resultado, err := service.BuscarAuditoria(database.DB, filtro)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar a auditoria", "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao buscar a auditoria.")
+		return
+	}
+
+	// Membros removidos continuam no filtro de autor
+	var autores []model.Usuario
+	if err := database.DB.Unscoped().Where("tipo = ?", model.RoleLojista).Order("nome").Find(&autores).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar a equipe para o filtro da auditoria", "erro", err)
+	}
+
+	// Links de paginação mantêm os filtros
+	linkPagina := func(p int) string {
+		q := url.Values{}
+		for _, campo := range []string{"entidade", "entidade_id", "acao", "autor", "de", "ate"} {
+			if v := c.Query(campo); v != "" {
+				q.Set(campo, v)
+			}
+		}
+		q.Set("pagina", strconv.Itoa(p))
+		return "/lojista/auditoria?" + q.Encode()
+	}
+	var anterior, proxima string
+	if resultado.Pagina > 1 {
+		anterior = linkPagina(resultado.Pagina - 1)
+	}
+	if resultado.Pagina < resultado.TotalPaginas {
+		proxima = linkPagina(resultado.Pagina + 1)
+	}
+
+	c.HTML(http.StatusOK, "lojista_auditoria.html", novaPagina(c, session, "auditoria", gin.H{
+		"Resultado": resultado,
+		"Filtro":    filtro,
+		"De":        c.Query("de"),
+		"Ate":       c.Query("ate"),
+		"Autores":   autores,
+		"Acoes":     model.AcoesAuditoria,
+		"Entidades": model.EntidadesAuditoria,
+		"Anterior":  anterior,
+		"Proxima":   proxima,
+	}))
+}
@@ -0,0 +1,169 @@
+// /internal/handler/lojista_avaliacao_handler.go
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FiltroAvaliacao é uma aba da fila de moderação, com a quantidade de avaliações.
+type FiltroAvaliacao struct {
+	Status model.StatusAvaliacao
+	Titulo string
+	Total  int64
+	Ativo  bool
+}
+
+// filtrosAvaliacao monta as abas da moderação; status inválido vira "pendente".
+func filtrosAvaliacao(db *gorm.DB, status string) ([]FiltroAvaliacao, model.StatusAvaliacao) {
+	filtros := []FiltroAvaliacao{
+		{Status: model.AvaliacaoPendente, Titulo: "Aguardando"},
+		{Status: model.AvaliacaoAprovada, Titulo: "Publicadas"},
+		{Status: model.AvaliacaoOculta, Titulo: "Ocultas"},
+	}
+	ativo := model.AvaliacaoPendente
+	for _, f := range filtros {
+		if string(f.Status) == status {
+			ativo = f.Status
+		}
+	}
+	for i := range filtros {
+		filtros[i].Ativo = filtros[i].Status == ativo
+		if err := db.Model(&model.Avaliacao{}).Where("status = ?", filtros[i].Status).Count(&filtros[i].Total).Error; err != nil {
+			slog.Error("Erro ao contar avaliações", "status", filtros[i].Status, "erro", err)
+		}
+	}
+	return filtros, ativo
+}
+
+// ShowAvaliacoesPage mostra a fila de moderação das avaliações.
+func (h *LojistaHandler) ShowAvaliacoesPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	filtros, status := filtrosAvaliacao(database.DB, c.Query("status"))
+
+	var avaliacoes []model.Avaliacao
+	err := database.DB.
+		Preload("Usuario").
+		Preload("Cupcake", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("status = ?", status).
+		Order("created_at asc").
+		Find(&avaliacoes).Error
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar avaliações.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "lojista_avaliacoes.html", novaPagina(c, session, "avaliacoes", gin.H{
+		"Avaliacoes": avaliacoes,
+		"Filtros":    filtros,
+		"Status":     status,
+		"MaxTexto":   service.MaxTextoAvaliacao,
+	}))
+}
+
+// voltarAvaliacoes redireciona para a aba de onde veio a ação.
+func voltarAvaliacoes(c *gin.Context) {
+	status := c.PostForm("voltar")
+	c.Redirect(http.StatusSeeOther, "/lojista/avaliacoes?status="+url.QueryEscape(status))
+}
+
+// ModerarAvaliacao aprova ou oculta uma avaliação.
+func (h *LojistaHandler) ModerarAvaliacao(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	status := model.StatusAvaliacao(c.PostForm("status"))
+	if status != model.AvaliacaoAprovada && status != model.AvaliacaoOculta {
+		adicionarFlash(session, FlashErro, "Ação inválida.")
+		session.Save(c.Request, c.Writer)
+		voltarAvaliacoes(c)
+		return
+	}
+
+	var avaliacao model.Avaliacao
+	if err := database.DB.First(&avaliacao, uint(id)).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Avaliação não encontrada.")
+		session.Save(c.Request, c.Writer)
+		voltarAvaliacoes(c)
+		return
+	}
+	antes := service.FotoAuditoria(avaliacao)
+
+	res := database.DB.Model(&avaliacao).Update("status", status)
+	if res.Error == nil && res.RowsAffected > 0 {
+		auditar(c, model.AcaoModerar, "avaliacao", avaliacao.ID, antes, avaliacao)
+	}
+	switch {
+	case res.Error != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao moderar avaliação", "id", id, "erro", res.Error)
+		adicionarFlash(session, FlashErro, "Erro ao atualizar a avaliação.")
+	case res.RowsAffected == 0:
+		adicionarFlash(session, FlashErro, "Avaliação não encontrada.")
+	case status == model.AvaliacaoAprovada:
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Avaliação #%d publicada na vitrine.", id))
+	default:
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Avaliação #%d ocultada.", id))
+	}
+	session.Save(c.Request, c.Writer)
+	voltarAvaliacoes(c)
+}
+
+// ResponderAvaliacao grava a resposta pública do lojista; resposta vazia remove a atual.
+func (h *LojistaHandler) ResponderAvaliacao(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	resposta := strings.TrimSpace(c.PostForm("resposta"))
+	if utf8.RuneCountInString(resposta) > service.MaxTextoAvaliacao {
+		adicionarFlash(session, FlashErro, fmt.Sprintf("A resposta pode ter no máximo %d caracteres.", service.MaxTextoAvaliacao))
+		session.Save(c.Request, c.Writer)
+		voltarAvaliacoes(c)
+		return
+	}
+	var respondidaEm *time.Time
+	if resposta != "" {
+		agora := time.Now()
+		respondidaEm = &agora
+	}
+
+	var avaliacao model.Avaliacao
+	if err := database.DB.First(&avaliacao, uint(id)).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Avaliação não encontrada.")
+		session.Save(c.Request, c.Writer)
+		voltarAvaliacoes(c)
+		return
+	}
+	antes := service.FotoAuditoria(avaliacao)
+
+	res := database.DB.Model(&avaliacao).
+		Updates(map[string]any{"resposta": resposta, "respondida_em": respondidaEm})
+	if res.Error == nil && res.RowsAffected > 0 {
+		auditar(c, model.AcaoResponder, "avaliacao", avaliacao.ID, antes, avaliacao)
+	}
+	switch {
+	case res.Error != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao responder avaliação", "id", id, "erro", res.Error)
+		adicionarFlash(session, FlashErro, "Erro ao salvar a resposta.")
+	case res.RowsAffected == 0:
+		adicionarFlash(session, FlashErro, "Avaliação não encontrada.")
+	case resposta == "":
+		adicionarFlash(session, FlashSucesso, "Resposta removida.")
+	default:
+		adicionarFlash(session, FlashSucesso, "Resposta salva.")
+	}
+	session.Save(c.Request, c.Writer)
+	voltarAvaliacoes(c)
+}
@@ -0,0 +1,151 @@
+// /internal/handler/lojista_cliente_handler.go
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tempoEnvioEmail limita a espera pelo servidor de e-mail dentro da requisição.
+const tempoEnvioEmail = 15 * time.Second
+
+// ShowClientesPage lista os clientes com busca, ordenação e paginação.
+func (h *LojistaHandler) ShowClientesPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	pagina, _ := strconv.Atoi(c.Query("pagina"))
+	filtro := service.FiltroClientes{Busca: c.Query("busca"), Ordem: c.Query("ordem"), Pagina: pagina}
+	if _, ok := service.OrdensClientes[filtro.Ordem]; !ok {
+		filtro.Ordem = "nome"
+	}
+	resultado, err := service.BuscarClientes(database.DB, filtro)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar clientes", "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao buscar clientes.")
+		return
+	}
+
+	// Links de paginação mantêm a busca e a ordem
+	linkPagina := func(p int) string {
+		q := url.Values{"pagina": {strconv.Itoa(p)}, "ordem": {filtro.Ordem}}
+		if filtro.Busca != "" {
+			q.Set("busca", filtro.Busca)
+		}
+		return "/lojista/clientes?" + q.Encode()
+	}
+	var anterior, proxima string
+	if resultado.Pagina > 1 {
+		anterior = linkPagina(resultado.Pagina - 1)
+	}
+	if resultado.Pagina < resultado.TotalPaginas {
+		proxima = linkPagina(resultado.Pagina + 1)
+	}
+
+	c.HTML(http.StatusOK, "lojista_clientes.html", novaPagina(c, session, "clientes", gin.H{
+		"Resultado": resultado,
+		"Filtro":    filtro,
+		"Anterior":  anterior,
+		"Proxima":   proxima,
+	}))
+}
+
+// ShowClientePage mostra o perfil do cliente, os totais e o histórico de pedidos.
+func (h *LojistaHandler) ShowClientePage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	cliente, err := service.ResumoDoCliente(database.DB, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusNotFound, "Cliente não encontrado.")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar o cliente", "id", id, "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao buscar o cliente.")
+		return
+	}
+
+	var pedidos []model.Order
+	if err := database.DB.Preload("Items").Where("usuario_id = ?", cliente.ID).Order("created_at desc").Find(&pedidos).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar os pedidos do cliente", "id", id, "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao buscar os pedidos do cliente.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "lojista_cliente.html", novaPagina(c, session, "cliente", gin.H{
+		"Cliente":       cliente,
+		"Pedidos":       pedidos,
+		"ValidadeHoras": int(service.ValidadeRedefinicao.Hours()),
+	}))
+}
+
+// AlterarAtivoCliente desativa ou reativa a conta do cliente.
+func (h *LojistaHandler) AlterarAtivoCliente(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	destino := fmt.Sprintf("/lojista/clientes/%d", id)
+
+	ativo := c.PostForm("ativo") == "true"
+	cliente, err := service.AlterarAtivoCliente(database.DB, uint(id), ativo, time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, service.ErrNaoEhCliente):
+		adicionarFlash(session, FlashErro, "Cliente não encontrado.")
+		destino = "/lojista/clientes"
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao alterar a conta do cliente", "id", id, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao alterar a conta. Tente novamente.")
+	case ativo:
+		auditar(c, model.AcaoReativar, "cliente", cliente.ID, gin.H{"Ativo": false}, gin.H{"Ativo": true})
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("A conta de %s foi reativada.", cliente.Nome))
+	default:
+		auditar(c, model.AcaoDesativar, "cliente", cliente.ID, gin.H{"Ativo": true}, gin.H{"Ativo": false})
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("A conta de %s foi desativada e não entra mais no site.", cliente.Nome))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, destino)
+}
+
+// RedefinirSenhaCliente envia ao cliente um e-mail com o link para escolher uma nova
+// senha. O lojista não vê o link.
+func (h *LojistaHandler) RedefinirSenhaCliente(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	destino := fmt.Sprintf("/lojista/clientes/%d", id)
+
+	cliente, token, err := service.SolicitarRedefinicaoSenha(database.DB, uint(id), time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, service.ErrNaoEhCliente):
+		adicionarFlash(session, FlashErro, "Cliente não encontrado.")
+		destino = "/lojista/clientes"
+	case errors.Is(err, service.ErrContaDesativada):
+		adicionarFlash(session, FlashErro, "Reative a conta antes de redefinir a senha.")
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao gerar a redefinição de senha do cliente", "id", id, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao gerar a redefinição de senha. Tente novamente.")
+	default:
+		assunto, corpo := service.EmailRedefinicaoSenha(cliente.Nome, urlAbsoluta(c, "/redefinir-senha/"+token))
+		ctx, cancel := context.WithTimeout(c.Request.Context(), tempoEnvioEmail)
+		defer cancel()
+		if err := h.Mailer.Enviar(ctx, cliente.Email, assunto, corpo); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao enviar o e-mail de redefinição", "id", id, "erro", err)
+			adicionarFlash(session, FlashErro, "Não foi possível enviar o e-mail de redefinição. Tente novamente.")
+		} else {
+			auditar(c, model.AcaoRedefinirSenha, "cliente", cliente.ID, nil, nil)
+			adicionarFlash(session, FlashSucesso, fmt.Sprintf("E-mail de redefinição de senha enviado para %s.", cliente.Email))
+		}
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, destino)
+}
@@ -0,0 +1,64 @@
+// /internal/handler/lojista_cozinha_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// intervaloCozinhaSegundos é de quanto em quanto tempo o quadro se atualiza sozinho.
+const intervaloCozinhaSegundos = 15
+
+// ShowCozinhaPage mostra o quadro de produção com os pedidos pagos, em preparo e enviados.
+func (h *LojistaHandler) ShowCozinhaPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	agora := time.Now()
+	quadro, err := service.CarregarQuadroCozinha(database.DB, agora)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao montar o quadro da cozinha", "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao buscar pedidos.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "lojista_cozinha.html", novaPagina(c, session, "cozinha", gin.H{
+		"Quadro":       quadro,
+		"AtualizadoEm": agora,
+		"Intervalo":    intervaloCozinhaSegundos,
+	}))
+}
+
+// AvancarPedidoCozinha move o cartão para a próxima coluna do quadro. O formulário
+// envia o status de onde o cartão saiu, para não avançar duas vezes o mesmo pedido.
+func (h *LojistaHandler) AvancarPedidoCozinha(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	de := model.StatusOrder(c.PostForm("de"))
+	pedido, err := service.AvancarPedidoCozinha(database.DB, uint(id), de)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		adicionarFlash(session, FlashErro, "Pedido não encontrado.")
+	case errors.Is(err, service.ErrPedidoForaDaEtapa):
+		adicionarFlash(session, FlashErro, fmt.Sprintf("O pedido #%d já tinha sido atualizado; o quadro foi recarregado.", id))
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao avançar o pedido na cozinha", "id", id, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao atualizar o pedido.")
+	default:
+		slog.InfoContext(c.Request.Context(), "Status do pedido atualizado pela cozinha", "id", id, "status", pedido.Status)
+		auditar(c, model.AcaoStatus, "pedido", pedido.ID, gin.H{"Status": de}, gin.H{"Status": pedido.Status})
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Pedido #%d: %s.", id, service.TituloStatus(pedido.Status, pedido.TipoEntrega)))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/cozinha")
+}
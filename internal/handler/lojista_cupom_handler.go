@@ -0,0 +1,259 @@
+// /internal/handler/lojista_cupom_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CupomView junta o cupom com os dados já formatados para o formulário de edição.
+type CupomView struct {
+	model.Cupom
+	Usos      int64
+	ValidoDe  string // yyyy-mm-dd, vazio = sem início
+	ValidoAte string // yyyy-mm-dd, vazio = sem fim
+	Opcoes    []OpcaoCupcakeCupom
+}
+
+// OpcaoCupcakeCupom é um cupcake no seletor de restrição do formulário do cupom.
+type OpcaoCupcakeCupom struct {
+	ID          uint
+	Nome        string
+	Selecionado bool
+}
+
+// opcoesCupcakes monta o seletor de cupcakes marcando os já vinculados ao cupom.
+func opcoesCupcakes(cupcakes []model.Cupcake, selecionados []model.Cupcake) []OpcaoCupcakeCupom {
+	marcados := make(map[uint]bool, len(selecionados))
+	for _, cp := range selecionados {
+		marcados[cp.ID] = true
+	}
+	opcoes := make([]OpcaoCupcakeCupom, 0, len(cupcakes))
+	for _, cp := range cupcakes {
+		opcoes = append(opcoes, OpcaoCupcakeCupom{ID: cp.ID, Nome: cp.Nome, Selecionado: marcados[cp.ID]})
+	}
+	return opcoes
+}
+
+// ShowCuponsPage lista os cupons com o número de usos de cada um.
+func (h *LojistaHandler) ShowCuponsPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var cupons []model.Cupom
+	if err := database.DB.Preload("Cupcakes").Order("created_at desc").Find(&cupons).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar cupons.")
+		return
+	}
+
+	var cupcakes []model.Cupcake
+	if err := database.DB.Order("nome").Find(&cupcakes).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar cupcakes.")
+		return
+	}
+
+	views := make([]CupomView, 0, len(cupons))
+	for _, cupom := range cupons {
+		usos, err := service.ContarUsosCupom(database.DB, cupom.ID, 0)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao contar usos do cupom", "cupom_id", cupom.ID, "erro", err)
+		}
+		view := CupomView{Cupom: cupom, Usos: usos, Opcoes: opcoesCupcakes(cupcakes, cupom.Cupcakes)}
+		if cupom.ValidoDe != nil {
+			view.ValidoDe = cupom.ValidoDe.Format(service.FormatoData)
+		}
+		if cupom.ValidoAte != nil {
+			view.ValidoAte = cupom.ValidoAte.Format(service.FormatoData)
+		}
+		views = append(views, view)
+	}
+
+	c.HTML(http.StatusOK, "lojista_cupons.html", novaPagina(c, session, "cupons", gin.H{
+		"Cupons":    views,
+		"NovoCupom": CupomView{Cupom: model.Cupom{Tipo: model.CupomPercentual, Ativo: true}, Opcoes: opcoesCupcakes(cupcakes, nil)},
+	}))
+}
+
+// lerFormCupom preenche o cupom com os campos do formulário, validando-os.
+// Retorna os cupcakes selecionados para a restrição (vazio = todos).
+func lerFormCupom(c *gin.Context, cupom *model.Cupom) ([]model.Cupcake, error) {
+	cupom.Codigo = service.NormalizarCodigoCupom(c.PostForm("codigo"))
+	cupom.Descricao = strings.TrimSpace(c.PostForm("descricao"))
+	cupom.Tipo = model.TipoCupom(c.PostForm("tipo"))
+	cupom.Ativo = c.PostForm("ativo") == "true"
+
+	if cupom.Codigo == "" || strings.ContainsAny(cupom.Codigo, " \t") {
+		return nil, errors.New("Informe um código sem espaços.")
+	}
+	if cupom.Tipo != model.CupomPercentual && cupom.Tipo != model.CupomValorFixo {
+		return nil, errors.New("Tipo de cupom inválido.")
+	}
+
+	valor, err := strconv.ParseFloat(strings.Replace(c.PostForm("valor"), ",", ".", 1), 64)
+	if err != nil || valor <= 0 {
+		return nil, errors.New("Informe um valor de desconto maior que zero.")
+	}
+	if cupom.Tipo == model.CupomPercentual && valor > 100 {
+		return nil, errors.New("O percentual não pode passar de 100.")
+	}
+	cupom.Valor = valor
+
+	valorMinimo, err := strconv.ParseFloat(strings.Replace(c.DefaultPostForm("valor_minimo", "0"), ",", ".", 1), 64)
+	if err != nil || valorMinimo < 0 {
+		return nil, errors.New("Valor mínimo inválido.")
+	}
+	cupom.ValorMinimo = valorMinimo
+
+	limiteTotal, errTotal := strconv.Atoi(c.DefaultPostForm("limite_uso_total", "0"))
+	limiteCliente, errCliente := strconv.Atoi(c.DefaultPostForm("limite_uso_por_cliente", "0"))
+	if errTotal != nil || errCliente != nil || limiteTotal < 0 || limiteCliente < 0 {
+		return nil, errors.New("Os limites de uso devem ser números maiores ou iguais a zero.")
+	}
+	cupom.LimiteUsoTotal = limiteTotal
+	cupom.LimiteUsoPorCliente = limiteCliente
+
+	// As datas vêm no fuso da loja; "válido até" vale até o fim do dia.
+	cupom.ValidoDe, cupom.ValidoAte = nil, nil
+	if s := c.PostForm("valido_de"); s != "" {
+		de, err := time.ParseInLocation(service.FormatoData, s, time.Local)
+		if err != nil {
+			return nil, errors.New("Data de início inválida.")
+		}
+		cupom.ValidoDe = &de
+	}
+	if s := c.PostForm("valido_ate"); s != "" {
+		ate, err := time.ParseInLocation(service.FormatoData, s, time.Local)
+		if err != nil {
+			return nil, errors.New("Data de término inválida.")
+		}
+		ate = ate.Add(24*time.Hour - time.Second)
+		cupom.ValidoAte = &ate
+	}
+	if cupom.ValidoDe != nil && cupom.ValidoAte != nil && cupom.ValidoAte.Before(*cupom.ValidoDe) {
+		return nil, errors.New("A data de término deve ser depois da data de início.")
+	}
+
+	var cupcakes []model.Cupcake
+	if ids := c.PostFormArray("cupcakes"); len(ids) > 0 {
+		if err := database.DB.Where("id IN ?", ids).Find(&cupcakes).Error; err != nil {
+			return nil, fmt.Errorf("buscar cupcakes do cupom: %w", err)
+		}
+	}
+	return cupcakes, nil
+}
+
+// salvarCupom grava o cupom e substitui a lista de cupcakes permitidos.
+func salvarCupom(cupom *model.Cupom, cupcakes []model.Cupcake) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(cupom).Error; err != nil {
+			return err
+		}
+		return tx.Model(cupom).Association("Cupcakes").Replace(cupcakes)
+	})
+}
+
+// ProcessNovoCupom cria um cupom de desconto.
+func (h *LojistaHandler) ProcessNovoCupom(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var cupom model.Cupom
+	cupcakes, err := lerFormCupom(c, &cupom)
+	if err == nil {
+		var existente int64
+		database.DB.Unscoped().Model(&model.Cupom{}).Where("codigo = ?", cupom.Codigo).Count(&existente)
+		if existente > 0 {
+			err = errors.New("Já existe um cupom com esse código.")
+		}
+	}
+	if err == nil {
+		if err = salvarCupom(&cupom, cupcakes); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao criar cupom", "erro", err)
+			err = errors.New("Erro ao salvar o cupom. Tente novamente.")
+		}
+	}
+
+	if err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else {
+		auditar(c, model.AcaoCriar, "cupom", cupom.ID, nil, cupom)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Cupom %s criado.", cupom.Codigo))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+}
+
+// ProcessEditCupom atualiza um cupom existente. Os pedidos que já usaram o cupom
+// guardam o código e o desconto, então editar não altera o histórico.
+func (h *LojistaHandler) ProcessEditCupom(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+		return
+	}
+
+	var cupom model.Cupom
+	if err := database.DB.First(&cupom, uint(id)).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Cupom não encontrado.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+		return
+	}
+	antes := service.FotoAuditoria(cupom)
+
+	cupcakes, err := lerFormCupom(c, &cupom)
+	if err == nil {
+		var existente int64
+		database.DB.Unscoped().Model(&model.Cupom{}).
+			Where("codigo = ? AND id <> ?", cupom.Codigo, cupom.ID).Count(&existente)
+		if existente > 0 {
+			err = errors.New("Já existe um cupom com esse código.")
+		}
+	}
+	if err == nil {
+		if err = salvarCupom(&cupom, cupcakes); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao atualizar cupom", "id", id, "erro", err)
+			err = errors.New("Erro ao salvar o cupom. Tente novamente.")
+		}
+	}
+
+	if err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else {
+		auditar(c, model.AcaoEditar, "cupom", cupom.ID, antes, cupom)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Cupom %s atualizado.", cupom.Codigo))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+}
+
+// DeleteCupom remove (soft delete) um cupom. Pedidos antigos continuam com o código registrado.
+func (h *LojistaHandler) DeleteCupom(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+		return
+	}
+	var cupom model.Cupom
+	if err := database.DB.First(&cupom, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+		return
+	}
+	if err := database.DB.Delete(&cupom).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao excluir cupom", "id", id, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "cupom", cupom.ID, cupom, nil)
+	}
+	c.Redirect(http.StatusSeeOther, "/lojista/cupons")
+}
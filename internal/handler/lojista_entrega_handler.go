@@ -0,0 +1,195 @@
+// /internal/handler/lojista_entrega_handler.go
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DiaSemanaView agrupa as janelas de um dia da semana para o template.
+type DiaSemanaView struct {
+	Dia     int
+	Nome    string
+	Janelas []model.JanelaEntrega
+}
+
+// ShowEntregasPage lista as janelas de entrega/retirada por dia da semana e as datas bloqueadas.
+func (h *LojistaHandler) ShowEntregasPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var janelas []model.JanelaEntrega
+	if err := database.DB.Order("dia_semana, hora_inicio").Find(&janelas).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar janelas de entrega.")
+		return
+	}
+
+	dias := make([]DiaSemanaView, 7)
+	for i := range dias {
+		dias[i] = DiaSemanaView{Dia: i, Nome: service.NomeDiaSemana(i)}
+	}
+	for _, janela := range janelas {
+		if janela.DiaSemana >= 0 && janela.DiaSemana < len(dias) {
+			dias[janela.DiaSemana].Janelas = append(dias[janela.DiaSemana].Janelas, janela)
+		}
+	}
+
+	var bloqueios []model.DataBloqueada
+	if err := database.DB.Where("data >= ?", service.DiaLoja(time.Now()).Format(service.FormatoData)).
+		Order("data").Find(&bloqueios).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar datas bloqueadas.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "lojista_entregas.html", novaPagina(c, session, "entregas", gin.H{
+		"Dias":      dias,
+		"Bloqueios": bloqueios,
+	}))
+}
+
+// ProcessNovaJanela cria uma janela de entrega ou retirada.
+func (h *LojistaHandler) ProcessNovaJanela(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	diaSemana, errDia := strconv.Atoi(c.PostForm("dia_semana"))
+	horaInicio := strings.TrimSpace(c.PostForm("hora_inicio"))
+	horaFim := strings.TrimSpace(c.PostForm("hora_fim"))
+	tipo := model.TipoEntrega(c.PostForm("tipo"))
+	maxPedidos, errPedidos := strconv.Atoi(c.DefaultPostForm("max_pedidos", "0"))
+	maxCupcakes, errCupcakes := strconv.Atoi(c.DefaultPostForm("max_cupcakes", "0"))
+
+	var msgErro string
+	switch {
+	case errDia != nil || diaSemana < 0 || diaSemana > 6:
+		msgErro = "Dia da semana inválido."
+	case !service.ValidarHorario(horaInicio) || !service.ValidarHorario(horaFim):
+		msgErro = "Informe os horários no formato HH:MM."
+	case horaFim <= horaInicio:
+		msgErro = "O horário final deve ser depois do inicial."
+	case tipo != model.TipoEntregaDelivery && tipo != model.TipoEntregaRetirada:
+		msgErro = "Tipo de janela inválido."
+	case errPedidos != nil || errCupcakes != nil || maxPedidos < 0 || maxCupcakes < 0:
+		msgErro = "Os limites devem ser números maiores ou iguais a zero."
+	}
+	if msgErro != "" {
+		adicionarFlash(session, FlashErro, msgErro)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+
+	janela := model.JanelaEntrega{
+		DiaSemana:   diaSemana,
+		HoraInicio:  horaInicio,
+		HoraFim:     horaFim,
+		Tipo:        tipo,
+		MaxPedidos:  maxPedidos,
+		MaxCupcakes: maxCupcakes,
+		Ativa:       true,
+	}
+	if err := database.DB.Create(&janela).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao criar janela de entrega", "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao salvar a janela. Tente novamente.")
+	} else {
+		auditar(c, model.AcaoCriar, "janela", janela.ID, nil, janela)
+		adicionarFlash(session, FlashSucesso, "Janela adicionada.")
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+}
+
+// ToggleJanela ativa ou desativa uma janela sem apagar o histórico dos pedidos.
+func (h *LojistaHandler) ToggleJanela(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	var janela model.JanelaEntrega
+	if err := database.DB.First(&janela, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	antes := service.FotoAuditoria(janela)
+	if err := database.DB.Model(&janela).Update("ativa", c.PostForm("ativa") == "true").Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar janela", "id", id, "erro", err)
+	} else {
+		auditar(c, model.AcaoEditar, "janela", janela.ID, antes, janela)
+	}
+	c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+}
+
+// DeleteJanela remove (soft delete) uma janela de entrega.
+func (h *LojistaHandler) DeleteJanela(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	var janela model.JanelaEntrega
+	if err := database.DB.First(&janela, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	if err := database.DB.Delete(&janela).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao excluir janela", "id", id, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "janela", janela.ID, janela, nil)
+	}
+	c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+}
+
+// ProcessNovoBloqueio bloqueia uma data para entregas e retiradas.
+func (h *LojistaHandler) ProcessNovoBloqueio(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	data, err := time.Parse(service.FormatoData, c.PostForm("data"))
+	if err != nil {
+		adicionarFlash(session, FlashErro, "Data inválida.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+
+	bloqueio := model.DataBloqueada{Data: service.Dia(data), Motivo: strings.TrimSpace(c.PostForm("motivo"))}
+	if err := database.DB.Create(&bloqueio).Error; err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			adicionarFlash(session, FlashErro, "Esta data já está bloqueada.")
+		} else {
+			slog.ErrorContext(c.Request.Context(), "Erro ao bloquear data", "erro", err)
+			adicionarFlash(session, FlashErro, "Erro ao bloquear a data. Tente novamente.")
+		}
+	} else {
+		auditar(c, model.AcaoCriar, "bloqueio", bloqueio.ID, nil, bloqueio)
+		adicionarFlash(session, FlashSucesso, "Data bloqueada.")
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+}
+
+// DeleteBloqueio libera novamente uma data bloqueada.
+func (h *LojistaHandler) DeleteBloqueio(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	var bloqueio model.DataBloqueada
+	if err := database.DB.First(&bloqueio, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+		return
+	}
+	if err := database.DB.Delete(&bloqueio).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao remover bloqueio", "id", id, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "bloqueio", bloqueio.ID, bloqueio, nil)
+	}
+	c.Redirect(http.StatusSeeOther, "/lojista/entregas")
+}
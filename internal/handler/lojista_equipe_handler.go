@@ -0,0 +1,201 @@
+// /internal/handler/lojista_equipe_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MembroView é uma linha da lista da equipe.
+type MembroView struct {
+	model.Usuario
+	Situacao string
+	Voce     bool // O próprio usuário logado: não pode alterar o próprio acesso
+}
+
+// PermissaoMatriz é uma linha da tabela de permissões exibida na página da equipe.
+type PermissaoMatriz struct {
+	Titulo string
+	Papeis []bool // Na ordem de model.Papeis
+}
+
+// matrizPermissoes descreve o que cada papel pode fazer.
+func matrizPermissoes() []PermissaoMatriz {
+	areas := []struct {
+		permissao model.Permissao
+		titulo    string
+	}{
+		{model.PermissaoPedidos, "Vendas e recibos"},
+		{model.PermissaoCozinha, "Quadro da cozinha"},
+		{model.PermissaoCatalogo, "Cupcakes, opções e kits"},
+		{model.PermissaoEntregas, "Entregas e retiradas"},
+		{model.PermissaoCupons, "Cupons"},
+		{model.PermissaoAvaliacoes, "Avaliações"},
+		{model.PermissaoClientes, "Clientes"},
+		{model.PermissaoEquipe, "Equipe"},
+		{model.PermissaoAuditoria, "Auditoria"},
+	}
+	matriz := make([]PermissaoMatriz, len(areas))
+	for i, area := range areas {
+		matriz[i].Titulo = area.titulo
+		for _, papel := range model.Papeis {
+			matriz[i].Papeis = append(matriz[i].Papeis, papel.Pode(area.permissao))
+		}
+	}
+	return matriz
+}
+
+// urlAbsoluta monta o endereço completo de caminho a partir da requisição atual, para
+// links enviados por fora do site.
+func urlAbsoluta(c *gin.Context, caminho string) string {
+	esquema := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		esquema = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", esquema, c.Request.Host, caminho)
+}
+
+// linkConvite monta o endereço completo do convite.
+func linkConvite(c *gin.Context, token string) string {
+	return urlAbsoluta(c, "/convite/"+token)
+}
+
+// ShowEquipePage lista a equipe da loja com o formulário de convite.
+func (h *LojistaHandler) ShowEquipePage(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var equipe []model.Usuario
+	if err := database.DB.Where("tipo = ?", model.RoleLojista).Order("nome").Find(&equipe).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar a equipe.")
+		return
+	}
+	agora := time.Now()
+	membros := make([]MembroView, len(equipe))
+	for i, membro := range equipe {
+		membros[i] = MembroView{Usuario: membro, Situacao: service.SituacaoMembro(membro, agora), Voce: membro.ID == user.ID}
+	}
+
+	convites := session.Flashes("convite")
+	session.Save(c.Request, c.Writer)
+
+	c.HTML(http.StatusOK, "lojista_equipe.html", novaPagina(c, session, "equipe", gin.H{
+		"Membros":      membros,
+		"Papeis":       model.Papeis,
+		"Matriz":       matrizPermissoes(),
+		"Convites":     convites,
+		"ValidadeDias": int(service.ValidadeConvite.Hours() / 24),
+	}))
+}
+
+// ProcessConviteEquipe cria a conta do novo membro e mostra o link de convite, que o
+// administrador repassa para ele definir a senha.
+func (h *LojistaHandler) ProcessConviteEquipe(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	membro, token, err := service.ConvidarMembro(database.DB, c.PostForm("nome"), c.PostForm("email"), model.Papel(c.PostForm("papel")))
+	switch {
+	case errors.Is(err, service.ErrDadosMembro):
+		adicionarFlash(session, FlashErro, "Informe o nome, um e-mail válido e o papel do novo membro.")
+	case errors.Is(err, service.ErrEmailEmUso):
+		adicionarFlash(session, FlashErro, "Este e-mail já está cadastrado na loja.")
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Erro ao convidar membro da equipe", "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao convidar. Tente novamente.")
+	default:
+		auditar(c, model.AcaoConvidar, "equipe", membro.ID, nil, membro)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("%s foi convidado(a) como %s. Envie o link abaixo para definir a senha.", membro.Nome, membro.Papel.Titulo()))
+		session.AddFlash(linkConvite(c, token), "convite")
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/equipe")
+}
+
+// flashErroEquipe traduz os erros de alteração da equipe em mensagens.
+func flashErroEquipe(err error, acao string) string {
+	switch {
+	case errors.Is(err, service.ErrProprioUsuario):
+		return "Você não pode alterar o seu próprio acesso."
+	case errors.Is(err, service.ErrUltimoAdmin):
+		return "A loja precisa de ao menos um administrador com acesso ativo."
+	case errors.Is(err, service.ErrNaoEhEquipe), errors.Is(err, gorm.ErrRecordNotFound):
+		return "Membro da equipe não encontrado."
+	case errors.Is(err, service.ErrDadosMembro):
+		return "Papel inválido."
+	}
+	slog.Error("Erro ao alterar a equipe", "acao", acao, "erro", err)
+	return "Erro ao " + acao + ". Tente novamente."
+}
+
+// AlterarPapelMembro troca o papel de outro membro da equipe.
+func (h *LojistaHandler) AlterarPapelMembro(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	papel := model.Papel(c.PostForm("papel"))
+	if anterior, err := service.AlterarPapel(database.DB, user, uint(id), papel); err != nil {
+		adicionarFlash(session, FlashErro, flashErroEquipe(err, "alterar o papel"))
+	} else {
+		auditar(c, model.AcaoPapel, "equipe", uint(id), gin.H{"Papel": anterior}, gin.H{"Papel": papel})
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Papel alterado para %s.", papel.Titulo()))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/equipe")
+}
+
+// RedefinirAcessoMembro gera um novo link de convite: para quem não usou o anterior ou
+// esqueceu a senha (a senha atual deixa de valer).
+func (h *LojistaHandler) RedefinirAcessoMembro(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	membro, token, err := service.RedefinirSenhaMembro(database.DB, user, uint(id))
+	if err != nil {
+		adicionarFlash(session, FlashErro, flashErroEquipe(err, "gerar o novo link"))
+	} else {
+		// Quem entrou com a senha antiga (ou com um token) perde o acesso agora
+		if err := h.Store.EncerrarDoUsuario(c.Request.Context(), membro.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao encerrar as sessões após redefinir o acesso", "usuario_id", membro.ID, "erro", err)
+		}
+		if err := service.RevogarTokensAPIDoUsuario(database.DB.WithContext(c.Request.Context()), membro.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao revogar os tokens de API após redefinir o acesso", "usuario_id", membro.ID, "erro", err)
+		}
+		auditar(c, model.AcaoAcesso, "equipe", membro.ID, nil, nil)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Novo link gerado para %s. Os anteriores deixaram de valer.", membro.Nome))
+		session.AddFlash(linkConvite(c, token), "convite")
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/equipe")
+}
+
+// DeleteMembro remove outro membro da equipe.
+func (h *LojistaHandler) DeleteMembro(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	// Só para a auditoria; RemoverMembro confere o membro com a equipe travada
+	var membro model.Usuario
+	database.DB.First(&membro, uint(id))
+
+	if err := service.RemoverMembro(database.DB, user, uint(id)); err != nil {
+		adicionarFlash(session, FlashErro, flashErroEquipe(err, "remover o membro"))
+	} else {
+		auditar(c, model.AcaoExcluir, "equipe", uint(id), membro, nil)
+		adicionarFlash(session, FlashSucesso, "Membro removido da equipe.")
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/equipe")
+}
@@ -1,73 +1,184 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ericoliveiras/meu-cupcake/internal/database"
 	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/gorilla/sessions"
 	"github.com/mercadopago/sdk-go/pkg/config"
+	"gorm.io/gorm"
 )
 
-const defaultCupcakeImage = "/static/images/placeholder.png"
+const defaultCupcakeImage = model.ImagemPadrao
 
 type LojistaHandler struct {
-	Store *sessions.CookieStore
-	MPCfg *config.Config
+	Store   *service.SessaoStore
+	MPCfg   *config.Config
+	Imagens *service.ProcessadorImagens
+	Mailer  service.Mailer // E-mails de redefinição de senha dos clientes
 }
 
-// getSessionData é uma função helper para buscar os dados do usuário da sessão.
-func (h *LojistaHandler) getSessionData(c *gin.Context) (model.Usuario, bool) {
+// maisFavoritadosDashboard é o tamanho do ranking de favoritos no painel.
+const maisFavoritadosDashboard = 5
+
+// ShowLojistaDashboard renderiza o painel principal do lojista.
+func (h *LojistaHandler) ShowLojistaDashboard(c *gin.Context) {
 	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
-	userID, ok := session.Values["userID"].(uint)
-	if !ok {
-		return model.Usuario{}, false
-	}
 
-	var user model.Usuario
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		return model.Usuario{}, false
+	maisFavoritados, err := service.MaisFavoritados(database.DB, maisFavoritadosDashboard)
+	if err != nil {
+		// O painel continua funcionando sem o ranking
+		slog.ErrorContext(c.Request.Context(), "Erro ao carregar os cupcakes mais favoritados", "erro", err)
 	}
-	user.Tipo = model.RoleLojista
-	return user, true
+
+	c.HTML(http.StatusOK, "lojista_dashboard.html", novaPagina(c, session, "dashboard", gin.H{
+		"MaisFavoritados": maisFavoritados,
+	}))
 }
 
-// ShowLojistaDashboard renderiza o painel principal do lojista.
-func (h *LojistaHandler) ShowLojistaDashboard(c *gin.Context) {
-	user, isLoggedIn := h.getSessionData(c)
+// CupcakeLojistaView junta o cupcake com as fotos do modal de edição.
+type CupcakeLojistaView struct {
+	model.Cupcake
+	Fotos []FotoGaleriaForm
+}
 
-	c.HTML(http.StatusOK, "lojista_dashboard.html", gin.H{
-		"IsLoggedIn": isLoggedIn,
-		"User":       user,
-	})
+// FotoGaleriaForm é uma foto já gravada na lista ordenável do modal.
+type FotoGaleriaForm struct {
+	Token string // Valor do campo "ordem" (service.TokenImagem)
+	Thumb string
+	Alt   string
 }
 
 // ShowCupcakesPage busca todos os cupcakes e renderiza a página de gerenciamento.
 func (h *LojistaHandler) ShowCupcakesPage(c *gin.Context) {
-	user, isLoggedIn := h.getSessionData(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
 	var cupcakes []model.Cupcake
 
-	if err := database.DB.Order("created_at desc").Find(&cupcakes).Error; err != nil {
+	if err := service.ComGaleria(database.DB).Order("created_at desc").Find(&cupcakes).Error; err != nil {
 		c.String(http.StatusInternalServerError, "Erro ao buscar cupcakes.")
 		return
 	}
 
-	c.HTML(http.StatusOK, "lojista_cupcakes.html", gin.H{
-		"IsLoggedIn": isLoggedIn,
-		"User":       user,
-		"Cupcakes":   cupcakes,
-	})
+	views := make([]CupcakeLojistaView, len(cupcakes))
+	for i, cupcake := range cupcakes {
+		views[i].Cupcake = cupcake
+		for _, img := range imagensAtuais(cupcake) {
+			views[i].Fotos = append(views[i].Fotos, FotoGaleriaForm{
+				Token: service.TokenImagem(img), Thumb: img.ImagemThumb(), Alt: img.TextoAlt,
+			})
+		}
+	}
+
+	c.HTML(http.StatusOK, "lojista_cupcakes.html", novaPagina(c, session, "cupcakes", gin.H{
+		"Cupcakes":   views,
+		"MaxImagens": model.MaxImagensCupcake,
+	}))
+}
+
+// salvarImagemEnviada processa a imagem do campo "imagem" (validação, rendições e
+// remoção do EXIF). Retorna "" quando nenhum arquivo foi enviado.
+func (h *LojistaHandler) salvarImagemEnviada(c *gin.Context) (string, error) {
+	return salvarImagemDoCampo(c, h.Imagens, "imagem")
+}
+
+// salvarImagemDoCampo processa a imagem enviada no campo do formulário.
+// Retorna "" quando nenhum arquivo foi enviado.
+func salvarImagemDoCampo(c *gin.Context, imagens *service.ProcessadorImagens, campo string) (string, error) {
+	file, err := c.FormFile(campo)
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return salvarArquivoImagem(c, imagens, file)
+}
+
+// salvarImagensGaleria processa os arquivos do campo "imagens" (fotos novas da galeria)
+// e retorna as URLs na ordem de envio. Se um arquivo falhar, os já gravados são removidos.
+func (h *LojistaHandler) salvarImagensGaleria(c *gin.Context) ([]string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	arquivos := form.File["imagens"]
+	if len(arquivos) > model.MaxImagensCupcake {
+		return nil, service.ErrGaleriaCheia
+	}
+
+	var urls []string
+	for _, file := range arquivos {
+		url, err := salvarArquivoImagem(c, h.Imagens, file)
+		if err != nil {
+			h.removerImagens(c, urls)
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+func salvarArquivoImagem(c *gin.Context, imagens *service.ProcessadorImagens, file *multipart.FileHeader) (string, error) {
+	if file.Size > imagens.TamanhoMaximo {
+		return "", service.ErrImagemMuitoGrande
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return imagens.Salvar(c.Request.Context(), f)
+}
+
+func (h *LojistaHandler) removerImagens(c *gin.Context, urls []string) {
+	for _, url := range urls {
+		h.Imagens.Remover(c.Request.Context(), url)
+	}
+}
+
+// mensagemGaleria traduz os erros de envio das fotos para o lojista.
+func (h *LojistaHandler) mensagemGaleria(err error) string {
+	if errors.Is(err, service.ErrGaleriaCheia) {
+		return fmt.Sprintf("A galeria aceita no máximo %d fotos.", model.MaxImagensCupcake)
+	}
+	return h.Imagens.MensagemImagem(err)
+}
+
+// montarGaleriaDoForm lê os campos "ordem" e "alt_<token>" do modal do cupcake.
+func montarGaleriaDoForm(c *gin.Context, atuais []model.CupcakeImagem, novas []string) ([]model.CupcakeImagem, []string, error) {
+	alts := make(map[string]string)
+	for campo, valores := range c.Request.PostForm {
+		if token, ok := strings.CutPrefix(campo, "alt_"); ok && len(valores) > 0 {
+			alts[token] = valores[0]
+		}
+	}
+	return service.MontarGaleria(atuais, c.PostFormArray("ordem"), alts, novas)
+}
+
+// imagensAtuais retorna a galeria gravada do cupcake; cupcakes antigos contam com
+// a foto do ImagemURL (a imagem padrão não entra).
+func imagensAtuais(cupcake model.Cupcake) []model.CupcakeImagem {
+	if len(cupcake.Imagens) > 0 || cupcake.ImagemURL == defaultCupcakeImage || cupcake.ImagemURL == "" {
+		return cupcake.Imagens
+	}
+	return []model.CupcakeImagem{{CupcakeID: cupcake.ID, URL: cupcake.ImagemURL}}
 }
 
 // ProcessNewCupcakeForm processa o formulário de criação de cupcake.
 func (h *LojistaHandler) ProcessNewCupcakeForm(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
 	nome := c.PostForm("nome")
 	descricao := c.PostForm("descricao")
 	precoStr := c.PostForm("preco")
@@ -75,33 +186,23 @@ func (h *LojistaHandler) ProcessNewCupcakeForm(c *gin.Context) {
 
 	preco, err := strconv.ParseFloat(precoStr, 64)
 	if err != nil {
-		log.Printf("Erro ao converter preço: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Erro ao converter preço", "erro", err)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
 
 	disponivel := disponivelStr == "true"
-	var imagemURL = defaultCupcakeImage
-
-	file, err := c.FormFile("imagem")
 
+	novas, err := h.salvarImagensGaleria(c)
+	var galeria []model.CupcakeImagem
 	if err == nil {
-		ext := filepath.Ext(file.Filename)
-		newFileName := uuid.New().String() + ext
-
-		localSavePath := filepath.Join("uploads", newFileName)
-
-		if err := c.SaveUploadedFile(file, localSavePath); err != nil {
-			log.Printf("Erro ao salvar imagem em %s: %v", localSavePath, err)
-			c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
-			return
-		}
-
-		webURLPath := "/uploads/" + newFileName
-		imagemURL = webURLPath
-
-	} else if err != http.ErrMissingFile {
-		log.Printf("Erro ao processar form-file: %v", err)
+		galeria, _, err = montarGaleriaDoForm(c, nil, novas)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao processar imagens do novo cupcake", "erro", err)
+		h.removerImagens(c, novas)
+		adicionarFlash(session, FlashErro, h.mensagemGaleria(err))
+		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
@@ -111,21 +212,31 @@ func (h *LojistaHandler) ProcessNewCupcakeForm(c *gin.Context) {
 		Descricao:  descricao,
 		Preco:      preco,
 		Disponivel: disponivel,
-		ImagemURL:  imagemURL,
+		ImagemURL:  defaultCupcakeImage,
 	}
 
-	if err := database.DB.Create(&cupcake).Error; err != nil {
-		log.Printf("Erro ao criar cupcake no DB: %v", err)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&cupcake).Error; err != nil {
+			return err
+		}
+		return service.SalvarGaleria(tx, &cupcake, galeria, defaultCupcakeImage)
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao criar cupcake no DB", "erro", err)
+		h.removerImagens(c, novas)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
 
-	log.Println("Novo cupcake criado com sucesso.")
+	auditar(c, model.AcaoCriar, "cupcake", cupcake.ID, nil, cupcake)
+	slog.InfoContext(c.Request.Context(), "Novo cupcake criado com sucesso.")
 	c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 }
 
-// ProcessEditCupcakeForm processa o envio do formulário de edição de cupcake.
+// ProcessEditCupcakeForm processa o envio do formulário de edição de cupcake,
+// incluindo fotos novas, removidas e a nova ordem da galeria.
 func (h *LojistaHandler) ProcessEditCupcakeForm(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -134,60 +245,55 @@ func (h *LojistaHandler) ProcessEditCupcakeForm(c *gin.Context) {
 	}
 
 	var cupcake model.Cupcake
-	if err := database.DB.First(&cupcake, uint(id)).Error; err != nil {
+	if err := service.ComGaleria(database.DB).First(&cupcake, uint(id)).Error; err != nil {
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
 
-	oldImagePath := cupcake.ImagemURL
-
+	antes := service.FotoAuditoria(cupcake)
 	cupcake.Nome = c.PostForm("nome")
 	cupcake.Descricao = c.PostForm("descricao")
 	cupcake.Disponivel = c.PostForm("disponivel") == "true"
 	preco, _ := strconv.ParseFloat(c.PostForm("preco"), 64)
 	cupcake.Preco = preco
 
-	file, err := c.FormFile("imagem")
+	novas, err := h.salvarImagensGaleria(c)
+	var galeria []model.CupcakeImagem
+	var removidas []string
 	if err == nil {
-		ext := filepath.Ext(file.Filename)
-		newFileName := uuid.New().String() + ext
-
-		localSavePath := filepath.Join("uploads", newFileName)
-
-		if err := c.SaveUploadedFile(file, localSavePath); err != nil {
-			log.Printf("Erro ao salvar nova imagem: %v", err)
-			c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
-			return
-		}
-
-		cupcake.ImagemURL = "/uploads/" + newFileName
-
-		if oldImagePath != defaultCupcakeImage && oldImagePath != "" {
-			fsOldPath := filepath.Clean(oldImagePath[1:])
-			if err := os.Remove(fsOldPath); err != nil {
-				log.Printf("AVISO: Não foi possível remover a imagem antiga '%s': %v", fsOldPath, err)
-			} else {
-				log.Printf("Imagem antiga '%s' removida com sucesso.", fsOldPath)
-			}
-		}
-
-	} else if err != http.ErrMissingFile {
-		log.Printf("Erro ao processar form-file (edição): %v", err)
+		galeria, removidas, err = montarGaleriaDoForm(c, imagensAtuais(cupcake), novas)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao processar imagens do cupcake", "id", id, "erro", err)
+		h.removerImagens(c, novas)
+		adicionarFlash(session, FlashErro, h.mensagemGaleria(err))
+		session.Save(c.Request, c.Writer)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
 
-	if err := database.DB.Save(&cupcake).Error; err != nil {
-		log.Printf("Erro ao atualizar cupcake no DB: %v", err)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Imagens", "GruposOpcoes").Save(&cupcake).Error; err != nil {
+			return err
+		}
+		return service.SalvarGaleria(tx, &cupcake, galeria, defaultCupcakeImage)
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar cupcake no DB", "erro", err)
+		h.removerImagens(c, novas)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 		return
 	}
 
-	log.Println("Cupcake atualizado com sucesso.")
+	// As fotos retiradas (com todas as rendições) só saem depois que a galeria foi gravada
+	h.removerImagens(c, removidas)
+
+	auditar(c, model.AcaoEditar, "cupcake", cupcake.ID, antes, cupcake)
+	slog.InfoContext(c.Request.Context(), "Cupcake atualizado com sucesso.")
 	c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 }
 
-// DeleteCupcake remove um cupcake do banco de dados e o arquivo de imagem associado.
+// DeleteCupcake remove um cupcake do banco de dados e os arquivos de imagem associados.
 func (h *LojistaHandler) DeleteCupcake(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
@@ -197,29 +303,27 @@ func (h *LojistaHandler) DeleteCupcake(c *gin.Context) {
 	}
 
 	var cupcake model.Cupcake
-	if err := database.DB.First(&cupcake, uint(id)).Error; err != nil {
+	if err := service.ComGaleria(database.DB).First(&cupcake, uint(id)).Error; err != nil {
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes?error=Cupcake não encontrado")
 		return
 	}
 
-	imagePath := cupcake.ImagemURL
-
-	if err := database.DB.Delete(&cupcake).Error; err != nil {
-		log.Printf("Erro ao deletar cupcake do DB: %v", err)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cupcake_id = ?", cupcake.ID).Delete(&model.CupcakeImagem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&cupcake).Error
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao deletar cupcake do DB", "erro", err)
 		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes?error=Erro ao deletar do banco")
 		return
 	}
 
-	log.Printf("Cupcake %d enviado para a lixeira (ou deletado) do DB.", id)
+	auditar(c, model.AcaoExcluir, "cupcake", cupcake.ID, cupcake, nil)
+	slog.InfoContext(c.Request.Context(), "Cupcake enviado para a lixeira (ou deletado) do DB.", "id", id)
 
-	if imagePath != defaultCupcakeImage && imagePath != "" {
-		fsPath := filepath.Clean(imagePath[1:])
-		if err := os.Remove(fsPath); err != nil {
-			log.Printf("AVISO: Não foi possível remover o arquivo de imagem '%s': %v", fsPath, err)
-		} else {
-			log.Printf("Arquivo de imagem '%s' removido com sucesso.", fsPath)
-		}
-	}
+	h.removerImagens(c, service.URLsGaleria(cupcake))
 
 	c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
 }
@@ -238,41 +342,63 @@ func (h *LojistaHandler) UpdatePedidoStatus(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Model(&model.Order{}).Where("id = ?", pedidoID).Update("status", model.StatusOrder(novoStatus))
-	if result.Error != nil {
-		log.Printf("Erro ao atualizar status do pedido %d: %v\n", pedidoID, result.Error)
-	} else if result.RowsAffected == 0 {
-		log.Printf("Tentativa de atualizar pedido %d não encontrado.\n", pedidoID)
+	// Status anterior só para a auditoria; AtualizarStatusPedido trava o pedido
+	var anterior model.Order
+	database.DB.Select("id", "status").First(&anterior, uint(pedidoID))
+
+	pedido := model.Order{ID: uint(pedidoID)}
+	err = service.AtualizarStatusPedido(database.DB, &pedido, model.StatusOrder(novoStatus))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.WarnContext(c.Request.Context(), "Tentativa de atualizar pedido não encontrado.", "pedido_id", pedidoID)
+	} else if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar status do pedido", "pedido_id", pedidoID, "erro", err)
 	} else {
-		log.Printf("Status do pedido %d atualizado para %s\n", pedidoID, novoStatus)
+		slog.InfoContext(c.Request.Context(), "Status do pedido atualizado", "pedido_id", pedidoID, "status", novoStatus)
+		if anterior.Status != pedido.Status {
+			auditar(c, model.AcaoStatus, "pedido", pedido.ID, gin.H{"Status": anterior.Status}, gin.H{"Status": pedido.Status})
+		}
 	}
 
 	c.Redirect(http.StatusFound, "/lojista/vendas")
 }
 
 func (h *LojistaHandler) ShowLojistaVendasPage(c *gin.Context) {
-	user, isLoggedIn := h.getSessionData(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	// Visão de produção: pedidos do dia escolhido agrupados por janela de entrega
+	diaProducao := service.DiaLoja(time.Now())
+	if diaStr := c.Query("dia"); diaStr != "" {
+		if dia, err := time.Parse(service.FormatoData, diaStr); err == nil {
+			diaProducao = dia
+		}
+	}
+	producao, err := service.MontarProducaoDia(database.DB, diaProducao)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao montar produção do dia", "dia", diaProducao.Format(service.FormatoData), "erro", err)
+	}
 
 	var vendas []model.Order
-	err := database.DB.Preload("Usuario").
+	err = database.DB.Preload("Usuario").
 		Preload("Items.Cupcake").
+		Preload("Items.Opcoes").
+		Preload("JanelaEntrega").
 		Order("created_at desc").
 		Find(&vendas).Error
 
 	if err != nil {
-		fmt.Printf("Erro ao buscar vendas para o lojista: %v\n", err)
-		c.HTML(http.StatusOK, "lojista_vendas.html", gin.H{
-			"IsLoggedIn": isLoggedIn,
-			"User":       user,
-			"Vendas":     []model.Order{},
-			"ErrorMsg":   "Erro ao carregar histórico de vendas.",
-		})
+		slog.ErrorContext(c.Request.Context(), "Erro ao buscar vendas para o lojista", "erro", err)
+		c.HTML(http.StatusOK, "lojista_vendas.html", novaPagina(c, session, "vendas", gin.H{
+			"Vendas":      []model.Order{},
+			"ErrorMsg":    "Erro ao carregar histórico de vendas.",
+			"DiaProducao": diaProducao,
+			"Producao":    producao,
+		}))
 		return
 	}
 
-	c.HTML(http.StatusOK, "lojista_vendas.html", gin.H{
-		"IsLoggedIn": isLoggedIn,
-		"User":       user,
-		"Vendas":     vendas,
-	})
+	c.HTML(http.StatusOK, "lojista_vendas.html", novaPagina(c, session, "vendas", gin.H{
+		"Vendas":      vendas,
+		"DiaProducao": diaProducao,
+		"Producao":    producao,
+	}))
 }
@@ -0,0 +1,273 @@
+// /internal/handler/lojista_kit_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// KitView junta o kit com a lista de cupcakes do formulário de edição.
+type KitView struct {
+	model.Kit
+	Cupcakes []CupcakeKitForm
+}
+
+// CupcakeKitForm é um cupcake no formulário do kit: quantidade na composição fixa
+// ou marcação de permitido na "monte sua caixa".
+type CupcakeKitForm struct {
+	ID         uint
+	Nome       string
+	Disponivel bool
+	Quantidade int
+	Permitido  bool
+}
+
+// cupcakesKitForm monta as linhas do formulário a partir da composição atual do kit.
+func cupcakesKitForm(cupcakes []model.Cupcake, kit model.Kit) []CupcakeKitForm {
+	quantidades := make(map[uint]int, len(kit.Itens))
+	for _, item := range kit.Itens {
+		quantidades[item.CupcakeID] += item.Quantidade
+	}
+	permitidos := make(map[uint]bool, len(kit.Permitidos))
+	for _, cp := range kit.Permitidos {
+		permitidos[cp.ID] = true
+	}
+	linhas := make([]CupcakeKitForm, 0, len(cupcakes))
+	for _, cp := range cupcakes {
+		linhas = append(linhas, CupcakeKitForm{
+			ID: cp.ID, Nome: cp.Nome, Disponivel: cp.Disponivel,
+			Quantidade: quantidades[cp.ID], Permitido: permitidos[cp.ID],
+		})
+	}
+	return linhas
+}
+
+// ShowKitsPage lista as caixas e kits com a composição de cada um.
+func (h *LojistaHandler) ShowKitsPage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var kits []model.Kit
+	if err := service.ComComponentes(database.DB).Order("created_at desc").Find(&kits).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar kits.")
+		return
+	}
+
+	var cupcakes []model.Cupcake
+	if err := database.DB.Order("nome").Find(&cupcakes).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao buscar cupcakes.")
+		return
+	}
+
+	views := make([]KitView, 0, len(kits))
+	for _, kit := range kits {
+		views = append(views, KitView{Kit: kit, Cupcakes: cupcakesKitForm(cupcakes, kit)})
+	}
+
+	c.HTML(http.StatusOK, "lojista_kits.html", novaPagina(c, session, "kits", gin.H{
+		"Kits":    views,
+		"NovoKit": KitView{Kit: model.Kit{Disponivel: true}, Cupcakes: cupcakesKitForm(cupcakes, model.Kit{})},
+	}))
+}
+
+// lerFormKit preenche o kit com os campos do formulário, validando-os. Retorna a
+// composição fixa (qtd_<cupcakeID>) ou os cupcakes permitidos na "monte sua caixa".
+func lerFormKit(c *gin.Context, kit *model.Kit) ([]model.KitItem, []model.Cupcake, error) {
+	kit.Nome = strings.TrimSpace(c.PostForm("nome"))
+	kit.Descricao = strings.TrimSpace(c.PostForm("descricao"))
+	kit.Disponivel = c.PostForm("disponivel") == "true"
+	if kit.Nome == "" {
+		return nil, nil, errors.New("Informe o nome do kit.")
+	}
+
+	preco, err := parsePreco(c.PostForm("preco"))
+	if err != nil || preco <= 0 {
+		return nil, nil, errors.New("Informe um preço maior que zero.")
+	}
+	kit.Preco = preco
+
+	tamanho, err := strconv.Atoi(c.DefaultPostForm("tamanho_caixa", "0"))
+	if err != nil || tamanho < 0 {
+		return nil, nil, errors.New("Tamanho da caixa inválido.")
+	}
+	if c.PostForm("tipo") != "caixa" {
+		tamanho = 0
+	} else if tamanho == 0 {
+		return nil, nil, errors.New("Informe quantos cupcakes o cliente escolhe na caixa.")
+	}
+	kit.TamanhoCaixa = tamanho
+
+	var cupcakes []model.Cupcake
+	if err := database.DB.Order("nome").Find(&cupcakes).Error; err != nil {
+		return nil, nil, fmt.Errorf("buscar cupcakes do kit: %w", err)
+	}
+
+	if kit.MonteSuaCaixa() {
+		marcados := make(map[string]bool)
+		for _, id := range c.PostFormArray("permitidos") {
+			marcados[id] = true
+		}
+		var permitidos []model.Cupcake
+		for _, cp := range cupcakes {
+			if marcados[strconv.FormatUint(uint64(cp.ID), 10)] {
+				permitidos = append(permitidos, cp)
+			}
+		}
+		if len(permitidos) == 0 {
+			return nil, nil, errors.New("Marque ao menos um cupcake que o cliente pode escolher.")
+		}
+		return nil, permitidos, nil
+	}
+
+	var itens []model.KitItem
+	for _, cp := range cupcakes {
+		qtd, err := strconv.Atoi(c.DefaultPostForm(fmt.Sprintf("qtd_%d", cp.ID), "0"))
+		if err != nil || qtd < 0 {
+			return nil, nil, fmt.Errorf("Quantidade inválida para %s.", cp.Nome)
+		}
+		if qtd > 0 {
+			itens = append(itens, model.KitItem{CupcakeID: cp.ID, Quantidade: qtd})
+		}
+	}
+	if len(itens) == 0 {
+		return nil, nil, errors.New("Informe a quantidade de ao menos um cupcake do kit.")
+	}
+	return itens, nil, nil
+}
+
+// salvarKit grava o kit e substitui a composição e os cupcakes permitidos.
+func salvarKit(kit *model.Kit, itens []model.KitItem, permitidos []model.Cupcake) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Itens", "Permitidos").Save(kit).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("kit_id = ?", kit.ID).Delete(&model.KitItem{}).Error; err != nil {
+			return err
+		}
+		for i := range itens {
+			itens[i].KitID = kit.ID
+			if err := tx.Create(&itens[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(kit).Association("Permitidos").Replace(permitidos)
+	})
+}
+
+// ProcessNovoKit cria uma caixa ou kit.
+func (h *LojistaHandler) ProcessNovoKit(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	var kit model.Kit
+	itens, permitidos, err := lerFormKit(c, &kit)
+	if err == nil {
+		var imagemURL string
+		if imagemURL, err = h.salvarImagemEnviada(c); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao processar imagem do kit", "erro", err)
+			err = errors.New(h.Imagens.MensagemImagem(err))
+		} else if imagemURL == "" {
+			kit.ImagemURL = defaultCupcakeImage
+		} else {
+			kit.ImagemURL = imagemURL
+		}
+	}
+	if err == nil {
+		if err = salvarKit(&kit, itens, permitidos); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao criar kit", "erro", err)
+			h.Imagens.Remover(c.Request.Context(), kit.ImagemURL)
+			err = errors.New("Erro ao salvar o kit. Tente novamente.")
+		}
+	}
+
+	if err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else {
+		auditar(c, model.AcaoCriar, "kit", kit.ID, nil, kit)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Kit %s criado.", kit.Nome))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/kits")
+}
+
+// ProcessEditKit atualiza um kit. Pedidos antigos guardam os componentes como itens
+// próprios, então mudar a composição não altera o histórico.
+func (h *LojistaHandler) ProcessEditKit(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/kits")
+		return
+	}
+
+	var kit model.Kit
+	if err := database.DB.First(&kit, uint(id)).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Kit não encontrado.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/lojista/kits")
+		return
+	}
+	imagemAntiga := kit.ImagemURL
+	antes := service.FotoAuditoria(kit)
+
+	itens, permitidos, err := lerFormKit(c, &kit)
+	if err == nil {
+		var imagemURL string
+		if imagemURL, err = h.salvarImagemEnviada(c); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao processar imagem do kit", "id", id, "erro", err)
+			err = errors.New(h.Imagens.MensagemImagem(err))
+		} else if imagemURL != "" {
+			kit.ImagemURL = imagemURL
+		}
+	}
+	if err == nil {
+		if err = salvarKit(&kit, itens, permitidos); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao atualizar kit", "id", id, "erro", err)
+			if kit.ImagemURL != imagemAntiga {
+				h.Imagens.Remover(c.Request.Context(), kit.ImagemURL)
+			}
+			err = errors.New("Erro ao salvar o kit. Tente novamente.")
+		} else if kit.ImagemURL != imagemAntiga {
+			h.Imagens.Remover(c.Request.Context(), imagemAntiga)
+		}
+	}
+
+	if err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else {
+		auditar(c, model.AcaoEditar, "kit", kit.ID, antes, kit)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Kit %s atualizado.", kit.Nome))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, "/lojista/kits")
+}
+
+// DeleteKit remove (soft delete) um kit e a imagem enviada para ele.
+func (h *LojistaHandler) DeleteKit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/kits")
+		return
+	}
+	var kit model.Kit
+	if err := database.DB.First(&kit, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/kits")
+		return
+	}
+	if err := database.DB.Delete(&kit).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao excluir kit", "id", id, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "kit", kit.ID, kit, nil)
+		h.Imagens.Remover(c.Request.Context(), kit.ImagemURL)
+	}
+	c.Redirect(http.StatusSeeOther, "/lojista/kits")
+}
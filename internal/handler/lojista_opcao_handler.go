@@ -0,0 +1,265 @@
+// /internal/handler/lojista_opcao_handler.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// opcoesURL é a página de opções do cupcake, para onde os formulários redirecionam.
+func opcoesURL(cupcakeID uint64) string {
+	return fmt.Sprintf("/lojista/cupcakes/opcoes/%d", cupcakeID)
+}
+
+// parsePreco aceita "2.50" ou "2,50" (vazio = 0).
+func parsePreco(s string) (float64, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// ShowOpcoesCupcakePage lista os grupos de opções (tamanho, recheio, mensagem...) de um cupcake.
+func (h *LojistaHandler) ShowOpcoesCupcakePage(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+
+	var cupcake model.Cupcake
+	if err := service.ComOpcoes(database.DB).First(&cupcake, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+
+	c.HTML(http.StatusOK, "lojista_opcoes.html", novaPagina(c, session, "opcoes", gin.H{
+		"Cupcake":   cupcake,
+		"NovoGrupo": model.GrupoOpcao{Tipo: model.GrupoEscolha, MaxEscolhas: 1},
+	}))
+}
+
+// lerFormGrupoOpcao preenche o grupo com os campos do formulário, validando as regras.
+func lerFormGrupoOpcao(c *gin.Context, g *model.GrupoOpcao) error {
+	g.Nome = strings.TrimSpace(c.PostForm("nome"))
+	g.Tipo = model.TipoGrupoOpcao(c.PostForm("tipo"))
+	g.Obrigatorio = c.PostForm("obrigatorio") == "true"
+
+	if g.Nome == "" {
+		return errors.New("Informe o nome do grupo.")
+	}
+	if g.Tipo != model.GrupoEscolha && g.Tipo != model.GrupoTexto {
+		return errors.New("Tipo de grupo inválido.")
+	}
+
+	minimo, errMin := strconv.Atoi(c.DefaultPostForm("min_escolhas", "0"))
+	maximo, errMax := strconv.Atoi(c.DefaultPostForm("max_escolhas", "1"))
+	maxCaracteres, errCar := strconv.Atoi(c.DefaultPostForm("max_caracteres", "0"))
+	posicao, errPos := strconv.Atoi(c.DefaultPostForm("posicao", "0"))
+	if errMin != nil || errMax != nil || errCar != nil || errPos != nil ||
+		minimo < 0 || maximo < 0 || maxCaracteres < 0 {
+		return errors.New("Os limites devem ser números maiores ou iguais a zero.")
+	}
+	if maximo > 0 && minimo > maximo {
+		return errors.New("O mínimo de escolhas não pode ser maior que o máximo.")
+	}
+	preco, err := parsePreco(c.PostForm("preco_adicional"))
+	if err != nil {
+		return errors.New("Preço adicional inválido.")
+	}
+
+	g.MinEscolhas, g.MaxEscolhas, g.MaxCaracteres, g.Posicao = minimo, maximo, maxCaracteres, posicao
+	g.PrecoAdicional = 0
+	if g.Tipo == model.GrupoTexto {
+		// Grupos de texto não têm escolhas; o preço é cobrado quando o texto é preenchido
+		g.MinEscolhas, g.MaxEscolhas = 0, 0
+		g.PrecoAdicional = preco
+	} else {
+		g.MaxCaracteres = 0
+	}
+	return nil
+}
+
+// ProcessNovoGrupoOpcao cria um grupo de opções para o cupcake.
+func (h *LojistaHandler) ProcessNovoGrupoOpcao(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cupcakeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+
+	grupo := model.GrupoOpcao{CupcakeID: uint(cupcakeID)}
+	if err := lerFormGrupoOpcao(c, &grupo); err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else if err := database.DB.Create(&grupo).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao criar grupo de opções do cupcake", "cupcake_id", cupcakeID, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao salvar o grupo. Tente novamente.")
+	} else {
+		auditar(c, model.AcaoCriar, "grupo_opcao", grupo.ID, nil, grupo)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Grupo %s adicionado.", grupo.Nome))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
+
+// ProcessEditGrupoOpcao atualiza as regras de um grupo de opções.
+func (h *LojistaHandler) ProcessEditGrupoOpcao(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cupcakeID, err1 := strconv.ParseUint(c.Param("id"), 10, 32)
+	grupoID, err2 := strconv.ParseUint(c.Param("grupo"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+
+	var grupo model.GrupoOpcao
+	if err := database.DB.Where("id = ? AND cupcake_id = ?", grupoID, cupcakeID).First(&grupo).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Grupo não encontrado.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+		return
+	}
+	antes := service.FotoAuditoria(grupo)
+
+	if err := lerFormGrupoOpcao(c, &grupo); err != nil {
+		adicionarFlash(session, FlashErro, err.Error())
+	} else if err := database.DB.Save(&grupo).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar grupo de opções", "grupo_id", grupoID, "erro", err)
+		adicionarFlash(session, FlashErro, "Erro ao salvar o grupo. Tente novamente.")
+	} else {
+		auditar(c, model.AcaoEditar, "grupo_opcao", grupo.ID, antes, grupo)
+		adicionarFlash(session, FlashSucesso, fmt.Sprintf("Grupo %s atualizado.", grupo.Nome))
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
+
+// DeleteGrupoOpcao remove (soft delete) um grupo e suas opções. Itens de pedidos antigos
+// guardam o nome da opção escolhida, então o histórico não muda.
+func (h *LojistaHandler) DeleteGrupoOpcao(c *gin.Context) {
+	cupcakeID, err1 := strconv.ParseUint(c.Param("id"), 10, 32)
+	grupoID, err2 := strconv.ParseUint(c.Param("grupo"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+	var grupo model.GrupoOpcao
+	if err := database.DB.Where("id = ? AND cupcake_id = ?", grupoID, cupcakeID).First(&grupo).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+		return
+	}
+	if err := database.DB.Delete(&grupo).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao excluir grupo de opções", "grupo_id", grupoID, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "grupo_opcao", grupo.ID, grupo, nil)
+		if err := database.DB.Where("grupo_opcao_id = ?", grupoID).Delete(&model.Opcao{}).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao excluir opções do grupo", "grupo_id", grupoID, "erro", err)
+		}
+	}
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
+
+// ProcessNovaOpcao adiciona uma opção a um grupo de escolha.
+func (h *LojistaHandler) ProcessNovaOpcao(c *gin.Context) {
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	cupcakeID, err1 := strconv.ParseUint(c.Param("id"), 10, 32)
+	grupoID, err2 := strconv.ParseUint(c.Param("grupo"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+
+	var grupo model.GrupoOpcao
+	if err := database.DB.Where("id = ? AND cupcake_id = ?", grupoID, cupcakeID).First(&grupo).Error; err != nil {
+		adicionarFlash(session, FlashErro, "Grupo não encontrado.")
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+		return
+	}
+
+	nome := strings.TrimSpace(c.PostForm("nome"))
+	preco, errPreco := parsePreco(c.PostForm("preco_adicional"))
+	posicao, errPos := strconv.Atoi(c.DefaultPostForm("posicao", "0"))
+	switch {
+	case grupo.Tipo != model.GrupoEscolha:
+		adicionarFlash(session, FlashErro, "Grupos de texto não têm opções.")
+	case nome == "":
+		adicionarFlash(session, FlashErro, "Informe o nome da opção.")
+	case errPreco != nil || errPos != nil:
+		adicionarFlash(session, FlashErro, "Preço ou posição inválidos.")
+	default:
+		opcao := model.Opcao{GrupoOpcaoID: grupo.ID, Nome: nome, PrecoAdicional: preco, Posicao: posicao, Disponivel: true}
+		if err := database.DB.Create(&opcao).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao criar opção no grupo", "grupo_id", grupo.ID, "erro", err)
+			adicionarFlash(session, FlashErro, "Erro ao salvar a opção. Tente novamente.")
+		} else {
+			auditar(c, model.AcaoCriar, "opcao", opcao.ID, nil, opcao)
+			adicionarFlash(session, FlashSucesso, fmt.Sprintf("Opção %s adicionada em %s.", opcao.Nome, grupo.Nome))
+		}
+	}
+	session.Save(c.Request, c.Writer)
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
+
+// opcaoDoCupcake restringe a consulta às opções dos grupos do cupcake.
+func opcaoDoCupcake(opcaoID, cupcakeID uint64) *gorm.DB {
+	grupos := database.DB.Model(&model.GrupoOpcao{}).Select("id").Where("cupcake_id = ?", cupcakeID)
+	return database.DB.Model(&model.Opcao{}).Where("id = ? AND grupo_opcao_id IN (?)", opcaoID, grupos)
+}
+
+// ToggleOpcao marca uma opção como disponível ou esgotada (ex.: recheio em falta).
+func (h *LojistaHandler) ToggleOpcao(c *gin.Context) {
+	cupcakeID, err1 := strconv.ParseUint(c.Param("id"), 10, 32)
+	opcaoID, err2 := strconv.ParseUint(c.Param("opcao"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+	var opcao model.Opcao
+	if err := opcaoDoCupcake(opcaoID, cupcakeID).First(&opcao).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+		return
+	}
+	antes := service.FotoAuditoria(opcao)
+	if err := database.DB.Model(&opcao).Update("disponivel", c.PostForm("disponivel") == "true").Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao atualizar opção", "opcao_id", opcaoID, "erro", err)
+	} else {
+		auditar(c, model.AcaoEditar, "opcao", opcao.ID, antes, opcao)
+	}
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
+
+// DeleteOpcao remove (soft delete) uma opção.
+func (h *LojistaHandler) DeleteOpcao(c *gin.Context) {
+	cupcakeID, err1 := strconv.ParseUint(c.Param("id"), 10, 32)
+	opcaoID, err2 := strconv.ParseUint(c.Param("opcao"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.Redirect(http.StatusSeeOther, "/lojista/cupcakes")
+		return
+	}
+	var opcao model.Opcao
+	if err := opcaoDoCupcake(opcaoID, cupcakeID).First(&opcao).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+		return
+	}
+	if err := database.DB.Delete(&opcao).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao excluir opção", "opcao_id", opcaoID, "erro", err)
+	} else {
+		auditar(c, model.AcaoExcluir, "opcao", opcao.ID, opcao, nil)
+	}
+	c.Redirect(http.StatusSeeOther, opcoesURL(cupcakeID))
+}
@@ -0,0 +1,171 @@
+// /internal/handler/pagina.go
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+// NivelFlash é o tipo de uma mensagem de flash. O valor é a chave usada na sessão.
+type NivelFlash string
+
+const (
+	FlashSucesso NivelFlash = "success"
+	FlashErro    NivelFlash = "error"
+)
+
+// niveisFlash é a ordem em que as mensagens aparecem em Flashes.
+var niveisFlash = []NivelFlash{FlashSucesso, FlashErro}
+
+// Flash é uma mensagem guardada na sessão até a próxima página exibida.
+type Flash struct {
+	Nivel    NivelFlash
+	Mensagem string
+}
+
+// adicionarFlash guarda a mensagem para a próxima página; quem chama salva a sessão.
+func adicionarFlash(session *sessions.Session, nivel NivelFlash, mensagem string) {
+	session.AddFlash(mensagem, string(nivel))
+}
+
+// lerFlashes tira da sessão as mensagens de todos os níveis.
+func lerFlashes(session *sessions.Session) []Flash {
+	var flashes []Flash
+	for _, nivel := range niveisFlash {
+		for _, f := range session.Flashes(string(nivel)) {
+			if msg, ok := f.(string); ok {
+				flashes = append(flashes, Flash{Nivel: nivel, Mensagem: msg})
+			}
+		}
+	}
+	return flashes
+}
+
+// mensagensDoNivel filtra as mensagens de um nível, no formato que os templates usam.
+func mensagensDoNivel(flashes []Flash, nivel NivelFlash) []string {
+	var msgs []string
+	for _, f := range flashes {
+		if f.Nivel == nivel {
+			msgs = append(msgs, f.Mensagem)
+		}
+	}
+	return msgs
+}
+
+// novaPagina monta os dados de um template com os campos comuns a todas as páginas,
+// lidos da requisição: usuário logado, itens no carrinho, flashes (consumidos aqui),
+// token CSRF e página ativa no menu. Os dados da página entram por cima.
+func novaPagina(c *gin.Context, session *sessions.Session, ativa string, dados gin.H) gin.H {
+	user, isLoggedIn := UsuarioAtual(c)
+
+	flashes := lerFlashes(session)
+	if len(flashes) > 0 {
+		if err := session.Save(c.Request, c.Writer); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Erro ao salvar sessão depois de ler os flashes", "pagina", ativa, "erro", err)
+		}
+	}
+
+	pagina := gin.H{
+		"IsLoggedIn":     isLoggedIn,
+		"User":           user,
+		"CartItemCount":  getTotalCartQuantity(session),
+		"Flashes":        flashes,
+		"FlashesSuccess": mensagensDoNivel(flashes, FlashSucesso),
+		"FlashesError":   mensagensDoNivel(flashes, FlashErro),
+		"CSRFToken":      tokenCSRF(c, session.Options),
+		"ActivePage":     ativa,
+	}
+	maps.Copy(pagina, dados)
+	return pagina
+}
+
+// cookieCSRF guarda o token CSRF do navegador (double submit): a página recebe o mesmo
+// valor em CSRFToken para mandar no campo csrf_token, e CSRFValido compara os dois. Fica
+// fora da sessão para que um visitante que só navega não crie sessão no servidor.
+const cookieCSRF = "meu-cupcake-csrf"
+
+// tamanhoTokenCSRF é o tamanho de 32 bytes aleatórios em base64 sem padding.
+var tamanhoTokenCSRF = base64.RawURLEncoding.EncodedLen(32)
+
+// tokenCSRF devolve o token do cookie ou cria um novo, com as opções do cookie de sessão.
+func tokenCSRF(c *gin.Context, opcoes *sessions.Options) string {
+	if cookie, err := c.Request.Cookie(cookieCSRF); err == nil && len(cookie.Value) == tamanhoTokenCSRF {
+		return cookie.Value
+	}
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+
+	cookie := &http.Cookie{Name: cookieCSRF, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	if opcoes != nil {
+		cookie.Domain, cookie.Secure = opcoes.Domain, opcoes.Secure
+		if opcoes.SameSite != 0 {
+			cookie.SameSite = opcoes.SameSite
+		}
+	}
+	http.SetCookie(c.Writer, cookie)
+	return token
+}
+
+// CSRFValido confere o token enviado no formulário (csrf_token) ou no cabeçalho
+// X-CSRF-Token contra o cookie.
+func CSRFValido(c *gin.Context) bool {
+	cookie, err := c.Request.Cookie(cookieCSRF)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	enviado := c.GetHeader("X-CSRF-Token")
+	if enviado == "" {
+		enviado = c.PostForm("csrf_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(enviado), []byte(cookie.Value)) == 1
+}
+
+// ExigirCSRF barra POST, PUT, PATCH e DELETE das páginas sem o token CSRF: o campo
+// csrf_token dos formulários ou o cabeçalho X-CSRF-Token das chamadas em JavaScript. A
+// API em /api/ faz a própria conferência em APIHandler.AutenticarAPI, que dispensa quem
+// usa token Bearer; os webhooks em /webhooks/ vêm de servidores, com assinatura própria.
+func (h *AuthHandler) ExigirCSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !metodoAlteraDados(c.Request.Method) || strings.HasPrefix(c.Request.URL.Path, "/api/") ||
+			strings.HasPrefix(c.Request.URL.Path, "/webhooks/") || CSRFValido(c) {
+			c.Next()
+			return
+		}
+		slog.WarnContext(c.Request.Context(), "Requisição sem token CSRF válido", "metodo", c.Request.Method, "caminho", c.Request.URL.Path)
+
+		const mensagem = "A página expirou. Recarregue e tente de novo."
+		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" || c.ContentType() == "application/json" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": mensagem})
+			return
+		}
+		session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+		adicionarFlash(session, FlashErro, mensagem)
+		_ = session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, paginaAnterior(c))
+		c.Abort()
+	}
+}
+
+// paginaAnterior devolve o caminho do Referer quando ele é deste site, ou "/".
+func paginaAnterior(c *gin.Context) string {
+	origem, err := url.Parse(c.GetHeader("Referer"))
+	// "//outro.site" e "/\outro.site" seriam lidos pelo navegador como outro domínio
+	if err != nil || origem.Host != c.Request.Host || !strings.HasPrefix(origem.Path, "/") ||
+		strings.HasPrefix(origem.Path, "//") || strings.HasPrefix(origem.Path, "/\\") {
+		return "/"
+	}
+	if origem.RawQuery != "" {
+		return origem.Path + "?" + origem.RawQuery
+	}
+	return origem.Path
+}
@@ -0,0 +1,210 @@
+// /internal/handler/pagina_test.go
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/ericoliveiras/meu-cupcake/internal/view"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+func TestNovaPagina(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/", Secure: true}, "segredo")
+
+	// Uma requisição que deixa flashes na sessão, como os handlers de formulário
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	session, _ := store.Get(req, "meu-cupcake-session")
+	adicionarFlash(session, FlashErro, "Falhou.")
+	adicionarFlash(session, FlashSucesso, "Salvo.")
+	if err := session.Save(req, w); err != nil {
+		t.Fatal(err)
+	}
+	cookieSessao := w.Result().Cookies()[0]
+
+	montar := func(cookies ...*http.Cookie) (gin.H, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/vitrine", nil)
+		for _, cookie := range cookies {
+			c.Request.AddCookie(cookie)
+		}
+		c.Set(chaveUsuarioContexto, model.Usuario{ID: 1, Nome: "Ana"})
+		session, _ := store.Get(c.Request, "meu-cupcake-session")
+		return novaPagina(c, session, "vitrine", gin.H{"Kits": 2, "CartItemCount": 9}), w
+	}
+
+	dados, w := montar(cookieSessao)
+	t.Run("Campos comuns", func(t *testing.T) {
+		user, _ := dados["User"].(model.Usuario)
+		if dados["IsLoggedIn"] != true || user.Nome != "Ana" || dados["ActivePage"] != "vitrine" {
+			t.Errorf("Dados = %+v", dados)
+		}
+		if dados["Kits"] != 2 || dados["CartItemCount"] != 9 {
+			t.Errorf("Os dados da página deveriam entrar por cima dos comuns: %+v", dados)
+		}
+	})
+
+	t.Run("Flashes separados por nível", func(t *testing.T) {
+		if !slices.Equal(dados["FlashesSuccess"].([]string), []string{"Salvo."}) || !slices.Equal(dados["FlashesError"].([]string), []string{"Falhou."}) {
+			t.Errorf("FlashesSuccess = %v, FlashesError = %v", dados["FlashesSuccess"], dados["FlashesError"])
+		}
+		esperado := []Flash{{FlashSucesso, "Salvo."}, {FlashErro, "Falhou."}}
+		if !slices.Equal(dados["Flashes"].([]Flash), esperado) {
+			t.Errorf("Flashes = %v; esperado %v", dados["Flashes"], esperado)
+		}
+		// Lidos uma vez, os flashes saem da sessão
+		if outra, _ := montar(cookieSessao); len(outra["Flashes"].([]Flash)) != 0 {
+			t.Errorf("Os flashes deveriam ter sido consumidos: %v", outra["Flashes"])
+		}
+	})
+
+	t.Run("Token CSRF", func(t *testing.T) {
+		var cookieToken *http.Cookie
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == cookieCSRF {
+				cookieToken = cookie
+			}
+		}
+		if cookieToken == nil || cookieToken.Value != dados["CSRFToken"] || !cookieToken.HttpOnly || !cookieToken.Secure {
+			t.Fatalf("Cookie CSRF = %+v; token da página = %v", cookieToken, dados["CSRFToken"])
+		}
+		if outra, _ := montar(cookieToken); outra["CSRFToken"] != cookieToken.Value {
+			t.Error("Com o cookie, o token deveria ser reaproveitado")
+		}
+
+		validar := func(enviado string, cabecalho bool) bool {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if cabecalho {
+				c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+				c.Request.Header.Set("X-CSRF-Token", enviado)
+			} else {
+				c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"csrf_token": {enviado}}.Encode()))
+				c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			c.Request.AddCookie(cookieToken)
+			return CSRFValido(c)
+		}
+		if !validar(cookieToken.Value, false) || !validar(cookieToken.Value, true) {
+			t.Error("O token do cookie deveria ser aceito no formulário e no cabeçalho")
+		}
+		if validar("outro", false) || validar("", true) {
+			t.Error("Um token diferente do cookie não deveria ser aceito")
+		}
+	})
+}
+
+func TestExigirCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := &AuthHandler{Store: service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "segredo")}
+	router := gin.New()
+	router.Use(auth.ExigirCSRF())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/carrinho", ok)
+	router.POST("/carrinho/limpar", ok)
+	router.POST("/api/v1/tokens", ok)
+	router.POST("/webhooks/mercadopago", ok)
+
+	token := strings.Repeat("a", tamanhoTokenCSRF)
+	chamar := func(metodo, caminho string, corpo url.Values, ajustar func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(metodo, caminho, strings.NewReader(corpo.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: cookieCSRF, Value: token})
+		if ajustar != nil {
+			ajustar(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Formulário com o token passa", func(t *testing.T) {
+		if w := chamar(http.MethodPost, "/carrinho/limpar", url.Values{"csrf_token": {token}}, nil); w.Code != http.StatusOK {
+			t.Errorf("Status = %d; esperado 200", w.Code)
+		}
+	})
+
+	t.Run("Formulário sem o token volta para a página com um aviso", func(t *testing.T) {
+		referer := func(r *http.Request) { r.Header.Set("Referer", "http://example.com/carrinho?x=1") }
+		w := chamar(http.MethodPost, "/carrinho/limpar", nil, referer)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/carrinho?x=1" {
+			t.Errorf("Status = %d, Location = %q; esperado voltar para /carrinho?x=1", w.Code, w.Header().Get("Location"))
+		}
+		if w.Body.String() == "ok" {
+			t.Error("O handler não deveria rodar")
+		}
+	})
+
+	t.Run("Referer de outro site ou ambíguo volta para o início", func(t *testing.T) {
+		for _, referer := range []string{"https://outro.site/carrinho", "http://example.com//outro.site", ""} {
+			w := chamar(http.MethodPost, "/carrinho/limpar", url.Values{"csrf_token": {"errado"}}, func(r *http.Request) { r.Header.Set("Referer", referer) })
+			if w.Header().Get("Location") != "/" {
+				t.Errorf("Referer %q: Location = %q; esperado /", referer, w.Header().Get("Location"))
+			}
+		}
+	})
+
+	t.Run("Chamada em JavaScript recebe 403 em JSON", func(t *testing.T) {
+		ajax := func(r *http.Request) { r.Header.Set("X-Requested-With", "XMLHttpRequest") }
+		w := chamar(http.MethodPost, "/carrinho/limpar", nil, ajax)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"success":false`) {
+			t.Errorf("Status = %d, corpo = %s", w.Code, w.Body.String())
+		}
+		comCabecalho := func(r *http.Request) { ajax(r); r.Header.Set("X-CSRF-Token", token) }
+		if w := chamar(http.MethodPost, "/carrinho/limpar", nil, comCabecalho); w.Code != http.StatusOK {
+			t.Errorf("Com X-CSRF-Token, status = %d; esperado 200", w.Code)
+		}
+	})
+
+	t.Run("GET, a API e os webhooks não passam pela conferência", func(t *testing.T) {
+		if w := chamar(http.MethodGet, "/carrinho", nil, nil); w.Code != http.StatusOK {
+			t.Errorf("GET: status = %d", w.Code)
+		}
+		if w := chamar(http.MethodPost, "/api/v1/tokens", nil, nil); w.Code != http.StatusOK {
+			t.Errorf("API: status = %d", w.Code)
+		}
+		if w := chamar(http.MethodPost, "/webhooks/mercadopago", nil, nil); w.Code != http.StatusOK {
+			t.Errorf("Webhook: status = %d", w.Code)
+		}
+	})
+}
+
+func TestCabecalhoSaiPorFormulario(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.LoadHTMLFS(http.FS(view.Templates), view.PadraoTemplates)
+	home := &HomeHandler{Store: service.NewSessaoStore(service.NewArmazemSessoesMemoria(), sessions.Options{Path: "/"}, "segredo")}
+	router.GET("/perfil", func(c *gin.Context) {
+		c.Set(chaveUsuarioContexto, model.Usuario{ID: 1, Nome: "Ana", Tipo: model.RoleCliente})
+		home.ShowProfilePage(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/perfil", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d; corpo: %s", w.Code, w.Body.String())
+	}
+	var token string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == cookieCSRF {
+			token = cookie.Value
+		}
+	}
+	corpo := w.Body.String()
+	// "Sair" muda estado: um link (GET) poderia ser disparado por outro site
+	if strings.Contains(corpo, `href="/logout"`) {
+		t.Error("O cabeçalho ainda tem um link GET para /logout")
+	}
+	if !strings.Contains(corpo, `action="/logout" method="POST"`) || token == "" || !strings.Contains(corpo, `name="csrf_token" value="`+token+`"`) {
+		t.Errorf("O cabeçalho deveria sair por um POST com o token CSRF %q", token)
+	}
+}
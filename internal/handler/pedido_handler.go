@@ -0,0 +1,228 @@
+// /internal/handler/pedido_handler.go
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RepetirPedido refaz o carrinho com os itens de um pedido antigo, aos preços de hoje.
+// Itens que não podem mais ser vendidos ficam de fora; o resumo do que mudou aparece
+// como flash no carrinho.
+func (h *HomeHandler) RepetirPedido(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	falhar := func(msg string) {
+		adicionarFlash(session, FlashErro, msg)
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/cliente/pedidos")
+	}
+
+	pedidoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		falhar("Pedido não encontrado.")
+		return
+	}
+	// Só o dono do pedido pode repeti-lo
+	var pedido model.Order
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Items.Opcoes").
+		Where("id = ? AND usuario_id = ?", pedidoID, user.ID).First(&pedido).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			falhar("Pedido não encontrado.")
+			return
+		}
+		falhar("Erro ao buscar o pedido.")
+		return
+	}
+
+	resultado, err := service.Recomprar(database.DB, pedido)
+	if err != nil {
+		if errors.Is(err, service.ErrPedidoSemItens) {
+			falhar("Este pedido não tem itens para pedir novamente.")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Erro ao refazer o pedido", "pedido_id", pedido.ID, "erro", err)
+		falhar("Erro ao refazer o pedido. Tente novamente.")
+		return
+	}
+
+	if resultado.Adicionadas == 0 {
+		adicionarFlash(session, FlashErro, fmt.Sprintf("Nenhum item do pedido #%d está disponível no momento.", pedido.ID))
+		for _, removido := range resultado.Removidos {
+			adicionarFlash(session, FlashErro, removido)
+		}
+		session.Save(c.Request, c.Writer)
+		c.Redirect(http.StatusSeeOther, "/cliente/pedidos")
+		return
+	}
+
+	msg := fmt.Sprintf("Carrinho montado com os itens do pedido #%d, pelos preços atuais.", pedido.ID)
+	if len(carrinhoDaSessao(session)) > 0 {
+		msg += " Os itens que já estavam no carrinho foram substituídos."
+	}
+	adicionarFlash(session, FlashSucesso, msg)
+	for _, alterado := range resultado.Alterados {
+		adicionarFlash(session, FlashSucesso, alterado)
+	}
+	for _, removido := range resultado.Removidos {
+		adicionarFlash(session, FlashErro, "Ficou de fora: "+removido)
+	}
+
+	session.Values[CartSessionKey] = resultado.Carrinho
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "Erro ao salvar o carrinho.")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/carrinho")
+}
+
+// carregarPedido busca o pedido do parâmetro :id com os dados do detalhe e do recibo.
+// Com usuarioID diferente de zero, só encontra pedidos desse cliente.
+func carregarPedido(c *gin.Context, usuarioID uint) (model.Order, error) {
+	var pedido model.Order
+	pedidoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return pedido, gorm.ErrRecordNotFound
+	}
+	query := service.ComDetalhes(database.DB).Where("id = ?", pedidoID)
+	if usuarioID != 0 {
+		query = query.Where("usuario_id = ?", usuarioID)
+	}
+	err = query.First(&pedido).Error
+	return pedido, err
+}
+
+// responderErroPedido responde à falha de carregarPedido.
+func responderErroPedido(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusNotFound, "Pedido não encontrado.")
+		return
+	}
+	c.String(http.StatusInternalServerError, "Erro ao buscar pedido.")
+}
+
+// enviarRecibo gera o PDF do pedido e o envia para download.
+func enviarRecibo(c *gin.Context, pedido model.Order) {
+	var pdf bytes.Buffer
+	if err := service.GerarReciboPDF(&pdf, pedido); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Erro ao gerar recibo do pedido", "pedido_id", pedido.ID, "erro", err)
+		c.String(http.StatusInternalServerError, "Erro ao gerar o recibo.")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="recibo-pedido-%d.pdf"`, pedido.ID))
+	c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+}
+
+// ShowPedidoDetalhePage mostra um pedido do cliente logado: itens, pagamento, entrega e
+// a linha do tempo dos status.
+func (h *HomeHandler) ShowPedidoDetalhePage(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+
+	pedido, err := carregarPedido(c, user.ID)
+	if err != nil {
+		responderErroPedido(c, err)
+		return
+	}
+
+	session, _ := h.Store.Get(c.Request, "meu-cupcake-session")
+	c.HTML(http.StatusOK, "cliente_pedido.html", novaPagina(c, session, "pedidos", gin.H{
+		"Pedido":    pedido,
+		"Linhas":    service.LinhasRecibo(pedido.Items),
+		"Subtotal":  pedido.Total + pedido.Desconto,
+		"Eventos":   service.LinhaDoTempo(pedido),
+		"Pagamento": service.DescreverPagamento(pedido),
+		"Entrega":   service.DescreverEntrega(pedido),
+		"Endereco":  service.EnderecoEntrega(pedido),
+	}))
+}
+
+// ReciboPedido baixa o recibo em PDF de um pedido do cliente logado.
+func (h *HomeHandler) ReciboPedido(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+
+	pedido, err := carregarPedido(c, user.ID)
+	if err != nil {
+		responderErroPedido(c, err)
+		return
+	}
+	enviarRecibo(c, pedido)
+}
+
+// ReciboVenda baixa o recibo em PDF de qualquer pedido, para o lojista.
+func (h *LojistaHandler) ReciboVenda(c *gin.Context) {
+	pedido, err := carregarPedido(c, 0)
+	if err != nil {
+		responderErroPedido(c, err)
+		return
+	}
+	enviarRecibo(c, pedido)
+}
+
+// intervaloPingEventos mantém a conexão viva através de proxies.
+const intervaloPingEventos = 25 * time.Second
+
+// StreamEventosPedido envia por Server-Sent Events o status atual do pedido do cliente
+// e cada mudança seguinte, venha ela do pagamento ou do lojista.
+func (h *HomeHandler) StreamEventosPedido(c *gin.Context) {
+	user, _ := UsuarioAtual(c)
+
+	var pedido model.Order
+	pedidoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		err = gorm.ErrRecordNotFound
+	} else {
+		err = database.DB.Where("id = ? AND usuario_id = ?", pedidoID, user.ID).First(&pedido).Error
+	}
+	if err != nil {
+		responderErroPedido(c, err)
+		return
+	}
+
+	// Assina antes de enviar o status atual para não perder uma mudança no meio
+	eventos, cancelar := service.Eventos.Assinar(pedido.ID)
+	defer cancelar()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Desliga o buffer do proxy (nginx/Fly)
+	// O stream dura mais que o HTTP_WRITE_TIMEOUT do servidor
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.DebugContext(c.Request.Context(), "Não foi possível tirar o limite de escrita do stream", "erro", err)
+	}
+	c.SSEvent("status", service.NovoEventoStatus(pedido))
+	c.Writer.Flush()
+
+	// O PIX pendente é conferido em segundo plano (service.ManterConferenciaPix) e pelo
+	// webhook; o stream só repassa as mudanças
+	ping := time.NewTicker(intervaloPingEventos)
+	defer ping.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.Encerrando:
+			return
+		case evento := <-eventos:
+			c.SSEvent("status", evento)
+			c.Writer.Flush()
+		case <-ping.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// /internal/handler/saude_handler.go
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/gin-gonic/gin"
+)
+
+// tempoVerificacao é o limite de cada verificação do /readyz.
+const tempoVerificacao = 3 * time.Second
+
+// Verificacao é uma dependência conferida pelo /readyz.
+type Verificacao struct {
+	Nome      string
+	Verificar func(ctx context.Context) error
+}
+
+// SaudeHandler atende as rotas de monitoramento: /healthz, /readyz e /metrics.
+type SaudeHandler struct {
+	Verificacoes []Verificacao
+	// MetricsToken, quando definido, é exigido em /metrics como "Authorization: Bearer".
+	MetricsToken string
+}
+
+// Healthz responde 200 enquanto o processo está de pé (liveness).
+func (h *SaudeHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz confere as dependências e responde 503 se alguma falhar (readiness). O erro
+// de cada uma só vai para o log.
+func (h *SaudeHandler) Readyz(c *gin.Context) {
+	status, resultados := http.StatusOK, gin.H{}
+	for _, v := range h.Verificacoes {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), tempoVerificacao)
+		err := v.Verificar(ctx)
+		cancel()
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Verificação de prontidão falhou", "verificacao", v.Nome, "erro", err)
+			resultados[v.Nome] = "falhou"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resultados[v.Nome] = "ok"
+	}
+	situacao := "ok"
+	if status != http.StatusOK {
+		situacao = "indisponivel"
+	}
+	c.JSON(status, gin.H{"status": situacao, "verificacoes": resultados})
+}
+
+// Metricas expõe as métricas para o Prometheus.
+func (h *SaudeHandler) Metricas(c *gin.Context) {
+	if h.MetricsToken != "" {
+		esperado := "Bearer " + h.MetricsToken
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(esperado)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+	metricas.Handler().ServeHTTP(c.Writer, c.Request)
+}
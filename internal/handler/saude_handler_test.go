@@ -0,0 +1,89 @@
+// /internal/handler/saude_handler_test.go
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSaudeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(context.Context) error { return nil }
+	falha := func(context.Context) error { return errors.New("connection refused") }
+
+	novoRouter := func(h *SaudeHandler) *gin.Engine {
+		router := gin.New()
+		router.GET("/healthz", h.Healthz)
+		router.GET("/readyz", h.Readyz)
+		router.GET("/metrics", h.Metricas)
+		return router
+	}
+	chamar := func(router *gin.Engine, caminho, autorizacao string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, caminho, nil)
+		if autorizacao != "" {
+			req.Header.Set("Authorization", autorizacao)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("healthz não depende de nada", func(t *testing.T) {
+		router := novoRouter(&SaudeHandler{Verificacoes: []Verificacao{{Nome: "postgres", Verificar: falha}}})
+		if w := chamar(router, "/healthz", ""); w.Code != http.StatusOK {
+			t.Errorf("Status = %d, esperado 200", w.Code)
+		}
+	})
+
+	t.Run("readyz responde 503 se alguma verificação falha", func(t *testing.T) {
+		router := novoRouter(&SaudeHandler{Verificacoes: []Verificacao{
+			{Nome: "postgres", Verificar: ok},
+			{Nome: "mercado_pago", Verificar: falha},
+		}})
+		w := chamar(router, "/readyz", "")
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Status = %d, esperado 503", w.Code)
+		}
+		var corpo struct {
+			Status       string
+			Verificacoes map[string]string
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &corpo); err != nil {
+			t.Fatal(err)
+		}
+		if corpo.Status != "indisponivel" || corpo.Verificacoes["postgres"] != "ok" || corpo.Verificacoes["mercado_pago"] != "falhou" {
+			t.Errorf("Corpo inesperado: %s", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "refused") {
+			t.Error("O erro da verificação não deve ir na resposta")
+		}
+	})
+
+	t.Run("readyz responde 200 com tudo ok", func(t *testing.T) {
+		router := novoRouter(&SaudeHandler{Verificacoes: []Verificacao{{Nome: "postgres", Verificar: ok}}})
+		if w := chamar(router, "/readyz", ""); w.Code != http.StatusOK {
+			t.Errorf("Status = %d, esperado 200", w.Code)
+		}
+	})
+
+	t.Run("metrics exige o token quando definido", func(t *testing.T) {
+		router := novoRouter(&SaudeHandler{MetricsToken: "segredo"})
+		if w := chamar(router, "/metrics", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Sem token: status = %d, esperado 401", w.Code)
+		}
+		if w := chamar(router, "/metrics", "Bearer outro"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Token errado: status = %d, esperado 401", w.Code)
+		}
+		w := chamar(router, "/metrics", "Bearer segredo")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "# TYPE go_goroutines gauge") {
+			t.Errorf("Com token: status = %d, corpo:\n%s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// /internal/handler/webhook_handler.go
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/database"
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/ericoliveiras/meu-cupcake/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// WebhookHandler recebe as notificações do Mercado Pago (configuradas no painel com a URL
+// /webhooks/mercadopago). A notificação só diz qual pagamento mudou: o status é sempre
+// buscado na API, então uma notificação forjada não muda nenhum pedido.
+type WebhookHandler struct {
+	Pagamentos payment.Client
+	// Segredo é o MP_WEBHOOK_SECRET; vazio aceita notificações sem assinatura.
+	Segredo string
+}
+
+// notificacaoMP é o corpo das notificações de webhook do Mercado Pago.
+type notificacaoMP struct {
+	Type string `json:"type"`
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// NotificacaoMercadoPago confere o pagamento notificado e atualiza o pedido dele.
+// Responde 2xx a tudo que não deve ser reenviado; erros ao consultar o pagamento ou
+// gravar o pedido respondem 500, para o Mercado Pago tentar de novo.
+func (h *WebhookHandler) NotificacaoMercadoPago(c *gin.Context) {
+	ctx := c.Request.Context()
+	var corpo notificacaoMP
+	_ = c.ShouldBindJSON(&corpo)
+	tipo := c.DefaultQuery("type", corpo.Type)
+	dataID := c.DefaultQuery("data.id", corpo.Data.ID)
+
+	if h.Segredo != "" && !service.AssinaturaWebhookValida(h.Segredo, c.GetHeader("X-Signature"), c.GetHeader("X-Request-Id"), dataID) {
+		slog.WarnContext(ctx, "Notificação do Mercado Pago com assinatura inválida", "pagamento_id", dataID)
+		metricas.EventosWebhook.WithLabelValues("invalido").Inc()
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	if tipo != "payment" {
+		metricas.EventosWebhook.WithLabelValues("ignorado").Inc()
+		c.Status(http.StatusOK)
+		return
+	}
+	pagamentoID, err := strconv.ParseInt(dataID, 10, 64)
+	if err != nil {
+		metricas.EventosWebhook.WithLabelValues("invalido").Inc()
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var pedido model.Order
+	err = database.DB.WithContext(ctx).Where("pagamento_mp_id = ?", pagamentoID).First(&pedido).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Pagamento de outra origem (ou de um pedido apagado): nada a fazer
+		metricas.EventosWebhook.WithLabelValues("ignorado").Inc()
+		c.Status(http.StatusOK)
+		return
+	}
+	if err == nil {
+		_, err = service.ConferirPagamentoPedido(ctx, database.DB, h.Pagamentos, &pedido)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao processar notificação do Mercado Pago", "pagamento_id", pagamentoID, "erro", err)
+		metricas.EventosWebhook.WithLabelValues("erro").Inc()
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	metricas.EventosWebhook.WithLabelValues("processado").Inc()
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,53 @@
+// /internal/handler/webhook_handler_test.go
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWebhookMercadoPago(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhooks/mercadopago", (&WebhookHandler{Segredo: "segredo"}).NotificacaoMercadoPago)
+	notificar := func(consulta, corpo, assinatura string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/mercadopago"+consulta, strings.NewReader(corpo))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signature", assinatura)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	contagem := func(resultado string) float64 {
+		return testutil.ToFloat64(metricas.EventosWebhook.WithLabelValues(resultado))
+	}
+
+	t.Run("Assinatura inválida é recusada e contada", func(t *testing.T) {
+		antes := contagem("invalido")
+		status := notificar("?type=payment&data.id=123", `{"type":"payment","data":{"id":"123"}}`, "ts=1,v1=abc")
+		if status != http.StatusUnauthorized {
+			t.Errorf("Status = %d; esperado 401", status)
+		}
+		if contagem("invalido") != antes+1 {
+			t.Error("A notificação recusada deveria ser contada como invalido")
+		}
+	})
+
+	t.Run("Notificação que não é de pagamento é ignorada", func(t *testing.T) {
+		router := gin.New()
+		router.POST("/webhooks/mercadopago", (&WebhookHandler{}).NotificacaoMercadoPago)
+		antes := contagem("ignorado")
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/mercadopago?type=merchant_order&data.id=9", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || contagem("ignorado") != antes+1 {
+			t.Errorf("Status = %d, ignorados = %v; esperado 200 e um a mais", w.Code, contagem("ignorado")-antes)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// /internal/logging/gin.go
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CabecalhoRequestID é o cabeçalho que traz (do proxy) e devolve o ID da requisição.
+const CabecalhoRequestID = "X-Request-ID"
+
+// reRequestIDValido aceita IDs de proxies (UUID, hex, Fly.io) sem abrir espaço para
+// injeção de texto no log.
+var reRequestIDValido = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// rotasMonitoramento são chamadas a cada poucos segundos; com sucesso, não poluem o log.
+var rotasMonitoramento = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// novoRequestID gera um ID aleatório de 16 caracteres hexadecimais.
+func novoRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware dá um ID a cada requisição (reaproveitando um X-Request-ID válido),
+// devolve-o na resposta, guarda-o no contexto da requisição para os logs dos handlers
+// e registra uma linha de acesso ao final. Substitui o logger e o recovery do gin;
+// http.ErrAbortHandler segue adiante, como no recovery do net/http.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		id := c.GetHeader(CabecalhoRequestID)
+		if !reRequestIDValido.MatchString(id) {
+			id = novoRequestID()
+		}
+		c.Header(CabecalhoRequestID, id)
+		c.Request = c.Request.WithContext(ComRequestID(c.Request.Context(), id))
+
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					// Aborto intencional (ex.: cliente foi embora no meio do stream): o
+					// net/http precisa do pânico para derrubar a conexão sem responder
+					registrarAcesso(c, inicio)
+					panic(r)
+				}
+				slog.ErrorContext(c.Request.Context(), "Pânico ao atender a requisição",
+					"panico", r, "stack", string(debug.Stack()))
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+			registrarAcesso(c, inicio)
+		}()
+		c.Next()
+	}
+}
+
+// registrarAcesso escreve a linha de acesso. A rota vem como padrão (/convite/:token),
+// para os tokens dos links não irem para o log.
+func registrarAcesso(c *gin.Context, inicio time.Time) {
+	rota := c.FullPath()
+	if rota == "" {
+		rota = "(sem rota)"
+	}
+	status := c.Writer.Status()
+	nivel := slog.LevelInfo
+	switch {
+	case status >= 500:
+		nivel = slog.LevelError
+	case status >= 400:
+		nivel = slog.LevelWarn
+	case rotasMonitoramento[rota]:
+		// Checagens do Fly.io e coletas do Prometheus só aparecem em LOG_LEVEL=debug
+		nivel = slog.LevelDebug
+	}
+	attrs := []slog.Attr{
+		slog.String("metodo", c.Request.Method),
+		slog.String("rota", rota),
+		slog.Int("status", status),
+		slog.Duration("duracao", time.Since(inicio)),
+		slog.String("ip", c.ClientIP()),
+		slog.Int("bytes", c.Writer.Size()),
+	}
+	if len(c.Errors) > 0 {
+		attrs = append(attrs, slog.String("erros", c.Errors.String()))
+	}
+	slog.LogAttrs(c.Request.Context(), nivel, "Requisição", attrs...)
+}
@@ -0,0 +1,88 @@
+// /internal/logging/logging.go
+//
+// Logger estruturado (log/slog) da aplicação: JSON em produção, texto no
+// desenvolvimento, com o ID da requisição em cada linha e dados sensíveis mascarados.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Config define o nível e o formato dos logs (LOG_LEVEL e LOG_FORMAT em config.Log).
+type Config struct {
+	Nivel   slog.Level
+	Formato string // "json" ou "text"
+}
+
+// New cria o logger que escreve em w.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opcoes := &slog.HandlerOptions{Level: cfg.Nivel}
+	var base slog.Handler
+	if cfg.Formato == "json" {
+		base = slog.NewJSONHandler(w, opcoes)
+	} else {
+		base = slog.NewTextHandler(w, opcoes)
+	}
+	return slog.New(&handler{base: base})
+}
+
+// Setup configura o logger padrão. Depois dele, slog.* e também o pacote log
+// (log.Printf) passam pelo mesmo formato e pela mesma máscara.
+func Setup(cfg Config) *slog.Logger {
+	logger := New(os.Stdout, cfg)
+	slog.SetDefault(logger)
+	return logger
+}
+
+type chaveRequestID struct{}
+
+// ComRequestID guarda o ID da requisição no contexto.
+func ComRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, chaveRequestID{}, id)
+}
+
+// RequestID devolve o ID da requisição guardado no contexto ("" sem ID).
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(chaveRequestID{}).(string)
+	return id
+}
+
+// handler acrescenta o request_id e mascara dados sensíveis antes de repassar o
+// registro ao handler de saída.
+type handler struct {
+	base slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, nivel slog.Level) bool {
+	return h.base.Enabled(ctx, nivel)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	limpo := slog.NewRecord(r.Time, r.Level, MascararTexto(r.Message), r.PC)
+	if id := RequestID(ctx); id != "" {
+		limpo.AddAttrs(slog.String("request_id", id))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		limpo.AddAttrs(mascararAttr(a))
+		return true
+	})
+	return h.base.Handle(ctx, limpo)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	limpos := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		limpos[i] = mascararAttr(a)
+	}
+	return &handler{base: h.base.WithAttrs(limpos)}
+}
+
+func (h *handler) WithGroup(nome string) slog.Handler {
+	return &handler{base: h.base.WithGroup(nome)}
+}
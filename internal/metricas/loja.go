@@ -0,0 +1,86 @@
+// /internal/metricas/loja.go
+package metricas
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Métricas da loja, expostas em /metrics.
+var (
+	// DuracaoHTTP mede cada requisição por rota do Gin (o padrão, como /pedidos/:id, para
+	// não criar uma série por ID), método e status.
+	DuracaoHTTP = registrar.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration_seconds",
+		Help: "Duração das requisições HTTP por rota, método e status.",
+	}, []string{"method", "route", "status"})
+
+	// PedidosCriados conta os pedidos gravados no checkout, por forma de pagamento
+	// (cartao ou pix).
+	PedidosCriados = registrar.NewCounterVec(prometheus.CounterOpts{
+		Name: "meucupcake_pedidos_criados_total",
+		Help: "Pedidos criados no checkout, por forma de pagamento.",
+	}, []string{"metodo"})
+
+	// Pagamentos conta os pagamentos decididos pelo Mercado Pago, por forma de pagamento
+	// e resultado (aprovado ou rejeitado).
+	Pagamentos = registrar.NewCounterVec(prometheus.CounterOpts{
+		Name: "meucupcake_pagamentos_total",
+		Help: "Pagamentos por forma de pagamento e resultado.",
+	}, []string{"metodo", "resultado"})
+
+	// AdicoesCarrinho conta os produtos adicionados ao carrinho (cupcake ou kit).
+	AdicoesCarrinho = registrar.NewCounterVec(prometheus.CounterOpts{
+		Name: "meucupcake_carrinho_adicoes_total",
+		Help: "Adições ao carrinho, por tipo de produto.",
+	}, []string{"tipo"})
+
+	// EventosWebhook conta as notificações do Mercado Pago recebidas em
+	// /webhooks/mercadopago, por resultado (processado, ignorado, invalido ou erro).
+	EventosWebhook = registrar.NewCounterVec(prometheus.CounterOpts{
+		Name: "meucupcake_webhook_eventos_total",
+		Help: "Eventos de webhook do Mercado Pago processados, por resultado.",
+	}, []string{"resultado"})
+)
+
+// ResultadoPagamento traduz o status de um pagamento do Mercado Pago no rótulo de
+// Pagamentos ("" enquanto o pagamento não está decidido).
+func ResultadoPagamento(status string) string {
+	switch status {
+	case "approved":
+		return "aprovado"
+	case "rejected", "cancelled", "expired":
+		return "rejeitado"
+	}
+	return ""
+}
+
+// RegistrarPagamento conta o pagamento, se o status o decide.
+func RegistrarPagamento(metodo, status string) {
+	if resultado := ResultadoPagamento(status); resultado != "" {
+		Pagamentos.WithLabelValues(metodo, resultado).Inc()
+	}
+}
+
+// Middleware registra a duração e o status de cada requisição em DuracaoHTTP.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+		rota := c.FullPath()
+		if rota == "" {
+			rota = "(sem rota)"
+		}
+		DuracaoHTTP.WithLabelValues(c.Request.Method, rota, strconv.Itoa(c.Writer.Status())).Observe(time.Since(inicio).Seconds())
+	}
+}
+
+// RegistrarPoolDB expõe o estado do pool de conexões do banco (go_sql_*).
+func RegistrarPoolDB(r prometheus.Registerer, db *sql.DB) {
+	r.MustRegister(collectors.NewDBStatsCollector(db, "meucupcake"))
+}
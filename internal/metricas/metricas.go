@@ -0,0 +1,35 @@
+// /internal/metricas/metricas.go
+//
+// Métricas para o Prometheus, pelo client_golang: um registro próprio (sem as métricas
+// globais de bibliotecas), com as do runtime do Go e do processo.
+package metricas
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Padrao é o registro exposto em /metrics.
+var Padrao = prometheus.NewRegistry()
+
+// registrar cria as métricas da loja já registradas em Padrao.
+var registrar = promauto.With(Padrao)
+
+func init() {
+	Padrao.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// manipulador é criado uma vez: ele registra em Padrao os próprios erros de coleta.
+var manipulador = promhttp.HandlerFor(Padrao, promhttp.HandlerOpts{Registry: Padrao})
+
+// Handler responde com as métricas de Padrao, no formato que o Prometheus negociar.
+func Handler() http.Handler {
+	return manipulador
+}
@@ -0,0 +1,90 @@
+// /internal/metricas/metricas_test.go
+package metricas
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// exposicao devolve o texto servido em /metrics.
+func exposicao(t *testing.T, h http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	corpo, _ := io.ReadAll(w.Result().Body)
+	return string(corpo)
+}
+
+func TestResultadoPagamento(t *testing.T) {
+	casos := map[string]string{
+		"approved": "aprovado", "rejected": "rejeitado", "cancelled": "rejeitado",
+		"expired": "rejeitado", "pending": "", "in_process": "",
+	}
+	for status, esperado := range casos {
+		if got := ResultadoPagamento(status); got != esperado {
+			t.Errorf("ResultadoPagamento(%q) = %q, esperado %q", status, got, esperado)
+		}
+	}
+}
+
+func TestRegistrarPagamento(t *testing.T) {
+	antes := testutil.ToFloat64(Pagamentos.WithLabelValues("pix", "aprovado"))
+	RegistrarPagamento("pix", "approved")
+	RegistrarPagamento("pix", "pending")
+	if v := testutil.ToFloat64(Pagamentos.WithLabelValues("pix", "aprovado")); v != antes+1 {
+		t.Errorf("Pagamentos pix aprovados = %v; esperado %v", v, antes+1)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/pedidos/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, caminho := range []string{"/pedidos/1", "/pedidos/2", "/nao-existe"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, caminho, nil))
+	}
+
+	texto := exposicao(t, Handler())
+	for _, linha := range []string{
+		`http_request_duration_seconds_count{method="GET",route="/pedidos/:id",status="204"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="(sem rota)",status="404"} 1`,
+		"# TYPE go_goroutines gauge",
+	} {
+		if !strings.Contains(texto, linha) {
+			t.Errorf("Faltou %q em:\n%s", linha, texto)
+		}
+	}
+}
+
+func TestRegistrarPoolDB(t *testing.T) {
+	db, err := sql.Open("pgx", "host=invalido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := prometheus.NewRegistry()
+	RegistrarPoolDB(r, db)
+
+	texto := exposicao(t, promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	for _, linha := range []string{
+		`go_sql_max_open_connections{db_name="meucupcake"} 0`,
+		`go_sql_in_use_connections{db_name="meucupcake"} 0`,
+		`go_sql_wait_count_total{db_name="meucupcake"} 0`,
+	} {
+		if !strings.Contains(texto, linha) {
+			t.Errorf("Faltou %q em:\n%s", linha, texto)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// /internal/model/auditoria.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditoriaImutavel impede que um registro de auditoria seja alterado ou excluído
+// pelo GORM. Só a política de retenção apaga registros antigos (com SQL direto).
+var ErrAuditoriaImutavel = errors.New("registros de auditoria não podem ser alterados")
+
+// Ações registradas na auditoria.
+const (
+	AcaoCriar          = "criar"
+	AcaoEditar         = "editar"
+	AcaoExcluir        = "excluir"
+	AcaoStatus         = "status"          // Mudança de status de pedido
+	AcaoConvidar       = "convidar"        // Convite para a equipe
+	AcaoPapel          = "papel"           // Troca de papel na equipe
+	AcaoAcesso         = "acesso"          // Novo link de acesso da equipe
+	AcaoDesativar      = "desativar"       // Conta de cliente desativada
+	AcaoReativar       = "reativar"        // Conta de cliente reativada
+	AcaoRedefinirSenha = "redefinir_senha" // E-mail de redefinição enviado ao cliente
+	AcaoModerar        = "moderar"         // Avaliação aprovada ou rejeitada
+	AcaoResponder      = "responder"       // Resposta da loja a uma avaliação
+)
+
+// AcoesAuditoria lista as ações na ordem do filtro, com o nome exibido.
+var AcoesAuditoria = []struct{ Valor, Titulo string }{
+	{AcaoCriar, "Criação"},
+	{AcaoEditar, "Edição"},
+	{AcaoExcluir, "Exclusão"},
+	{AcaoStatus, "Status do pedido"},
+	{AcaoConvidar, "Convite"},
+	{AcaoPapel, "Troca de papel"},
+	{AcaoAcesso, "Novo link de acesso"},
+	{AcaoDesativar, "Desativação"},
+	{AcaoReativar, "Reativação"},
+	{AcaoRedefinirSenha, "Redefinição de senha"},
+	{AcaoModerar, "Moderação"},
+	{AcaoResponder, "Resposta"},
+}
+
+// EntidadesAuditoria lista os tipos de registro auditados, com o nome exibido.
+var EntidadesAuditoria = []struct{ Valor, Titulo string }{
+	{"cupcake", "Cupcake"},
+	{"grupo_opcao", "Grupo de opções"},
+	{"opcao", "Opção"},
+	{"kit", "Kit"},
+	{"pedido", "Pedido"},
+	{"cupom", "Cupom"},
+	{"janela", "Janela de entrega"},
+	{"bloqueio", "Data bloqueada"},
+	{"avaliacao", "Avaliação"},
+	{"equipe", "Membro da equipe"},
+	{"cliente", "Cliente"},
+}
+
+// AuditLog registra uma ação administrativa: quem fez, o quê, em qual registro e quais
+// campos mudaram. Só recebe inclusões.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"not null;index"`
+	AutorID    *uint     `gorm:"index"`
+	AutorNome  string    `gorm:"size:255"` // Mantém o nome mesmo se o membro for removido
+	Acao       string    `gorm:"size:30;not null;index"`
+	Entidade   string    `gorm:"size:30;not null;index:idx_audit_entidade"`
+	EntidadeID uint      `gorm:"not null;index:idx_audit_entidade"`
+	Mudancas   string    `gorm:"type:jsonb;not null;default:'[]'"` // []CampoAuditoria
+	IP         string    `gorm:"size:45"`
+}
+
+// CampoAuditoria é um campo alterado, com o valor antes e depois (nil quando o registro
+// não existia antes ou deixou de existir).
+type CampoAuditoria struct {
+	Campo  string `json:"campo"`
+	Antes  any    `json:"antes"`
+	Depois any    `json:"depois"`
+}
+
+// BeforeUpdate mantém a auditoria somente de inclusão.
+func (AuditLog) BeforeUpdate(*gorm.DB) error { return ErrAuditoriaImutavel }
+
+// BeforeDelete mantém a auditoria somente de inclusão.
+func (AuditLog) BeforeDelete(*gorm.DB) error { return ErrAuditoriaImutavel }
+
+// Campos decodifica as mudanças gravadas para exibição.
+func (a AuditLog) Campos() []CampoAuditoria {
+	var campos []CampoAuditoria
+	if err := json.Unmarshal([]byte(a.Mudancas), &campos); err != nil {
+		return nil
+	}
+	return campos
+}
+
+// TituloAcao é o nome da ação exibido nas telas.
+func (a AuditLog) TituloAcao() string {
+	for _, acao := range AcoesAuditoria {
+		if acao.Valor == a.Acao {
+			return acao.Titulo
+		}
+	}
+	return a.Acao
+}
+
+// TituloEntidade é o nome do tipo de registro exibido nas telas.
+func (a AuditLog) TituloEntidade() string {
+	for _, entidade := range EntidadesAuditoria {
+		if entidade.Valor == a.Entidade {
+			return entidade.Titulo
+		}
+	}
+	return a.Entidade
+}
+
+// ValorAuditoria formata um valor de CampoAuditoria para exibição.
+func ValorAuditoria(v any) string {
+	switch valor := v.(type) {
+	case nil:
+		return "—"
+	case string:
+		return valor
+	case bool:
+		if valor {
+			return "sim"
+		}
+		return "não"
+	case float64:
+		return fmt.Sprint(valor)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// AntesTexto é o valor anterior formatado.
+func (c CampoAuditoria) AntesTexto() string { return ValorAuditoria(c.Antes) }
+
+// DepoisTexto é o novo valor formatado.
+func (c CampoAuditoria) DepoisTexto() string { return ValorAuditoria(c.Depois) }
@@ -0,0 +1,54 @@
+// /internal/model/avaliacao.go
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// StatusAvaliacao controla se a avaliação aparece na vitrine.
+type StatusAvaliacao string
+
+const (
+	AvaliacaoPendente StatusAvaliacao = "pendente" // Aguardando moderação do lojista
+	AvaliacaoAprovada StatusAvaliacao = "aprovada" // Visível na vitrine e na média
+	AvaliacaoOculta   StatusAvaliacao = "oculta"   // Escondida pelo lojista
+)
+
+// Avaliacao é a nota (1 a 5) e o comentário de um cliente sobre um cupcake que ele
+// recebeu. Cada cliente tem uma avaliação por cupcake; editar volta para a moderação.
+type Avaliacao struct {
+	ID           uint            `gorm:"primaryKey"`
+	CupcakeID    uint            `gorm:"not null;uniqueIndex:idx_avaliacao_cliente_cupcake"`
+	Cupcake      Cupcake         `gorm:"foreignKey:CupcakeID"`
+	UsuarioID    uint            `gorm:"not null;uniqueIndex:idx_avaliacao_cliente_cupcake"`
+	Usuario      Usuario         `gorm:"foreignKey:UsuarioID"`
+	Nota         int             `gorm:"not null"`
+	Texto        string          `gorm:"type:text"`
+	FotoURL      string          // Opcional, com as mesmas rendições das fotos dos produtos
+	Status       StatusAvaliacao `gorm:"type:varchar(20);not null;default:'pendente';index"`
+	Resposta     string          `gorm:"type:text"` // Resposta pública do lojista
+	RespondidaEm *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// FotoThumb serve a foto da avaliação em miniatura.
+func (a Avaliacao) FotoThumb() string { return URLRendicao(a.FotoURL, RendicaoThumb) }
+
+// Estrelas desenha a nota (ex.: "★★★★☆").
+func (a Avaliacao) Estrelas() string { return Estrelas(a.Nota) }
+
+// NomePublico é como o cliente aparece nas avaliações da vitrine (só o primeiro nome).
+func (a Avaliacao) NomePublico() string {
+	if campos := strings.Fields(a.Usuario.Nome); len(campos) > 0 {
+		return campos[0]
+	}
+	return "Cliente"
+}
+
+// Estrelas desenha uma nota de 0 a 5.
+func Estrelas(nota int) string {
+	nota = max(0, min(nota, 5))
+	return strings.Repeat("★", nota) + strings.Repeat("☆", 5-nota)
+}
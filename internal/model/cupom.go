@@ -0,0 +1,35 @@
+// /internal/model/cupom.go
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TipoCupom define como o desconto do cupom é calculado.
+type TipoCupom string
+
+const (
+	CupomPercentual TipoCupom = "percentual"
+	CupomValorFixo  TipoCupom = "valor_fixo"
+)
+
+// Cupom representa um cupom de desconto aplicado no carrinho.
+type Cupom struct {
+	ID                  uint      `gorm:"primaryKey"`
+	Codigo              string    `gorm:"uniqueIndex;size:50;not null"` // Sempre em maiúsculas
+	Descricao           string    `gorm:"size:255"`
+	Tipo                TipoCupom `gorm:"type:varchar(20);not null"`
+	Valor               float64   `gorm:"not null"` // Percentual (0-100) ou valor em R$
+	ValorMinimo         float64   `gorm:"not null;default:0"`
+	ValidoDe            *time.Time
+	ValidoAte           *time.Time
+	LimiteUsoTotal      int       `gorm:"not null;default:0"` // 0 = sem limite
+	LimiteUsoPorCliente int       `gorm:"not null;default:0"` // 0 = sem limite
+	Ativo               bool      `gorm:"default:true"`
+	Cupcakes            []Cupcake `gorm:"many2many:cupom_cupcakes;"` // Vazio = vale para todos
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           gorm.DeletedAt `gorm:"index"`
+}
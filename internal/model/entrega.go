@@ -0,0 +1,49 @@
+// /internal/model/entrega.go
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TipoEntrega define se o pedido será entregue ou retirado na loja.
+type TipoEntrega string
+
+const (
+	TipoEntregaDelivery TipoEntrega = "entrega"
+	TipoEntregaRetirada TipoEntrega = "retirada"
+)
+
+// JanelaEntrega é uma faixa de horário semanal em que a loja entrega ou libera retiradas.
+type JanelaEntrega struct {
+	ID          uint        `gorm:"primaryKey"`
+	DiaSemana   int         `gorm:"not null;index"`  // 0 = domingo ... 6 = sábado (time.Weekday)
+	HoraInicio  string      `gorm:"size:5;not null"` // "14:00"
+	HoraFim     string      `gorm:"size:5;not null"` // "16:00"
+	Tipo        TipoEntrega `gorm:"type:varchar(20);not null;default:'entrega'"`
+	MaxPedidos  int         `gorm:"not null;default:0"` // 0 = sem limite
+	MaxCupcakes int         `gorm:"not null;default:0"` // 0 = sem limite
+	Ativa       bool        `gorm:"default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// DataBloqueada é um dia sem entregas nem retiradas (feriado, folga, etc.).
+type DataBloqueada struct {
+	ID        uint      `gorm:"primaryKey"`
+	Data      time.Time `gorm:"type:date;uniqueIndex;not null"`
+	Motivo    string    `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+// OcupacaoJanela guarda quanto da capacidade de uma janela já foi consumida em uma data.
+type OcupacaoJanela struct {
+	ID        uint      `gorm:"primaryKey"`
+	JanelaID  uint      `gorm:"not null;uniqueIndex:idx_ocupacao_janela_data"`
+	Data      time.Time `gorm:"type:date;not null;uniqueIndex:idx_ocupacao_janela_data"`
+	Pedidos   int       `gorm:"not null;default:0"`
+	Cupcakes  int       `gorm:"not null;default:0"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,13 @@
+// /internal/model/favorito.go
+package model
+
+import "time"
+
+// Favorito marca um cupcake na lista de favoritos do cliente.
+type Favorito struct {
+	ID        uint    `gorm:"primaryKey"`
+	UsuarioID uint    `gorm:"not null;uniqueIndex:idx_favorito_cliente_cupcake"`
+	CupcakeID uint    `gorm:"not null;uniqueIndex:idx_favorito_cliente_cupcake;index"`
+	Cupcake   Cupcake `gorm:"foreignKey:CupcakeID"`
+	CreatedAt time.Time
+}
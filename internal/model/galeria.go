@@ -0,0 +1,54 @@
+// /internal/model/galeria.go
+package model
+
+import "time"
+
+// ImagemPadrao é exibida para produtos cadastrados sem foto.
+const ImagemPadrao = "/static/images/placeholder.png"
+
+// MaxImagensCupcake limita o tamanho da galeria de cada cupcake.
+const MaxImagensCupcake = 8
+
+// CupcakeImagem é uma foto da galeria do cupcake. A de menor Posicao é a principal e
+// também fica em Cupcake.ImagemURL, usada pelos cards, carrinho e pedidos.
+type CupcakeImagem struct {
+	ID        uint   `gorm:"primaryKey"`
+	CupcakeID uint   `gorm:"not null;index"`
+	URL       string `gorm:"not null"`
+	TextoAlt  string `gorm:"size:255"`
+	Posicao   int    `gorm:"not null;default:0"`
+	CreatedAt time.Time
+}
+
+// ImagemThumb, ImagemCard e ImagemSrcset servem a foto no tamanho certo.
+func (i CupcakeImagem) ImagemThumb() string  { return URLRendicao(i.URL, RendicaoThumb) }
+func (i CupcakeImagem) ImagemCard() string   { return URLRendicao(i.URL, RendicaoCard) }
+func (i CupcakeImagem) ImagemSrcset() string { return SrcsetImagem(i.URL) }
+
+// Galeria retorna as fotos do cupcake (com Imagens carregado), com o nome do cupcake
+// como texto alternativo quando o lojista não informou um. Cupcakes cadastrados antes
+// da galeria têm só o ImagemURL, devolvido como uma galeria de uma foto com ID zero.
+func (c Cupcake) Galeria() []CupcakeImagem {
+	if len(c.Imagens) == 0 {
+		if c.ImagemURL == "" || c.ImagemURL == ImagemPadrao {
+			return nil
+		}
+		return []CupcakeImagem{{CupcakeID: c.ID, URL: c.ImagemURL, TextoAlt: c.Nome}}
+	}
+	galeria := make([]CupcakeImagem, len(c.Imagens))
+	for i, img := range c.Imagens {
+		if img.TextoAlt == "" {
+			img.TextoAlt = c.Nome
+		}
+		galeria[i] = img
+	}
+	return galeria
+}
+
+// ImagemAlt é o texto alternativo da imagem principal.
+func (c Cupcake) ImagemAlt() string {
+	if len(c.Imagens) > 0 && c.Imagens[0].TextoAlt != "" && c.Imagens[0].URL == c.ImagemURL {
+		return c.Imagens[0].TextoAlt
+	}
+	return c.Nome
+}
@@ -0,0 +1,62 @@
+// /internal/model/imagem.go
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rendicao é um dos tamanhos gerados para cada imagem enviada. As imagens processadas
+// ficam em "<nome>-<rendição>.jpg" e o ImagemURL guardado aponta para a rendição "full".
+type Rendicao struct {
+	Nome     string
+	Largura  int
+	Altura   int
+	Recortar bool // true = preenche exatamente Largura x Altura; false = cabe dentro
+}
+
+var (
+	RendicaoThumb = Rendicao{Nome: "thumb", Largura: 200, Altura: 200, Recortar: true} // Carrinho, pedidos, listas
+	RendicaoCard  = Rendicao{Nome: "card", Largura: 600, Altura: 400, Recortar: true}  // Cards da vitrine
+	RendicaoFull  = Rendicao{Nome: "full", Largura: 1600, Altura: 1600}                // Modal / zoom
+
+	// Rendicoes lista todas as rendições geradas, da menor para a maior.
+	Rendicoes = []Rendicao{RendicaoThumb, RendicaoCard, RendicaoFull}
+)
+
+// SufixoRendicao é o final do nome do arquivo da rendição (ex.: "-card.jpg").
+func SufixoRendicao(r Rendicao) string {
+	return "-" + r.Nome + ".jpg"
+}
+
+// URLRendicao troca a rendição de uma imagem processada. Imagens antigas (enviadas antes
+// do processamento) e a imagem padrão não têm rendições e são devolvidas como estão.
+func URLRendicao(imagemURL string, r Rendicao) string {
+	base, ok := strings.CutSuffix(imagemURL, SufixoRendicao(RendicaoFull))
+	if !ok {
+		return imagemURL
+	}
+	return base + SufixoRendicao(r)
+}
+
+// SrcsetImagem monta o atributo srcset com todas as rendições da imagem.
+func SrcsetImagem(imagemURL string) string {
+	if URLRendicao(imagemURL, RendicaoThumb) == imagemURL {
+		return imagemURL
+	}
+	partes := make([]string, 0, len(Rendicoes))
+	for _, r := range Rendicoes {
+		partes = append(partes, fmt.Sprintf("%s %dw", URLRendicao(imagemURL, r), r.Largura))
+	}
+	return strings.Join(partes, ", ")
+}
+
+// ImagemThumb, ImagemCard e ImagemSrcset servem a imagem do cupcake no tamanho certo.
+func (c Cupcake) ImagemThumb() string  { return URLRendicao(c.ImagemURL, RendicaoThumb) }
+func (c Cupcake) ImagemCard() string   { return URLRendicao(c.ImagemURL, RendicaoCard) }
+func (c Cupcake) ImagemSrcset() string { return SrcsetImagem(c.ImagemURL) }
+
+// ImagemThumb, ImagemCard e ImagemSrcset servem a imagem do kit no tamanho certo.
+func (k Kit) ImagemThumb() string  { return URLRendicao(k.ImagemURL, RendicaoThumb) }
+func (k Kit) ImagemCard() string   { return URLRendicao(k.ImagemURL, RendicaoCard) }
+func (k Kit) ImagemSrcset() string { return SrcsetImagem(k.ImagemURL) }
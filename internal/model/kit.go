@@ -0,0 +1,40 @@
+// /internal/model/kit.go
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kit é um produto composto por outros cupcakes, com preço próprio (ex.: "Caixa com 6",
+// "Kit Festa"). Kits fixos têm a composição em Itens; na "monte sua caixa" (TamanhoCaixa > 0)
+// o cliente escolhe TamanhoCaixa cupcakes entre os Permitidos.
+type Kit struct {
+	ID           uint      `gorm:"primaryKey"`
+	Nome         string    `gorm:"not null;size:100"`
+	Descricao    string    `gorm:"type:text"`
+	Preco        float64   `gorm:"not null"`
+	ImagemURL    string    `gorm:"not null"`
+	Disponivel   bool      `gorm:"default:true"`
+	TamanhoCaixa int       `gorm:"not null;default:0"` // 0 = composição fixa
+	Itens        []KitItem `gorm:"foreignKey:KitID"`
+	Permitidos   []Cupcake `gorm:"many2many:kit_cupcakes_permitidos;"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// MonteSuaCaixa indica se o cliente escolhe os cupcakes do kit.
+func (k Kit) MonteSuaCaixa() bool {
+	return k.TamanhoCaixa > 0
+}
+
+// KitItem é um componente de um kit de composição fixa.
+type KitItem struct {
+	ID         uint    `gorm:"primaryKey"`
+	KitID      uint    `gorm:"not null;index"`
+	CupcakeID  uint    `gorm:"not null"`
+	Cupcake    Cupcake `gorm:"foreignKey:CupcakeID"`
+	Quantidade int     `gorm:"not null"`
+}
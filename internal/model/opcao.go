@@ -0,0 +1,59 @@
+// /internal/model/opcao.go
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TipoGrupoOpcao define como o cliente preenche um grupo de opções.
+type TipoGrupoOpcao string
+
+const (
+	GrupoEscolha TipoGrupoOpcao = "escolha" // Escolha entre opções (ex.: Tamanho, Recheio)
+	GrupoTexto   TipoGrupoOpcao = "texto"   // Texto livre (ex.: mensagem no topper)
+)
+
+// GrupoOpcao agrupa as opções de personalização de um cupcake.
+type GrupoOpcao struct {
+	ID          uint           `gorm:"primaryKey"`
+	CupcakeID   uint           `gorm:"not null;index"`
+	Nome        string         `gorm:"not null;size:100"`
+	Tipo        TipoGrupoOpcao `gorm:"type:varchar(20);not null;default:'escolha'"`
+	Obrigatorio bool           `gorm:"default:false"`
+	MinEscolhas int            `gorm:"not null;default:0"` // Só para grupos de escolha
+	MaxEscolhas int            `gorm:"not null;default:1"` // 0 = sem limite
+	// Para grupos de texto: tamanho máximo e preço cobrado quando preenchido
+	MaxCaracteres  int     `gorm:"not null;default:0"`
+	PrecoAdicional float64 `gorm:"not null;default:0"`
+	Posicao        int     `gorm:"not null;default:0"`
+	Opcoes         []Opcao `gorm:"foreignKey:GrupoOpcaoID"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+// Opcao é uma escolha dentro de um grupo, com a diferença de preço sobre o cupcake.
+type Opcao struct {
+	ID             uint    `gorm:"primaryKey"`
+	GrupoOpcaoID   uint    `gorm:"not null;index"`
+	Nome           string  `gorm:"not null;size:100"`
+	PrecoAdicional float64 `gorm:"not null;default:0"` // Pode ser negativo (ex.: tamanho mini)
+	Disponivel     bool    `gorm:"default:true"`
+	Posicao        int     `gorm:"not null;default:0"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+// ItemOrderOpcao guarda a opção escolhida em um item do pedido no momento da compra.
+type ItemOrderOpcao struct {
+	ID             uint   `gorm:"primaryKey"`
+	ItemOrderID    uint   `gorm:"not null;index"`
+	GrupoOpcaoID   uint   `gorm:"not null"`
+	OpcaoID        *uint  // Nulo para grupos de texto
+	Grupo          string `gorm:"not null;size:100"`
+	Valor          string `gorm:"not null;size:255"` // Nome da opção ou texto digitado
+	PrecoAdicional float64
+}
@@ -14,26 +14,45 @@ const (
 	StatusPendente   StatusOrder = "pendente"
 	StatusPago       StatusOrder = "pago"
 	StatusFalhou     StatusOrder = "falhou"
-	StatusPreparando StatusOrder = "preparando" 
-	StatusEnviado    StatusOrder = "enviado"   
-	StatusEntregue   StatusOrder = "entregue"   
-	StatusCancelado  StatusOrder = "cancelado"  
+	StatusPreparando StatusOrder = "preparando"
+	StatusEnviado    StatusOrder = "enviado"
+	StatusEntregue   StatusOrder = "entregue"
+	StatusCancelado  StatusOrder = "cancelado"
 )
 
 // Order representa uma ordem de compra no sistema.
 type Order struct {
 	ID        uint        `gorm:"primaryKey"`
-	UsuarioID uint        `gorm:"not null"`            
-	Usuario   Usuario     `gorm:"foreignKey:UsuarioID"` 
+	UsuarioID uint        `gorm:"not null"`
+	Usuario   Usuario     `gorm:"foreignKey:UsuarioID"`
 	Status    StatusOrder `gorm:"type:varchar(20);not null;default:'pendente'"`
 	Total     float64     `gorm:"not null"`
 	// --- Informações do Pagamento ---
-	PagamentoMPID   *int64 `gorm:"uniqueIndex"` 
+	PagamentoMPID   *int64 `gorm:"uniqueIndex"`
 	MetodoPagamento string // Ex: "credit_card"
 	Parcelas        int
+	// --- Endereço de Entrega ---
+	EntregaCEP         string `gorm:"size:10"`
+	EntregaRua         string `gorm:"size:255"`
+	EntregaNumero      string `gorm:"size:20"`
+	EntregaComplemento string `gorm:"size:100"`
+	EntregaBairro      string `gorm:"size:100"`
+	EntregaCidade      string `gorm:"size:100"`
+	EntregaEstado      string `gorm:"size:2"`
+	// --- Agendamento ---
+	TipoEntrega     TipoEntrega    `gorm:"type:varchar(20);not null;default:'entrega'"`
+	JanelaEntregaID *uint          `gorm:"index"`
+	JanelaEntrega   *JanelaEntrega `gorm:"foreignKey:JanelaEntregaID"`
+	DataEntrega     *time.Time     `gorm:"type:date;index"`
+	// --- Cupom de Desconto ---
+	CupomID     *uint  `gorm:"index"`
+	Cupom       *Cupom `gorm:"foreignKey:CupomID"`
+	CodigoCupom string `gorm:"size:50"`
+	Desconto    float64
 	// -------------------------------
-	ExternalReference string      `gorm:"uniqueIndex"`         
-	Items             []ItemOrder `gorm:"foreignKey:PedidoID"` 
+	ExternalReference string                  `gorm:"uniqueIndex"`
+	Items             []ItemOrder             `gorm:"foreignKey:PedidoID"`
+	Historico         []HistoricoStatusPedido `gorm:"foreignKey:PedidoID"`
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	DeletedAt         gorm.DeletedAt `gorm:"index"`
@@ -41,12 +60,28 @@ type Order struct {
 
 // ItemOrder representa um item dentro de um Pedido.
 type ItemOrder struct {
-	ID            uint    `gorm:"primaryKey"`
-	PedidoID      uint    `gorm:"not null"`             // Chave estrangeira para o Pedido
-	CupcakeID     uint    `gorm:"not null"`             // Chave estrangeira para o Cupcake
-	Cupcake       Cupcake `gorm:"foreignKey:CupcakeID"` // Relacionamento com Cupcake (para buscar dados depois)
-	Quantidade    int     `gorm:"not null"`
-	PrecoUnitario float64 `gorm:"not null"` // Preço no momento da compra (importante!)
-	Subtotal      float64 `gorm:"not null"`
+	ID            uint             `gorm:"primaryKey"`
+	PedidoID      uint             `gorm:"not null"`             // Chave estrangeira para o Pedido
+	CupcakeID     uint             `gorm:"not null"`             // Chave estrangeira para o Cupcake
+	Cupcake       Cupcake          `gorm:"foreignKey:CupcakeID"` // Relacionamento com Cupcake (para buscar dados depois)
+	Quantidade    int              `gorm:"not null"`
+	PrecoUnitario float64          `gorm:"not null"` // Preço final no momento da compra, já com as opções (importante!)
+	Subtotal      float64          `gorm:"not null"`
+	Opcoes        []ItemOrderOpcao `gorm:"foreignKey:ItemOrderID"`
+	// --- Kit ---
+	// Kits são gravados como um item por cupcake componente; o preço do kit é rateado
+	// entre eles. KitLinha agrupa os componentes de uma mesma linha do carrinho.
+	KitID         *uint  `gorm:"index"`
+	KitNome       string `gorm:"size:100"`
+	KitLinha      int
+	KitQuantidade int // Quantos kits foram comprados nessa linha
 	CreatedAt     time.Time
 }
+
+// HistoricoStatusPedido registra cada mudança de status do pedido, para a linha do tempo.
+type HistoricoStatusPedido struct {
+	ID        uint        `gorm:"primaryKey"`
+	PedidoID  uint        `gorm:"not null;index"`
+	Status    StatusOrder `gorm:"type:varchar(20);not null"`
+	CreatedAt time.Time
+}
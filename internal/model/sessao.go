@@ -0,0 +1,16 @@
+// /internal/model/sessao.go
+package model
+
+import "time"
+
+// Sessao guarda no servidor os dados de uma sessão do navegador; o cookie leva só o ID,
+// assinado e criptografado. UsuarioID fica nulo até o login e permite encerrar todas as
+// sessões de uma conta.
+type Sessao struct {
+	ID        string    `gorm:"primaryKey;size:64"`
+	UsuarioID *uint     `gorm:"index"`
+	Dados     []byte    `gorm:"not null"`
+	ExpiraEm  time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
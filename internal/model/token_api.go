@@ -0,0 +1,17 @@
+// /internal/model/token_api.go
+package model
+
+import "time"
+
+// TokenAPI é um token de acesso pessoal à API (/api/v1), enviado no cabeçalho
+// Authorization: Bearer. Só o hash fica gravado; o token aparece uma vez, na criação.
+type TokenAPI struct {
+	ID          uint      `gorm:"primaryKey"`
+	UsuarioID   uint      `gorm:"not null;index"`
+	Nome        string    `gorm:"size:100"` // Identifica o cliente que usa o token (ex.: "app")
+	Hash        string    `gorm:"size:64;uniqueIndex;not null"`
+	Prefixo     string    `gorm:"size:12"` // Início do token, para reconhecê-lo sem guardá-lo
+	ExpiraEm    time.Time `gorm:"not null;index"`
+	UltimoUsoEm *time.Time
+	CreatedAt   time.Time
+}
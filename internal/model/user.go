@@ -12,6 +12,75 @@ const (
 	RoleLojista = "lojista"
 )
 
+// Papel é a função de um usuário da equipe (Tipo lojista) e define o que ele pode fazer.
+type Papel string
+
+const (
+	PapelAdmin     Papel = "admin"
+	PapelAtendente Papel = "atendente"
+	PapelCozinha   Papel = "cozinha"
+)
+
+// Papeis lista os papéis na ordem em que aparecem nos formulários.
+var Papeis = []Papel{PapelAdmin, PapelAtendente, PapelCozinha}
+
+// Permissao é uma área do painel do lojista.
+type Permissao string
+
+const (
+	PermissaoPedidos    Permissao = "pedidos"    // Vendas, recibos e status dos pedidos
+	PermissaoCozinha    Permissao = "cozinha"    // Quadro de produção
+	PermissaoCatalogo   Permissao = "catalogo"   // Cupcakes, opções e kits
+	PermissaoEntregas   Permissao = "entregas"   // Janelas e datas bloqueadas
+	PermissaoCupons     Permissao = "cupons"     // Cupons de desconto
+	PermissaoAvaliacoes Permissao = "avaliacoes" // Moderação das avaliações
+	PermissaoClientes   Permissao = "clientes"   // Contas dos clientes
+	PermissaoEquipe     Permissao = "equipe"     // Convites e papéis da equipe
+	PermissaoAuditoria  Permissao = "auditoria"  // Registro das ações administrativas
+)
+
+// permissoesPapel é a matriz de permissões; o admin pode tudo.
+var permissoesPapel = map[Papel][]Permissao{
+	PapelAtendente: {PermissaoPedidos, PermissaoCozinha, PermissaoEntregas, PermissaoAvaliacoes, PermissaoClientes},
+	PapelCozinha:   {PermissaoCozinha},
+}
+
+// Titulo é o nome do papel exibido nas telas.
+func (p Papel) Titulo() string {
+	switch p {
+	case PapelAdmin:
+		return "Administrador"
+	case PapelAtendente:
+		return "Atendente"
+	case PapelCozinha:
+		return "Cozinha"
+	}
+	return string(p)
+}
+
+// Valido indica se o papel existe.
+func (p Papel) Valido() bool {
+	for _, papel := range Papeis {
+		if p == papel {
+			return true
+		}
+	}
+	return false
+}
+
+// Pode indica se o papel dá acesso à área do painel.
+func (p Papel) Pode(permissao Permissao) bool {
+	if p == PapelAdmin {
+		return true
+	}
+	for _, perm := range permissoesPapel[p] {
+		if perm == permissao {
+			return true
+		}
+	}
+	return false
+}
+
 type Usuario struct {
 	ID          uint   `gorm:"primaryKey"`
 	Nome        string `gorm:"not null"`
@@ -26,7 +95,34 @@ type Usuario struct {
 	Cidade      string `gorm:"size:100"`
 	Estado      string `gorm:"size:2"`
 	Tipo        string `gorm:"default:'cliente';not null"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Papel       Papel  `gorm:"type:varchar(20)"` // Só para a equipe (Tipo lojista)
+
+	// Convite da equipe: guarda só o hash do token; a senha é definida ao aceitar
+	ConviteHash     string `gorm:"size:64;index"`
+	ConviteExpiraEm *time.Time
+
+	// Redefinição de senha pedida para o cliente: a senha atual vale até ser trocada
+	RedefinicaoHash     string `gorm:"size:64;index"`
+	RedefinicaoExpiraEm *time.Time
+
+	DesativadoEm *time.Time // Conta bloqueada pela loja; não entra mais no site
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// Pode indica se o usuário é da equipe e o papel dele dá acesso à área.
+func (u Usuario) Pode(permissao Permissao) bool {
+	return u.Tipo == RoleLojista && u.Papel.Pode(permissao)
+}
+
+// ConvitePendente indica que o membro da equipe ainda não definiu a senha.
+func (u Usuario) ConvitePendente() bool {
+	return u.ConviteHash != ""
+}
+
+// Ativo indica que a conta não foi desativada pela loja.
+func (u Usuario) Ativo() bool {
+	return u.DesativadoEm == nil
 }
@@ -0,0 +1,367 @@
+// /internal/service/agenda.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrJanelaIndisponivel indica janela inexistente, inativa, no passado ou em data bloqueada.
+	ErrJanelaIndisponivel = errors.New("janela de entrega indisponível")
+	// ErrJanelaLotada indica que a capacidade da janela na data já foi atingida.
+	ErrJanelaLotada = errors.New("janela de entrega sem capacidade")
+)
+
+// FormatoData é o formato usado para datas de entrega em formulários e queries.
+const FormatoData = "2006-01-02"
+
+// DiasAgendamento é quantos dias à frente o checkout oferece horários.
+const DiasAgendamento = 14
+
+var nomesDiaSemana = [...]string{"Domingo", "Segunda", "Terça", "Quarta", "Quinta", "Sexta", "Sábado"}
+
+// NomeDiaSemana retorna o nome em português de um dia da semana (0 = domingo).
+func NomeDiaSemana(dia int) string {
+	if dia < 0 || dia >= len(nomesDiaSemana) {
+		return ""
+	}
+	return nomesDiaSemana[dia]
+}
+
+// FusoLoja é o fuso da loja (LOJA_TZ, definido na inicialização). "Hoje" e o horário das
+// janelas seguem o relógio da loja, não o do servidor.
+var FusoLoja = time.UTC
+
+// Dia descarta o horário de t e retorna a data à meia-noite em UTC,
+// que é como as colunas do tipo date são gravadas e lidas.
+func Dia(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DiaLoja é a data em que o instante t cai no fuso da loja (à meia-noite UTC, como Dia).
+func DiaLoja(t time.Time) time.Time {
+	return Dia(t.In(FusoLoja))
+}
+
+// InicioDiaLoja é o instante em que a data começa no fuso da loja, para comparar com
+// colunas de data e hora (ex.: created_at).
+func InicioDiaLoja(data time.Time) time.Time {
+	y, m, d := data.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, FusoLoja)
+}
+
+// OpcaoEntrega é uma janela em uma data específica, com a capacidade restante.
+type OpcaoEntrega struct {
+	Janela            model.JanelaEntrega
+	Data              time.Time
+	PedidosRestantes  int // -1 = sem limite
+	CupcakesRestantes int // -1 = sem limite
+}
+
+// Valor identifica a opção nos formulários ("<janelaID>|<AAAA-MM-DD>").
+func (o OpcaoEntrega) Valor() string {
+	return fmt.Sprintf("%d|%s", o.Janela.ID, o.Data.Format(FormatoData))
+}
+
+// Horario retorna a faixa de horário formatada ("14:00 - 16:00").
+func (o OpcaoEntrega) Horario() string {
+	return o.Janela.HoraInicio + " - " + o.Janela.HoraFim
+}
+
+// DiaEntrega agrupa as opções disponíveis de uma mesma data.
+type DiaEntrega struct {
+	Data   time.Time
+	Opcoes []OpcaoEntrega
+}
+
+// Rotulo formata a data para exibição ("Terça, 20/10").
+func (d DiaEntrega) Rotulo() string {
+	return NomeDiaSemana(int(d.Data.Weekday())) + ", " + d.Data.Format("02/01")
+}
+
+// ParseOpcaoEntrega decodifica o valor gerado por OpcaoEntrega.Valor.
+func ParseOpcaoEntrega(valor string) (uint, time.Time, error) {
+	partes := strings.SplitN(valor, "|", 2)
+	if len(partes) != 2 {
+		return 0, time.Time{}, ErrJanelaIndisponivel
+	}
+	id, err := strconv.ParseUint(partes[0], 10, 32)
+	if err != nil {
+		return 0, time.Time{}, ErrJanelaIndisponivel
+	}
+	data, err := time.Parse(FormatoData, partes[1])
+	if err != nil {
+		return 0, time.Time{}, ErrJanelaIndisponivel
+	}
+	return uint(id), data, nil
+}
+
+// ValidarHorario confere o formato HH:MM.
+func ValidarHorario(h string) bool {
+	_, err := time.Parse("15:04", h)
+	return err == nil && len(h) == 5
+}
+
+// janelaAindaAberta indica se a janela na data ainda não começou, pelo relógio da loja.
+func janelaAindaAberta(janela model.JanelaEntrega, data, agora time.Time) bool {
+	agora = agora.In(FusoLoja)
+	hoje := Dia(agora)
+	if data.Before(hoje) {
+		return false
+	}
+	if data.Equal(hoje) {
+		return janela.HoraInicio > agora.Format("15:04")
+	}
+	return true
+}
+
+func restante(max, usado int) int {
+	if max <= 0 {
+		return -1
+	}
+	if usado >= max {
+		return 0
+	}
+	return max - usado
+}
+
+// ListarOpcoesEntrega monta as opções de agendamento do tipo informado para os próximos
+// dias, omitindo datas bloqueadas e janelas sem capacidade para qtdCupcakes.
+func ListarOpcoesEntrega(db *gorm.DB, tipo model.TipoEntrega, agora time.Time, dias, qtdCupcakes int) ([]DiaEntrega, error) {
+	var janelas []model.JanelaEntrega
+	if err := db.Where("ativa = ? AND tipo = ?", true, tipo).Order("hora_inicio").Find(&janelas).Error; err != nil {
+		return nil, err
+	}
+	if len(janelas) == 0 {
+		return []DiaEntrega{}, nil
+	}
+
+	inicio := DiaLoja(agora)
+	fim := inicio.AddDate(0, 0, dias)
+
+	var bloqueios []model.DataBloqueada
+	if err := db.Where("data >= ? AND data < ?", inicio.Format(FormatoData), fim.Format(FormatoData)).Find(&bloqueios).Error; err != nil {
+		return nil, err
+	}
+	bloqueadas := make(map[string]bool, len(bloqueios))
+	for _, b := range bloqueios {
+		bloqueadas[b.Data.Format(FormatoData)] = true
+	}
+
+	var ocupacoes []model.OcupacaoJanela
+	if err := db.Where("data >= ? AND data < ?", inicio.Format(FormatoData), fim.Format(FormatoData)).Find(&ocupacoes).Error; err != nil {
+		return nil, err
+	}
+	ocupacaoPorChave := make(map[string]model.OcupacaoJanela, len(ocupacoes))
+	for _, o := range ocupacoes {
+		ocupacaoPorChave[fmt.Sprintf("%d|%s", o.JanelaID, o.Data.Format(FormatoData))] = o
+	}
+
+	resultado := make([]DiaEntrega, 0, dias)
+	for data := inicio; data.Before(fim); data = data.AddDate(0, 0, 1) {
+		if bloqueadas[data.Format(FormatoData)] {
+			continue
+		}
+		dia := DiaEntrega{Data: data}
+		for _, janela := range janelas {
+			if janela.DiaSemana != int(data.Weekday()) || !janelaAindaAberta(janela, data, agora) {
+				continue
+			}
+			opcao := OpcaoEntrega{Janela: janela, Data: data}
+			ocupacao := ocupacaoPorChave[opcao.Valor()]
+			opcao.PedidosRestantes = restante(janela.MaxPedidos, ocupacao.Pedidos)
+			opcao.CupcakesRestantes = restante(janela.MaxCupcakes, ocupacao.Cupcakes)
+			if opcao.PedidosRestantes == 0 || (opcao.CupcakesRestantes >= 0 && opcao.CupcakesRestantes < qtdCupcakes) {
+				continue
+			}
+			dia.Opcoes = append(dia.Opcoes, opcao)
+		}
+		if len(dia.Opcoes) > 0 {
+			resultado = append(resultado, dia)
+		}
+	}
+	return resultado, nil
+}
+
+// ReservarJanela consome a capacidade da janela na data para um pedido com qtdCupcakes.
+// Deve ser chamada dentro da mesma transação que cria o pedido: a linha de ocupação fica
+// bloqueada (SELECT ... FOR UPDATE) até o commit, evitando overbooking concorrente.
+func ReservarJanela(tx *gorm.DB, janelaID uint, data time.Time, tipo model.TipoEntrega, qtdCupcakes int, agora time.Time) (model.JanelaEntrega, error) {
+	data = Dia(data)
+	dataStr := data.Format(FormatoData)
+
+	var janela model.JanelaEntrega
+	if err := tx.Where("id = ? AND ativa = ?", janelaID, true).First(&janela).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return janela, ErrJanelaIndisponivel
+		}
+		return janela, err
+	}
+	if janela.Tipo != tipo || janela.DiaSemana != int(data.Weekday()) || !janelaAindaAberta(janela, data, agora) {
+		return janela, ErrJanelaIndisponivel
+	}
+
+	var bloqueios int64
+	if err := tx.Model(&model.DataBloqueada{}).Where("data = ?", dataStr).Count(&bloqueios).Error; err != nil {
+		return janela, err
+	}
+	if bloqueios > 0 {
+		return janela, ErrJanelaIndisponivel
+	}
+
+	ocupacao := model.OcupacaoJanela{JanelaID: janela.ID, Data: data}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ocupacao).Error; err != nil {
+		return janela, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("janela_id = ? AND data = ?", janela.ID, dataStr).
+		First(&ocupacao).Error; err != nil {
+		return janela, err
+	}
+
+	if janela.MaxPedidos > 0 && ocupacao.Pedidos+1 > janela.MaxPedidos {
+		return janela, ErrJanelaLotada
+	}
+	if janela.MaxCupcakes > 0 && ocupacao.Cupcakes+qtdCupcakes > janela.MaxCupcakes {
+		return janela, ErrJanelaLotada
+	}
+
+	err := tx.Model(&ocupacao).Updates(map[string]interface{}{
+		"pedidos":  gorm.Expr("pedidos + 1"),
+		"cupcakes": gorm.Expr("cupcakes + ?", qtdCupcakes),
+	}).Error
+	return janela, err
+}
+
+// LiberarJanela devolve a capacidade consumida pelo pedido (usado quando ele falha ou é cancelado).
+func LiberarJanela(tx *gorm.DB, pedido model.Order) error {
+	if pedido.JanelaEntregaID == nil || pedido.DataEntrega == nil {
+		return nil
+	}
+
+	var qtdCupcakes int
+	if err := tx.Model(&model.ItemOrder{}).
+		Where("pedido_id = ?", pedido.ID).
+		Select("COALESCE(SUM(quantidade), 0)").
+		Scan(&qtdCupcakes).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&model.OcupacaoJanela{}).
+		Where("janela_id = ? AND data = ?", *pedido.JanelaEntregaID, pedido.DataEntrega.Format(FormatoData)).
+		Updates(map[string]interface{}{
+			"pedidos":  gorm.Expr("GREATEST(pedidos - 1, 0)"),
+			"cupcakes": gorm.Expr("GREATEST(cupcakes - ?, 0)", qtdCupcakes),
+		}).Error
+}
+
+// statusLiberaCapacidade indica os status em que o pedido não ocupa mais a janela.
+func statusLiberaCapacidade(status model.StatusOrder) bool {
+	return status == model.StatusFalhou || status == model.StatusCancelado
+}
+
+// AtualizarStatusPedido grava o novo status e, quando o pedido deixa de valer
+// (falhou/cancelado), devolve a capacidade da janela de entrega. Quem acompanha o
+// pedido é avisado pelo barramento Eventos.
+func AtualizarStatusPedido(db *gorm.DB, pedido *model.Order, novo model.StatusOrder) error {
+	var atual model.Order
+	var anterior model.StatusOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&atual, pedido.ID).Error; err != nil {
+			return err
+		}
+		// Update também grava o novo status em atual; o anterior decide o que muda
+		anterior = atual.Status
+		if err := tx.Model(&atual).Update("status", novo).Error; err != nil {
+			return err
+		}
+		if anterior != novo {
+			if err := RegistrarStatusPedido(tx, atual.ID, novo); err != nil {
+				return err
+			}
+		}
+		pedido.Status = novo
+		if !statusLiberaCapacidade(anterior) && statusLiberaCapacidade(novo) {
+			return LiberarJanela(tx, atual)
+		}
+		return nil
+	})
+	if err == nil && anterior != novo {
+		PublicarStatusPedido(atual)
+	}
+	return err
+}
+
+// ProducaoItem é a quantidade total de um cupcake a produzir.
+type ProducaoItem struct {
+	Nome       string
+	Quantidade int
+}
+
+// ProducaoJanela reúne os pedidos de uma janela em um dia de produção.
+type ProducaoJanela struct {
+	Janela        *model.JanelaEntrega // nil = pedidos sem horário agendado
+	Pedidos       []model.Order
+	Itens         []ProducaoItem
+	TotalCupcakes int
+}
+
+// MontarProducaoDia agrupa por janela os pedidos válidos com entrega/retirada na data.
+func MontarProducaoDia(db *gorm.DB, dia time.Time) ([]ProducaoJanela, error) {
+	var pedidos []model.Order
+	err := db.Preload("Usuario").Preload("Items.Cupcake").Preload("Items.Opcoes").Preload("JanelaEntrega").
+		Where("data_entrega = ? AND status NOT IN ?", Dia(dia).Format(FormatoData),
+			[]model.StatusOrder{model.StatusFalhou, model.StatusCancelado}).
+		Order("created_at").
+		Find(&pedidos).Error
+	if err != nil {
+		return nil, err
+	}
+
+	grupos := make(map[uint]*ProducaoJanela)
+	contagens := make(map[uint]map[string]int)
+	ordem := make([]uint, 0)
+	for _, pedido := range pedidos {
+		var chave uint
+		if pedido.JanelaEntregaID != nil {
+			chave = *pedido.JanelaEntregaID
+		}
+		grupo, ok := grupos[chave]
+		if !ok {
+			grupo = &ProducaoJanela{Janela: pedido.JanelaEntrega}
+			grupos[chave] = grupo
+			contagens[chave] = make(map[string]int)
+			ordem = append(ordem, chave)
+		}
+		grupo.Pedidos = append(grupo.Pedidos, pedido)
+		for _, item := range pedido.Items {
+			contagens[chave][nomeProducao(item)] += item.Quantidade
+			grupo.TotalCupcakes += item.Quantidade
+		}
+	}
+
+	resultado := make([]ProducaoJanela, 0, len(ordem))
+	for _, chave := range ordem {
+		grupo := grupos[chave]
+		grupo.Itens = somarProducao(contagens[chave])
+		resultado = append(resultado, *grupo)
+	}
+	sort.SliceStable(resultado, func(i, j int) bool {
+		a, b := resultado[i].Janela, resultado[j].Janela
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.HoraInicio < b.HoraInicio
+	})
+	return resultado, nil
+}
@@ -0,0 +1,112 @@
+// /internal/service/agenda_test.go
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestOpcaoEntregaValorEParse(t *testing.T) {
+	data := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	opcao := OpcaoEntrega{Janela: model.JanelaEntrega{ID: 7}, Data: data}
+
+	valor := opcao.Valor()
+	if valor != "7|2026-10-20" {
+		t.Fatalf("Valor inesperado: %s", valor)
+	}
+
+	janelaID, dataLida, err := ParseOpcaoEntrega(valor)
+	if err != nil || janelaID != 7 || !dataLida.Equal(data) {
+		t.Errorf("ParseOpcaoEntrega(%q) = %d, %v, %v", valor, janelaID, dataLida, err)
+	}
+
+	for _, invalido := range []string{"", "7", "x|2026-10-20", "7|20/10/2026"} {
+		if _, _, err := ParseOpcaoEntrega(invalido); err == nil {
+			t.Errorf("ParseOpcaoEntrega(%q) deveria falhar", invalido)
+		}
+	}
+}
+
+func TestJanelaAindaAberta(t *testing.T) {
+	agora := time.Date(2026, 10, 20, 13, 30, 0, 0, time.UTC)
+	hoje := Dia(agora)
+	janela := model.JanelaEntrega{HoraInicio: "14:00", HoraFim: "16:00"}
+
+	if !janelaAindaAberta(janela, hoje, agora) {
+		t.Error("Janela das 14:00 de hoje deveria estar aberta às 13:30")
+	}
+	janela.HoraInicio = "13:00"
+	if janelaAindaAberta(janela, hoje, agora) {
+		t.Error("Janela das 13:00 de hoje não deveria estar aberta às 13:30")
+	}
+	if !janelaAindaAberta(janela, hoje.AddDate(0, 0, 1), agora) {
+		t.Error("Janela de amanhã deveria estar aberta")
+	}
+	if janelaAindaAberta(janela, hoje.AddDate(0, 0, -1), agora) {
+		t.Error("Janela de ontem não deveria estar aberta")
+	}
+}
+
+func TestJanelaAindaAbertaNoFusoDaLoja(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior := FusoLoja
+	FusoLoja = saoPaulo
+	t.Cleanup(func() { FusoLoja = anterior })
+
+	// 22:30 de segunda em São Paulo já é terça (01:30) em UTC
+	agora := time.Date(2026, 10, 19, 22, 30, 0, 0, saoPaulo)
+	segunda := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	if hoje := DiaLoja(agora); !hoje.Equal(segunda) {
+		t.Errorf("DiaLoja = %s; esperado 2026-10-19", hoje.Format(FormatoData))
+	}
+	if DiaLoja(agora.UTC()) != DiaLoja(agora) {
+		t.Error("O mesmo instante em outro fuso deveria cair no mesmo dia da loja")
+	}
+
+	janela := model.JanelaEntrega{HoraInicio: "23:00", HoraFim: "23:59"}
+	if !janelaAindaAberta(janela, segunda, agora.UTC()) {
+		t.Error("Janela das 23:00 de segunda deveria estar aberta às 22:30 de São Paulo")
+	}
+	janela.HoraInicio = "09:00"
+	if !janelaAindaAberta(janela, segunda.AddDate(0, 0, 1), agora) {
+		t.Error("Janela das 09:00 de terça ainda não começou no relógio da loja")
+	}
+	if janelaAindaAberta(janela, segunda, agora) {
+		t.Error("Janela das 09:00 de segunda já passou")
+	}
+
+	inicio := InicioDiaLoja(segunda)
+	if !inicio.Equal(time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("InicioDiaLoja = %s; esperado 03:00 UTC", inicio.UTC())
+	}
+}
+
+func TestValidarHorario(t *testing.T) {
+	for _, valido := range []string{"00:00", "09:30", "23:59"} {
+		if !ValidarHorario(valido) {
+			t.Errorf("%s deveria ser válido", valido)
+		}
+	}
+	for _, invalido := range []string{"", "9:30", "24:00", "12:60", "meio-dia"} {
+		if ValidarHorario(invalido) {
+			t.Errorf("%s deveria ser inválido", invalido)
+		}
+	}
+}
+
+func TestRestante(t *testing.T) {
+	if r := restante(0, 50); r != -1 {
+		t.Errorf("Sem limite deveria retornar -1, obteve %d", r)
+	}
+	if r := restante(10, 4); r != 6 {
+		t.Errorf("Esperava 6, obteve %d", r)
+	}
+	if r := restante(10, 12); r != 0 {
+		t.Errorf("Capacidade excedida deveria retornar 0, obteve %d", r)
+	}
+}
@@ -0,0 +1,215 @@
+// /internal/service/auditoria.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// AuditoriaPorPagina é o tamanho de cada página da auditoria.
+	AuditoriaPorPagina = 50
+	// intervaloRetencaoAuditoria é de quanto em quanto tempo os registros vencidos saem.
+	intervaloRetencaoAuditoria = 24 * time.Hour
+)
+
+// camposForaDaAuditoria não entram nas mudanças: datas de controle e segredos.
+var camposForaDaAuditoria = map[string]bool{
+	"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true,
+	"SenhaHash": true, "ConviteHash": true, "RedefinicaoHash": true,
+}
+
+// Auditoria é uma ação a registrar.
+type Auditoria struct {
+	Autor      model.Usuario
+	IP         string
+	Acao       string
+	Entidade   string
+	EntidadeID uint
+	Antes      any // Estado anterior (nil na criação); ver FotoAuditoria
+	Depois     any // Estado novo (nil na exclusão)
+}
+
+// FotoAuditoria copia as colunas de um registro para comparar depois, sem datas de
+// controle nem segredos. Registros associados (itens, imagens, usuário do pedido) ficam
+// de fora: cada um é auditado por conta própria. Use antes de alterar o registro no lugar.
+func FotoAuditoria(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var foto map[string]any
+	if err := json.Unmarshal(b, &foto); err != nil {
+		return nil
+	}
+	for campo, valor := range foto {
+		if camposForaDaAuditoria[campo] || associado(valor) {
+			delete(foto, campo)
+		}
+	}
+	return foto
+}
+
+// associado indica um valor que veio de uma associação: objeto ou lista de objetos.
+func associado(v any) bool {
+	switch valor := v.(type) {
+	case map[string]any:
+		return true
+	case []any:
+		for _, item := range valor {
+			if _, ok := item.(map[string]any); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiffAuditoria lista, em ordem alfabética, os campos que mudaram entre as fotos.
+func DiffAuditoria(antes, depois map[string]any) []model.CampoAuditoria {
+	nomes := map[string]bool{}
+	for campo := range antes {
+		nomes[campo] = true
+	}
+	for campo := range depois {
+		nomes[campo] = true
+	}
+	campos := make([]model.CampoAuditoria, 0, len(nomes))
+	for campo := range nomes {
+		a, d := antes[campo], depois[campo]
+		if reflect.DeepEqual(a, d) {
+			continue
+		}
+		campos = append(campos, model.CampoAuditoria{Campo: campo, Antes: a, Depois: d})
+	}
+	sort.Slice(campos, func(i, j int) bool { return campos[i].Campo < campos[j].Campo })
+	return campos
+}
+
+// RegistrarAuditoria grava a ação. Uma edição sem nenhuma mudança não é registrada.
+func RegistrarAuditoria(db *gorm.DB, a Auditoria) error {
+	antes, depois := FotoAuditoria(a.Antes), FotoAuditoria(a.Depois)
+	campos := DiffAuditoria(antes, depois)
+	if len(campos) == 0 && a.Acao == model.AcaoEditar {
+		return nil
+	}
+	mudancas, err := json.Marshal(campos)
+	if err != nil {
+		return err
+	}
+	registro := model.AuditLog{
+		AutorNome:  a.Autor.Nome,
+		Acao:       a.Acao,
+		Entidade:   a.Entidade,
+		EntidadeID: a.EntidadeID,
+		Mudancas:   string(mudancas),
+		IP:         a.IP,
+	}
+	if a.Autor.ID != 0 {
+		registro.AutorID = &a.Autor.ID
+	}
+	return db.Create(&registro).Error
+}
+
+// FiltroAuditoria são os parâmetros da página de auditoria.
+type FiltroAuditoria struct {
+	Entidade   string
+	EntidadeID uint
+	Acao       string
+	AutorID    uint
+	De, Ate    *time.Time // Dias inteiros, inclusive
+	Pagina     int
+}
+
+// PaginaAuditoria é uma página de registros, do mais recente para o mais antigo.
+type PaginaAuditoria struct {
+	Registros    []model.AuditLog
+	Total        int64
+	Pagina       int
+	TotalPaginas int
+}
+
+// consultaAuditoria aplica o filtro.
+func consultaAuditoria(db *gorm.DB, filtro FiltroAuditoria) *gorm.DB {
+	query := db.Model(&model.AuditLog{})
+	if filtro.Entidade != "" {
+		query = query.Where("entidade = ?", filtro.Entidade)
+	}
+	if filtro.EntidadeID != 0 {
+		query = query.Where("entidade_id = ?", filtro.EntidadeID)
+	}
+	if filtro.Acao != "" {
+		query = query.Where("acao = ?", filtro.Acao)
+	}
+	if filtro.AutorID != 0 {
+		query = query.Where("autor_id = ?", filtro.AutorID)
+	}
+	if filtro.De != nil {
+		query = query.Where("created_at >= ?", InicioDiaLoja(*filtro.De))
+	}
+	if filtro.Ate != nil {
+		query = query.Where("created_at < ?", InicioDiaLoja(*filtro.Ate).AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// BuscarAuditoria lista uma página de registros de auditoria.
+func BuscarAuditoria(db *gorm.DB, filtro FiltroAuditoria) (PaginaAuditoria, error) {
+	pagina := PaginaAuditoria{Pagina: filtro.Pagina}
+	if err := consultaAuditoria(db, filtro).Count(&pagina.Total).Error; err != nil {
+		return pagina, err
+	}
+	pagina.TotalPaginas = TotalPaginas(pagina.Total, AuditoriaPorPagina)
+	if pagina.Pagina < 1 {
+		pagina.Pagina = 1
+	}
+	if pagina.Pagina > pagina.TotalPaginas {
+		pagina.Pagina = pagina.TotalPaginas
+	}
+	err := consultaAuditoria(db, filtro).
+		Order("created_at DESC, id DESC").
+		Limit(AuditoriaPorPagina).
+		Offset((pagina.Pagina - 1) * AuditoriaPorPagina).
+		Find(&pagina.Registros).Error
+	return pagina, err
+}
+
+// LimparAuditoria apaga os registros anteriores a limite. Usa SQL direto porque o
+// modelo recusa exclusões (model.ErrAuditoriaImutavel).
+func LimparAuditoria(db *gorm.DB, limite time.Time) (int64, error) {
+	res := db.Exec("DELETE FROM audit_logs WHERE created_at < ?", limite)
+	return res.RowsAffected, res.Error
+}
+
+// ManterRetencaoAuditoria apaga, agora e a cada dia, os registros mais antigos que
+// retencao, até ctx acabar. retencao <= 0 guarda tudo.
+func ManterRetencaoAuditoria(ctx context.Context, db *gorm.DB, retencao time.Duration) {
+	if retencao <= 0 {
+		return
+	}
+	ticker := time.NewTicker(intervaloRetencaoAuditoria)
+	defer ticker.Stop()
+	for {
+		apagados, err := LimparAuditoria(db, time.Now().Add(-retencao))
+		if err != nil {
+			slog.ErrorContext(ctx, "Erro ao aplicar a retenção da auditoria", "erro", err)
+		} else if apagados > 0 {
+			slog.InfoContext(ctx, "Retenção da auditoria: registros antigos apagados", "apagados", apagados)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// /internal/service/auditoria_test.go
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestFotoAuditoria(t *testing.T) {
+	t.Run("Sem segredos, datas de controle ou associações", func(t *testing.T) {
+		usuario := model.Usuario{ID: 3, Nome: "Ana", SenhaHash: "hash", ConviteHash: "convite", CreatedAt: time.Now()}
+		foto := FotoAuditoria(usuario)
+		for _, campo := range []string{"SenhaHash", "ConviteHash", "RedefinicaoHash", "CreatedAt", "UpdatedAt", "DeletedAt"} {
+			if _, ok := foto[campo]; ok {
+				t.Errorf("Campo %s não deveria estar na foto", campo)
+			}
+		}
+		if foto["Nome"] != "Ana" {
+			t.Errorf("Nome = %v; esperado Ana", foto["Nome"])
+		}
+
+		pedido := model.Order{ID: 1, Status: model.StatusPago, Usuario: usuario, Items: []model.ItemOrder{{ID: 2}}}
+		foto = FotoAuditoria(pedido)
+		if _, ok := foto["Usuario"]; ok {
+			t.Error("Usuário do pedido não deveria estar na foto")
+		}
+		if _, ok := foto["Items"]; ok {
+			t.Error("Itens do pedido não deveriam estar na foto")
+		}
+		if foto["Status"] != "pago" {
+			t.Errorf("Status = %v; esperado pago", foto["Status"])
+		}
+	})
+
+	t.Run("Foto tirada antes não muda com o registro", func(t *testing.T) {
+		cupcake := redVelvet
+		antes := FotoAuditoria(cupcake)
+		cupcake.Preco = 99
+		if antes["Preco"] == 99.0 {
+			t.Error("A foto deveria guardar o preço antigo")
+		}
+	})
+
+	if FotoAuditoria(nil) != nil {
+		t.Error("Foto de nil deveria ser nil")
+	}
+}
+
+func TestDiffAuditoria(t *testing.T) {
+	antes := model.Cupom{ID: 1, Codigo: "BEMVINDO", Valor: 10, Ativo: true}
+	depois := antes
+	depois.Valor = 15
+	depois.Ativo = false
+
+	t.Run("Edição lista só os campos alterados, em ordem", func(t *testing.T) {
+		campos := DiffAuditoria(FotoAuditoria(antes), FotoAuditoria(depois))
+		if len(campos) != 2 || campos[0].Campo != "Ativo" || campos[1].Campo != "Valor" {
+			t.Fatalf("Campos inesperados: %+v", campos)
+		}
+		if campos[1].Antes != 10.0 || campos[1].Depois != 15.0 {
+			t.Errorf("Valor: %v -> %v", campos[1].Antes, campos[1].Depois)
+		}
+	})
+
+	t.Run("Criação e exclusão", func(t *testing.T) {
+		criacao := DiffAuditoria(nil, FotoAuditoria(antes))
+		exclusao := DiffAuditoria(FotoAuditoria(antes), nil)
+		if len(criacao) == 0 || len(criacao) != len(exclusao) {
+			t.Fatalf("Criação %d campos, exclusão %d", len(criacao), len(exclusao))
+		}
+		for _, campo := range criacao {
+			if campo.Antes != nil {
+				t.Errorf("Na criação %s deveria começar vazio", campo.Campo)
+			}
+		}
+	})
+
+	t.Run("Sem mudanças", func(t *testing.T) {
+		if campos := DiffAuditoria(FotoAuditoria(antes), FotoAuditoria(antes)); len(campos) != 0 {
+			t.Errorf("Não deveria haver mudanças: %+v", campos)
+		}
+	})
+}
+
+func TestAuditLogCampos(t *testing.T) {
+	campos := DiffAuditoria(map[string]any{"Ativo": true, "Nome": "A"}, map[string]any{"Ativo": false, "Nome": nil})
+	b, _ := json.Marshal(campos)
+	registro := model.AuditLog{Acao: model.AcaoEditar, Entidade: "cupom", Mudancas: string(b)}
+
+	lidos := registro.Campos()
+	if len(lidos) != 2 {
+		t.Fatalf("Campos = %+v", lidos)
+	}
+	if lidos[0].AntesTexto() != "sim" || lidos[0].DepoisTexto() != "não" || lidos[1].DepoisTexto() != "—" {
+		t.Errorf("Textos inesperados: %+v", lidos)
+	}
+	if registro.TituloAcao() != "Edição" || registro.TituloEntidade() != "Cupom" {
+		t.Errorf("Títulos: %q / %q", registro.TituloAcao(), registro.TituloEntidade())
+	}
+	if (model.AuditLog{Mudancas: "inválido"}).Campos() != nil {
+		t.Error("Mudanças inválidas deveriam virar lista vazia")
+	}
+}
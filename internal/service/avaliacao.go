@@ -0,0 +1,153 @@
+// /internal/service/avaliacao.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// MaxTextoAvaliacao limita o comentário da avaliação (e a resposta do lojista).
+const MaxTextoAvaliacao = 2000
+
+var (
+	ErrAvaliacaoNaoPermitida = errors.New("avaliação permitida apenas para cupcakes recebidos")
+	ErrNotaInvalida          = errors.New("nota deve ser de 1 a 5")
+	ErrTextoAvaliacaoLongo   = fmt.Errorf("comentário acima de %d caracteres", MaxTextoAvaliacao)
+)
+
+// MensagemAvaliacao traduz os erros de validação para exibição ao cliente.
+func MensagemAvaliacao(err error) string {
+	switch {
+	case errors.Is(err, ErrAvaliacaoNaoPermitida):
+		return "Você só pode avaliar cupcakes de pedidos já entregues."
+	case errors.Is(err, ErrNotaInvalida):
+		return "Escolha uma nota de 1 a 5 estrelas."
+	case errors.Is(err, ErrTextoAvaliacaoLongo):
+		return fmt.Sprintf("O comentário pode ter no máximo %d caracteres.", MaxTextoAvaliacao)
+	}
+	return "Erro ao salvar a avaliação. Tente novamente."
+}
+
+// ValidarAvaliacao confere a nota e o tamanho do comentário e devolve o texto sem espaços
+// nas pontas.
+func ValidarAvaliacao(nota int, texto string) (string, error) {
+	if nota < 1 || nota > 5 {
+		return "", ErrNotaInvalida
+	}
+	texto = strings.TrimSpace(texto)
+	if utf8.RuneCountInString(texto) > MaxTextoAvaliacao {
+		return "", ErrTextoAvaliacaoLongo
+	}
+	return texto, nil
+}
+
+// cupcakesEntregues filtra os itens de pedidos entregues ao cliente.
+func cupcakesEntregues(db *gorm.DB, usuarioID uint) *gorm.DB {
+	return db.Model(&model.ItemOrder{}).
+		Joins("JOIN orders ON orders.id = item_orders.pedido_id AND orders.deleted_at IS NULL").
+		Where("orders.usuario_id = ? AND orders.status = ?", usuarioID, model.StatusEntregue)
+}
+
+// PodeAvaliar indica se o cliente recebeu o cupcake em algum pedido entregue (inclusive
+// como parte de um kit).
+func PodeAvaliar(db *gorm.DB, usuarioID, cupcakeID uint) (bool, error) {
+	var total int64
+	err := cupcakesEntregues(db, usuarioID).Where("item_orders.cupcake_id = ?", cupcakeID).Count(&total).Error
+	return total > 0, err
+}
+
+// NotasDoCliente retorna a nota já dada pelo cliente a cada cupcake avaliado.
+func NotasDoCliente(db *gorm.DB, usuarioID uint) (map[uint]int, error) {
+	var avaliacoes []model.Avaliacao
+	if err := db.Select("cupcake_id", "nota").Where("usuario_id = ?", usuarioID).Find(&avaliacoes).Error; err != nil {
+		return nil, err
+	}
+	notas := make(map[uint]int, len(avaliacoes))
+	for _, a := range avaliacoes {
+		notas[a.CupcakeID] = a.Nota
+	}
+	return notas, nil
+}
+
+// ResumoAvaliacao é a média e a quantidade de avaliações aprovadas de um cupcake.
+type ResumoAvaliacao struct {
+	Media float64
+	Total int
+}
+
+// Estrelas desenha a média arredondada (ex.: "★★★★☆").
+func (r ResumoAvaliacao) Estrelas() string {
+	return model.Estrelas(int(math.Round(r.Media)))
+}
+
+// ResumosAvaliacoes calcula o ResumoAvaliacao dos cupcakes; os sem avaliação aprovada
+// ficam fora do mapa.
+func ResumosAvaliacoes(db *gorm.DB, cupcakeIDs []uint) (map[uint]ResumoAvaliacao, error) {
+	resumos := make(map[uint]ResumoAvaliacao, len(cupcakeIDs))
+	if len(cupcakeIDs) == 0 {
+		return resumos, nil
+	}
+	var linhas []struct {
+		CupcakeID uint
+		Media     float64
+		Total     int
+	}
+	err := db.Model(&model.Avaliacao{}).
+		Select("cupcake_id, AVG(nota) AS media, COUNT(*) AS total").
+		Where("status = ? AND cupcake_id IN ?", model.AvaliacaoAprovada, cupcakeIDs).
+		Group("cupcake_id").
+		Scan(&linhas).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range linhas {
+		resumos[l.CupcakeID] = ResumoAvaliacao{Media: math.Round(l.Media*10) / 10, Total: l.Total}
+	}
+	return resumos, nil
+}
+
+// CompararAvaliacoes ordena da maior média para a menor; no empate, vence quem tem mais
+// avaliações. Cupcakes sem avaliação ficam por último.
+func CompararAvaliacoes(a, b ResumoAvaliacao) int {
+	switch {
+	case a.Media != b.Media:
+		if a.Media > b.Media {
+			return -1
+		}
+		return 1
+	case a.Total != b.Total:
+		return b.Total - a.Total
+	}
+	return 0
+}
+
+// AvaliacoesRecentes busca as até n avaliações aprovadas mais recentes de cada cupcake,
+// com o cliente carregado.
+func AvaliacoesRecentes(db *gorm.DB, cupcakeIDs []uint, n int) (map[uint][]model.Avaliacao, error) {
+	porCupcake := make(map[uint][]model.Avaliacao, len(cupcakeIDs))
+	if len(cupcakeIDs) == 0 {
+		return porCupcake, nil
+	}
+	recentes := db.Model(&model.Avaliacao{}).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY cupcake_id ORDER BY created_at DESC) AS ordem_cupcake").
+		Where("status = ? AND cupcake_id IN ?", model.AvaliacaoAprovada, cupcakeIDs)
+	var avaliacoes []model.Avaliacao
+	err := db.Table("(?) AS avaliacaos", recentes).
+		Where("ordem_cupcake <= ?", n).
+		Order("cupcake_id, created_at DESC").
+		Preload("Usuario").
+		Find(&avaliacoes).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range avaliacoes {
+		porCupcake[a.CupcakeID] = append(porCupcake[a.CupcakeID], a)
+	}
+	return porCupcake, nil
+}
@@ -0,0 +1,68 @@
+// /internal/service/avaliacao_test.go
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestValidarAvaliacao(t *testing.T) {
+	casos := []struct {
+		nome     string
+		nota     int
+		texto    string
+		esperado error
+	}{
+		{"Nota mínima", 1, "", nil},
+		{"Nota máxima com texto", 5, "  Delicioso!  ", nil},
+		{"Nota zero", 0, "", ErrNotaInvalida},
+		{"Nota acima de 5", 6, "", ErrNotaInvalida},
+		{"Texto longo", 4, strings.Repeat("é", MaxTextoAvaliacao+1), ErrTextoAvaliacaoLongo},
+		{"Texto no limite", 4, strings.Repeat("é", MaxTextoAvaliacao), nil},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			texto, err := ValidarAvaliacao(caso.nota, caso.texto)
+			if !errors.Is(err, caso.esperado) {
+				t.Fatalf("Erro = %v; esperado %v", err, caso.esperado)
+			}
+			if err == nil && texto != strings.TrimSpace(caso.texto) {
+				t.Errorf("Texto = %q", texto)
+			}
+		})
+	}
+}
+
+func TestCompararAvaliacoes(t *testing.T) {
+	resumos := []ResumoAvaliacao{
+		{},
+		{Media: 4.5, Total: 2},
+		{Media: 5, Total: 1},
+		{Media: 4.5, Total: 10},
+	}
+	slices.SortStableFunc(resumos, CompararAvaliacoes)
+	esperado := []ResumoAvaliacao{{Media: 5, Total: 1}, {Media: 4.5, Total: 10}, {Media: 4.5, Total: 2}, {}}
+	if !slices.Equal(resumos, esperado) {
+		t.Errorf("Ordem = %v; esperado %v", resumos, esperado)
+	}
+}
+
+func TestEstrelas(t *testing.T) {
+	if got := (ResumoAvaliacao{Media: 3.6}).Estrelas(); got != "★★★★☆" {
+		t.Errorf("Estrelas(3.6) = %s", got)
+	}
+	if got := model.Estrelas(9); got != "★★★★★" {
+		t.Errorf("Estrelas(9) = %s", got)
+	}
+	a := model.Avaliacao{Nota: 2, Usuario: model.Usuario{Nome: "  Maria da Silva"}}
+	if a.Estrelas() != "★★☆☆☆" || a.NomePublico() != "Maria" {
+		t.Errorf("Avaliação = %s, %s", a.Estrelas(), a.NomePublico())
+	}
+	if (model.Avaliacao{}).NomePublico() != "Cliente" {
+		t.Error("Cliente sem nome deveria aparecer como Cliente")
+	}
+}
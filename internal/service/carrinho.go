@@ -0,0 +1,201 @@
+// /internal/service/carrinho.go
+package service
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LinhaCarrinho é um item do carrinho: um cupcake com as opções escolhidas, ou um kit,
+// e a quantidade.
+type LinhaCarrinho struct {
+	CupcakeID  uint
+	Opcoes     []uint          // IDs das opções escolhidas, em ordem crescente
+	Textos     map[uint]string // Texto digitado por grupo (ex.: mensagem no topper)
+	KitID      uint            // Linha de kit (CupcakeID fica zerado)
+	Caixa      map[uint]int    // "Monte sua caixa": cupcake -> quantidade escolhida
+	Quantidade int
+}
+
+// EhKit indica se a linha é de um kit.
+func (l LinhaCarrinho) EhKit() bool {
+	return l.KitID != 0
+}
+
+// normalizar ordena as opções e descarta textos vazios, para que escolhas iguais
+// gerem sempre a mesma chave.
+func (l LinhaCarrinho) normalizar() LinhaCarrinho {
+	opcoes := append([]uint(nil), l.Opcoes...)
+	sort.Slice(opcoes, func(i, j int) bool { return opcoes[i] < opcoes[j] })
+	l.Opcoes = opcoes
+
+	var textos map[uint]string
+	for grupo, texto := range l.Textos {
+		if texto = strings.TrimSpace(texto); texto != "" {
+			if textos == nil {
+				textos = make(map[uint]string)
+			}
+			textos[grupo] = texto
+		}
+	}
+	l.Textos = textos
+
+	var caixa map[uint]int
+	for cupcake, qtd := range l.Caixa {
+		if qtd > 0 {
+			if caixa == nil {
+				caixa = make(map[uint]int)
+			}
+			caixa[cupcake] = qtd
+		}
+	}
+	l.Caixa = caixa
+	return l
+}
+
+// Chave identifica a linha no carrinho: o mesmo cupcake com as mesmas escolhas cai
+// na mesma linha. Sem opções a chave é só o ID do cupcake; kits usam o prefixo "k".
+func (l LinhaCarrinho) Chave() string {
+	l = l.normalizar()
+	if l.EhKit() {
+		if len(l.Caixa) == 0 {
+			return fmt.Sprintf("k%d", l.KitID)
+		}
+		h := sha1.New()
+		for _, id := range idsOrdenados(l.Caixa) {
+			fmt.Fprintf(h, "c%d=%d;", id, l.Caixa[id])
+		}
+		return fmt.Sprintf("k%d-%x", l.KitID, h.Sum(nil)[:6])
+	}
+	if len(l.Opcoes) == 0 && len(l.Textos) == 0 {
+		return strconv.FormatUint(uint64(l.CupcakeID), 10)
+	}
+
+	h := sha1.New()
+	for _, id := range l.Opcoes {
+		fmt.Fprintf(h, "o%d;", id)
+	}
+	for _, grupo := range idsOrdenados(l.Textos) {
+		fmt.Fprintf(h, "t%d=%q;", grupo, l.Textos[grupo])
+	}
+	return fmt.Sprintf("%d-%x", l.CupcakeID, h.Sum(nil)[:6])
+}
+
+// idsOrdenados retorna as chaves do mapa em ordem crescente.
+func idsOrdenados[V any](m map[uint]V) []uint {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Carrinho guarda as linhas do carrinho na sessão, indexadas por LinhaCarrinho.Chave.
+type Carrinho map[string]LinhaCarrinho
+
+// CarrinhoLegado converte o carrinho antigo (ID do cupcake -> quantidade) ainda
+// presente em sessões criadas antes das opções de personalização.
+func CarrinhoLegado(antigo map[uint]int) Carrinho {
+	carrinho := make(Carrinho, len(antigo))
+	for id, quantidade := range antigo {
+		carrinho.Adicionar(LinhaCarrinho{CupcakeID: id}, quantidade)
+	}
+	return carrinho
+}
+
+// Adicionar soma qtd unidades da linha ao carrinho e retorna a chave usada.
+func (c Carrinho) Adicionar(linha LinhaCarrinho, qtd int) string {
+	linha = linha.normalizar()
+	chave := linha.Chave()
+	if qtd <= 0 {
+		return chave
+	}
+	if existente, ok := c[chave]; ok {
+		existente.Quantidade += qtd
+		c[chave] = existente
+		return chave
+	}
+	linha.Quantidade = qtd
+	c[chave] = linha
+	return chave
+}
+
+// Aumentar soma uma unidade à linha; retorna false se a linha não existe.
+func (c Carrinho) Aumentar(chave string) bool {
+	linha, ok := c[chave]
+	if !ok {
+		return false
+	}
+	linha.Quantidade++
+	c[chave] = linha
+	return true
+}
+
+// Diminuir tira uma unidade da linha, removendo-a ao chegar a zero.
+func (c Carrinho) Diminuir(chave string) {
+	linha, ok := c[chave]
+	if !ok {
+		return
+	}
+	if linha.Quantidade > 1 {
+		linha.Quantidade--
+		c[chave] = linha
+		return
+	}
+	delete(c, chave)
+}
+
+// DefinirQuantidade troca a quantidade da linha; zero ou menos a remove. Retorna false
+// se a linha não existe.
+func (c Carrinho) DefinirQuantidade(chave string, qtd int) bool {
+	linha, ok := c[chave]
+	if !ok {
+		return false
+	}
+	if qtd <= 0 {
+		delete(c, chave)
+		return true
+	}
+	linha.Quantidade = qtd
+	c[chave] = linha
+	return true
+}
+
+// TotalItens soma as quantidades de todas as linhas.
+func (c Carrinho) TotalItens() int {
+	total := 0
+	for _, linha := range c {
+		total += linha.Quantidade
+	}
+	return total
+}
+
+// CupcakeIDs retorna os cupcakes distintos presentes no carrinho (fora dos kits).
+func (c Carrinho) CupcakeIDs() []uint {
+	vistos := make(map[uint]bool, len(c))
+	ids := make([]uint, 0, len(c))
+	for _, linha := range c {
+		if !linha.EhKit() && !vistos[linha.CupcakeID] {
+			vistos[linha.CupcakeID] = true
+			ids = append(ids, linha.CupcakeID)
+		}
+	}
+	return ids
+}
+
+// KitIDs retorna os kits distintos presentes no carrinho.
+func (c Carrinho) KitIDs() []uint {
+	vistos := make(map[uint]bool, len(c))
+	ids := make([]uint, 0, len(c))
+	for _, linha := range c {
+		if linha.EhKit() && !vistos[linha.KitID] {
+			vistos[linha.KitID] = true
+			ids = append(ids, linha.KitID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,162 @@
+// /internal/service/carrinho_test.go
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestChaveLinhaCarrinho(t *testing.T) {
+	t.Run("Sem opções a chave é o ID do cupcake", func(t *testing.T) {
+		if chave := (LinhaCarrinho{CupcakeID: 7}).Chave(); chave != "7" {
+			t.Errorf("Chave = %q; esperado \"7\"", chave)
+		}
+		if chave := (LinhaCarrinho{CupcakeID: 7, Textos: map[uint]string{1: "   "}}).Chave(); chave != "7" {
+			t.Errorf("Texto vazio não deveria mudar a chave: %q", chave)
+		}
+	})
+
+	t.Run("Ordem das opções não importa", func(t *testing.T) {
+		a := LinhaCarrinho{CupcakeID: 7, Opcoes: []uint{3, 1}}
+		b := LinhaCarrinho{CupcakeID: 7, Opcoes: []uint{1, 3}}
+		if a.Chave() != b.Chave() {
+			t.Errorf("Chaves diferentes para as mesmas opções: %q e %q", a.Chave(), b.Chave())
+		}
+	})
+
+	t.Run("Escolhas diferentes geram linhas diferentes", func(t *testing.T) {
+		base := LinhaCarrinho{CupcakeID: 7, Opcoes: []uint{1}}
+		outra := LinhaCarrinho{CupcakeID: 7, Opcoes: []uint{2}}
+		comTexto := LinhaCarrinho{CupcakeID: 7, Opcoes: []uint{1}, Textos: map[uint]string{4: "Parabéns"}}
+		if base.Chave() == outra.Chave() || base.Chave() == comTexto.Chave() {
+			t.Error("Escolhas diferentes não deveriam compartilhar a chave")
+		}
+	})
+}
+
+func TestCarrinho(t *testing.T) {
+	t.Run("Adicionar junta linhas iguais", func(t *testing.T) {
+		carrinho := Carrinho{}
+		chave := carrinho.Adicionar(LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{2, 5}}, 1)
+		carrinho.Adicionar(LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{5, 2}}, 2)
+		carrinho.Adicionar(LinhaCarrinho{CupcakeID: 1}, 1)
+
+		if len(carrinho) != 2 {
+			t.Fatalf("Carrinho com %d linhas; esperado 2", len(carrinho))
+		}
+		if carrinho[chave].Quantidade != 3 {
+			t.Errorf("Quantidade = %d; esperado 3", carrinho[chave].Quantidade)
+		}
+		if carrinho.TotalItens() != 4 {
+			t.Errorf("TotalItens = %d; esperado 4", carrinho.TotalItens())
+		}
+		if ids := carrinho.CupcakeIDs(); len(ids) != 1 || ids[0] != 1 {
+			t.Errorf("CupcakeIDs = %v; esperado [1]", ids)
+		}
+	})
+
+	t.Run("Aumentar e Diminuir", func(t *testing.T) {
+		carrinho := Carrinho{}
+		chave := carrinho.Adicionar(LinhaCarrinho{CupcakeID: 1}, 1)
+		if !carrinho.Aumentar(chave) || carrinho[chave].Quantidade != 2 {
+			t.Errorf("Aumentar deveria levar a quantidade a 2, obteve %d", carrinho[chave].Quantidade)
+		}
+		if carrinho.Aumentar("99") {
+			t.Error("Aumentar não deveria criar linhas")
+		}
+		carrinho.Diminuir(chave)
+		carrinho.Diminuir(chave)
+		if _, ok := carrinho[chave]; ok {
+			t.Error("Linha deveria ser removida ao chegar a zero")
+		}
+	})
+
+	t.Run("DefinirQuantidade", func(t *testing.T) {
+		carrinho := Carrinho{}
+		chave := carrinho.Adicionar(LinhaCarrinho{CupcakeID: 1}, 1)
+		if !carrinho.DefinirQuantidade(chave, 5) || carrinho[chave].Quantidade != 5 {
+			t.Errorf("Quantidade = %d; esperado 5", carrinho[chave].Quantidade)
+		}
+		if carrinho.DefinirQuantidade("99", 2) || len(carrinho) != 1 {
+			t.Error("DefinirQuantidade não deveria criar linhas")
+		}
+		if !carrinho.DefinirQuantidade(chave, 0) || len(carrinho) != 0 {
+			t.Error("Quantidade zero deveria remover a linha")
+		}
+	})
+
+	t.Run("Converte o carrinho legado", func(t *testing.T) {
+		carrinho := CarrinhoLegado(map[uint]int{1: 2, 3: 1})
+		if carrinho["1"].Quantidade != 2 || carrinho["3"].CupcakeID != 3 || carrinho.TotalItens() != 3 {
+			t.Errorf("Conversão inesperada: %+v", carrinho)
+		}
+	})
+}
+
+func cupcakeComOpcoes() model.Cupcake {
+	return model.Cupcake{
+		ID:    1,
+		Preco: 10,
+		GruposOpcoes: []model.GrupoOpcao{
+			{ID: 1, Nome: "Tamanho", Tipo: model.GrupoEscolha, Obrigatorio: true, MaxEscolhas: 1, Opcoes: []model.Opcao{
+				{ID: 11, Nome: "Mini", PrecoAdicional: -3, Disponivel: true},
+				{ID: 12, Nome: "Grande", PrecoAdicional: 4, Disponivel: true},
+			}},
+			{ID: 2, Nome: "Cobertura", Tipo: model.GrupoEscolha, MaxEscolhas: 2, Posicao: 1, Opcoes: []model.Opcao{
+				{ID: 21, Nome: "Granulado", PrecoAdicional: 1.5, Disponivel: true},
+				{ID: 22, Nome: "Morango", PrecoAdicional: 2, Disponivel: true},
+				{ID: 23, Nome: "Pistache", PrecoAdicional: 5, Disponivel: false},
+			}},
+			{ID: 3, Nome: "Mensagem", Tipo: model.GrupoTexto, MaxCaracteres: 10, PrecoAdicional: 3, Posicao: 2},
+		},
+	}
+}
+
+func TestPrecoLinha(t *testing.T) {
+	cupcake := cupcakeComOpcoes()
+
+	t.Run("Soma os adicionais", func(t *testing.T) {
+		linha := LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{12, 21, 22}, Textos: map[uint]string{3: "Parabéns"}}
+		preco, opcoes, err := PrecoLinha(cupcake, linha)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if preco != 20.5 {
+			t.Errorf("Preço = %.2f; esperado 20.50", preco)
+		}
+		if len(opcoes) != 4 || opcoes[3].Valor != "Parabéns" || opcoes[3].OpcaoID != nil {
+			t.Errorf("Opções registradas inesperadas: %+v", opcoes)
+		}
+		if got := DescreverOpcoes(opcoes[:1]); got != "Tamanho: Grande" {
+			t.Errorf("DescreverOpcoes = %q", got)
+		}
+	})
+
+	t.Run("Adicional negativo reduz o preço", func(t *testing.T) {
+		preco, _, err := PrecoLinha(cupcake, LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11}})
+		if err != nil || preco != 7 {
+			t.Errorf("PrecoLinha = %.2f, %v; esperado 7.00", preco, err)
+		}
+	})
+
+	casos := []struct {
+		nome  string
+		linha LinhaCarrinho
+	}{
+		{"Grupo obrigatório sem escolha", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{21}}},
+		{"Mais escolhas que o máximo", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11, 12}}},
+		{"Opção esgotada", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11, 23}}},
+		{"Texto acima do limite", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11}, Textos: map[uint]string{3: "Feliz aniversário"}}},
+		{"Opção de outro cupcake", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11, 99}}},
+		{"Texto de grupo inexistente", LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{11}, Textos: map[uint]string{9: "oi"}}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if _, _, err := PrecoLinha(cupcake, caso.linha); !errors.Is(err, ErrOpcoesInvalidas) {
+				t.Errorf("Erro = %v; esperado ErrOpcoesInvalidas", err)
+			}
+		})
+	}
+}
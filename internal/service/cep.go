@@ -0,0 +1,250 @@
+// /internal/service/cep.go
+package service
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCEPInvalido indica que o CEP informado não tem 8 dígitos.
+	ErrCEPInvalido = errors.New("CEP inválido")
+	// ErrCEPNaoEncontrado indica que o provedor não conhece o CEP.
+	ErrCEPNaoEncontrado = errors.New("CEP não encontrado")
+	// ErrUFInvalida indica que a sigla do estado não é uma UF brasileira.
+	ErrUFInvalida = errors.New("UF inválida")
+)
+
+// UFs lista as siglas válidas para Usuario.Estado.
+var UFs = []string{
+	"AC", "AL", "AP", "AM", "BA", "CE", "DF", "ES", "GO", "MA", "MT", "MS", "MG", "PA",
+	"PB", "PR", "PE", "PI", "RJ", "RN", "RS", "RO", "RR", "SC", "SP", "SE", "TO",
+}
+
+// Endereco é o resultado de uma consulta de CEP.
+type Endereco struct {
+	CEP    string `json:"cep"`
+	Rua    string `json:"rua"`
+	Bairro string `json:"bairro"`
+	Cidade string `json:"cidade"`
+	Estado string `json:"estado"`
+}
+
+// CEPProvider consulta o endereço correspondente a um CEP já normalizado (8 dígitos).
+type CEPProvider interface {
+	BuscarCEP(ctx context.Context, cep string) (Endereco, error)
+}
+
+// NormalizarCEP remove a formatação e retorna apenas os 8 dígitos do CEP.
+func NormalizarCEP(cep string) (string, error) {
+	var b strings.Builder
+	for _, r := range cep {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' || r == '.' || r == ' ':
+			// separadores aceitos
+		default:
+			return "", ErrCEPInvalido
+		}
+	}
+	digits := b.String()
+	if len(digits) != 8 {
+		return "", ErrCEPInvalido
+	}
+	return digits, nil
+}
+
+// FormatarCEP formata um CEP normalizado no padrão 00000-000.
+func FormatarCEP(cep string) string {
+	if len(cep) != 8 {
+		return cep
+	}
+	return cep[:5] + "-" + cep[5:]
+}
+
+// NormalizarUF valida a sigla do estado e a retorna em maiúsculas.
+func NormalizarUF(uf string) (string, error) {
+	uf = strings.ToUpper(strings.TrimSpace(uf))
+	for _, valida := range UFs {
+		if uf == valida {
+			return uf, nil
+		}
+	}
+	return "", ErrUFInvalida
+}
+
+// --- Adaptador HTTP (ViaCEP) ---
+
+// ViaCEPProvider consulta uma API compatível com o ViaCEP (GET {BaseURL}/ws/{cep}/json/).
+type ViaCEPProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewViaCEPProvider cria o adaptador apontando para baseURL (ex: https://viacep.com.br).
+func NewViaCEPProvider(baseURL string) *ViaCEPProvider {
+	return &ViaCEPProvider{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type viaCEPResponse struct {
+	CEP        string `json:"cep"`
+	Logradouro string `json:"logradouro"`
+	Bairro     string `json:"bairro"`
+	Localidade string `json:"localidade"`
+	UF         string `json:"uf"`
+	Erro       any    `json:"erro"` // a API já retornou tanto true quanto "true"
+}
+
+func (p *ViaCEPProvider) BuscarCEP(ctx context.Context, cep string) (Endereco, error) {
+	url := fmt.Sprintf("%s/ws/%s/json/", p.BaseURL, cep)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Endereco{}, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Endereco{}, fmt.Errorf("falha ao consultar CEP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound {
+		return Endereco{}, ErrCEPNaoEncontrado
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Endereco{}, fmt.Errorf("provedor de CEP retornou status %d", resp.StatusCode)
+	}
+
+	var data viaCEPResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return Endereco{}, fmt.Errorf("resposta inválida do provedor de CEP: %w", err)
+	}
+	if data.Erro != nil && data.Erro != false && data.Erro != "false" {
+		return Endereco{}, ErrCEPNaoEncontrado
+	}
+
+	return Endereco{
+		CEP:    FormatarCEP(cep),
+		Rua:    data.Logradouro,
+		Bairro: data.Bairro,
+		Cidade: data.Localidade,
+		Estado: data.UF,
+	}, nil
+}
+
+// --- Adaptador estático (testes / modo offline) ---
+
+// StaticCEPProvider responde a partir de um mapa fixo, carregado de um arquivo JSON
+// no formato {"01001000": {"rua": "...", "bairro": "...", "cidade": "...", "estado": "SP"}}.
+type StaticCEPProvider struct {
+	enderecos map[string]Endereco
+}
+
+// NewStaticCEPProvider carrega os endereços do arquivo JSON em path.
+func NewStaticCEPProvider(path string) (*StaticCEPProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de CEPs: %w", err)
+	}
+	var dados map[string]Endereco
+	if err := json.Unmarshal(raw, &dados); err != nil {
+		return nil, fmt.Errorf("arquivo de CEPs inválido: %w", err)
+	}
+
+	enderecos := make(map[string]Endereco, len(dados))
+	for cep, endereco := range dados {
+		normalizado, err := NormalizarCEP(cep)
+		if err != nil {
+			return nil, fmt.Errorf("CEP inválido no arquivo (%s): %w", cep, err)
+		}
+		endereco.CEP = FormatarCEP(normalizado)
+		enderecos[normalizado] = endereco
+	}
+	return &StaticCEPProvider{enderecos: enderecos}, nil
+}
+
+func (p *StaticCEPProvider) BuscarCEP(_ context.Context, cep string) (Endereco, error) {
+	endereco, ok := p.enderecos[cep]
+	if !ok {
+		return Endereco{}, ErrCEPNaoEncontrado
+	}
+	return endereco, nil
+}
+
+// --- Cache LRU ---
+
+type cepCacheEntry struct {
+	cep      string
+	endereco Endereco
+}
+
+// CachedCEPProvider mantém as consultas bem-sucedidas mais recentes em memória (LRU).
+type CachedCEPProvider struct {
+	provider CEPProvider
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewCachedCEPProvider envolve provider com um cache de até capacity entradas.
+func NewCachedCEPProvider(provider CEPProvider, capacity int) *CachedCEPProvider {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &CachedCEPProvider{
+		provider: provider,
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (p *CachedCEPProvider) BuscarCEP(ctx context.Context, cep string) (Endereco, error) {
+	p.mu.Lock()
+	if el, ok := p.items[cep]; ok {
+		p.order.MoveToFront(el)
+		endereco := el.Value.(*cepCacheEntry).endereco
+		p.mu.Unlock()
+		return endereco, nil
+	}
+	p.mu.Unlock()
+
+	endereco, err := p.provider.BuscarCEP(ctx, cep)
+	if err != nil {
+		return Endereco{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.items[cep]; ok {
+		p.order.MoveToFront(el)
+		return endereco, nil
+	}
+	p.items[cep] = p.order.PushFront(&cepCacheEntry{cep: cep, endereco: endereco})
+	if p.order.Len() > p.capacity {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.items, oldest.Value.(*cepCacheEntry).cep)
+	}
+	return endereco, nil
+}
+
+// Len retorna quantas entradas estão no cache.
+func (p *CachedCEPProvider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.order.Len()
+}
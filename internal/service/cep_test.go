@@ -0,0 +1,150 @@
+// /internal/service/cep_test.go
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// contadorCEPProvider conta quantas consultas chegaram ao provedor real.
+type contadorCEPProvider struct {
+	provider CEPProvider
+	chamadas int
+}
+
+func (p *contadorCEPProvider) BuscarCEP(ctx context.Context, cep string) (Endereco, error) {
+	p.chamadas++
+	return p.provider.BuscarCEP(ctx, cep)
+}
+
+func TestNormalizarCEP(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"01001-000", "01001000", true},
+		{"01001000", "01001000", true},
+		{" 01.001-000 ", "01001000", true},
+		{"0100100", "", false},
+		{"010010000", "", false},
+		{"01001-00a", "", false},
+		{"", "", false},
+	}
+
+	for _, caso := range casos {
+		obtido, err := NormalizarCEP(caso.entrada)
+		if caso.valido && (err != nil || obtido != caso.esperado) {
+			t.Errorf("NormalizarCEP(%q) = %q, %v; esperado %q", caso.entrada, obtido, err, caso.esperado)
+		}
+		if !caso.valido && !errors.Is(err, ErrCEPInvalido) {
+			t.Errorf("NormalizarCEP(%q) deveria retornar ErrCEPInvalido, obteve %v", caso.entrada, err)
+		}
+	}
+
+	if formatado := FormatarCEP("01001000"); formatado != "01001-000" {
+		t.Errorf("FormatarCEP: esperado 01001-000, obteve %s", formatado)
+	}
+}
+
+func TestNormalizarUF(t *testing.T) {
+	if uf, err := NormalizarUF(" sp "); err != nil || uf != "SP" {
+		t.Errorf("NormalizarUF(\" sp \") = %q, %v; esperado SP", uf, err)
+	}
+	for _, invalida := range []string{"", "XX", "São Paulo"} {
+		if _, err := NormalizarUF(invalida); !errors.Is(err, ErrUFInvalida) {
+			t.Errorf("NormalizarUF(%q) deveria retornar ErrUFInvalida, obteve %v", invalida, err)
+		}
+	}
+}
+
+func TestStaticCEPProvider(t *testing.T) {
+	provider, err := NewStaticCEPProvider(filepath.Join("testdata", "ceps.json"))
+	if err != nil {
+		t.Fatalf("Erro ao carregar testdata/ceps.json: %v", err)
+	}
+
+	endereco, err := provider.BuscarCEP(context.Background(), "01001000")
+	if err != nil {
+		t.Fatalf("CEP 01001000 deveria existir no arquivo: %v", err)
+	}
+	if endereco.CEP != "01001-000" || endereco.Cidade != "São Paulo" || endereco.Estado != "SP" {
+		t.Errorf("Endereço inesperado: %+v", endereco)
+	}
+
+	if _, err := provider.BuscarCEP(context.Background(), "99999999"); !errors.Is(err, ErrCEPNaoEncontrado) {
+		t.Errorf("CEP inexistente deveria retornar ErrCEPNaoEncontrado, obteve %v", err)
+	}
+}
+
+func TestViaCEPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ws/01001000/json/":
+			w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP"}`))
+		case "/ws/99999999/json/":
+			w.Write([]byte(`{"erro": "true"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewViaCEPProvider(server.URL)
+
+	endereco, err := provider.BuscarCEP(context.Background(), "01001000")
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if endereco.Rua != "Praça da Sé" || endereco.Bairro != "Sé" || endereco.Estado != "SP" {
+		t.Errorf("Endereço inesperado: %+v", endereco)
+	}
+
+	if _, err := provider.BuscarCEP(context.Background(), "99999999"); !errors.Is(err, ErrCEPNaoEncontrado) {
+		t.Errorf("Resposta com 'erro' deveria virar ErrCEPNaoEncontrado, obteve %v", err)
+	}
+}
+
+func TestCachedCEPProvider(t *testing.T) {
+	static, err := NewStaticCEPProvider(filepath.Join("testdata", "ceps.json"))
+	if err != nil {
+		t.Fatalf("Erro ao carregar testdata/ceps.json: %v", err)
+	}
+	contador := &contadorCEPProvider{provider: static}
+	cache := NewCachedCEPProvider(contador, 2)
+	ctx := context.Background()
+
+	// --- Cenário 1: segunda consulta do mesmo CEP vem do cache ---
+	cache.BuscarCEP(ctx, "01001000")
+	cache.BuscarCEP(ctx, "01001000")
+	if contador.chamadas != 1 {
+		t.Errorf("Esperava 1 chamada ao provedor, obteve %d", contador.chamadas)
+	}
+
+	// --- Cenário 2: o menos usado recentemente é descartado ---
+	cache.BuscarCEP(ctx, "20040020")
+	cache.BuscarCEP(ctx, "01001000") // 01001000 volta a ser o mais recente
+	cache.BuscarCEP(ctx, "70040010") // descarta 20040020
+	if cache.Len() != 2 {
+		t.Errorf("Cache deveria ter 2 entradas, tem %d", cache.Len())
+	}
+	antes := contador.chamadas
+	cache.BuscarCEP(ctx, "01001000")
+	if contador.chamadas != antes {
+		t.Errorf("01001000 deveria continuar no cache")
+	}
+	cache.BuscarCEP(ctx, "20040020")
+	if contador.chamadas != antes+1 {
+		t.Errorf("20040020 deveria ter sido descartado do cache")
+	}
+
+	// --- Cenário 3: erros não são guardados ---
+	if _, err := cache.BuscarCEP(ctx, "99999999"); !errors.Is(err, ErrCEPNaoEncontrado) {
+		t.Errorf("Esperava ErrCEPNaoEncontrado, obteve %v", err)
+	}
+}
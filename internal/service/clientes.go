@@ -0,0 +1,253 @@
+// /internal/service/clientes.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// ClientesPorPagina é o tamanho de cada página da lista de clientes do lojista.
+	ClientesPorPagina = 20
+	// ValidadeRedefinicao é por quanto tempo o link de redefinição de senha vale.
+	ValidadeRedefinicao = 24 * time.Hour
+)
+
+var (
+	ErrNaoEhCliente        = errors.New("usuário não é cliente")
+	ErrLoginInvalido       = errors.New("e-mail ou senha inválidos")
+	ErrContaDesativada     = errors.New("conta desativada")
+	ErrRedefinicaoInvalida = errors.New("link de redefinição inválido ou expirado")
+)
+
+// AutenticarSenha confere e-mail e senha; é o mesmo teste do login do site e da API. O
+// e-mail é comparado exatamente como foi gravado no cadastro.
+func AutenticarSenha(db *gorm.DB, email, senha string) (model.Usuario, error) {
+	var usuario model.Usuario
+	err := db.Where("email = ?", email).First(&usuario).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return usuario, ErrLoginInvalido
+	}
+	if err != nil {
+		return usuario, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(usuario.SenhaHash), []byte(senha)) != nil {
+		return model.Usuario{}, ErrLoginInvalido
+	}
+	if !usuario.Ativo() {
+		return usuario, ErrContaDesativada
+	}
+	return usuario, nil
+}
+
+// statusVenda são os status que contam como compra nos totais do cliente.
+var statusVenda = []model.StatusOrder{model.StatusPago, model.StatusPreparando, model.StatusEnviado, model.StatusEntregue}
+
+// ResumoCliente é o cliente com os totais das compras dele.
+type ResumoCliente struct {
+	model.Usuario
+	Pedidos      int64
+	ValorTotal   float64
+	UltimoPedido *time.Time
+}
+
+// FiltroClientes são os parâmetros da lista de clientes.
+type FiltroClientes struct {
+	Busca  string // Parte do nome, e-mail ou telefone
+	Ordem  string // Uma das chaves de OrdensClientes; vazio ordena por nome
+	Pagina int    // A partir de 1
+}
+
+// OrdensClientes são as ordenações aceitas pela lista, com o SQL de cada uma.
+var OrdensClientes = map[string]string{
+	"nome":     "usuarios.nome, usuarios.id",
+	"valor":    "valor_total DESC, usuarios.id",
+	"ultimo":   "ultimo_pedido DESC NULLS LAST, usuarios.id",
+	"recentes": "usuarios.created_at DESC, usuarios.id DESC",
+}
+
+// PaginaClientes é uma página da lista de clientes.
+type PaginaClientes struct {
+	Clientes     []ResumoCliente
+	Total        int64
+	Pagina       int
+	TotalPaginas int
+}
+
+// escaparLike protege os curingas do LIKE no texto digitado na busca.
+func escaparLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// consultaClientes filtra os clientes pela busca.
+func consultaClientes(db *gorm.DB, busca string) *gorm.DB {
+	query := db.Model(&model.Usuario{}).Where("usuarios.tipo = ?", model.RoleCliente)
+	if busca = strings.TrimSpace(busca); busca != "" {
+		termo := "%" + escaparLike(busca) + "%"
+		query = query.Where("usuarios.nome ILIKE ? OR usuarios.email ILIKE ? OR usuarios.telefone ILIKE ?", termo, termo, termo)
+	}
+	return query
+}
+
+// comTotais junta os pedidos pagos de cada cliente à consulta.
+func comTotais(query *gorm.DB) *gorm.DB {
+	return query.
+		Select("usuarios.*, COUNT(orders.id) AS pedidos, COALESCE(SUM(orders.total), 0) AS valor_total, MAX(orders.created_at) AS ultimo_pedido").
+		Joins("LEFT JOIN orders ON orders.usuario_id = usuarios.id AND orders.deleted_at IS NULL AND orders.status IN ?", statusVenda).
+		Group("usuarios.id")
+}
+
+// TotalPaginas calcula quantas páginas são necessárias para total itens.
+func TotalPaginas(total int64, porPagina int) int {
+	if total <= 0 {
+		return 1
+	}
+	return int((total + int64(porPagina) - 1) / int64(porPagina))
+}
+
+// BuscarClientes lista uma página de clientes com os totais de compras.
+func BuscarClientes(db *gorm.DB, filtro FiltroClientes) (PaginaClientes, error) {
+	pagina := PaginaClientes{Pagina: filtro.Pagina}
+	if err := consultaClientes(db, filtro.Busca).Count(&pagina.Total).Error; err != nil {
+		return pagina, err
+	}
+	pagina.TotalPaginas = TotalPaginas(pagina.Total, ClientesPorPagina)
+	if pagina.Pagina < 1 {
+		pagina.Pagina = 1
+	}
+	if pagina.Pagina > pagina.TotalPaginas {
+		pagina.Pagina = pagina.TotalPaginas
+	}
+
+	ordem, ok := OrdensClientes[filtro.Ordem]
+	if !ok {
+		ordem = OrdensClientes["nome"]
+	}
+	err := comTotais(consultaClientes(db, filtro.Busca)).
+		Order(ordem).
+		Limit(ClientesPorPagina).
+		Offset((pagina.Pagina - 1) * ClientesPorPagina).
+		Scan(&pagina.Clientes).Error
+	return pagina, err
+}
+
+// ResumoDoCliente busca um cliente com os totais de compras.
+func ResumoDoCliente(db *gorm.DB, id uint) (ResumoCliente, error) {
+	var resumo ResumoCliente
+	res := comTotais(consultaClientes(db, "")).Where("usuarios.id = ?", id).Scan(&resumo)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return resumo, gorm.ErrRecordNotFound
+	}
+	return resumo, res.Error
+}
+
+// buscarCliente carrega a conta de cliente id.
+func buscarCliente(db *gorm.DB, id uint) (model.Usuario, error) {
+	var cliente model.Usuario
+	if err := db.First(&cliente, id).Error; err != nil {
+		return cliente, err
+	}
+	if cliente.Tipo != model.RoleCliente {
+		return cliente, ErrNaoEhCliente
+	}
+	return cliente, nil
+}
+
+// AlterarAtivoCliente desativa ou reativa a conta de um cliente. A conta desativada
+// perde a sessão na próxima requisição e os links de redefinição pendentes.
+func AlterarAtivoCliente(db *gorm.DB, id uint, ativo bool, agora time.Time) (model.Usuario, error) {
+	cliente, err := buscarCliente(db, id)
+	if err != nil {
+		return cliente, err
+	}
+	mudancas := map[string]any{"desativado_em": nil}
+	if !ativo {
+		mudancas = map[string]any{"desativado_em": agora, "redefinicao_hash": "", "redefinicao_expira_em": nil}
+	}
+	err = db.Model(&cliente).Updates(mudancas).Error
+	Usuarios.Invalidar(cliente.ID)
+	return cliente, err
+}
+
+// SolicitarRedefinicaoSenha gera o link de redefinição de senha do cliente. A senha
+// atual continua valendo até o link ser usado; um novo pedido invalida o anterior.
+func SolicitarRedefinicaoSenha(db *gorm.DB, id uint, agora time.Time) (model.Usuario, string, error) {
+	cliente, err := buscarCliente(db, id)
+	if err != nil {
+		return cliente, "", err
+	}
+	if !cliente.Ativo() {
+		return cliente, "", ErrContaDesativada
+	}
+	token, err := novoToken()
+	if err != nil {
+		return cliente, "", err
+	}
+	expira := agora.Add(ValidadeRedefinicao)
+	err = db.Model(&cliente).Updates(map[string]any{"redefinicao_hash": HashToken(token), "redefinicao_expira_em": expira}).Error
+	return cliente, token, err
+}
+
+// EmailRedefinicaoSenha monta o assunto e o corpo do e-mail com o link de redefinição.
+func EmailRedefinicaoSenha(nome, link string) (string, string) {
+	horas := int(ValidadeRedefinicao.Hours())
+	corpo := fmt.Sprintf(`Olá, %s!
+
+Recebemos um pedido para redefinir a senha da sua conta na Meu Cupcake.
+Para escolher uma nova senha, acesse o link abaixo (válido por %d horas):
+
+%s
+
+Se você não pediu a redefinição, ignore este e-mail: sua senha atual continua valendo.
+
+Equipe Meu Cupcake`, nome, horas, link)
+	return "Redefinição de senha - Meu Cupcake", corpo
+}
+
+// BuscarRedefinicao encontra o cliente dono de um link de redefinição ainda válido.
+func BuscarRedefinicao(db *gorm.DB, token string, agora time.Time) (model.Usuario, error) {
+	var cliente model.Usuario
+	if token == "" {
+		return cliente, ErrRedefinicaoInvalida
+	}
+	err := db.Where("redefinicao_hash = ? AND redefinicao_expira_em > ? AND desativado_em IS NULL", HashToken(token), agora).
+		First(&cliente).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cliente, ErrRedefinicaoInvalida
+	}
+	return cliente, err
+}
+
+// RedefinirSenha troca a senha do cliente e invalida o link.
+func RedefinirSenha(db *gorm.DB, token, senha string) (model.Usuario, error) {
+	if utf8.RuneCountInString(senha) < MinSenha {
+		return model.Usuario{}, ErrSenhaCurta
+	}
+	cliente, err := BuscarRedefinicao(db, token, time.Now())
+	if err != nil {
+		return cliente, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
+	if err != nil {
+		return cliente, err
+	}
+	cliente.SenhaHash = string(hash)
+	// Como em AceitarConvite, o hash no WHERE faz o link valer uma vez só
+	res := db.Model(&cliente).Where("redefinicao_hash = ?", cliente.RedefinicaoHash).
+		Updates(map[string]any{"senha_hash": cliente.SenhaHash, "redefinicao_hash": "", "redefinicao_expira_em": nil})
+	if res.Error != nil {
+		return cliente, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return cliente, ErrRedefinicaoInvalida
+	}
+	Usuarios.Invalidar(cliente.ID)
+	return cliente, nil
+}
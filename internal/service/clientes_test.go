@@ -0,0 +1,91 @@
+// /internal/service/clientes_test.go
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestTotalPaginas(t *testing.T) {
+	casos := []struct {
+		total    int64
+		esperado int
+	}{
+		{0, 1},
+		{1, 1},
+		{20, 1},
+		{21, 2},
+		{60, 3},
+	}
+	for _, caso := range casos {
+		if got := TotalPaginas(caso.total, ClientesPorPagina); got != caso.esperado {
+			t.Errorf("TotalPaginas(%d) = %d; esperado %d", caso.total, got, caso.esperado)
+		}
+	}
+}
+
+func TestEscaparLike(t *testing.T) {
+	if got := escaparLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("escaparLike = %q", got)
+	}
+	if got := escaparLike("Ana Maria"); got != "Ana Maria" {
+		t.Errorf("Texto sem curingas mudou: %q", got)
+	}
+}
+
+func TestContaCliente(t *testing.T) {
+	t.Run("Conta desativada", func(t *testing.T) {
+		agora := time.Now()
+		if !(model.Usuario{}).Ativo() {
+			t.Error("Conta nova deveria estar ativa")
+		}
+		if (model.Usuario{DesativadoEm: &agora}).Ativo() {
+			t.Error("Conta desativada não deveria estar ativa")
+		}
+	})
+
+	t.Run("Atendente gerencia clientes, cozinha não", func(t *testing.T) {
+		if !model.PapelAtendente.Pode(model.PermissaoClientes) || model.PapelCozinha.Pode(model.PermissaoClientes) {
+			t.Error("Permissão de clientes inesperada")
+		}
+	})
+}
+
+func TestEmailRedefinicaoSenha(t *testing.T) {
+	assunto, corpo := EmailRedefinicaoSenha("Ana", "https://loja/redefinir-senha/abc")
+	if assunto == "" || !strings.Contains(corpo, "Olá, Ana!") || !strings.Contains(corpo, "https://loja/redefinir-senha/abc") {
+		t.Errorf("E-mail inesperado: %q\n%s", assunto, corpo)
+	}
+	if !strings.Contains(corpo, "24 horas") {
+		t.Errorf("O e-mail deveria informar a validade do link:\n%s", corpo)
+	}
+
+	msg := string(montarEmail("loja@x.com", "ana@x.com", assunto, corpo))
+	if !strings.Contains(msg, "Subject: =?utf-8?q?") {
+		t.Errorf("Assunto com acento deveria ser codificado:\n%s", msg)
+	}
+	if strings.Contains(msg, "\n") && strings.Count(msg, "\r\n") != strings.Count(msg, "\n") {
+		t.Error("Todas as linhas deveriam terminar em CRLF")
+	}
+}
+
+func TestAutenticarSenha(t *testing.T) {
+	// DryRun monta a consulta sem banco; o callback guarda o valor usado no WHERE
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=invalido"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var valores []any
+	db.Callback().Query().After("gorm:query").Register("teste:valores", func(tx *gorm.DB) { valores = tx.Statement.Vars })
+
+	// O e-mail com maiúsculas é buscado como foi cadastrado
+	AutenticarSenha(db, "Ana.Souza@Exemplo.com", "senha")
+	if len(valores) == 0 || valores[0] != "Ana.Souza@Exemplo.com" {
+		t.Errorf("Valores da consulta = %v; esperado o e-mail exatamente como digitado", valores)
+	}
+}
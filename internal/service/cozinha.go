@@ -0,0 +1,195 @@
+// /internal/service/cozinha.go
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// statusCozinha são as colunas do quadro de produção, na ordem em que o pedido anda.
+var statusCozinha = []model.StatusOrder{model.StatusPago, model.StatusPreparando, model.StatusEnviado}
+
+// ProximoStatusCozinha é o status para onde o pedido vai ao avançar no quadro; vazio
+// se ele já está na última etapa.
+func ProximoStatusCozinha(status model.StatusOrder) model.StatusOrder {
+	switch status {
+	case model.StatusPago:
+		return model.StatusPreparando
+	case model.StatusPreparando:
+		return model.StatusEnviado
+	case model.StatusEnviado:
+		return model.StatusEntregue
+	}
+	return ""
+}
+
+// CartaoCozinha é um pedido no quadro, com os cupcakes a produzir.
+type CartaoCozinha struct {
+	Pedido        model.Order
+	Itens         []ProducaoItem
+	TotalCupcakes int
+	Proximo       model.StatusOrder
+	Acao          string // Texto do botão que leva ao próximo status
+}
+
+// ColunaCozinha agrupa os pedidos de um status.
+type ColunaCozinha struct {
+	Status  model.StatusOrder
+	Titulo  string
+	Cartoes []CartaoCozinha
+}
+
+// QuadroCozinha é o quadro de produção: as colunas e o total a assar hoje.
+type QuadroCozinha struct {
+	Colunas   []ColunaCozinha
+	AssarHoje []ProducaoItem // Pedidos pagos ou em preparo para hoje, atrasados ou sem data
+	TotalHoje int
+}
+
+// acaoCozinha é o texto do botão que avança o pedido a partir do status.
+func acaoCozinha(pedido model.Order) string {
+	switch pedido.Status {
+	case model.StatusPago:
+		return "Iniciar preparo"
+	case model.StatusPreparando:
+		if pedido.TipoEntrega == model.TipoEntregaRetirada {
+			return "Pronto para retirada"
+		}
+		return "Saiu para entrega"
+	case model.StatusEnviado:
+		if pedido.TipoEntrega == model.TipoEntregaRetirada {
+			return "Retirado"
+		}
+		return "Entregue"
+	}
+	return ""
+}
+
+// nomeProducao identifica o que é produzido: personalizações diferentes são feitas separadamente.
+func nomeProducao(item model.ItemOrder) string {
+	nome := nomeItem(item)
+	if len(item.Opcoes) > 0 {
+		nome += " (" + DescreverOpcoes(item.Opcoes) + ")"
+	}
+	return nome
+}
+
+// somarProducao soma as quantidades por nome, em ordem alfabética.
+func somarProducao(contagem map[string]int) []ProducaoItem {
+	itens := make([]ProducaoItem, 0, len(contagem))
+	for nome, qtd := range contagem {
+		itens = append(itens, ProducaoItem{Nome: nome, Quantidade: qtd})
+	}
+	sort.Slice(itens, func(i, j int) bool { return itens[i].Nome < itens[j].Nome })
+	return itens
+}
+
+// MontarQuadroCozinha distribui os pedidos pelas colunas, dos mais urgentes (data e
+// horário de entrega) para os mais novos.
+func MontarQuadroCozinha(pedidos []model.Order, hoje time.Time) QuadroCozinha {
+	hoje = DiaLoja(hoje)
+	colunas := make(map[model.StatusOrder]*ColunaCozinha, len(statusCozinha))
+	quadro := QuadroCozinha{Colunas: make([]ColunaCozinha, len(statusCozinha))}
+	for i, status := range statusCozinha {
+		titulo := TituloStatus(status, "")
+		if status == model.StatusEnviado {
+			titulo = "Enviado / pronto para retirada"
+		}
+		quadro.Colunas[i] = ColunaCozinha{Status: status, Titulo: titulo}
+		colunas[status] = &quadro.Colunas[i]
+	}
+
+	pedidos = append([]model.Order(nil), pedidos...)
+	sort.SliceStable(pedidos, func(i, j int) bool { return antesNaCozinha(pedidos[i], pedidos[j]) })
+
+	assar := make(map[string]int)
+	for _, pedido := range pedidos {
+		coluna, ok := colunas[pedido.Status]
+		if !ok {
+			continue
+		}
+		contagem := make(map[string]int)
+		cartao := CartaoCozinha{Pedido: pedido, Proximo: ProximoStatusCozinha(pedido.Status), Acao: acaoCozinha(pedido)}
+		for _, item := range pedido.Items {
+			contagem[nomeProducao(item)] += item.Quantidade
+			cartao.TotalCupcakes += item.Quantidade
+		}
+		cartao.Itens = somarProducao(contagem)
+		coluna.Cartoes = append(coluna.Cartoes, cartao)
+
+		paraHoje := pedido.DataEntrega == nil || !Dia(*pedido.DataEntrega).After(hoje)
+		if pedido.Status != model.StatusEnviado && paraHoje {
+			for nome, qtd := range contagem {
+				assar[nome] += qtd
+			}
+			quadro.TotalHoje += cartao.TotalCupcakes
+		}
+	}
+	quadro.AssarHoje = somarProducao(assar)
+	return quadro
+}
+
+// antesNaCozinha ordena por data de entrega (sem data primeiro), horário da janela e criação.
+func antesNaCozinha(a, b model.Order) bool {
+	if (a.DataEntrega == nil) != (b.DataEntrega == nil) {
+		return a.DataEntrega == nil
+	}
+	if a.DataEntrega != nil && !a.DataEntrega.Equal(*b.DataEntrega) {
+		return a.DataEntrega.Before(*b.DataEntrega)
+	}
+	inicioA, inicioB := "", ""
+	if a.JanelaEntrega != nil {
+		inicioA = a.JanelaEntrega.HoraInicio
+	}
+	if b.JanelaEntrega != nil {
+		inicioB = b.JanelaEntrega.HoraInicio
+	}
+	if inicioA != inicioB {
+		return inicioA < inicioB
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// CarregarQuadroCozinha busca os pedidos em produção e monta o quadro.
+func CarregarQuadroCozinha(db *gorm.DB, agora time.Time) (QuadroCozinha, error) {
+	var pedidos []model.Order
+	err := db.Preload("Usuario").
+		Preload("Items.Cupcake", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Items.Opcoes").
+		Preload("JanelaEntrega", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("status IN ?", statusCozinha).
+		Find(&pedidos).Error
+	if err != nil {
+		return QuadroCozinha{}, err
+	}
+	return MontarQuadroCozinha(pedidos, agora), nil
+}
+
+// ErrPedidoForaDaEtapa indica que o pedido já saiu da coluna de onde o lojista o moveu
+// (outra aba ou o webhook atualizou antes).
+var ErrPedidoForaDaEtapa = errors.New("o pedido não está mais nesta etapa")
+
+// AvancarPedidoCozinha move o pedido da etapa "de" para a seguinte do quadro e o
+// devolve já com o novo status.
+func AvancarPedidoCozinha(db *gorm.DB, pedidoID uint, de model.StatusOrder) (model.Order, error) {
+	var pedido model.Order
+	proximo := ProximoStatusCozinha(de)
+	if proximo == "" {
+		return pedido, ErrPedidoForaDaEtapa
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pedido, pedidoID).Error; err != nil {
+			return err
+		}
+		if pedido.Status != de {
+			return ErrPedidoForaDaEtapa
+		}
+		return AtualizarStatusPedido(tx, &pedido, proximo)
+	})
+	return pedido, err
+}
@@ -0,0 +1,90 @@
+// /internal/service/cozinha_test.go
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestMontarQuadroCozinha(t *testing.T) {
+	hoje := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+	amanha := Dia(hoje).AddDate(0, 0, 1)
+	ontem := Dia(hoje).AddDate(0, 0, -1)
+	grande := []model.ItemOrderOpcao{{Grupo: "Tamanho", Valor: "Grande"}}
+
+	pedidos := []model.Order{
+		{ID: 1, Status: model.StatusPago, DataEntrega: &amanha, Items: []model.ItemOrder{
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 6},
+		}},
+		{ID: 2, Status: model.StatusPago, Items: []model.ItemOrder{
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 2},
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 1, Opcoes: grande},
+		}},
+		{ID: 3, Status: model.StatusPreparando, DataEntrega: &ontem, Items: []model.ItemOrder{
+			{CupcakeID: 2, Cupcake: limao, Quantidade: 4},
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 1},
+		}},
+		{ID: 4, Status: model.StatusEnviado, TipoEntrega: model.TipoEntregaRetirada, Items: []model.ItemOrder{
+			{CupcakeID: 2, Cupcake: limao, Quantidade: 12},
+		}},
+		{ID: 5, Status: model.StatusEntregue, Items: []model.ItemOrder{{CupcakeID: 2, Cupcake: limao, Quantidade: 1}}},
+	}
+
+	quadro := MontarQuadroCozinha(pedidos, hoje)
+
+	t.Run("Distribui os pedidos nas colunas, sem data primeiro", func(t *testing.T) {
+		if len(quadro.Colunas) != 3 {
+			t.Fatalf("Esperado 3 colunas, obtido %d", len(quadro.Colunas))
+		}
+		pagos := quadro.Colunas[0].Cartoes
+		if len(pagos) != 2 || pagos[0].Pedido.ID != 2 || pagos[1].Pedido.ID != 1 {
+			t.Errorf("Coluna de pagos inesperada: %+v", pagos)
+		}
+		if len(quadro.Colunas[1].Cartoes) != 1 || len(quadro.Colunas[2].Cartoes) != 1 {
+			t.Errorf("Pedido entregue não deveria aparecer: %+v", quadro.Colunas)
+		}
+		if pagos[0].TotalCupcakes != 3 || len(pagos[0].Itens) != 2 || pagos[0].Itens[0].Nome != "Red Velvet" {
+			t.Errorf("Cartão inesperado: %+v", pagos[0])
+		}
+	})
+
+	t.Run("Ações seguem o tipo de entrega", func(t *testing.T) {
+		enviado := quadro.Colunas[2].Cartoes[0]
+		if enviado.Proximo != model.StatusEntregue || enviado.Acao != "Retirado" {
+			t.Errorf("Ação inesperada: %q -> %q", enviado.Acao, enviado.Proximo)
+		}
+		if quadro.Colunas[1].Cartoes[0].Acao != "Saiu para entrega" {
+			t.Errorf("Ação inesperada: %q", quadro.Colunas[1].Cartoes[0].Acao)
+		}
+	})
+
+	t.Run("Assar hoje ignora pedidos futuros e enviados", func(t *testing.T) {
+		esperado := []ProducaoItem{
+			{Nome: "Limão", Quantidade: 4},
+			{Nome: "Red Velvet", Quantidade: 3},
+			{Nome: "Red Velvet (Tamanho: Grande)", Quantidade: 1},
+		}
+		if len(quadro.AssarHoje) != len(esperado) {
+			t.Fatalf("Assar hoje = %+v", quadro.AssarHoje)
+		}
+		for i, item := range esperado {
+			if quadro.AssarHoje[i] != item {
+				t.Errorf("Assar hoje[%d] = %+v; esperado %+v", i, quadro.AssarHoje[i], item)
+			}
+		}
+		if quadro.TotalHoje != 8 {
+			t.Errorf("Total hoje = %d; esperado 8", quadro.TotalHoje)
+		}
+	})
+}
+
+func TestProximoStatusCozinha(t *testing.T) {
+	if ProximoStatusCozinha(model.StatusPago) != model.StatusPreparando || ProximoStatusCozinha(model.StatusPreparando) != model.StatusEnviado {
+		t.Error("Sequência da cozinha inesperada")
+	}
+	if ProximoStatusCozinha(model.StatusEntregue) != "" || ProximoStatusCozinha(model.StatusPendente) != "" {
+		t.Error("Pedido fora do quadro não deveria avançar")
+	}
+}
@@ -0,0 +1,180 @@
+// /internal/service/cupom.go
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCupomInvalido        = errors.New("cupom inválido ou expirado")
+	ErrCupomValorMinimo     = errors.New("valor mínimo do pedido não atingido")
+	ErrCupomEsgotado        = errors.New("cupom esgotado")
+	ErrCupomLimiteCliente   = errors.New("limite de uso do cupom por cliente atingido")
+	ErrCupomSemItens        = errors.New("nenhum item do carrinho é válido para o cupom")
+	ErrCupomDescontoAlterou = errors.New("o desconto do cupom mudou")
+)
+
+// MensagemCupom traduz os erros de validação de cupom para mensagens exibidas ao cliente.
+func MensagemCupom(err error) string {
+	switch {
+	case errors.Is(err, ErrCupomValorMinimo):
+		return "O pedido ainda não atingiu o valor mínimo para este cupom."
+	case errors.Is(err, ErrCupomEsgotado):
+		return "Este cupom já atingiu o limite de usos."
+	case errors.Is(err, ErrCupomLimiteCliente):
+		return "Você já usou este cupom o número máximo de vezes."
+	case errors.Is(err, ErrCupomSemItens):
+		return "Nenhum item do seu carrinho é válido para este cupom."
+	case errors.Is(err, ErrCupomDescontoAlterou):
+		return "O desconto do cupom mudou. Revise seu pedido."
+	default:
+		return "Cupom inválido ou expirado."
+	}
+}
+
+// ErroDeCupom indica se err é um dos erros de validação de cupom.
+func ErroDeCupom(err error) bool {
+	for _, alvo := range []error{ErrCupomInvalido, ErrCupomValorMinimo, ErrCupomEsgotado,
+		ErrCupomLimiteCliente, ErrCupomSemItens, ErrCupomDescontoAlterou} {
+		if errors.Is(err, alvo) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizarCodigoCupom padroniza o código digitado (sem espaços, maiúsculas).
+func NormalizarCodigoCupom(codigo string) string {
+	return strings.ToUpper(strings.TrimSpace(codigo))
+}
+
+// ItemDesconto é a parte de um item do carrinho relevante para o cálculo do desconto.
+type ItemDesconto struct {
+	CupcakeID uint
+	Subtotal  float64
+}
+
+// arredondar arredonda para centavos.
+func arredondar(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// CalcularDesconto calcula o desconto do cupom para os itens, sem consultar o banco.
+// Se o cupom for restrito a alguns cupcakes, só o subtotal desses itens é descontado;
+// o valor mínimo é sempre comparado com o subtotal do pedido inteiro.
+func CalcularDesconto(cupom model.Cupom, itens []ItemDesconto) (float64, error) {
+	permitidos := make(map[uint]bool, len(cupom.Cupcakes))
+	for _, cp := range cupom.Cupcakes {
+		permitidos[cp.ID] = true
+	}
+
+	var subtotal, base float64
+	for _, item := range itens {
+		subtotal += item.Subtotal
+		if len(permitidos) == 0 || permitidos[item.CupcakeID] {
+			base += item.Subtotal
+		}
+	}
+
+	if subtotal+0.001 < cupom.ValorMinimo {
+		return 0, ErrCupomValorMinimo
+	}
+	if base <= 0 {
+		return 0, ErrCupomSemItens
+	}
+
+	var desconto float64
+	switch cupom.Tipo {
+	case model.CupomPercentual:
+		desconto = base * cupom.Valor / 100
+	case model.CupomValorFixo:
+		desconto = cupom.Valor
+	default:
+		return 0, ErrCupomInvalido
+	}
+	if desconto > base {
+		desconto = base
+	}
+	return arredondar(desconto), nil
+}
+
+// cupomVigente confere se o cupom está ativo e dentro da janela de validade.
+func cupomVigente(cupom model.Cupom, agora time.Time) bool {
+	if !cupom.Ativo {
+		return false
+	}
+	if cupom.ValidoDe != nil && agora.Before(*cupom.ValidoDe) {
+		return false
+	}
+	if cupom.ValidoAte != nil && agora.After(*cupom.ValidoAte) {
+		return false
+	}
+	return true
+}
+
+// statusNaoContaUso são os status de pedido que não consomem usos do cupom.
+var statusNaoContaUso = []model.StatusOrder{model.StatusFalhou, model.StatusCancelado}
+
+// ContarUsosCupom retorna quantos pedidos válidos usaram o cupom (filtrando por cliente se usuarioID > 0).
+func ContarUsosCupom(db *gorm.DB, cupomID, usuarioID uint) (int64, error) {
+	query := db.Model(&model.Order{}).Where("cupom_id = ? AND status NOT IN ?", cupomID, statusNaoContaUso)
+	if usuarioID > 0 {
+		query = query.Where("usuario_id = ?", usuarioID)
+	}
+	var total int64
+	err := query.Count(&total).Error
+	return total, err
+}
+
+// ValidarCupom busca o cupom pelo código e confere vigência, limites de uso e valor mínimo,
+// retornando o desconto para os itens. A linha do cupom é bloqueada (FOR UPDATE), então,
+// quando db é uma transação, os limites continuam valendo até o commit do pedido.
+// usuarioID = 0 pula a verificação do limite por cliente (visitante sem login).
+func ValidarCupom(db *gorm.DB, codigo string, usuarioID uint, itens []ItemDesconto, agora time.Time) (model.Cupom, float64, error) {
+	var cupom model.Cupom
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("codigo = ?", NormalizarCodigoCupom(codigo)).
+		First(&cupom).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cupom, 0, ErrCupomInvalido
+		}
+		return cupom, 0, err
+	}
+	if err := db.Model(&cupom).Association("Cupcakes").Find(&cupom.Cupcakes); err != nil {
+		return cupom, 0, err
+	}
+
+	if !cupomVigente(cupom, agora) {
+		return cupom, 0, ErrCupomInvalido
+	}
+
+	if cupom.LimiteUsoTotal > 0 {
+		usos, err := ContarUsosCupom(db, cupom.ID, 0)
+		if err != nil {
+			return cupom, 0, err
+		}
+		if usos >= int64(cupom.LimiteUsoTotal) {
+			return cupom, 0, ErrCupomEsgotado
+		}
+	}
+	if cupom.LimiteUsoPorCliente > 0 && usuarioID > 0 {
+		usos, err := ContarUsosCupom(db, cupom.ID, usuarioID)
+		if err != nil {
+			return cupom, 0, err
+		}
+		if usos >= int64(cupom.LimiteUsoPorCliente) {
+			return cupom, 0, ErrCupomLimiteCliente
+		}
+	}
+
+	desconto, err := CalcularDesconto(cupom, itens)
+	return cupom, desconto, err
+}
@@ -0,0 +1,99 @@
+// /internal/service/cupom_test.go
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestCalcularDesconto(t *testing.T) {
+	itens := []ItemDesconto{
+		{CupcakeID: 1, Subtotal: 30},
+		{CupcakeID: 2, Subtotal: 20},
+	}
+
+	t.Run("Percentual sobre o carrinho inteiro", func(t *testing.T) {
+		cupom := model.Cupom{Tipo: model.CupomPercentual, Valor: 10}
+		desconto, err := CalcularDesconto(cupom, itens)
+		if err != nil || desconto != 5 {
+			t.Errorf("CalcularDesconto = %.2f, %v; esperado 5.00", desconto, err)
+		}
+	})
+
+	t.Run("Percentual arredonda para centavos", func(t *testing.T) {
+		cupom := model.Cupom{Tipo: model.CupomPercentual, Valor: 15}
+		desconto, _ := CalcularDesconto(cupom, []ItemDesconto{{CupcakeID: 1, Subtotal: 8.99}})
+		if desconto != 1.35 {
+			t.Errorf("Desconto = %v; esperado 1.35", desconto)
+		}
+	})
+
+	t.Run("Valor fixo limitado ao subtotal", func(t *testing.T) {
+		cupom := model.Cupom{Tipo: model.CupomValorFixo, Valor: 80}
+		desconto, err := CalcularDesconto(cupom, itens)
+		if err != nil || desconto != 50 {
+			t.Errorf("CalcularDesconto = %.2f, %v; esperado 50.00", desconto, err)
+		}
+	})
+
+	t.Run("Restrito a alguns cupcakes", func(t *testing.T) {
+		cupom := model.Cupom{Tipo: model.CupomPercentual, Valor: 50, Cupcakes: []model.Cupcake{{ID: 2}}}
+		desconto, err := CalcularDesconto(cupom, itens)
+		if err != nil || desconto != 10 {
+			t.Errorf("CalcularDesconto = %.2f, %v; esperado 10.00", desconto, err)
+		}
+
+		cupom.Cupcakes = []model.Cupcake{{ID: 9}}
+		if _, err := CalcularDesconto(cupom, itens); !errors.Is(err, ErrCupomSemItens) {
+			t.Errorf("Erro = %v; esperado ErrCupomSemItens", err)
+		}
+	})
+
+	t.Run("Valor mínimo usa o subtotal do pedido", func(t *testing.T) {
+		cupom := model.Cupom{Tipo: model.CupomValorFixo, Valor: 5, ValorMinimo: 50, Cupcakes: []model.Cupcake{{ID: 2}}}
+		if _, err := CalcularDesconto(cupom, itens); err != nil {
+			t.Errorf("Pedido de R$ 50 deveria atingir o mínimo: %v", err)
+		}
+		cupom.ValorMinimo = 60
+		if _, err := CalcularDesconto(cupom, itens); !errors.Is(err, ErrCupomValorMinimo) {
+			t.Errorf("Erro = %v; esperado ErrCupomValorMinimo", err)
+		}
+	})
+}
+
+func TestCupomVigente(t *testing.T) {
+	agora := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+	antes := agora.Add(-time.Hour)
+	depois := agora.Add(time.Hour)
+
+	casos := []struct {
+		nome     string
+		cupom    model.Cupom
+		esperado bool
+	}{
+		{"Ativo sem janela", model.Cupom{Ativo: true}, true},
+		{"Inativo", model.Cupom{Ativo: false}, false},
+		{"Dentro da janela", model.Cupom{Ativo: true, ValidoDe: &antes, ValidoAte: &depois}, true},
+		{"Ainda não começou", model.Cupom{Ativo: true, ValidoDe: &depois}, false},
+		{"Expirado", model.Cupom{Ativo: true, ValidoAte: &antes}, false},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if got := cupomVigente(caso.cupom, agora); got != caso.esperado {
+				t.Errorf("cupomVigente = %v; esperado %v", got, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestMensagemCupom(t *testing.T) {
+	if !ErroDeCupom(ErrCupomEsgotado) || ErroDeCupom(errors.New("falha no banco")) {
+		t.Error("ErroDeCupom deveria reconhecer apenas erros de cupom")
+	}
+	if MensagemCupom(ErrCupomValorMinimo) == MensagemCupom(ErrCupomInvalido) {
+		t.Error("Valor mínimo deveria ter uma mensagem própria")
+	}
+}
@@ -0,0 +1,91 @@
+// /internal/service/email.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
+)
+
+// Mailer envia e-mails de texto simples para os clientes.
+type Mailer interface {
+	Enviar(ctx context.Context, para, assunto, corpo string) error
+}
+
+// NewMailer usa SMTP quando SMTP_HOST está definido (SMTP_PORT, SMTP_USUARIO,
+// SMTP_SENHA, SMTP_REMETENTE); sem ele, os e-mails só aparecem no log.
+func NewMailer(cfg config.Email) (Mailer, error) {
+	if cfg.Host == "" {
+		return LogMailer{}, nil
+	}
+	if cfg.Remetente == "" {
+		return nil, errors.New("SMTP_REMETENTE é obrigatório com SMTP_HOST")
+	}
+	return &SMTPMailer{
+		Endereco:  net.JoinHostPort(cfg.Host, cfg.Porta),
+		Host:      cfg.Host,
+		Usuario:   cfg.Usuario,
+		Senha:     cfg.Senha,
+		Remetente: cfg.Remetente,
+	}, nil
+}
+
+// LogMailer escreve os e-mails no log, para desenvolvimento.
+type LogMailer struct{}
+
+func (LogMailer) Enviar(ctx context.Context, para, assunto, corpo string) error {
+	slog.InfoContext(ctx, "E-mail (não enviado, sem SMTP_HOST)", "para", para, "assunto", assunto, "corpo", corpo)
+	return nil
+}
+
+// SMTPMailer envia pelo servidor SMTP configurado (STARTTLS quando o servidor oferece).
+type SMTPMailer struct {
+	Endereco  string // host:porta
+	Host      string
+	Usuario   string // Vazio dispensa a autenticação
+	Senha     string
+	Remetente string
+}
+
+func (m *SMTPMailer) Enviar(ctx context.Context, para, assunto, corpo string) error {
+	if strings.ContainsAny(para, "\r\n") {
+		return fmt.Errorf("destinatário inválido: %q", para)
+	}
+	var auth smtp.Auth
+	if m.Usuario != "" {
+		auth = smtp.PlainAuth("", m.Usuario, m.Senha, m.Host)
+	}
+	msg := montarEmail(m.Remetente, para, assunto, corpo)
+
+	// smtp.SendMail não recebe contexto: o envio segue em segundo plano se ele acabar antes
+	erro := make(chan error, 1)
+	go func() { erro <- smtp.SendMail(m.Endereco, auth, m.Remetente, []string{para}, msg) }()
+	select {
+	case err := <-erro:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// montarEmail monta a mensagem com cabeçalhos UTF-8.
+func montarEmail(de, para, assunto, corpo string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", de)
+	fmt.Fprintf(&b, "To: %s\r\n", para)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", assunto))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(corpo, "\n", "\r\n"))
+	return []byte(b.String())
+}
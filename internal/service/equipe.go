@@ -0,0 +1,260 @@
+// /internal/service/equipe.go
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ValidadeConvite é por quanto tempo o link de convite da equipe vale.
+	ValidadeConvite = 7 * 24 * time.Hour
+	// MinSenha é o tamanho mínimo das senhas definidas por link (convite ou redefinição).
+	MinSenha = 8
+)
+
+var (
+	ErrConviteInvalido = errors.New("convite inválido ou expirado")
+	ErrEmailEmUso      = errors.New("e-mail já cadastrado")
+	ErrDadosMembro     = errors.New("informe nome, e-mail válido e papel")
+	ErrSenhaCurta      = errors.New("senha curta demais")
+	ErrProprioUsuario  = errors.New("não é possível alterar o próprio acesso")
+	ErrUltimoAdmin     = errors.New("a loja precisa de ao menos um administrador")
+	ErrNaoEhEquipe     = errors.New("usuário não faz parte da equipe")
+	ErrAcessoAtivo     = errors.New("o membro já definiu a senha")
+)
+
+// SituacaoMembro descreve o acesso de um membro da equipe.
+func SituacaoMembro(u model.Usuario, agora time.Time) string {
+	switch {
+	case !u.ConvitePendente():
+		return "Ativo"
+	case u.ConviteExpiraEm != nil && agora.After(*u.ConviteExpiraEm):
+		return "Convite expirado"
+	default:
+		return "Convite pendente"
+	}
+}
+
+// HashToken é o que fica gravado no banco para o token de um link (convite ou
+// redefinição de senha).
+func HashToken(token string) string {
+	soma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(soma[:])
+}
+
+// novoToken gera um token aleatório para links enviados por fora do site (convite,
+// redefinição de senha).
+func novoToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// novoTokenConvite gera o token do link e grava o hash e a validade no usuário.
+func novoTokenConvite(u *model.Usuario, agora time.Time) (string, error) {
+	token, err := novoToken()
+	if err != nil {
+		return "", err
+	}
+	expira := agora.Add(ValidadeConvite)
+	u.ConviteHash = HashToken(token)
+	u.ConviteExpiraEm = &expira
+	return token, nil
+}
+
+// ConvidarMembro cria a conta da equipe sem senha e devolve o token do link de convite.
+func ConvidarMembro(db *gorm.DB, nome, email string, papel model.Papel) (model.Usuario, string, error) {
+	nome = strings.TrimSpace(nome)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if nome == "" || !papel.Valido() {
+		return model.Usuario{}, "", ErrDadosMembro
+	}
+	if endereco, err := mail.ParseAddress(email); err != nil || endereco.Address != email {
+		return model.Usuario{}, "", ErrDadosMembro
+	}
+
+	membro := model.Usuario{Nome: nome, Email: email, Tipo: model.RoleLojista, Papel: papel}
+	token, err := novoTokenConvite(&membro, time.Now())
+	if err != nil {
+		return model.Usuario{}, "", err
+	}
+	// Contas excluídas também ocupam o e-mail (índice único)
+	var existentes int64
+	if err := db.Unscoped().Model(&model.Usuario{}).Where("LOWER(email) = ?", email).Count(&existentes).Error; err != nil {
+		return model.Usuario{}, "", err
+	}
+	if existentes > 0 {
+		return model.Usuario{}, "", ErrEmailEmUso
+	}
+	if err := db.Create(&membro).Error; err != nil {
+		return model.Usuario{}, "", err
+	}
+	return membro, token, nil
+}
+
+// RedefinirAcesso apaga a senha do membro (se ele já tinha uma) e gera um novo link
+// de convite para defini-la.
+func RedefinirAcesso(db *gorm.DB, membroID uint) (model.Usuario, string, error) {
+	var membro model.Usuario
+	if err := db.Where("tipo = ?", model.RoleLojista).First(&membro, membroID).Error; err != nil {
+		return membro, "", err
+	}
+	token, err := novoTokenConvite(&membro, time.Now())
+	if err != nil {
+		return membro, "", err
+	}
+	membro.SenhaHash = ""
+	err = db.Model(&membro).Updates(map[string]any{
+		"senha_hash": "", "convite_hash": membro.ConviteHash, "convite_expira_em": membro.ConviteExpiraEm,
+	}).Error
+	Usuarios.Invalidar(membro.ID)
+	return membro, token, err
+}
+
+// RenovarConvite gera um novo link de convite para o membro da equipe com este e-mail,
+// desde que ele ainda não tenha definido a senha; o link anterior deixa de valer.
+func RenovarConvite(db *gorm.DB, email string) (model.Usuario, string, error) {
+	var membro model.Usuario
+	err := db.Where("tipo = ? AND LOWER(email) = ?", model.RoleLojista, strings.ToLower(strings.TrimSpace(email))).First(&membro).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return membro, "", ErrNaoEhEquipe
+	}
+	if err != nil {
+		return membro, "", err
+	}
+	if !membro.ConvitePendente() {
+		return membro, "", ErrAcessoAtivo
+	}
+	token, err := novoTokenConvite(&membro, time.Now())
+	if err != nil {
+		return membro, "", err
+	}
+	err = db.Model(&membro).Updates(map[string]any{
+		"convite_hash": membro.ConviteHash, "convite_expira_em": membro.ConviteExpiraEm,
+	}).Error
+	return membro, token, err
+}
+
+// BuscarConvite encontra o membro dono de um convite ainda válido.
+func BuscarConvite(db *gorm.DB, token string, agora time.Time) (model.Usuario, error) {
+	var membro model.Usuario
+	if token == "" {
+		return membro, ErrConviteInvalido
+	}
+	err := db.Where("convite_hash = ? AND convite_expira_em > ?", HashToken(token), agora).First(&membro).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return membro, ErrConviteInvalido
+	}
+	return membro, err
+}
+
+// AceitarConvite define a senha do membro e invalida o link.
+func AceitarConvite(db *gorm.DB, token, senha string) (model.Usuario, error) {
+	if utf8.RuneCountInString(senha) < MinSenha {
+		return model.Usuario{}, ErrSenhaCurta
+	}
+	membro, err := BuscarConvite(db, token, time.Now())
+	if err != nil {
+		return membro, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
+	if err != nil {
+		return membro, err
+	}
+	membro.SenhaHash = string(hash)
+	// O hash no WHERE impede que dois envios do mesmo link troquem a senha duas vezes
+	res := db.Model(&membro).Where("convite_hash = ?", membro.ConviteHash).
+		Updates(map[string]any{"senha_hash": membro.SenhaHash, "convite_hash": "", "convite_expira_em": nil})
+	if res.Error != nil {
+		return membro, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return membro, ErrConviteInvalido
+	}
+	Usuarios.Invalidar(membro.ID)
+	return membro, nil
+}
+
+// alterarMembro trava a equipe, valida o alvo e garante que continue existindo um
+// administrador ativo depois da mudança.
+func alterarMembro(db *gorm.DB, autor model.Usuario, membroID uint, mudar func(tx *gorm.DB, membro *model.Usuario) (perdeAdmin bool, err error)) error {
+	if autor.ID == membroID {
+		return ErrProprioUsuario
+	}
+	defer Usuarios.Invalidar(membroID)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var equipe []model.Usuario
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tipo = ?", model.RoleLojista).Find(&equipe).Error; err != nil {
+			return err
+		}
+		var membro *model.Usuario
+		admins := 0
+		for i := range equipe {
+			if equipe[i].ID == membroID {
+				membro = &equipe[i]
+			}
+			if equipe[i].Papel == model.PapelAdmin && !equipe[i].ConvitePendente() {
+				admins++
+			}
+		}
+		if membro == nil {
+			return ErrNaoEhEquipe
+		}
+		ativoAdmin := membro.Papel == model.PapelAdmin && !membro.ConvitePendente()
+		perdeAdmin, err := mudar(tx, membro)
+		if err != nil {
+			return err
+		}
+		if ativoAdmin && perdeAdmin && admins <= 1 {
+			return ErrUltimoAdmin
+		}
+		return nil
+	})
+}
+
+// AlterarPapel troca o papel de outro membro da equipe e devolve o papel anterior.
+func AlterarPapel(db *gorm.DB, autor model.Usuario, membroID uint, papel model.Papel) (model.Papel, error) {
+	if !papel.Valido() {
+		return "", ErrDadosMembro
+	}
+	var anterior model.Papel
+	err := alterarMembro(db, autor, membroID, func(tx *gorm.DB, membro *model.Usuario) (bool, error) {
+		anterior = membro.Papel
+		return papel != model.PapelAdmin, tx.Model(membro).Update("papel", papel).Error
+	})
+	return anterior, err
+}
+
+// RemoverMembro exclui a conta de outro membro da equipe; quem estiver logado com ela
+// cai na próxima requisição.
+func RemoverMembro(db *gorm.DB, autor model.Usuario, membroID uint) error {
+	return alterarMembro(db, autor, membroID, func(tx *gorm.DB, membro *model.Usuario) (bool, error) {
+		return true, tx.Delete(membro).Error
+	})
+}
+
+// RedefinirSenhaMembro é o RedefinirAcesso pedido por outro membro da equipe.
+func RedefinirSenhaMembro(db *gorm.DB, autor model.Usuario, membroID uint) (model.Usuario, string, error) {
+	var membro model.Usuario
+	var token string
+	err := alterarMembro(db, autor, membroID, func(tx *gorm.DB, _ *model.Usuario) (bool, error) {
+		var err error
+		membro, token, err = RedefinirAcesso(tx, membroID)
+		return true, err
+	})
+	return membro, token, err
+}
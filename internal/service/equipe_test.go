@@ -0,0 +1,124 @@
+// /internal/service/equipe_test.go
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestPermissoesPapel(t *testing.T) {
+	casos := []struct {
+		papel     model.Papel
+		permissao model.Permissao
+		esperado  bool
+	}{
+		{model.PapelAdmin, model.PermissaoEquipe, true},
+		{model.PapelAdmin, model.PermissaoCatalogo, true},
+		{model.PapelAtendente, model.PermissaoPedidos, true},
+		{model.PapelAtendente, model.PermissaoCatalogo, false},
+		{model.PapelAtendente, model.PermissaoEquipe, false},
+		{model.PapelCozinha, model.PermissaoCozinha, true},
+		{model.PapelCozinha, model.PermissaoPedidos, false},
+		{model.Papel(""), model.PermissaoCozinha, false},
+	}
+	for _, caso := range casos {
+		if got := caso.papel.Pode(caso.permissao); got != caso.esperado {
+			t.Errorf("%q.Pode(%q) = %v; esperado %v", caso.papel, caso.permissao, got, caso.esperado)
+		}
+	}
+
+	t.Run("Cliente não tem permissões da equipe", func(t *testing.T) {
+		cliente := model.Usuario{Tipo: model.RoleCliente, Papel: model.PapelAdmin}
+		if cliente.Pode(model.PermissaoPedidos) {
+			t.Error("Cliente não deveria ter acesso ao painel")
+		}
+		if !(model.Usuario{Tipo: model.RoleLojista, Papel: model.PapelAdmin}).Pode(model.PermissaoPedidos) {
+			t.Error("Administrador deveria ter acesso às vendas")
+		}
+	})
+}
+
+func TestConviteEquipe(t *testing.T) {
+	agora := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Token novo grava só o hash", func(t *testing.T) {
+		var membro model.Usuario
+		token, err := novoTokenConvite(&membro, agora)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if len(token) != 64 || membro.ConviteHash == token || membro.ConviteHash != HashToken(token) {
+			t.Errorf("Token/hash inesperados: %q / %q", token, membro.ConviteHash)
+		}
+		if !membro.ConviteExpiraEm.Equal(agora.Add(ValidadeConvite)) {
+			t.Errorf("Expira em %v", membro.ConviteExpiraEm)
+		}
+		outro, _ := novoTokenConvite(&model.Usuario{}, agora)
+		if outro == token {
+			t.Error("Dois convites não deveriam ter o mesmo token")
+		}
+	})
+
+	t.Run("Situação do membro", func(t *testing.T) {
+		expira := agora.Add(time.Hour)
+		pendente := model.Usuario{ConviteHash: "x", ConviteExpiraEm: &expira}
+		if got := SituacaoMembro(model.Usuario{}, agora); got != "Ativo" {
+			t.Errorf("Situação = %q; esperado Ativo", got)
+		}
+		if got := SituacaoMembro(pendente, agora); got != "Convite pendente" {
+			t.Errorf("Situação = %q; esperado Convite pendente", got)
+		}
+		if got := SituacaoMembro(pendente, agora.Add(2*time.Hour)); got != "Convite expirado" {
+			t.Errorf("Situação = %q; esperado Convite expirado", got)
+		}
+	})
+}
+
+func TestRenovarConvite(t *testing.T) {
+	// DryRun monta as consultas sem banco (e sem transação); o callback faz a busca
+	// encontrar o membro
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=invalido"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encontrado model.Usuario
+	var busca, atualizacao string
+	db.Callback().Query().After("gorm:query").Register("teste:membro", func(tx *gorm.DB) {
+		busca = tx.Statement.SQL.String()
+		*tx.Statement.Dest.(*model.Usuario) = encontrado
+	})
+	db.Callback().Update().After("gorm:update").Register("teste:update", func(tx *gorm.DB) {
+		atualizacao = tx.Statement.SQL.String()
+	})
+
+	t.Run("Convite pendente ganha um token novo", func(t *testing.T) {
+		encontrado = model.Usuario{ID: 7, Email: "admin@loja.com", Tipo: model.RoleLojista, ConviteHash: HashToken("antigo")}
+		membro, token, err := RenovarConvite(db, " Admin@Loja.com ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(busca, "LOWER(email)") || !strings.Contains(busca, "tipo") {
+			t.Errorf("Busca inesperada: %s", busca)
+		}
+		if token == "" || membro.ConviteHash != HashToken(token) {
+			t.Errorf("Token %q não corresponde ao hash gravado %q", token, membro.ConviteHash)
+		}
+		// Só o convite muda: a senha e as sessões não são tocadas
+		if !strings.Contains(atualizacao, "convite_hash") || strings.Contains(atualizacao, "senha_hash") {
+			t.Errorf("Atualização inesperada: %s", atualizacao)
+		}
+	})
+
+	t.Run("Quem já definiu a senha não recebe convite", func(t *testing.T) {
+		encontrado = model.Usuario{ID: 7, Email: "admin@loja.com", Tipo: model.RoleLojista, SenhaHash: "hash"}
+		if _, _, err := RenovarConvite(db, "admin@loja.com"); !errors.Is(err, ErrAcessoAtivo) {
+			t.Errorf("Erro = %v; esperado ErrAcessoAtivo", err)
+		}
+	})
+}
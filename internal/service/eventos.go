@@ -0,0 +1,166 @@
+// /internal/service/eventos.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// EventoStatusPedido é enviado aos clientes que acompanham o pedido (SSE).
+type EventoStatusPedido struct {
+	PedidoID uint              `json:"pedido_id"`
+	Status   model.StatusOrder `json:"status"`
+	Titulo   string            `json:"titulo"`
+}
+
+// NovoEventoStatus descreve o status atual do pedido.
+func NovoEventoStatus(pedido model.Order) EventoStatusPedido {
+	return EventoStatusPedido{PedidoID: pedido.ID, Status: pedido.Status, Titulo: TituloStatus(pedido.Status, pedido.TipoEntrega)}
+}
+
+// BarramentoPedidos distribui as mudanças de status, com um tópico por pedido.
+type BarramentoPedidos interface {
+	Publicar(evento EventoStatusPedido)
+	// Assinar devolve os eventos do pedido e a função que encerra a assinatura.
+	Assinar(pedidoID uint) (<-chan EventoStatusPedido, func())
+}
+
+// Eventos é o barramento usado por AtualizarStatusPedido. O padrão só entrega dentro
+// deste processo; main troca pelo HubPostgres quando há mais de uma instância.
+var Eventos BarramentoPedidos = NewHubPedidos()
+
+// PublicarStatusPedido avisa os assinantes do pedido sobre o status atual.
+func PublicarStatusPedido(pedido model.Order) {
+	Eventos.Publicar(NovoEventoStatus(pedido))
+}
+
+// bufferAssinatura é quantos eventos esperam por um assinante lento; quando enche, o
+// mais antigo é descartado (só o status mais recente importa).
+const bufferAssinatura = 4
+
+// HubPedidos é o barramento em memória.
+type HubPedidos struct {
+	mu         sync.Mutex
+	assinantes map[uint]map[chan EventoStatusPedido]struct{}
+}
+
+func NewHubPedidos() *HubPedidos {
+	return &HubPedidos{assinantes: make(map[uint]map[chan EventoStatusPedido]struct{})}
+}
+
+func (h *HubPedidos) Assinar(pedidoID uint) (<-chan EventoStatusPedido, func()) {
+	ch := make(chan EventoStatusPedido, bufferAssinatura)
+	h.mu.Lock()
+	if h.assinantes[pedidoID] == nil {
+		h.assinantes[pedidoID] = make(map[chan EventoStatusPedido]struct{})
+	}
+	h.assinantes[pedidoID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancelar := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.assinantes[pedidoID], ch)
+			if len(h.assinantes[pedidoID]) == 0 {
+				delete(h.assinantes, pedidoID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancelar
+}
+
+func (h *HubPedidos) Publicar(evento EventoStatusPedido) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.assinantes[evento.PedidoID] {
+		select {
+		case ch <- evento:
+		default:
+			// Assinante atrasado: descarta o evento mais antigo para caber o novo
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- evento:
+			default:
+			}
+		}
+	}
+}
+
+// canalPedidosPostgres é o canal de LISTEN/NOTIFY dos eventos de pedido.
+const canalPedidosPostgres = "pedidos_status"
+
+// HubPostgres publica com NOTIFY para que todas as instâncias recebam o evento; cada
+// uma escuta o canal (Escutar) e entrega aos seus assinantes pelo hub em memória.
+type HubPostgres struct {
+	*HubPedidos
+	db  *gorm.DB
+	dsn string
+}
+
+func NewHubPostgres(db *gorm.DB, dsn string) *HubPostgres {
+	return &HubPostgres{HubPedidos: NewHubPedidos(), db: db, dsn: dsn}
+}
+
+func (h *HubPostgres) Publicar(evento EventoStatusPedido) {
+	payload, err := json.Marshal(evento)
+	if err == nil {
+		err = h.db.Exec("SELECT pg_notify(?, ?)", canalPedidosPostgres, string(payload)).Error
+	}
+	if err != nil {
+		// Sem o NOTIFY, ao menos quem está conectado a esta instância recebe
+		slog.Error("Erro ao publicar evento do pedido no Postgres", "pedido_id", evento.PedidoID, "erro", err)
+		h.HubPedidos.Publicar(evento)
+	}
+}
+
+// Escutar mantém uma conexão dedicada com LISTEN até ctx ser cancelado, reconectando
+// após falhas.
+func (h *HubPostgres) Escutar(ctx context.Context) {
+	for {
+		if err := h.escutar(ctx); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Conexão de eventos dos pedidos caiu, reconectando", "erro", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (h *HubPostgres) escutar(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+canalPedidosPostgres); err != nil {
+		return err
+	}
+	for {
+		notificacao, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var evento EventoStatusPedido
+		if err := json.Unmarshal([]byte(notificacao.Payload), &evento); err != nil {
+			slog.WarnContext(ctx, "Evento de pedido inválido no Postgres", "erro", err)
+			continue
+		}
+		h.HubPedidos.Publicar(evento)
+	}
+}
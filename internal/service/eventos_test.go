@@ -0,0 +1,71 @@
+// /internal/service/eventos_test.go
+package service
+
+import (
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestHubPedidos(t *testing.T) {
+	t.Run("Entrega só aos assinantes do pedido", func(t *testing.T) {
+		hub := NewHubPedidos()
+		eventos, cancelar := hub.Assinar(1)
+		defer cancelar()
+		outros, cancelarOutros := hub.Assinar(2)
+		defer cancelarOutros()
+
+		hub.Publicar(EventoStatusPedido{PedidoID: 1, Status: model.StatusPago})
+		select {
+		case evento := <-eventos:
+			if evento.Status != model.StatusPago {
+				t.Errorf("Status = %q; esperado pago", evento.Status)
+			}
+		default:
+			t.Fatal("O assinante do pedido deveria receber o evento")
+		}
+		select {
+		case evento := <-outros:
+			t.Errorf("Assinante de outro pedido recebeu %+v", evento)
+		default:
+		}
+	})
+
+	t.Run("Assinante lento fica com os eventos mais recentes", func(t *testing.T) {
+		hub := NewHubPedidos()
+		eventos, cancelar := hub.Assinar(1)
+		defer cancelar()
+
+		for i := 0; i < bufferAssinatura+2; i++ {
+			hub.Publicar(EventoStatusPedido{PedidoID: 1, Titulo: string(rune('a' + i))})
+		}
+		var ultimo EventoStatusPedido
+		for i := 0; i < bufferAssinatura; i++ {
+			ultimo = <-eventos
+		}
+		if ultimo.Titulo != string(rune('a'+bufferAssinatura+1)) {
+			t.Errorf("Último evento = %q; o mais recente deveria ter sido mantido", ultimo.Titulo)
+		}
+	})
+
+	t.Run("Cancelar fecha o canal e pode ser repetido", func(t *testing.T) {
+		hub := NewHubPedidos()
+		eventos, cancelar := hub.Assinar(1)
+		cancelar()
+		cancelar()
+		if _, aberto := <-eventos; aberto {
+			t.Error("O canal deveria estar fechado")
+		}
+		hub.Publicar(EventoStatusPedido{PedidoID: 1})
+		if len(hub.assinantes) != 0 {
+			t.Errorf("Assinaturas restantes: %d", len(hub.assinantes))
+		}
+	})
+}
+
+func TestNovoEventoStatus(t *testing.T) {
+	evento := NovoEventoStatus(model.Order{ID: 9, Status: model.StatusEnviado, TipoEntrega: model.TipoEntregaRetirada})
+	if evento.PedidoID != 9 || evento.Titulo != "Pronto para retirada" {
+		t.Errorf("Evento inesperado: %+v", evento)
+	}
+}
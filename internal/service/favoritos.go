@@ -0,0 +1,160 @@
+// /internal/service/favoritos.go
+package service
+
+import (
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SituacaoFavorito diz se um cupcake favoritado ainda pode ser comprado.
+type SituacaoFavorito string
+
+const (
+	FavoritoDisponivel   SituacaoFavorito = "disponivel"
+	FavoritoIndisponivel SituacaoFavorito = "indisponivel" // Fora da vitrine por enquanto
+	FavoritoRemovido     SituacaoFavorito = "removido"     // Excluído pelo lojista (soft delete)
+)
+
+// SituacaoCupcake classifica o cupcake (carregado com Unscoped) de um favorito.
+func SituacaoCupcake(c model.Cupcake) SituacaoFavorito {
+	switch {
+	case c.ID == 0 || c.DeletedAt.Valid:
+		return FavoritoRemovido
+	case !c.Disponivel:
+		return FavoritoIndisponivel
+	}
+	return FavoritoDisponivel
+}
+
+// AlternarFavorito adiciona o cupcake aos favoritos do cliente ou o retira, se já
+// estiver lá. Retorna se o cupcake ficou favoritado.
+func AlternarFavorito(db *gorm.DB, usuarioID, cupcakeID uint) (bool, error) {
+	removido, err := RemoverFavorito(db, usuarioID, cupcakeID)
+	if err != nil || removido {
+		return false, err
+	}
+	// Dois cliques simultâneos não criam linhas repetidas
+	err = db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.Favorito{UsuarioID: usuarioID, CupcakeID: cupcakeID}).Error
+	return err == nil, err
+}
+
+// RemoverFavorito retira o cupcake dos favoritos do cliente e diz se ele estava lá.
+func RemoverFavorito(db *gorm.DB, usuarioID, cupcakeID uint) (bool, error) {
+	res := db.Where("usuario_id = ? AND cupcake_id = ?", usuarioID, cupcakeID).Delete(&model.Favorito{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// IDsFavoritos retorna os cupcakes favoritados pelo cliente.
+func IDsFavoritos(db *gorm.DB, usuarioID uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := db.Model(&model.Favorito{}).Where("usuario_id = ?", usuarioID).Pluck("cupcake_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	favoritos := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		favoritos[id] = true
+	}
+	return favoritos, nil
+}
+
+// FavoritosDoCliente lista os favoritos do mais recente para o mais antigo, incluindo
+// cupcakes já excluídos pelo lojista.
+func FavoritosDoCliente(db *gorm.DB, usuarioID uint) ([]model.Favorito, error) {
+	var favoritos []model.Favorito
+	if err := db.Where("usuario_id = ?", usuarioID).Order("created_at desc, id desc").Find(&favoritos).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(favoritos))
+	for i, f := range favoritos {
+		ids[i] = f.CupcakeID
+	}
+	cupcakes, err := comExcluidos(db, ComOpcoes, ids, func(c model.Cupcake) uint { return c.ID })
+	if err != nil {
+		return nil, err
+	}
+	for i := range favoritos {
+		favoritos[i].Cupcake = cupcakes[favoritos[i].CupcakeID]
+	}
+	return favoritos, nil
+}
+
+// comExcluidos carrega os registros com as relações de comRelacoes e completa com os
+// excluídos (soft delete), que voltam sem relações. Unscoped não é usado junto com os
+// Preloads porque ele se propaga para as relações e traria opções e componentes excluídos.
+func comExcluidos[T any](db *gorm.DB, comRelacoes func(*gorm.DB) *gorm.DB, ids []uint, id func(T) uint) (map[uint]T, error) {
+	registros := make(map[uint]T, len(ids))
+	if len(ids) == 0 {
+		return registros, nil
+	}
+	var ativos []T
+	if err := comRelacoes(db).Where("id IN ?", ids).Find(&ativos).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range ativos {
+		registros[id(r)] = r
+	}
+
+	var faltando []uint
+	for _, i := range ids {
+		if _, ok := registros[i]; !ok {
+			faltando = append(faltando, i)
+		}
+	}
+	if len(faltando) == 0 {
+		return registros, nil
+	}
+	var excluidos []T
+	if err := db.Unscoped().Where("id IN ?", faltando).Find(&excluidos).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range excluidos {
+		registros[id(r)] = r
+	}
+	return registros, nil
+}
+
+// CupcakeFavoritado é uma linha do ranking de favoritos do painel do lojista.
+type CupcakeFavoritado struct {
+	Cupcake  model.Cupcake
+	Total    int64
+	Situacao SituacaoFavorito
+}
+
+// MaisFavoritados retorna os cupcakes presentes em mais listas de favoritos.
+func MaisFavoritados(db *gorm.DB, limite int) ([]CupcakeFavoritado, error) {
+	var linhas []struct {
+		CupcakeID uint
+		Total     int64
+	}
+	err := db.Model(&model.Favorito{}).
+		Select("cupcake_id, COUNT(*) AS total").
+		Group("cupcake_id").
+		Order("total desc, cupcake_id").
+		Limit(limite).
+		Scan(&linhas).Error
+	if err != nil || len(linhas) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint, len(linhas))
+	for i, l := range linhas {
+		ids[i] = l.CupcakeID
+	}
+	var cupcakes []model.Cupcake
+	if err := db.Unscoped().Where("id IN ?", ids).Find(&cupcakes).Error; err != nil {
+		return nil, err
+	}
+	porID := make(map[uint]model.Cupcake, len(cupcakes))
+	for _, c := range cupcakes {
+		porID[c.ID] = c
+	}
+
+	ranking := make([]CupcakeFavoritado, len(linhas))
+	for i, l := range linhas {
+		cupcake := porID[l.CupcakeID]
+		ranking[i] = CupcakeFavoritado{Cupcake: cupcake, Total: l.Total, Situacao: SituacaoCupcake(cupcake)}
+	}
+	return ranking, nil
+}
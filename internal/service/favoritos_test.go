@@ -0,0 +1,30 @@
+// /internal/service/favoritos_test.go
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestSituacaoCupcake(t *testing.T) {
+	casos := []struct {
+		nome     string
+		cupcake  model.Cupcake
+		esperado SituacaoFavorito
+	}{
+		{"Disponível", model.Cupcake{ID: 1, Disponivel: true}, FavoritoDisponivel},
+		{"Fora da vitrine", model.Cupcake{ID: 1, Disponivel: false}, FavoritoIndisponivel},
+		{"Excluído", model.Cupcake{ID: 1, Disponivel: true, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}, FavoritoRemovido},
+		{"Apagado do banco", model.Cupcake{}, FavoritoRemovido},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if got := SituacaoCupcake(caso.cupcake); got != caso.esperado {
+				t.Errorf("SituacaoCupcake = %s; esperado %s", got, caso.esperado)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// /internal/service/galeria.go
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrGaleriaCheia indica que a galeria passaria de model.MaxImagensCupcake fotos.
+var ErrGaleriaCheia = fmt.Errorf("a galeria aceita no máximo %d fotos", model.MaxImagensCupcake)
+
+// TokenImagemLegada identifica, no formulário da galeria, a foto de um cupcake antigo
+// que só tem ImagemURL (sem linha em CupcakeImagem).
+const TokenImagemLegada = "legado"
+
+// ComGaleria carrega as fotos dos cupcakes, na ordem de exibição.
+func ComGaleria(db *gorm.DB) *gorm.DB {
+	return db.Preload("Imagens", func(db *gorm.DB) *gorm.DB { return db.Order("posicao, id") })
+}
+
+// TokenImagem é o valor que identifica a foto no campo "ordem" do formulário.
+func TokenImagem(img model.CupcakeImagem) string {
+	if img.ID == 0 {
+		return TokenImagemLegada
+	}
+	return strconv.FormatUint(uint64(img.ID), 10)
+}
+
+// TokenNovaImagem identifica o i-ésimo arquivo enviado no campo "imagens".
+func TokenNovaImagem(i int) string {
+	return "n" + strconv.Itoa(i)
+}
+
+// MontarGaleria aplica o formulário da galeria: ordem lista os tokens das fotos que
+// ficam (TokenImagem para as atuais, TokenNovaImagem para os arquivos enviados, cujas URLs
+// estão em novas), alts traz o texto alternativo por token. Fotos atuais fora da ordem
+// são removidas; arquivos novos fora da ordem (ex.: navegador sem JavaScript) vão para
+// o fim. Retorna a galeria com Posicao preenchida e as URLs que saíram.
+func MontarGaleria(atuais []model.CupcakeImagem, ordem []string, alts map[string]string, novas []string) ([]model.CupcakeImagem, []string, error) {
+	porToken := make(map[string]model.CupcakeImagem, len(atuais)+len(novas))
+	for _, img := range atuais {
+		porToken[TokenImagem(img)] = img
+	}
+	for i, url := range novas {
+		porToken[TokenNovaImagem(i)] = model.CupcakeImagem{URL: url}
+	}
+
+	usados := make(map[string]bool, len(porToken))
+	var galeria []model.CupcakeImagem
+	incluir := func(token string) {
+		img, ok := porToken[token]
+		if !ok || usados[token] {
+			return
+		}
+		usados[token] = true
+		if alt, ok := alts[token]; ok {
+			img.TextoAlt = limitarTexto(strings.TrimSpace(alt), 255)
+		}
+		img.Posicao = len(galeria)
+		galeria = append(galeria, img)
+	}
+	for _, token := range ordem {
+		incluir(strings.TrimSpace(token))
+	}
+	for i := range novas {
+		incluir(TokenNovaImagem(i))
+	}
+
+	if len(galeria) > model.MaxImagensCupcake {
+		return nil, nil, ErrGaleriaCheia
+	}
+
+	var removidas []string
+	for _, img := range atuais {
+		if !usados[TokenImagem(img)] {
+			removidas = append(removidas, img.URL)
+		}
+	}
+	return galeria, removidas, nil
+}
+
+// SalvarGaleria grava a galeria montada por MontarGaleria e atualiza a imagem principal
+// do cupcake (padrao quando a galeria fica vazia). Deve rodar dentro de uma transação.
+func SalvarGaleria(tx *gorm.DB, cupcake *model.Cupcake, galeria []model.CupcakeImagem, padrao string) error {
+	manter := make([]uint, 0, len(galeria))
+	for _, img := range galeria {
+		if img.ID != 0 {
+			manter = append(manter, img.ID)
+		}
+	}
+	apagar := tx.Where("cupcake_id = ?", cupcake.ID)
+	if len(manter) > 0 {
+		apagar = apagar.Where("id NOT IN ?", manter)
+	}
+	if err := apagar.Delete(&model.CupcakeImagem{}).Error; err != nil {
+		return err
+	}
+
+	for i := range galeria {
+		galeria[i].CupcakeID = cupcake.ID
+		if err := tx.Save(&galeria[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	cupcake.Imagens = galeria
+	cupcake.ImagemURL = padrao
+	if len(galeria) > 0 {
+		cupcake.ImagemURL = galeria[0].URL
+	}
+	return tx.Model(cupcake).UpdateColumn("imagem_url", cupcake.ImagemURL).Error
+}
+
+// URLsGaleria lista as URLs de todas as fotos do cupcake, incluindo ImagemURL, sem repetir.
+func URLsGaleria(cupcake model.Cupcake) []string {
+	vistas := map[string]bool{}
+	var urls []string
+	for _, url := range append([]string{cupcake.ImagemURL}, urlsImagens(cupcake.Imagens)...) {
+		if url != "" && !vistas[url] {
+			vistas[url] = true
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func urlsImagens(imagens []model.CupcakeImagem) []string {
+	urls := make([]string, len(imagens))
+	for i, img := range imagens {
+		urls[i] = img.URL
+	}
+	return urls
+}
+
+// limitarTexto corta o texto em max caracteres.
+func limitarTexto(texto string, max int) string {
+	if utf8.RuneCountInString(texto) <= max {
+		return texto
+	}
+	return string([]rune(texto)[:max])
+}
@@ -0,0 +1,93 @@
+// /internal/service/galeria_test.go
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func urlsGaleria(galeria []model.CupcakeImagem) []string {
+	urls := make([]string, len(galeria))
+	for i, img := range galeria {
+		urls[i] = img.URL
+	}
+	return urls
+}
+
+func TestMontarGaleria(t *testing.T) {
+	atuais := []model.CupcakeImagem{
+		{ID: 10, URL: "/uploads/a-full.jpg", TextoAlt: "Cupcake de frente"},
+		{ID: 11, URL: "/uploads/b-full.jpg", Posicao: 1},
+	}
+
+	t.Run("Reordena, remove e intercala fotos novas", func(t *testing.T) {
+		alts := map[string]string{"n0": "  Recheio  ", "10": ""}
+		galeria, removidas, err := MontarGaleria(atuais, []string{"n0", "10"}, alts, []string{"/uploads/c-full.jpg"})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if got := urlsGaleria(galeria); !reflect.DeepEqual(got, []string{"/uploads/c-full.jpg", "/uploads/a-full.jpg"}) {
+			t.Errorf("Ordem = %v", got)
+		}
+		if galeria[0].TextoAlt != "Recheio" || galeria[1].TextoAlt != "" || galeria[1].ID != 10 {
+			t.Errorf("Galeria = %+v", galeria)
+		}
+		if galeria[0].Posicao != 0 || galeria[1].Posicao != 1 {
+			t.Errorf("Posições = %d, %d", galeria[0].Posicao, galeria[1].Posicao)
+		}
+		if !reflect.DeepEqual(removidas, []string{"/uploads/b-full.jpg"}) {
+			t.Errorf("Removidas = %v", removidas)
+		}
+	})
+
+	t.Run("Fotos novas sem ordem vão para o fim e tokens desconhecidos são ignorados", func(t *testing.T) {
+		galeria, removidas, _ := MontarGaleria(atuais, []string{"11", "99", "11", "10"}, nil, []string{"/uploads/c-full.jpg"})
+		if got := urlsGaleria(galeria); !reflect.DeepEqual(got, []string{"/uploads/b-full.jpg", "/uploads/a-full.jpg", "/uploads/c-full.jpg"}) {
+			t.Errorf("Ordem = %v", got)
+		}
+		if galeria[1].TextoAlt != "Cupcake de frente" || len(removidas) != 0 {
+			t.Errorf("Alt = %q, removidas = %v", galeria[1].TextoAlt, removidas)
+		}
+	})
+
+	t.Run("Foto de cupcake antigo usa o token legado", func(t *testing.T) {
+		legado := []model.CupcakeImagem{{URL: "/uploads/antiga.png"}}
+		galeria, _, _ := MontarGaleria(legado, []string{TokenNovaImagem(0), TokenImagemLegada}, nil, []string{"/uploads/c-full.jpg"})
+		if got := urlsGaleria(galeria); !reflect.DeepEqual(got, []string{"/uploads/c-full.jpg", "/uploads/antiga.png"}) {
+			t.Errorf("Ordem = %v", got)
+		}
+	})
+
+	t.Run("Limite de fotos", func(t *testing.T) {
+		novas := make([]string, model.MaxImagensCupcake-1)
+		if _, _, err := MontarGaleria(atuais, []string{"10", "11"}, nil, novas); !errors.Is(err, ErrGaleriaCheia) {
+			t.Errorf("Erro = %v; esperado ErrGaleriaCheia", err)
+		}
+		if _, _, err := MontarGaleria(atuais, []string{"10"}, nil, novas); err != nil {
+			t.Errorf("Removendo uma foto a galeria cabe: %v", err)
+		}
+	})
+}
+
+func TestGaleriaCupcake(t *testing.T) {
+	antigo := model.Cupcake{Nome: "Red Velvet", ImagemURL: "/uploads/antiga.png"}
+	if g := antigo.Galeria(); len(g) != 1 || g[0].URL != antigo.ImagemURL || g[0].TextoAlt != "Red Velvet" {
+		t.Errorf("Galeria de cupcake antigo = %+v", g)
+	}
+	if g := (model.Cupcake{ImagemURL: model.ImagemPadrao}).Galeria(); len(g) != 0 {
+		t.Errorf("Imagem padrão não entra na galeria: %+v", g)
+	}
+
+	novo := model.Cupcake{Nome: "Limão", ImagemURL: "/uploads/a-full.jpg", Imagens: []model.CupcakeImagem{
+		{URL: "/uploads/a-full.jpg", TextoAlt: "Cobertura de limão"}, {URL: "/uploads/b-full.jpg"},
+	}}
+	if novo.ImagemAlt() != "Cobertura de limão" || novo.Galeria()[1].TextoAlt != "Limão" {
+		t.Errorf("Textos alternativos = %q, %q", novo.ImagemAlt(), novo.Galeria()[1].TextoAlt)
+	}
+	if urls := URLsGaleria(novo); len(urls) != 2 {
+		t.Errorf("URLsGaleria = %v", urls)
+	}
+}
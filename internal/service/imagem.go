@@ -0,0 +1,372 @@
+// /internal/service/imagem.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var (
+	ErrImagemMuitoGrande = errors.New("imagem maior que o permitido")
+	ErrImagemFormato     = errors.New("formato de imagem não suportado")
+	ErrImagemCorrompida  = errors.New("imagem corrompida")
+)
+
+// maxPixelsImagem barra imagens que ocupariam memória demais ao serem decodificadas
+// (ex.: um PNG pequeno com dimensões gigantes).
+const maxPixelsImagem = 50_000_000
+
+// qualidadeJPEG é usada em todas as rendições.
+const qualidadeJPEG = 85
+
+// FormatoImagem identifica o formato pelos primeiros bytes do arquivo, ignorando a
+// extensão e o Content-Type enviados pelo navegador.
+func FormatoImagem(cabecalho []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(cabecalho, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg", nil
+	case bytes.HasPrefix(cabecalho, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", nil
+	case len(cabecalho) >= 12 && string(cabecalho[:4]) == "RIFF" && string(cabecalho[8:12]) == "WEBP":
+		return "webp", nil
+	}
+	return "", ErrImagemFormato
+}
+
+// ProcessadorImagens valida as imagens enviadas pelo lojista e grava as rendições
+// (model.Rendicoes) no Store. As rendições são recodificadas em JPEG, o que descarta os
+// metadados EXIF (localização, câmera...) depois de aplicar a orientação da foto.
+type ProcessadorImagens struct {
+	Store         ImageStore
+	TamanhoMaximo int64 // Em bytes
+}
+
+// NewProcessadorImagens cria o processador; tamanhoMaximo em bytes.
+func NewProcessadorImagens(store ImageStore, tamanhoMaximo int64) *ProcessadorImagens {
+	return &ProcessadorImagens{Store: store, TamanhoMaximo: tamanhoMaximo}
+}
+
+// MensagemImagem traduz os erros de Salvar para exibição ao lojista.
+func (p *ProcessadorImagens) MensagemImagem(err error) string {
+	switch {
+	case errors.Is(err, ErrImagemMuitoGrande):
+		return fmt.Sprintf("A imagem é grande demais. Envie um arquivo de até %d MB.", p.TamanhoMaximo/(1<<20))
+	case errors.Is(err, ErrImagemFormato):
+		return "Formato de imagem não suportado. Envie JPEG, PNG ou WebP."
+	case errors.Is(err, ErrImagemCorrompida):
+		return "Não foi possível ler a imagem. Verifique o arquivo e tente novamente."
+	}
+	return "Erro ao salvar a imagem. Tente novamente."
+}
+
+// Salvar valida a imagem, gera as rendições e retorna a URL da rendição "full",
+// que é o valor guardado em ImagemURL.
+func (p *ProcessadorImagens) Salvar(ctx context.Context, r io.Reader) (string, error) {
+	dados, err := io.ReadAll(io.LimitReader(r, p.TamanhoMaximo+1))
+	if err != nil {
+		return "", fmt.Errorf("ler imagem enviada: %w", err)
+	}
+	if int64(len(dados)) > p.TamanhoMaximo {
+		return "", ErrImagemMuitoGrande
+	}
+
+	rendicoes, err := GerarRendicoes(dados)
+	if err != nil {
+		return "", err
+	}
+	return p.gravarRendicoes(ctx, uuid.New().String(), rendicoes)
+}
+
+// gravarRendicoes envia as rendições com o nome base dado; se uma falhar, apaga as já enviadas.
+func (p *ProcessadorImagens) gravarRendicoes(ctx context.Context, base string, rendicoes map[string][]byte) (string, error) {
+	var gravadas []string
+	for _, r := range model.Rendicoes {
+		chave := base + model.SufixoRendicao(r)
+		if err := p.Store.Put(ctx, chave, rendicoes[r.Nome], "image/jpeg"); err != nil {
+			for _, g := range gravadas {
+				p.Store.Delete(context.WithoutCancel(ctx), g)
+			}
+			return "", fmt.Errorf("gravar rendição %s: %w", r.Nome, err)
+		}
+		gravadas = append(gravadas, chave)
+	}
+	return p.Store.URL(base + model.SufixoRendicao(model.RendicaoFull)), nil
+}
+
+// chavesImagem lista os arquivos de uma imagem do Store: todas as rendições, ou o arquivo
+// único de imagens antigas. ok = false para URLs de fora do Store, como a imagem padrão.
+func chavesImagem(store ImageStore, imagemURL string) (chaves []string, rendicoes bool, ok bool) {
+	chave, ok := store.Chave(imagemURL)
+	if !ok {
+		return nil, false, false
+	}
+	if model.URLRendicao(imagemURL, model.RendicaoThumb) == imagemURL {
+		return []string{chave}, false, true
+	}
+	base := strings.TrimSuffix(chave, model.SufixoRendicao(model.RendicaoFull))
+	for _, r := range model.Rendicoes {
+		chaves = append(chaves, base+model.SufixoRendicao(r))
+	}
+	return chaves, true, true
+}
+
+// Remover apaga todas as rendições da imagem (ou o arquivo único de imagens antigas).
+// URLs de fora do Store, como a imagem padrão, são ignoradas.
+func (p *ProcessadorImagens) Remover(ctx context.Context, imagemURL string) {
+	chaves, _, ok := chavesImagem(p.Store, imagemURL)
+	if !ok {
+		return
+	}
+	for _, chave := range chaves {
+		if err := p.Store.Delete(ctx, chave); err != nil {
+			slog.WarnContext(ctx, "Não foi possível remover a imagem", "chave", chave, "erro", err)
+		}
+	}
+}
+
+// Migrar copia uma imagem gravada em origem para o Store e retorna a nova URL. Imagens
+// antigas (arquivo único) ganham as rendições; se não puderem ser decodificadas, são
+// copiadas como estão. URLs que já são do Store ou de fora da origem voltam sem mudança.
+func (p *ProcessadorImagens) Migrar(ctx context.Context, origem *LocalImageStore, imagemURL string) (string, error) {
+	if _, ok := p.Store.Chave(imagemURL); ok {
+		return imagemURL, nil
+	}
+	chaves, rendicoes, ok := chavesImagem(origem, imagemURL)
+	if !ok {
+		return imagemURL, nil
+	}
+
+	if rendicoes {
+		for _, chave := range chaves {
+			dados, err := origem.Ler(chave)
+			if err != nil {
+				return "", err
+			}
+			if err := p.Store.Put(ctx, chave, dados, "image/jpeg"); err != nil {
+				return "", err
+			}
+		}
+		chaveFull, _ := origem.Chave(imagemURL)
+		return p.Store.URL(chaveFull), nil
+	}
+
+	dados, err := origem.Ler(chaves[0])
+	if err != nil {
+		return "", err
+	}
+	if geradas, err := GerarRendicoes(dados); err == nil {
+		return p.gravarRendicoes(ctx, strings.TrimSuffix(chaves[0], filepath.Ext(chaves[0])), geradas)
+	}
+	if err := p.Store.Put(ctx, chaves[0], dados, http.DetectContentType(dados)); err != nil {
+		return "", err
+	}
+	return p.Store.URL(chaves[0]), nil
+}
+
+// GerarRendicoes decodifica a imagem (JPEG, PNG ou WebP), aplica a orientação EXIF e
+// retorna cada rendição de model.Rendicoes codificada em JPEG, indexada pelo nome.
+func GerarRendicoes(dados []byte) (map[string][]byte, error) {
+	formato, err := FormatoImagem(dados)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg image.Config
+	switch formato {
+	case "jpeg":
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(dados))
+	case "png":
+		cfg, err = png.DecodeConfig(bytes.NewReader(dados))
+	case "webp":
+		cfg, err = webp.DecodeConfig(bytes.NewReader(dados))
+	}
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrImagemCorrompida
+	}
+	if cfg.Width*cfg.Height > maxPixelsImagem {
+		return nil, ErrImagemMuitoGrande
+	}
+
+	var img image.Image
+	switch formato {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(dados))
+	case "png":
+		img, err = png.Decode(bytes.NewReader(dados))
+	case "webp":
+		img, err = webp.Decode(bytes.NewReader(dados))
+	}
+	if err != nil {
+		return nil, ErrImagemCorrompida
+	}
+
+	orientacao := 1
+	if formato == "jpeg" {
+		orientacao = orientacaoJPEG(dados)
+	}
+	base := aplicarOrientacao(sobreFundoBranco(img), orientacao)
+
+	rendicoes := make(map[string][]byte, len(model.Rendicoes))
+	for _, r := range model.Rendicoes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, redimensionar(base, r), &jpeg.Options{Quality: qualidadeJPEG}); err != nil {
+			return nil, fmt.Errorf("codificar rendição %s: %w", r.Nome, err)
+		}
+		rendicoes[r.Nome] = buf.Bytes()
+	}
+	return rendicoes, nil
+}
+
+// sobreFundoBranco converte para RGBA opaco; a transparência de PNG/WebP vira branco,
+// já que as rendições são JPEG.
+func sobreFundoBranco(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// redimensionar gera a rendição: recortada no centro para preencher exatamente o tamanho,
+// ou reduzida para caber nele (sem ampliar imagens menores).
+func redimensionar(src *image.RGBA, r model.Rendicao) image.Image {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	if r.Recortar {
+		// Maior retângulo central com a proporção da rendição
+		recorte := image.Rect(0, 0, sw, sh)
+		if sw*r.Altura > sh*r.Largura {
+			w := sh * r.Largura / r.Altura
+			recorte = image.Rect((sw-w)/2, 0, (sw-w)/2+w, sh)
+		} else {
+			h := sw * r.Altura / r.Largura
+			recorte = image.Rect(0, (sh-h)/2, sw, (sh-h)/2+h)
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, r.Largura, r.Altura))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, recorte, draw.Src, nil)
+		return dst
+	}
+
+	if sw <= r.Largura && sh <= r.Altura {
+		return src
+	}
+	w, h := r.Largura, sh*r.Largura/sw
+	if h > r.Altura {
+		w, h = sw*r.Altura/sh, r.Altura
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// orientacaoJPEG lê a tag Orientation (0x0112) do EXIF de um JPEG. Retorna 1 (normal)
+// se não houver EXIF ou se ele estiver malformado.
+func orientacaoJPEG(dados []byte) int {
+	i := 2 // Depois do SOI (FF D8)
+	for i+4 <= len(dados) {
+		if dados[i] != 0xFF {
+			return 1
+		}
+		marcador := dados[i+1]
+		if marcador == 0xDA || marcador == 0xD9 { // Início dos dados da imagem / fim
+			return 1
+		}
+		tamanho := int(binary.BigEndian.Uint16(dados[i+2:]))
+		if tamanho < 2 || i+2+tamanho > len(dados) {
+			return 1
+		}
+		segmento := dados[i+4 : i+2+tamanho]
+		if marcador == 0xE1 && bytes.HasPrefix(segmento, []byte("Exif\x00\x00")) {
+			return orientacaoTIFF(segmento[6:])
+		}
+		i += 2 + tamanho
+	}
+	return 1
+}
+
+// orientacaoTIFF procura a orientação no primeiro IFD do cabeçalho TIFF do EXIF.
+func orientacaoTIFF(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var ordem binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		ordem = binary.LittleEndian
+	case "MM":
+		ordem = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(ordem.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entradas := int(ordem.Uint16(tiff[ifd:]))
+	for k := 0; k < entradas; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if ordem.Uint16(tiff[e:]) == 0x0112 {
+			if o := int(ordem.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// aplicarOrientacao gira/espelha a imagem conforme a orientação EXIF (1 a 8), para que
+// ela continue em pé depois que os metadados forem descartados.
+func aplicarOrientacao(src *image.RGBA, orientacao int) *image.RGBA {
+	if orientacao <= 1 || orientacao > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientacao >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientacao {
+			case 2: // Espelhada na horizontal
+				sx, sy = w-1-x, y
+			case 3: // Girada 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // Espelhada na vertical
+				sx, sy = x, h-1-y
+			case 5: // Transposta
+				sx, sy = y, x
+			case 6: // Girar 90° no sentido horário
+				sx, sy = y, h-1-x
+			case 7: // Transversa
+				sx, sy = w-1-y, h-1-x
+			case 8: // Girar 90° no sentido anti-horário
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,217 @@
+// /internal/service/imagem_test.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func imagemTeste(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func pngTeste(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imagemTeste(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegComOrientacao gera um JPEG com um segmento EXIF contendo a tag Orientation.
+func jpegComOrientacao(t *testing.T, w, h, orientacao int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, imagemTeste(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")       // Big endian, IFD0 no offset 8
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)      // Uma entrada
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112) // Orientation
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)      // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)      // Contagem
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientacao))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // Resto do valor + próximo IFD
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segmento := []byte{0xFF, 0xE1}
+	segmento = binary.BigEndian.AppendUint16(segmento, uint16(len(app1)+2))
+	segmento = append(segmento, app1...)
+
+	dados := buf.Bytes()
+	return append(append(append([]byte{}, dados[:2]...), segmento...), dados[2:]...)
+}
+
+func TestFormatoImagem(t *testing.T) {
+	casos := []struct {
+		nome     string
+		dados    []byte
+		esperado string
+	}{
+		{"JPEG", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0}, "jpeg"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "png"},
+		{"WebP", []byte("RIFF\x10\x00\x00\x00WEBPVP8 "), "webp"},
+		{"GIF", []byte("GIF89a\x01\x00"), ""},
+		{"Texto com extensão de imagem", []byte("<?php echo 1; ?>"), ""},
+		{"Arquivo vazio", nil, ""},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			formato, err := FormatoImagem(caso.dados)
+			if caso.esperado == "" {
+				if !errors.Is(err, ErrImagemFormato) {
+					t.Errorf("Erro = %v; esperado ErrImagemFormato", err)
+				}
+				return
+			}
+			if err != nil || formato != caso.esperado {
+				t.Errorf("FormatoImagem = %q, %v; esperado %q", formato, err, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestGerarRendicoes(t *testing.T) {
+	t.Run("Tamanhos fixos e sem ampliar a full", func(t *testing.T) {
+		rendicoes, err := GerarRendicoes(pngTeste(t, 800, 300))
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		esperados := map[string][2]int{"thumb": {200, 200}, "card": {600, 400}, "full": {800, 300}}
+		for nome, tam := range esperados {
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(rendicoes[nome]))
+			if err != nil {
+				t.Fatalf("Rendição %s não é JPEG: %v", nome, err)
+			}
+			if cfg.Width != tam[0] || cfg.Height != tam[1] {
+				t.Errorf("Rendição %s = %dx%d; esperado %dx%d", nome, cfg.Width, cfg.Height, tam[0], tam[1])
+			}
+		}
+	})
+
+	t.Run("Full reduzida para caber", func(t *testing.T) {
+		rendicoes, err := GerarRendicoes(pngTeste(t, 3200, 1000))
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		cfg, _ := jpeg.DecodeConfig(bytes.NewReader(rendicoes["full"]))
+		if cfg.Width != 1600 || cfg.Height != 500 {
+			t.Errorf("Full = %dx%d; esperado 1600x500", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("Aplica a orientação e remove o EXIF", func(t *testing.T) {
+		dados := jpegComOrientacao(t, 40, 20, 6)
+		if orientacaoJPEG(dados) != 6 {
+			t.Fatalf("orientacaoJPEG = %d; esperado 6", orientacaoJPEG(dados))
+		}
+		rendicoes, err := GerarRendicoes(dados)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		cfg, _ := jpeg.DecodeConfig(bytes.NewReader(rendicoes["full"]))
+		if cfg.Width != 20 || cfg.Height != 40 {
+			t.Errorf("Full = %dx%d; esperado 20x40 (girada)", cfg.Width, cfg.Height)
+		}
+		for nome, r := range rendicoes {
+			if bytes.Contains(r, []byte("Exif")) {
+				t.Errorf("Rendição %s manteve o EXIF", nome)
+			}
+		}
+	})
+
+	t.Run("Rejeita arquivos corrompidos", func(t *testing.T) {
+		dados := pngTeste(t, 50, 50)
+		if _, err := GerarRendicoes(dados[:40]); !errors.Is(err, ErrImagemCorrompida) {
+			t.Errorf("Erro = %v; esperado ErrImagemCorrompida", err)
+		}
+	})
+}
+
+func TestAplicarOrientacao(t *testing.T) {
+	src := imagemTeste(3, 2)
+	canto := src.RGBAAt(0, 0) // Canto superior esquerdo original
+
+	// Onde o canto superior esquerdo original deve parar em cada orientação
+	destinos := map[int]image.Point{2: {2, 0}, 3: {2, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2}}
+	for orientacao, p := range destinos {
+		dst := aplicarOrientacao(src, orientacao)
+		if dst.RGBAAt(p.X, p.Y) != canto {
+			t.Errorf("Orientação %d: canto em %v = %v; esperado %v", orientacao, p, dst.RGBAAt(p.X, p.Y), canto)
+		}
+	}
+}
+
+func TestProcessadorImagens(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalImageStore(dir, "/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProcessadorImagens(local, 1<<20)
+	ctx := context.Background()
+
+	t.Run("Grava as rendições e remove todas juntas", func(t *testing.T) {
+		url, err := p.Salvar(ctx, bytes.NewReader(pngTeste(t, 300, 300)))
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !strings.HasPrefix(url, "/uploads/") || !strings.HasSuffix(url, "-full.jpg") {
+			t.Fatalf("URL inesperada: %s", url)
+		}
+		for _, r := range model.Rendicoes {
+			if _, err := os.Stat(filepath.Join(dir, filepath.Base(model.URLRendicao(url, r)))); err != nil {
+				t.Errorf("Rendição %s não gravada: %v", r.Nome, err)
+			}
+		}
+		if srcset := model.SrcsetImagem(url); strings.Count(srcset, "w,") != 2 {
+			t.Errorf("Srcset inesperado: %s", srcset)
+		}
+
+		p.Remover(ctx, url)
+		if arquivos, _ := os.ReadDir(dir); len(arquivos) != 0 {
+			t.Errorf("Sobraram %d arquivos após remover", len(arquivos))
+		}
+	})
+
+	t.Run("Rejeita acima do tamanho máximo", func(t *testing.T) {
+		if _, err := p.Salvar(ctx, bytes.NewReader(make([]byte, 1<<20+1))); !errors.Is(err, ErrImagemMuitoGrande) {
+			t.Errorf("Erro = %v; esperado ErrImagemMuitoGrande", err)
+		}
+	})
+
+	t.Run("Remove imagem antiga e ignora a padrão", func(t *testing.T) {
+		antiga := filepath.Join(dir, "antiga.png")
+		os.WriteFile(antiga, []byte("x"), 0o644)
+		p.Remover(ctx, "/static/images/placeholder.png")
+		p.Remover(ctx, "/uploads/../antiga.png")
+		if _, err := os.Stat(antiga); err != nil {
+			t.Fatal("Arquivo removido por URL inválida")
+		}
+		p.Remover(ctx, "/uploads/antiga.png")
+		if _, err := os.Stat(antiga); !errors.Is(err, os.ErrNotExist) {
+			t.Error("Imagem antiga deveria ser removida")
+		}
+		if model.SrcsetImagem("/uploads/antiga.png") != "/uploads/antiga.png" {
+			t.Error("Imagem antiga não tem rendições para o srcset")
+		}
+	})
+}
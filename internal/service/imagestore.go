@@ -0,0 +1,198 @@
+// /internal/service/imagestore.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
+)
+
+// ImageStore guarda os arquivos de imagem dos produtos. As chaves são nomes de arquivo
+// simples (ex.: "<uuid>-card.jpg").
+type ImageStore interface {
+	Put(ctx context.Context, chave string, dados []byte, contentType string) error
+	Delete(ctx context.Context, chave string) error
+	URL(chave string) string
+	// Chave faz o caminho inverso de URL; ok = false para URLs de fora deste armazenamento
+	// (ex.: a imagem padrão em /static).
+	Chave(url string) (chave string, ok bool)
+}
+
+// chaveValida recusa chaves que poderiam escapar da pasta/bucket.
+func chaveValida(chave string) bool {
+	return chave != "" && chave != "." && chave != ".." && !strings.ContainsAny(chave, `/\`)
+}
+
+// NewImageStore escolhe o armazenamento pelo IMAGEM_STORE: "s3" usa as variáveis S3_*;
+// "local" grava em ./uploads (perdido a cada deploy no Fly.io).
+func NewImageStore(cfg config.Imagens) (ImageStore, error) {
+	if cfg.Store != "s3" {
+		return NewLocalImageStore("uploads", "/uploads")
+	}
+	return NewS3ImageStore(S3Config{
+		Endpoint:        cfg.S3Endpoint,
+		Region:          cfg.S3Region,
+		Bucket:          cfg.S3Bucket,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+		PublicURL:       cfg.S3PublicURL,
+	})
+}
+
+// --- Disco local ---
+
+// LocalImageStore grava as imagens em uma pasta servida pelo próprio app.
+type LocalImageStore struct {
+	Dir     string // Ex.: "uploads"
+	URLBase string // Caminho público da pasta (ex.: "/uploads")
+}
+
+// NewLocalImageStore cria a pasta, se preciso.
+func NewLocalImageStore(dir, urlBase string) (*LocalImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("criar pasta de imagens %s: %w", dir, err)
+	}
+	return &LocalImageStore{Dir: dir, URLBase: strings.TrimSuffix(urlBase, "/")}, nil
+}
+
+func (s *LocalImageStore) Put(_ context.Context, chave string, dados []byte, _ string) error {
+	if !chaveValida(chave) {
+		return fmt.Errorf("chave de imagem inválida: %q", chave)
+	}
+	return os.WriteFile(filepath.Join(s.Dir, chave), dados, 0o644)
+}
+
+func (s *LocalImageStore) Delete(_ context.Context, chave string) error {
+	if !chaveValida(chave) {
+		return fmt.Errorf("chave de imagem inválida: %q", chave)
+	}
+	if err := os.Remove(filepath.Join(s.Dir, chave)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalImageStore) URL(chave string) string {
+	return s.URLBase + "/" + chave
+}
+
+func (s *LocalImageStore) Chave(imagemURL string) (string, bool) {
+	chave, ok := strings.CutPrefix(imagemURL, s.URLBase+"/")
+	if !ok || !chaveValida(chave) {
+		return "", false
+	}
+	return chave, true
+}
+
+// Ler devolve o conteúdo de uma imagem gravada (usado na migração para outro armazenamento).
+func (s *LocalImageStore) Ler(chave string) ([]byte, error) {
+	if !chaveValida(chave) {
+		return nil, fmt.Errorf("chave de imagem inválida: %q", chave)
+	}
+	return os.ReadFile(filepath.Join(s.Dir, chave))
+}
+
+// --- S3 e compatíveis (MinIO, Tigris, R2...) ---
+
+// S3Config configura o S3ImageStore.
+type S3Config struct {
+	Endpoint        string // Ex.: https://s3.amazonaws.com, https://fly.storage.tigris.dev, http://localhost:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PublicURL       string // Base pública dos objetos (CDN ou bucket); padrão Endpoint/Bucket
+}
+
+// S3ImageStore grava as imagens em um bucket S3 pelo aws-sdk-go-v2, em path-style
+// (Endpoint/Bucket/chave), que todos os compatíveis aceitam.
+// O bucket precisa permitir leitura pública (ou PublicURL apontar para uma CDN).
+type S3ImageStore struct {
+	cfg    S3Config
+	client *s3.Client
+}
+
+// NewS3ImageStore valida a configuração do bucket.
+func NewS3ImageStore(cfg S3Config) (*S3ImageStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID e S3_SECRET_ACCESS_KEY são obrigatórios")
+	}
+	if _, err := url.Parse(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido: %w", err)
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = cfg.Endpoint + "/" + cfg.Bucket
+	}
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(cfg.Endpoint),
+		Region:       cfg.Region,
+		Credentials:  credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		// Os checksums CRC só quando a operação exige: nem todo compatível (R2, MinIO
+		// antigo) os aceita
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+	return &S3ImageStore{cfg: cfg, client: client}, nil
+}
+
+func (s *S3ImageStore) Put(ctx context.Context, chave string, dados []byte, contentType string) error {
+	if !chaveValida(chave) {
+		return fmt.Errorf("chave de imagem inválida: %q", chave)
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.cfg.Bucket),
+		Key:         aws.String(chave),
+		Body:        bytes.NewReader(dados),
+		ContentType: aws.String(contentType),
+		// As chaves nunca são reaproveitadas: uma imagem nova sempre ganha outro nome
+		CacheControl: aws.String("public, max-age=31536000, immutable"),
+	})
+	if err != nil {
+		return fmt.Errorf("s3 enviar %s: %w", chave, err)
+	}
+	return nil
+}
+
+func (s *S3ImageStore) Delete(ctx context.Context, chave string) error {
+	if !chaveValida(chave) {
+		return fmt.Errorf("chave de imagem inválida: %q", chave)
+	}
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(chave),
+	})
+	var naoEncontrado *types.NoSuchKey
+	if err != nil && !errors.As(err, &naoEncontrado) {
+		return fmt.Errorf("s3 remover %s: %w", chave, err)
+	}
+	return nil
+}
+
+func (s *S3ImageStore) URL(chave string) string {
+	return s.cfg.PublicURL + "/" + chave
+}
+
+func (s *S3ImageStore) Chave(imagemURL string) (string, bool) {
+	chave, ok := strings.CutPrefix(imagemURL, s.cfg.PublicURL+"/")
+	if !ok || !chaveValida(chave) {
+		return "", false
+	}
+	return chave, true
+}
@@ -0,0 +1,214 @@
+// /internal/service/imagestore_test.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+// s3Falso imita um servidor compatível com S3 (como o MinIO) com um bucket em memória,
+// conferindo a assinatura de cada requisição.
+type s3Falso struct {
+	mu      sync.Mutex
+	objetos map[string][]byte
+	tipos   map[string]string
+}
+
+// erroS3 responde no formato de erro do S3.
+func erroS3(w http.ResponseWriter, codigo string, status int) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", codigo, codigo)
+}
+
+// assinaturaValida reassina só os cabeçalhos listados em SignedHeaders e compara.
+func assinaturaValida(r *http.Request, corpo []byte) bool {
+	auth := r.Header.Get("Authorization")
+	_, assinados, ok := strings.Cut(auth, "SignedHeaders=")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") || !ok {
+		return false
+	}
+	assinados, _, _ = strings.Cut(assinados, ",")
+	hashCorpo := r.Header.Get("X-Amz-Content-Sha256")
+	if h := sha256.Sum256(corpo); hashCorpo != hex.EncodeToString(h[:]) {
+		return false
+	}
+	data, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	copia, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, nome := range strings.Split(assinados, ";") {
+		if nome != "host" {
+			copia.Header[http.CanonicalHeaderKey(nome)] = r.Header.Values(nome)
+		}
+	}
+	if r.ContentLength > 0 {
+		copia.ContentLength = r.ContentLength
+	}
+	// O S3 assina o caminho escapado uma vez só, ao contrário dos outros serviços
+	credenciais := aws.Credentials{AccessKeyID: "minio", SecretAccessKey: "minio-secret"}
+	if err := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true }).SignHTTP(r.Context(), credenciais, copia, hashCorpo, "s3", "us-east-1", data); err != nil {
+		return false
+	}
+	return copia.Header.Get("Authorization") == auth
+}
+
+func (f *s3Falso) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	corpo, _ := io.ReadAll(r.Body)
+	if !assinaturaValida(r, corpo) {
+		erroS3(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	chave, ok := strings.CutPrefix(r.URL.Path, "/produtos/")
+	if !ok {
+		erroS3(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objetos[chave] = corpo
+		f.tipos[chave] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(f.objetos, chave)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3ImageStore(t *testing.T) {
+	falso := &s3Falso{objetos: map[string][]byte{}, tipos: map[string]string{}}
+	srv := httptest.NewServer(falso)
+	defer srv.Close()
+
+	store, err := NewS3ImageStore(S3Config{
+		Endpoint: srv.URL, Region: "us-east-1", Bucket: "produtos",
+		AccessKeyID: "minio", SecretAccessKey: "minio-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Run("URL pública e caminho inverso", func(t *testing.T) {
+		url := store.URL("abc-full.jpg")
+		if url != srv.URL+"/produtos/abc-full.jpg" {
+			t.Errorf("URL = %s", url)
+		}
+		if chave, ok := store.Chave(url); !ok || chave != "abc-full.jpg" {
+			t.Errorf("Chave = %q, %v", chave, ok)
+		}
+		if _, ok := store.Chave("/uploads/abc-full.jpg"); ok {
+			t.Error("URL local não pertence ao bucket")
+		}
+	})
+
+	t.Run("Rendições enviadas e removidas juntas", func(t *testing.T) {
+		p := NewProcessadorImagens(store, 1<<20)
+		url, err := p.Salvar(ctx, bytes.NewReader(pngTeste(t, 300, 300)))
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !strings.HasPrefix(url, srv.URL+"/produtos/") {
+			t.Fatalf("URL inesperada: %s", url)
+		}
+		if len(falso.objetos) != len(model.Rendicoes) {
+			t.Fatalf("Objetos no bucket = %d; esperado %d", len(falso.objetos), len(model.Rendicoes))
+		}
+		for chave, tipo := range falso.tipos {
+			if tipo != "image/jpeg" {
+				t.Errorf("Content-Type de %s = %q", chave, tipo)
+			}
+		}
+		p.Remover(ctx, url)
+		if len(falso.objetos) != 0 {
+			t.Errorf("Sobraram %d objetos após remover", len(falso.objetos))
+		}
+	})
+
+	t.Run("Chaves com caracteres que precisam de codificação", func(t *testing.T) {
+		for _, chave := range []string{"café com leite.jpg", "a+b=c&d.jpg", "100%~.jpg"} {
+			if err := store.Put(ctx, chave, []byte("x"), "image/jpeg"); err != nil {
+				t.Errorf("Put(%q): %v", chave, err)
+			}
+			if err := store.Delete(ctx, chave); err != nil {
+				t.Errorf("Delete(%q): %v", chave, err)
+			}
+		}
+	})
+
+	t.Run("Credencial errada", func(t *testing.T) {
+		errado, _ := NewS3ImageStore(S3Config{
+			Endpoint: srv.URL, Region: "us-east-1", Bucket: "produtos",
+			AccessKeyID: "minio", SecretAccessKey: "outra",
+		})
+		if err := errado.Put(ctx, "x.jpg", []byte("x"), "image/jpeg"); err == nil || !strings.Contains(err.Error(), "403") {
+			t.Errorf("Erro = %v; esperado status 403", err)
+		}
+	})
+
+	t.Run("Migra imagens do disco", func(t *testing.T) {
+		dir := t.TempDir()
+		local, _ := NewLocalImageStore(dir, "/uploads")
+		os.WriteFile(filepath.Join(dir, "antiga.png"), pngTeste(t, 50, 50), 0o644)
+		os.WriteFile(filepath.Join(dir, "bolo.gif"), []byte("GIF89a"), 0o644)
+		p := NewProcessadorImagens(store, 1<<20)
+
+		url, err := p.Migrar(ctx, local, "/uploads/antiga.png")
+		if err != nil || url != store.URL("antiga-full.jpg") {
+			t.Fatalf("Migrar = %s, %v", url, err)
+		}
+		if _, ok := falso.objetos["antiga-thumb.jpg"]; !ok {
+			t.Error("Imagem antiga deveria ganhar as rendições")
+		}
+
+		localURL, _ := NewProcessadorImagens(local, 1<<20).Salvar(ctx, bytes.NewReader(pngTeste(t, 80, 80)))
+		url, err = p.Migrar(ctx, local, localURL)
+		chave, _ := local.Chave(localURL)
+		if err != nil || url != store.URL(chave) || !bytes.Equal(falso.objetos[chave], lerArquivo(t, filepath.Join(dir, chave))) {
+			t.Errorf("Rendições já geradas deveriam ser copiadas como estão: %s, %v", url, err)
+		}
+
+		url, err = p.Migrar(ctx, local, "/uploads/bolo.gif")
+		if err != nil || url != store.URL("bolo.gif") {
+			t.Errorf("Arquivo não decodificável deveria ser copiado: %s, %v", url, err)
+		}
+
+		for _, url := range []string{"/static/images/placeholder.png", store.URL("antiga-full.jpg")} {
+			if got, err := p.Migrar(ctx, local, url); err != nil || got != url {
+				t.Errorf("Migrar(%s) = %s, %v; esperado sem mudança", url, got, err)
+			}
+		}
+	})
+}
+
+func lerArquivo(t *testing.T, caminho string) []byte {
+	t.Helper()
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dados
+}
@@ -0,0 +1,164 @@
+// /internal/service/kit.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrKitInvalido indica que o kit ou a caixa montada pelo cliente não pode ser vendida.
+var ErrKitInvalido = errors.New("kit inválido")
+
+// ErroKit descreve por que o kit não pode ser vendido. A mensagem é exibida ao cliente.
+type ErroKit struct {
+	Motivo string
+}
+
+func (e *ErroKit) Error() string { return e.Motivo }
+
+func (e *ErroKit) Unwrap() error { return ErrKitInvalido }
+
+// ComComponentes carrega a composição dos kits e os cupcakes permitidos na "monte sua caixa".
+func ComComponentes(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Itens", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Itens.Cupcake").
+		Preload("Permitidos", func(db *gorm.DB) *gorm.DB { return db.Order("nome") })
+}
+
+// ComponenteKit é um cupcake dentro de um kit, com a quantidade por kit.
+type ComponenteKit struct {
+	Cupcake    model.Cupcake
+	Quantidade int
+}
+
+// ComponentesKit resolve os cupcakes de uma unidade do kit (carregado com ComComponentes)
+// e confere se todos estão disponíveis. Na "monte sua caixa" as escolhas vêm de linha.Caixa.
+func ComponentesKit(kit model.Kit, linha LinhaCarrinho) ([]ComponenteKit, error) {
+	var componentes []ComponenteKit
+	if kit.MonteSuaCaixa() {
+		caixa := linha.normalizar().Caixa
+		permitidos := make(map[uint]model.Cupcake, len(kit.Permitidos))
+		for _, cp := range kit.Permitidos {
+			permitidos[cp.ID] = cp
+		}
+		total := 0
+		for _, id := range idsOrdenados(caixa) {
+			cupcake, ok := permitidos[id]
+			if !ok {
+				return nil, &ErroKit{Motivo: fmt.Sprintf("Um dos cupcakes escolhidos não faz parte de %s.", kit.Nome)}
+			}
+			componentes = append(componentes, ComponenteKit{Cupcake: cupcake, Quantidade: caixa[id]})
+			total += caixa[id]
+		}
+		if total != kit.TamanhoCaixa {
+			return nil, &ErroKit{Motivo: fmt.Sprintf("Escolha exatamente %d cupcakes para %s (você escolheu %d).", kit.TamanhoCaixa, kit.Nome, total)}
+		}
+	} else {
+		if len(linha.Caixa) > 0 {
+			return nil, &ErroKit{Motivo: fmt.Sprintf("%s tem composição fixa.", kit.Nome)}
+		}
+		for _, item := range kit.Itens {
+			componentes = append(componentes, ComponenteKit{Cupcake: item.Cupcake, Quantidade: item.Quantidade})
+		}
+	}
+
+	if len(componentes) == 0 {
+		return nil, &ErroKit{Motivo: fmt.Sprintf("%s está sem cupcakes no momento.", kit.Nome)}
+	}
+	for _, comp := range componentes {
+		// Cupcake excluído chega zerado do Preload
+		if comp.Cupcake.ID == 0 || !comp.Cupcake.Disponivel {
+			nome := comp.Cupcake.Nome
+			if nome == "" {
+				nome = "um dos cupcakes"
+			}
+			return nil, &ErroKit{Motivo: fmt.Sprintf("%s não está disponível no momento, então %s não pode ser vendido.", nome, kit.Nome)}
+		}
+		if comp.Quantidade <= 0 {
+			return nil, &ErroKit{Motivo: fmt.Sprintf("%s está com a composição inválida.", kit.Nome)}
+		}
+	}
+	return componentes, nil
+}
+
+// UnidadesKit conta quantos cupcakes há em uma unidade do kit.
+func UnidadesKit(componentes []ComponenteKit) int {
+	total := 0
+	for _, comp := range componentes {
+		total += comp.Quantidade
+	}
+	return total
+}
+
+// RatearPrecoKit divide o valor pago pelos kits entre os componentes, proporcionalmente ao
+// preço avulso de cada cupcake, para que relatórios por cupcake somem o faturamento real.
+// Os subtotais somam exatamente o valor; a diferença de centavos vai para o último componente.
+func RatearPrecoKit(valor float64, componentes []ComponenteKit, kits int) []float64 {
+	subtotais := make([]float64, len(componentes))
+	if len(componentes) == 0 {
+		return subtotais
+	}
+	valor = arredondar(valor)
+
+	base := 0.0
+	for _, comp := range componentes {
+		base += comp.Cupcake.Preco * float64(comp.Quantidade*kits)
+	}
+
+	restante := valor
+	for i, comp := range componentes {
+		if i == len(componentes)-1 {
+			subtotais[i] = arredondar(restante)
+			break
+		}
+		var parte float64
+		if base > 0 {
+			parte = valor * comp.Cupcake.Preco * float64(comp.Quantidade*kits) / base
+		} else {
+			parte = valor * float64(comp.Quantidade) / float64(UnidadesKit(componentes))
+		}
+		subtotais[i] = arredondar(parte)
+		restante -= subtotais[i]
+	}
+	return subtotais
+}
+
+// ResumoComponentes descreve a composição em uma linha (ex.: "4x Red Velvet · 2x Limão").
+func ResumoComponentes(componentes []ComponenteKit) string {
+	partes := make([]string, 0, len(componentes))
+	for _, comp := range componentes {
+		partes = append(partes, fmt.Sprintf("%dx %s", comp.Quantidade, comp.Cupcake.Nome))
+	}
+	return strings.Join(partes, " · ")
+}
+
+// KitsVendaveis filtra os kits que podem ir para a vitrine: kits fixos com todos os
+// componentes disponíveis e caixas com ao menos um cupcake permitido disponível (os
+// indisponíveis saem da lista de escolha).
+func KitsVendaveis(kits []model.Kit) []model.Kit {
+	vendaveis := make([]model.Kit, 0, len(kits))
+	for _, kit := range kits {
+		if !kit.MonteSuaCaixa() {
+			if _, err := ComponentesKit(kit, LinhaCarrinho{KitID: kit.ID}); err == nil {
+				vendaveis = append(vendaveis, kit)
+			}
+			continue
+		}
+		permitidos := make([]model.Cupcake, 0, len(kit.Permitidos))
+		for _, cp := range kit.Permitidos {
+			if cp.Disponivel {
+				permitidos = append(permitidos, cp)
+			}
+		}
+		if len(permitidos) > 0 {
+			kit.Permitidos = permitidos
+			vendaveis = append(vendaveis, kit)
+		}
+	}
+	return vendaveis
+}
@@ -0,0 +1,130 @@
+// /internal/service/kit_test.go
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+var (
+	redVelvet = model.Cupcake{ID: 1, Nome: "Red Velvet", Preco: 10, Disponivel: true}
+	limao     = model.Cupcake{ID: 2, Nome: "Limão", Preco: 8, Disponivel: true}
+	esgotado  = model.Cupcake{ID: 3, Nome: "Pistache", Preco: 12, Disponivel: false}
+)
+
+func TestComponentesKit(t *testing.T) {
+	t.Run("Kit fixo usa a composição cadastrada", func(t *testing.T) {
+		kit := model.Kit{Nome: "Caixa com 6", Itens: []model.KitItem{
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 4},
+			{CupcakeID: 2, Cupcake: limao, Quantidade: 2},
+		}}
+		componentes, err := ComponentesKit(kit, LinhaCarrinho{KitID: 1})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if UnidadesKit(componentes) != 6 || ResumoComponentes(componentes) != "4x Red Velvet · 2x Limão" {
+			t.Errorf("Composição inesperada: %s", ResumoComponentes(componentes))
+		}
+	})
+
+	t.Run("Componente indisponível bloqueia o kit", func(t *testing.T) {
+		kit := model.Kit{Nome: "Kit Festa", Itens: []model.KitItem{
+			{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 4},
+			{CupcakeID: 3, Cupcake: esgotado, Quantidade: 2},
+		}}
+		if _, err := ComponentesKit(kit, LinhaCarrinho{KitID: 1}); !errors.Is(err, ErrKitInvalido) {
+			t.Errorf("Erro = %v; esperado ErrKitInvalido", err)
+		}
+		kit.Itens[1].Cupcake = model.Cupcake{} // Cupcake excluído
+		if _, err := ComponentesKit(kit, LinhaCarrinho{KitID: 1}); !errors.Is(err, ErrKitInvalido) {
+			t.Errorf("Erro = %v; esperado ErrKitInvalido para cupcake excluído", err)
+		}
+	})
+
+	caixa := model.Kit{Nome: "Monte sua caixa", TamanhoCaixa: 4, Permitidos: []model.Cupcake{redVelvet, limao, esgotado}}
+
+	t.Run("Monte sua caixa com o total certo", func(t *testing.T) {
+		componentes, err := ComponentesKit(caixa, LinhaCarrinho{KitID: 1, Caixa: map[uint]int{1: 3, 2: 1, 3: 0}})
+		if err != nil || len(componentes) != 2 || UnidadesKit(componentes) != 4 {
+			t.Errorf("ComponentesKit = %v, %v", componentes, err)
+		}
+	})
+
+	casos := []struct {
+		nome  string
+		caixa map[uint]int
+	}{
+		{"Faltando cupcakes", map[uint]int{1: 3}},
+		{"Cupcakes demais", map[uint]int{1: 3, 2: 2}},
+		{"Cupcake fora da lista", map[uint]int{1: 3, 9: 1}},
+		{"Cupcake indisponível", map[uint]int{1: 3, 3: 1}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			if _, err := ComponentesKit(caixa, LinhaCarrinho{KitID: 1, Caixa: caso.caixa}); !errors.Is(err, ErrKitInvalido) {
+				t.Errorf("Erro = %v; esperado ErrKitInvalido", err)
+			}
+		})
+	}
+}
+
+func TestRatearPrecoKit(t *testing.T) {
+	componentes := []ComponenteKit{
+		{Cupcake: redVelvet, Quantidade: 4},
+		{Cupcake: limao, Quantidade: 2},
+		{Cupcake: model.Cupcake{ID: 4, Nome: "Brinde", Preco: 0}, Quantidade: 1},
+	}
+
+	for _, kits := range []int{1, 3} {
+		valor := 50.0 * float64(kits)
+		subtotais := RatearPrecoKit(valor, componentes, kits)
+		soma := 0.0
+		for _, s := range subtotais {
+			soma += s
+		}
+		if math.Abs(soma-valor) > 0.001 {
+			t.Errorf("%d kit(s): soma dos subtotais = %.2f; esperado %.2f", kits, soma, valor)
+		}
+		if subtotais[0] <= subtotais[1] {
+			t.Errorf("%d kit(s): rateio deveria acompanhar o preço avulso: %v", kits, subtotais)
+		}
+	}
+}
+
+func TestKitsVendaveis(t *testing.T) {
+	kits := []model.Kit{
+		{ID: 1, Nome: "Fixo ok", Itens: []model.KitItem{{Cupcake: redVelvet, Quantidade: 6}}},
+		{ID: 2, Nome: "Fixo com esgotado", Itens: []model.KitItem{{Cupcake: esgotado, Quantidade: 6}}},
+		{ID: 3, Nome: "Caixa", TamanhoCaixa: 6, Permitidos: []model.Cupcake{limao, esgotado}},
+		{ID: 4, Nome: "Caixa sem opções", TamanhoCaixa: 6, Permitidos: []model.Cupcake{esgotado}},
+	}
+	vendaveis := KitsVendaveis(kits)
+	if len(vendaveis) != 2 || vendaveis[0].ID != 1 || vendaveis[1].ID != 3 {
+		t.Fatalf("KitsVendaveis = %+v", vendaveis)
+	}
+	if len(vendaveis[1].Permitidos) != 1 {
+		t.Errorf("Cupcakes indisponíveis deveriam sair da caixa: %+v", vendaveis[1].Permitidos)
+	}
+}
+
+func TestChaveLinhaKit(t *testing.T) {
+	carrinho := Carrinho{}
+	fixo := carrinho.Adicionar(LinhaCarrinho{KitID: 2}, 1)
+	caixaA := carrinho.Adicionar(LinhaCarrinho{KitID: 3, Caixa: map[uint]int{1: 2, 2: 4}}, 1)
+	carrinho.Adicionar(LinhaCarrinho{KitID: 3, Caixa: map[uint]int{2: 4, 1: 2, 5: 0}}, 1)
+	caixaB := carrinho.Adicionar(LinhaCarrinho{KitID: 3, Caixa: map[uint]int{1: 6}}, 1)
+	carrinho.Adicionar(LinhaCarrinho{CupcakeID: 2}, 1)
+
+	if fixo != "k2" || caixaA == caixaB || carrinho[caixaA].Quantidade != 2 {
+		t.Errorf("Chaves inesperadas: %q %q %q (%+v)", fixo, caixaA, caixaB, carrinho)
+	}
+	if ids := carrinho.KitIDs(); len(ids) != 2 {
+		t.Errorf("KitIDs = %v; esperado 2 kits", ids)
+	}
+	if ids := carrinho.CupcakeIDs(); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("CupcakeIDs = %v; esperado [2]", ids)
+	}
+}
@@ -0,0 +1,137 @@
+// /internal/service/opcoes.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrOpcoesInvalidas indica que as escolhas de personalização não atendem às regras do cupcake.
+var ErrOpcoesInvalidas = errors.New("opções inválidas")
+
+// ErroOpcao descreve qual regra de um grupo de opções não foi atendida.
+// A mensagem é exibida ao cliente.
+type ErroOpcao struct {
+	Grupo  string
+	Motivo string
+}
+
+func (e *ErroOpcao) Error() string {
+	if e.Grupo == "" {
+		return e.Motivo
+	}
+	return e.Grupo + ": " + e.Motivo
+}
+
+func (e *ErroOpcao) Unwrap() error { return ErrOpcoesInvalidas }
+
+// ComOpcoes carrega os grupos de opções dos cupcakes, na ordem de exibição.
+func ComOpcoes(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("GruposOpcoes", func(db *gorm.DB) *gorm.DB { return db.Order("posicao, id") }).
+		Preload("GruposOpcoes.Opcoes", func(db *gorm.DB) *gorm.DB { return db.Order("posicao, id") })
+}
+
+// EscolhasMinimas retorna quantas opções o grupo exige (Obrigatorio vale ao menos uma).
+func EscolhasMinimas(g model.GrupoOpcao) int {
+	if g.Obrigatorio && g.MinEscolhas < 1 {
+		return 1
+	}
+	return g.MinEscolhas
+}
+
+// PrecoLinha confere as escolhas da linha contra os grupos do cupcake (carregados com
+// ComOpcoes) e retorna o preço unitário final e as opções a registrar no item do pedido.
+func PrecoLinha(cupcake model.Cupcake, linha LinhaCarrinho) (float64, []model.ItemOrderOpcao, error) {
+	escolhidas := make(map[uint]bool, len(linha.Opcoes))
+	for _, id := range linha.Opcoes {
+		escolhidas[id] = true
+	}
+	textos := linha.normalizar().Textos
+
+	grupos := append([]model.GrupoOpcao(nil), cupcake.GruposOpcoes...)
+	sort.SliceStable(grupos, func(i, j int) bool { return grupos[i].Posicao < grupos[j].Posicao })
+
+	preco := cupcake.Preco
+	var selecionadas []model.ItemOrderOpcao
+	usadas := 0
+	textosUsados := 0
+
+	for _, g := range grupos {
+		if g.Tipo == model.GrupoTexto {
+			texto, ok := textos[g.ID]
+			if !ok {
+				if g.Obrigatorio {
+					return 0, nil, &ErroOpcao{Grupo: g.Nome, Motivo: "preencha este campo."}
+				}
+				continue
+			}
+			textosUsados++
+			if g.MaxCaracteres > 0 && utf8.RuneCountInString(texto) > g.MaxCaracteres {
+				return 0, nil, &ErroOpcao{Grupo: g.Nome, Motivo: fmt.Sprintf("use no máximo %d caracteres.", g.MaxCaracteres)}
+			}
+			preco += g.PrecoAdicional
+			selecionadas = append(selecionadas, model.ItemOrderOpcao{
+				GrupoOpcaoID: g.ID, Grupo: g.Nome, Valor: texto, PrecoAdicional: g.PrecoAdicional,
+			})
+			continue
+		}
+
+		n := 0
+		for _, op := range g.Opcoes {
+			if !escolhidas[op.ID] {
+				continue
+			}
+			if !op.Disponivel {
+				return 0, nil, &ErroOpcao{Grupo: g.Nome, Motivo: fmt.Sprintf("a opção %s não está disponível.", op.Nome)}
+			}
+			n++
+			preco += op.PrecoAdicional
+			opcaoID := op.ID
+			selecionadas = append(selecionadas, model.ItemOrderOpcao{
+				GrupoOpcaoID: g.ID, OpcaoID: &opcaoID, Grupo: g.Nome, Valor: op.Nome, PrecoAdicional: op.PrecoAdicional,
+			})
+		}
+		usadas += n
+
+		if minimo := EscolhasMinimas(g); n < minimo {
+			motivo := "escolha uma opção."
+			if minimo > 1 {
+				motivo = fmt.Sprintf("escolha ao menos %d opções.", minimo)
+			}
+			return 0, nil, &ErroOpcao{Grupo: g.Nome, Motivo: motivo}
+		}
+		if g.MaxEscolhas > 0 && n > g.MaxEscolhas {
+			motivo := "escolha apenas uma opção."
+			if g.MaxEscolhas > 1 {
+				motivo = fmt.Sprintf("escolha no máximo %d opções.", g.MaxEscolhas)
+			}
+			return 0, nil, &ErroOpcao{Grupo: g.Nome, Motivo: motivo}
+		}
+	}
+
+	// Opções ou textos que não pertencem a nenhum grupo atual do cupcake (ex.: opção excluída)
+	if usadas != len(escolhidas) || textosUsados != len(textos) {
+		return 0, nil, &ErroOpcao{Motivo: "As opções escolhidas não estão mais disponíveis para este cupcake."}
+	}
+
+	if preco < 0 {
+		preco = 0
+	}
+	return arredondar(preco), selecionadas, nil
+}
+
+// DescreverOpcoes resume as opções de um item em uma linha (ex.: "Tamanho: Grande · Recheio: Ninho").
+func DescreverOpcoes(opcoes []model.ItemOrderOpcao) string {
+	partes := make([]string, 0, len(opcoes))
+	for _, op := range opcoes {
+		partes = append(partes, op.Grupo+": "+op.Valor)
+	}
+	return strings.Join(partes, " · ")
+}
@@ -0,0 +1,117 @@
+// /internal/service/pagamento_mp.go
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/metricas"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// ConferirPagamentoPedido consulta no Mercado Pago o pagamento de um pedido pendente e,
+// se ele já foi decidido, atualiza o pedido (quem acompanha é avisado pelo barramento).
+// Serve ao webhook e às conferências periódicas do PIX: o status vem sempre da API,
+// nunca da notificação.
+func ConferirPagamentoPedido(ctx context.Context, db *gorm.DB, cliente payment.Client, pedido *model.Order) (*payment.Response, error) {
+	resource, err := cliente.Get(ctx, int(*pedido.PagamentoMPID))
+	if err != nil {
+		return nil, err
+	}
+	novo := pedido.Status
+	switch resource.Status {
+	case "approved":
+		novo = model.StatusPago
+	case "rejected", "cancelled", "expired":
+		novo = model.StatusFalhou
+	}
+	if novo == pedido.Status || pedido.Status != model.StatusPendente {
+		return resource, nil
+	}
+	if err := AtualizarStatusPedido(db.WithContext(ctx), pedido, novo); err != nil {
+		return resource, err
+	}
+	metricas.RegistrarPagamento(pedido.MetodoPagamento, resource.Status)
+	return resource, nil
+}
+
+const (
+	// intervaloConferenciaPix é de quanto em quanto tempo os PIX pendentes são
+	// consultados no Mercado Pago, para não depender só do webhook.
+	intervaloConferenciaPix = 10 * time.Second
+	// janelaConferenciaPix limita a conferência aos PIX recentes: os mais antigos já
+	// venceram no Mercado Pago e, se o pedido ficou pendente, é o lojista quem resolve.
+	janelaConferenciaPix = 48 * time.Hour
+)
+
+// ConferirPixPendentes consulta cada PIX pendente recente e atualiza os pedidos pagos ou
+// vencidos. Devolve quantos foram consultados.
+func ConferirPixPendentes(ctx context.Context, db *gorm.DB, cliente payment.Client, agora time.Time) (int, error) {
+	var pedidos []model.Order
+	err := db.WithContext(ctx).
+		Where("status = ? AND metodo_pagamento = ? AND pagamento_mp_id IS NOT NULL AND created_at > ?",
+			model.StatusPendente, "pix", agora.Add(-janelaConferenciaPix)).
+		Find(&pedidos).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range pedidos {
+		if _, err := ConferirPagamentoPedido(ctx, db, cliente, &pedidos[i]); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Erro ao conferir o PIX do pedido", "pedido_id", pedidos[i].ID, "erro", err)
+		}
+	}
+	return len(pedidos), nil
+}
+
+// ManterConferenciaPix confere os PIX pendentes agora e a cada intervaloConferenciaPix,
+// até ctx ser cancelado. Quem acompanha o pedido (a página do PIX, por SSE) recebe a
+// mudança pelo barramento Eventos, sem consultar o Mercado Pago por conta própria.
+func ManterConferenciaPix(ctx context.Context, db *gorm.DB, cliente payment.Client) {
+	ticker := time.NewTicker(intervaloConferenciaPix)
+	defer ticker.Stop()
+	for {
+		if _, err := ConferirPixPendentes(ctx, db, cliente, time.Now()); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Erro ao buscar os PIX pendentes", "erro", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AssinaturaWebhookValida confere o cabeçalho x-signature ("ts=...,v1=...") de uma
+// notificação do Mercado Pago: HMAC-SHA256, com o segredo do painel, de
+// "id:<data.id>;request-id:<x-request-id>;ts:<ts>;".
+func AssinaturaWebhookValida(segredo, assinatura, requestID, dataID string) bool {
+	var ts, v1 string
+	for _, parte := range strings.Split(assinatura, ",") {
+		chave, valor, _ := strings.Cut(strings.TrimSpace(parte), "=")
+		switch chave {
+		case "ts":
+			ts = valor
+		case "v1":
+			v1 = valor
+		}
+	}
+	if ts == "" || v1 == "" {
+		return false
+	}
+	manifesto := "id:" + strings.ToLower(dataID) + ";"
+	if requestID != "" {
+		manifesto += "request-id:" + requestID + ";"
+	}
+	manifesto += "ts:" + ts + ";"
+	mac := hmac.New(sha256.New, []byte(segredo))
+	mac.Write([]byte(manifesto))
+	esperado := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(esperado), []byte(strings.ToLower(v1)))
+}
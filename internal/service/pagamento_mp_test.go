@@ -0,0 +1,94 @@
+// /internal/service/pagamento_mp_test.go
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// pagamentosFalsos responde ao Get com um status fixo.
+type pagamentosFalsos struct {
+	payment.Client
+	status string
+}
+
+func (p pagamentosFalsos) Get(_ context.Context, id int) (*payment.Response, error) {
+	return &payment.Response{ID: id, Status: p.status}, nil
+}
+
+func TestAssinaturaWebhookValida(t *testing.T) {
+	assinar := func(manifesto string) string {
+		mac := hmac.New(sha256.New, []byte("segredo"))
+		mac.Write([]byte(manifesto))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	v1 := assinar("id:123;request-id:req-1;ts:1700000000;")
+
+	if !AssinaturaWebhookValida("segredo", "ts=1700000000,v1="+v1, "req-1", "123") {
+		t.Error("A assinatura do Mercado Pago deveria ser aceita")
+	}
+	for nome, caso := range map[string][4]string{
+		"segredo errado":  {"outro", "ts=1700000000,v1=" + v1, "req-1", "123"},
+		"outro pagamento": {"segredo", "ts=1700000000,v1=" + v1, "req-1", "124"},
+		"outro ts":        {"segredo", "ts=1700000001,v1=" + v1, "req-1", "123"},
+		"sem v1":          {"segredo", "ts=1700000000", "req-1", "123"},
+		"vazia":           {"segredo", "", "req-1", "123"},
+	} {
+		if AssinaturaWebhookValida(caso[0], caso[1], caso[2], caso[3]) {
+			t.Errorf("%s: assinatura deveria ser recusada", nome)
+		}
+	}
+	semRequestID := assinar("id:123;ts:1700000000;")
+	if !AssinaturaWebhookValida("segredo", "ts=1700000000, v1="+semRequestID, "", "123") {
+		t.Error("Sem x-request-id, o manifesto não leva o request-id")
+	}
+}
+
+func TestConferirPagamentoPedidoPendente(t *testing.T) {
+	id := int64(55)
+	pedido := model.Order{ID: 9, Status: model.StatusPendente, MetodoPagamento: "pix", PagamentoMPID: &id}
+	// Pagamento ainda pendente: nada muda e o banco nem é usado
+	resource, err := ConferirPagamentoPedido(t.Context(), nil, pagamentosFalsos{status: "pending"}, &pedido)
+	if err != nil || resource.Status != "pending" || pedido.Status != model.StatusPendente {
+		t.Errorf("ConferirPagamentoPedido = %+v, %v; pedido %s", resource, err, pedido.Status)
+	}
+	// Pedido que já saiu de pendente não volta atrás
+	pedido.Status = model.StatusCancelado
+	if _, err := ConferirPagamentoPedido(t.Context(), nil, pagamentosFalsos{status: "approved"}, &pedido); err != nil || pedido.Status != model.StatusCancelado {
+		t.Errorf("Pedido cancelado não deveria mudar: %s, %v", pedido.Status, err)
+	}
+}
+
+func TestConferirPixPendentesSoBuscaPixRecentes(t *testing.T) {
+	// DryRun monta a consulta sem banco; o callback guarda o SQL e os valores
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=invalido"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sql string
+	var valores []any
+	db.Callback().Query().After("gorm:query").Register("teste:valores", func(tx *gorm.DB) {
+		sql, valores = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	agora := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	if _, err := ConferirPixPendentes(t.Context(), db, pagamentosFalsos{status: "approved"}, agora); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, "pagamento_mp_id IS NOT NULL") || len(valores) != 3 {
+		t.Fatalf("Consulta inesperada: %s %v", sql, valores)
+	}
+	if valores[0] != model.StatusPendente || valores[1] != "pix" || valores[2] != agora.Add(-janelaConferenciaPix) {
+		t.Errorf("Valores da consulta = %v; esperado PIX pendentes das últimas 48h", valores)
+	}
+}
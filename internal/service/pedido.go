@@ -0,0 +1,136 @@
+// /internal/service/pedido.go
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// ComDetalhes carrega tudo o que a página de detalhe e o recibo do pedido exibem.
+// Cupcakes e janelas excluídos depois da compra continuam aparecendo.
+func ComDetalhes(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Usuario").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Cupcake", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Items.Opcoes").
+		Preload("JanelaEntrega", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Historico", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") })
+}
+
+// RegistrarStatusPedido grava uma entrada no histórico de status do pedido.
+func RegistrarStatusPedido(db *gorm.DB, pedidoID uint, status model.StatusOrder) error {
+	return db.Create(&model.HistoricoStatusPedido{PedidoID: pedidoID, Status: status}).Error
+}
+
+// EventoPedido é um passo da linha do tempo do pedido.
+type EventoPedido struct {
+	Status model.StatusOrder
+	Titulo string
+	Quando time.Time
+}
+
+// TituloStatus descreve o status para o cliente; "enviado" depende de o pedido ser
+// entregue ou retirado na loja.
+func TituloStatus(status model.StatusOrder, tipo model.TipoEntrega) string {
+	switch status {
+	case model.StatusPendente:
+		return "Aguardando pagamento"
+	case model.StatusPago:
+		return "Pagamento aprovado"
+	case model.StatusPreparando:
+		return "Em preparo"
+	case model.StatusEnviado:
+		if tipo == model.TipoEntregaRetirada {
+			return "Pronto para retirada"
+		}
+		return "Saiu para entrega"
+	case model.StatusEntregue:
+		if tipo == model.TipoEntregaRetirada {
+			return "Retirado"
+		}
+		return "Entregue"
+	case model.StatusFalhou:
+		return "Pagamento não aprovado"
+	case model.StatusCancelado:
+		return "Pedido cancelado"
+	}
+	return string(status)
+}
+
+// LinhaDoTempo monta os passos do pedido (carregado com o Historico em ordem). Pedidos
+// anteriores ao histórico mostram só a criação e o status atual.
+func LinhaDoTempo(pedido model.Order) []EventoPedido {
+	eventos := []EventoPedido{{Status: model.StatusPendente, Titulo: "Pedido realizado", Quando: pedido.CreatedAt}}
+	for _, h := range pedido.Historico {
+		eventos = append(eventos, EventoPedido{Status: h.Status, Titulo: TituloStatus(h.Status, pedido.TipoEntrega), Quando: h.CreatedAt})
+	}
+	if len(pedido.Historico) == 0 && pedido.Status != model.StatusPendente {
+		eventos = append(eventos, EventoPedido{Status: pedido.Status, Titulo: TituloStatus(pedido.Status, pedido.TipoEntrega), Quando: pedido.UpdatedAt})
+	}
+	return eventos
+}
+
+// bandeirasCartao traduz os payment_method_id do Mercado Pago mais comuns.
+var bandeirasCartao = map[string]string{
+	"visa":      "Visa",
+	"master":    "Mastercard",
+	"amex":      "American Express",
+	"elo":       "Elo",
+	"hipercard": "Hipercard",
+	"debvisa":   "Visa Débito",
+	"debmaster": "Mastercard Débito",
+	"debelo":    "Elo Débito",
+}
+
+// DescreverPagamento resume a forma de pagamento do pedido (ex.: "Cartão Visa em 3x").
+func DescreverPagamento(pedido model.Order) string {
+	metodo := strings.ToLower(strings.TrimSpace(pedido.MetodoPagamento))
+	switch metodo {
+	case "":
+		return "Não informado"
+	case "pix":
+		return "PIX"
+	}
+
+	bandeira, ok := bandeirasCartao[metodo]
+	if !ok {
+		bandeira = strings.ToUpper(metodo[:1]) + metodo[1:]
+	}
+	if pedido.Parcelas > 1 {
+		return fmt.Sprintf("Cartão %s em %dx", bandeira, pedido.Parcelas)
+	}
+	return fmt.Sprintf("Cartão %s à vista", bandeira)
+}
+
+// DescreverEntrega resume como e quando o pedido chega ao cliente.
+func DescreverEntrega(pedido model.Order) string {
+	tipo := "Entrega"
+	if pedido.TipoEntrega == model.TipoEntregaRetirada {
+		tipo = "Retirada na loja"
+	}
+	if pedido.DataEntrega == nil {
+		return tipo
+	}
+	descricao := fmt.Sprintf("%s em %s", tipo, pedido.DataEntrega.Format("02/01/2006"))
+	if pedido.JanelaEntrega != nil {
+		descricao += fmt.Sprintf(", %s - %s", pedido.JanelaEntrega.HoraInicio, pedido.JanelaEntrega.HoraFim)
+	}
+	return descricao
+}
+
+// EnderecoEntrega formata o endereço do pedido em uma linha; vazio para retiradas.
+func EnderecoEntrega(pedido model.Order) string {
+	if pedido.TipoEntrega != model.TipoEntregaDelivery || pedido.EntregaRua == "" {
+		return ""
+	}
+	endereco := pedido.EntregaRua + ", " + pedido.EntregaNumero
+	if pedido.EntregaComplemento != "" {
+		endereco += " " + pedido.EntregaComplemento
+	}
+	return fmt.Sprintf("%s - %s, %s/%s (%s)", endereco, pedido.EntregaBairro, pedido.EntregaCidade, pedido.EntregaEstado, pedido.EntregaCEP)
+}
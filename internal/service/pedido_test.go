@@ -0,0 +1,109 @@
+// /internal/service/pedido_test.go
+package service
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+)
+
+func TestLinhaDoTempo(t *testing.T) {
+	criado := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Usa o histórico gravado", func(t *testing.T) {
+		pedido := model.Order{CreatedAt: criado, Status: model.StatusEnviado, TipoEntrega: model.TipoEntregaRetirada, Historico: []model.HistoricoStatusPedido{
+			{Status: model.StatusPago, CreatedAt: criado.Add(time.Minute)},
+			{Status: model.StatusEnviado, CreatedAt: criado.Add(time.Hour)},
+		}}
+		eventos := LinhaDoTempo(pedido)
+		if len(eventos) != 3 || eventos[0].Titulo != "Pedido realizado" || eventos[2].Titulo != "Pronto para retirada" {
+			t.Errorf("Linha do tempo inesperada: %+v", eventos)
+		}
+	})
+
+	t.Run("Pedido sem histórico mostra o status atual", func(t *testing.T) {
+		pedido := model.Order{CreatedAt: criado, UpdatedAt: criado.Add(time.Hour), Status: model.StatusEntregue}
+		eventos := LinhaDoTempo(pedido)
+		if len(eventos) != 2 || eventos[1].Titulo != "Entregue" || !eventos[1].Quando.Equal(criado.Add(time.Hour)) {
+			t.Errorf("Linha do tempo inesperada: %+v", eventos)
+		}
+		if len(LinhaDoTempo(model.Order{Status: model.StatusPendente})) != 1 {
+			t.Error("Pedido pendente sem histórico deveria ter só a criação")
+		}
+	})
+}
+
+func TestDescreverPagamento(t *testing.T) {
+	casos := []struct {
+		pedido   model.Order
+		esperado string
+	}{
+		{model.Order{MetodoPagamento: "pix"}, "PIX"},
+		{model.Order{MetodoPagamento: "master", Parcelas: 3}, "Cartão Mastercard em 3x"},
+		{model.Order{MetodoPagamento: "visa", Parcelas: 1}, "Cartão Visa à vista"},
+		{model.Order{MetodoPagamento: "cabal"}, "Cartão Cabal à vista"},
+		{model.Order{}, "Não informado"},
+	}
+	for _, caso := range casos {
+		if got := DescreverPagamento(caso.pedido); got != caso.esperado {
+			t.Errorf("DescreverPagamento(%q) = %q; esperado %q", caso.pedido.MetodoPagamento, got, caso.esperado)
+		}
+	}
+}
+
+func TestLinhasRecibo(t *testing.T) {
+	kitID := uint(5)
+	itens := []model.ItemOrder{
+		{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 2, PrecoUnitario: 12, Subtotal: 24,
+			Opcoes: []model.ItemOrderOpcao{{Grupo: "Tamanho", Valor: "Grande"}}},
+		{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 8, Subtotal: 66.67, KitID: &kitID, KitNome: "Caixa com 6", KitLinha: 2, KitQuantidade: 2},
+		{CupcakeID: 9, Quantidade: 4, Subtotal: 33.33, KitID: &kitID, KitNome: "Caixa com 6", KitLinha: 2, KitQuantidade: 2},
+	}
+
+	linhas := LinhasRecibo(itens)
+	if len(linhas) != 2 {
+		t.Fatalf("Esperado 2 linhas, obtido %d", len(linhas))
+	}
+	if linhas[0].Descricao != "Red Velvet" || linhas[0].Detalhe != "Tamanho: Grande" || linhas[0].Subtotal != 24 {
+		t.Errorf("Linha avulsa inesperada: %+v", linhas[0])
+	}
+	kit := linhas[1]
+	if kit.Descricao != "Caixa com 6" || kit.Quantidade != 2 || kit.Subtotal != 100 || kit.PrecoUnitario != 50 {
+		t.Errorf("Linha de kit inesperada: %+v", kit)
+	}
+	if kit.Detalhe != "4x Red Velvet · 2x Cupcake #9" {
+		t.Errorf("Composição = %q", kit.Detalhe)
+	}
+}
+
+func TestGerarReciboPDF(t *testing.T) {
+	mpID := int64(123456)
+	data := time.Date(2026, 10, 22, 0, 0, 0, 0, time.UTC)
+	pedido := model.Order{
+		ID: 42, Status: model.StatusPago, Total: 21.6, Desconto: 2.4, CodigoCupom: "DOCE10",
+		MetodoPagamento: "visa", Parcelas: 2, PagamentoMPID: &mpID,
+		TipoEntrega: model.TipoEntregaDelivery, DataEntrega: &data,
+		EntregaRua: "Rua das Flores", EntregaNumero: "10", EntregaBairro: "Centro", EntregaCidade: "São Paulo", EntregaEstado: "SP", EntregaCEP: "01001-000",
+		Usuario: model.Usuario{Nome: "Ana", Email: "ana@exemplo.com"},
+		Items:   []model.ItemOrder{{CupcakeID: 1, Cupcake: redVelvet, Quantidade: 2, PrecoUnitario: 12, Subtotal: 24}},
+	}
+
+	var buf bytes.Buffer
+	if err := GerarReciboPDF(&buf, pedido); err != nil {
+		t.Fatalf("Erro ao gerar o recibo: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Error("O recibo deveria ser um PDF")
+	}
+}
+
+func TestFormatarReais(t *testing.T) {
+	casos := map[float64]string{0: "R$ 0,00", 8.5: "R$ 8,50", 1234.5: "R$ 1.234,50", 1234567.891: "R$ 1.234.567,89"}
+	for valor, esperado := range casos {
+		if got := formatarReais(valor); got != esperado {
+			t.Errorf("formatarReais(%v) = %q; esperado %q", valor, got, esperado)
+		}
+	}
+}
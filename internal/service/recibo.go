@@ -0,0 +1,173 @@
+// /internal/service/recibo.go
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/go-pdf/fpdf"
+)
+
+// LinhaRecibo é uma linha do pedido como o cliente comprou: um cupcake com as opções
+// ou um kit inteiro (os componentes gravados em ItemOrder voltam a ser uma linha só).
+type LinhaRecibo struct {
+	Descricao     string
+	Detalhe       string // Opções escolhidas ou composição do kit
+	Quantidade    int
+	PrecoUnitario float64
+	Subtotal      float64
+}
+
+// LinhasRecibo agrupa os itens do pedido (em ordem de gravação) nas linhas do recibo.
+func LinhasRecibo(itens []model.ItemOrder) []LinhaRecibo {
+	var linhas []LinhaRecibo
+	kits := make(map[int]int)            // KitLinha -> posição em linhas
+	composicao := make(map[int][]string) // KitLinha -> "4x Red Velvet"
+
+	for _, item := range itens {
+		if item.KitID == nil {
+			linhas = append(linhas, LinhaRecibo{
+				Descricao: nomeItem(item), Detalhe: DescreverOpcoes(item.Opcoes),
+				Quantidade: item.Quantidade, PrecoUnitario: item.PrecoUnitario, Subtotal: item.Subtotal,
+			})
+			continue
+		}
+
+		qtdKits := item.KitQuantidade
+		if qtdKits <= 0 {
+			qtdKits = 1
+		}
+		i, ok := kits[item.KitLinha]
+		if !ok {
+			i = len(linhas)
+			kits[item.KitLinha] = i
+			linhas = append(linhas, LinhaRecibo{Descricao: item.KitNome, Quantidade: qtdKits})
+		}
+		linhas[i].Subtotal += item.Subtotal
+		composicao[item.KitLinha] = append(composicao[item.KitLinha], fmt.Sprintf("%dx %s", item.Quantidade/qtdKits, nomeItem(item)))
+	}
+
+	for kitLinha, i := range kits {
+		linhas[i].Subtotal = arredondar(linhas[i].Subtotal)
+		linhas[i].PrecoUnitario = arredondar(linhas[i].Subtotal / float64(linhas[i].Quantidade))
+		linhas[i].Detalhe = strings.Join(composicao[kitLinha], " · ")
+	}
+	return linhas
+}
+
+// nomeItem é o nome do cupcake do item, mesmo que ele não exista mais.
+func nomeItem(item model.ItemOrder) string {
+	if item.Cupcake.Nome != "" {
+		return item.Cupcake.Nome
+	}
+	return fmt.Sprintf("Cupcake #%d", item.CupcakeID)
+}
+
+// GerarReciboPDF escreve em w o recibo do pedido (carregado com ComDetalhes).
+func GerarReciboPDF(w io.Writer, pedido model.Order) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// As fontes padrão do PDF usam cp1252; o tradutor converte os acentos do UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(tr(fmt.Sprintf("Recibo do pedido #%d", pedido.ID)), false)
+	pdf.SetAuthor("Meu Cupcake", false)
+	pdf.SetCreationDate(pedido.CreatedAt)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.SetTextColor(255, 105, 180)
+	pdf.CellFormat(0, 10, "Meu Cupcake", "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, tr(fmt.Sprintf("Recibo do pedido #%d", pedido.ID)), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	campo := func(rotulo, valor string) {
+		if valor == "" {
+			return
+		}
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(40, 6, tr(rotulo), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 6, tr(valor), "", "L", false)
+	}
+	campo("Data do pedido:", pedido.CreatedAt.Format("02/01/2006 15:04"))
+	campo("Cliente:", strings.TrimSpace(pedido.Usuario.Nome+" "+pedido.Usuario.Email))
+	campo("Status:", TituloStatus(pedido.Status, pedido.TipoEntrega))
+	campo("Pagamento:", DescreverPagamento(pedido))
+	if pedido.PagamentoMPID != nil {
+		campo("ID Mercado Pago:", fmt.Sprintf("%d", *pedido.PagamentoMPID))
+	}
+	campo("Entrega:", DescreverEntrega(pedido))
+	campo("Endereço:", EnderecoEntrega(pedido))
+	pdf.Ln(4)
+
+	// --- Itens ---
+	larguras := []float64{95, 15, 35, 35}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(255, 228, 240)
+	for i, titulo := range []string{"Item", "Qtd", "Preço unit.", "Subtotal"} {
+		alinhamento := "R"
+		if i == 0 {
+			alinhamento = "L"
+		}
+		pdf.CellFormat(larguras[i], 8, tr(titulo), "B", 0, alinhamento, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	subtotal := 0.0
+	for _, linha := range LinhasRecibo(pedido.Items) {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(larguras[0], 7, tr(linha.Descricao), "", 0, "L", false, 0, "")
+		pdf.CellFormat(larguras[1], 7, fmt.Sprintf("%d", linha.Quantidade), "", 0, "R", false, 0, "")
+		pdf.CellFormat(larguras[2], 7, formatarReais(linha.PrecoUnitario), "", 0, "R", false, 0, "")
+		pdf.CellFormat(larguras[3], 7, formatarReais(linha.Subtotal), "", 1, "R", false, 0, "")
+		if linha.Detalhe != "" {
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(100, 100, 100)
+			pdf.MultiCell(larguras[0], 5, tr(linha.Detalhe), "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+		}
+		subtotal += linha.Subtotal
+	}
+	pdf.Ln(2)
+
+	total := func(rotulo, valor string, negrito bool) {
+		estilo := ""
+		if negrito {
+			estilo = "B"
+		}
+		pdf.SetFont("Helvetica", estilo, 11)
+		pdf.CellFormat(larguras[0]+larguras[1]+larguras[2], 7, tr(rotulo), "", 0, "R", false, 0, "")
+		pdf.CellFormat(larguras[3], 7, valor, "", 1, "R", false, 0, "")
+	}
+	if pedido.Desconto > 0 {
+		total("Subtotal", formatarReais(subtotal), false)
+		total(fmt.Sprintf("Desconto (%s)", pedido.CodigoCupom), "- "+formatarReais(pedido.Desconto), false)
+	}
+	total("Total", formatarReais(pedido.Total), true)
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.MultiCell(0, 4, tr("Este documento é um comprovante do pedido e não substitui a nota fiscal."), "", "L", false)
+
+	return pdf.Output(w)
+}
+
+// formatarReais formata o valor no padrão brasileiro (ex.: "R$ 1.234,50").
+func formatarReais(v float64) string {
+	s := fmt.Sprintf("%.2f", arredondar(v))
+	inteiro, centavos, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, r := range inteiro {
+		if i > 0 && (len(inteiro)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	return "R$ " + b.String() + "," + centavos
+}
@@ -0,0 +1,171 @@
+// /internal/service/recompra.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrPedidoSemItens indica que o pedido não tem itens para pedir novamente.
+var ErrPedidoSemItens = errors.New("pedido sem itens")
+
+// LinhaRecompra é uma linha do carrinho reconstruída a partir dos itens de um pedido,
+// com o preço unitário pago na época (por kit, nas linhas de kit).
+type LinhaRecompra struct {
+	Linha     LinhaCarrinho
+	PrecoPago float64
+}
+
+// LinhasDoPedido reconstrói as linhas do carrinho de um pedido: cada item avulso vira uma
+// linha com as mesmas opções e os componentes de um kit voltam a ser uma linha só.
+func LinhasDoPedido(itens []model.ItemOrder) []LinhaRecompra {
+	var linhas []LinhaRecompra
+	kits := make(map[int]int) // KitLinha -> posição em linhas
+
+	for _, item := range itens {
+		if item.KitID == nil {
+			linha := LinhaCarrinho{CupcakeID: item.CupcakeID, Quantidade: item.Quantidade}
+			for _, op := range item.Opcoes {
+				if op.OpcaoID != nil {
+					linha.Opcoes = append(linha.Opcoes, *op.OpcaoID)
+					continue
+				}
+				if linha.Textos == nil {
+					linha.Textos = make(map[uint]string)
+				}
+				linha.Textos[op.GrupoOpcaoID] = op.Valor
+			}
+			linhas = append(linhas, LinhaRecompra{Linha: linha.normalizar(), PrecoPago: item.PrecoUnitario})
+			continue
+		}
+
+		kitsNaLinha := item.KitQuantidade
+		if kitsNaLinha <= 0 {
+			kitsNaLinha = 1
+		}
+		i, ok := kits[item.KitLinha]
+		if !ok {
+			i = len(linhas)
+			kits[item.KitLinha] = i
+			linhas = append(linhas, LinhaRecompra{Linha: LinhaCarrinho{
+				KitID: *item.KitID, Quantidade: kitsNaLinha, Caixa: make(map[uint]int),
+			}})
+		}
+		// A composição fica guardada em Caixa; para kits fixos ela é descartada na conferência
+		linhas[i].Linha.Caixa[item.CupcakeID] += item.Quantidade / kitsNaLinha
+		// Soma o que foi pago pela linha; o preço por kit é calculado no fim
+		linhas[i].PrecoPago += item.Subtotal
+	}
+	for _, i := range kits {
+		linhas[i].PrecoPago = arredondar(linhas[i].PrecoPago / float64(linhas[i].Linha.Quantidade))
+	}
+	return linhas
+}
+
+// ResultadoRecompra é o carrinho montado a partir de um pedido e o que mudou desde a compra.
+type ResultadoRecompra struct {
+	Carrinho    Carrinho
+	Adicionadas int      // Linhas que foram para o carrinho
+	Removidos   []string // Itens que ficaram de fora, com o motivo
+	Alterados   []string // Itens que foram, mas com preço diferente
+}
+
+// ConferirRecompra confere as linhas do pedido contra os cupcakes e kits atuais (com as
+// relações de ComOpcoes e ComComponentes, e os excluídos sem relações) e monta o carrinho
+// com os preços de hoje.
+func ConferirRecompra(linhas []LinhaRecompra, cupcakes map[uint]model.Cupcake, kits map[uint]model.Kit) ResultadoRecompra {
+	resultado := ResultadoRecompra{Carrinho: Carrinho{}}
+
+	for _, lr := range linhas {
+		linha := lr.Linha
+		var nome string
+		var preco float64
+
+		if linha.EhKit() {
+			kit, ok := kits[linha.KitID]
+			nome = kit.Nome
+			switch {
+			case !ok || kit.DeletedAt.Valid:
+				resultado.Removidos = append(resultado.Removidos, naoVendido(nome, "Um kit do pedido"))
+				continue
+			case !kit.Disponivel:
+				resultado.Removidos = append(resultado.Removidos, fmt.Sprintf("%s está indisponível no momento.", nome))
+				continue
+			}
+			if !kit.MonteSuaCaixa() {
+				linha.Caixa = nil
+			}
+			if _, err := ComponentesKit(kit, linha); err != nil {
+				resultado.Removidos = append(resultado.Removidos, err.Error())
+				continue
+			}
+			preco = kit.Preco
+		} else {
+			cupcake := cupcakes[linha.CupcakeID]
+			nome = cupcake.Nome
+			switch SituacaoCupcake(cupcake) {
+			case FavoritoRemovido:
+				resultado.Removidos = append(resultado.Removidos, naoVendido(nome, "Um cupcake do pedido"))
+				continue
+			case FavoritoIndisponivel:
+				resultado.Removidos = append(resultado.Removidos, fmt.Sprintf("%s está indisponível no momento.", nome))
+				continue
+			}
+			var err error
+			if preco, _, err = PrecoLinha(cupcake, linha); err != nil {
+				resultado.Removidos = append(resultado.Removidos,
+					fmt.Sprintf("%s: as opções escolhidas não valem mais (%s). Personalize novamente na vitrine.", nome, strings.TrimSuffix(err.Error(), ".")))
+				continue
+			}
+		}
+
+		resultado.Carrinho.Adicionar(linha, linha.Quantidade)
+		resultado.Adicionadas++
+		if preco != lr.PrecoPago {
+			resultado.Alterados = append(resultado.Alterados,
+				fmt.Sprintf("%s: o preço mudou de R$ %.2f para R$ %.2f.", nome, lr.PrecoPago, preco))
+		}
+	}
+	return resultado
+}
+
+// naoVendido descreve um item excluído pelo lojista; padrao cobre registros apagados do banco.
+func naoVendido(nome, padrao string) string {
+	if nome == "" {
+		nome = padrao
+	}
+	return nome + " não é mais vendido."
+}
+
+// Recomprar monta um carrinho com os itens do pedido (carregado com Items.Opcoes) aos
+// preços atuais. Cupcakes e kits excluídos também são carregados, para serem avisados.
+func Recomprar(db *gorm.DB, pedido model.Order) (ResultadoRecompra, error) {
+	linhas := LinhasDoPedido(pedido.Items)
+	if len(linhas) == 0 {
+		return ResultadoRecompra{}, ErrPedidoSemItens
+	}
+
+	var cupcakeIDs, kitIDs []uint
+	for _, lr := range linhas {
+		if lr.Linha.EhKit() {
+			kitIDs = append(kitIDs, lr.Linha.KitID)
+		} else {
+			cupcakeIDs = append(cupcakeIDs, lr.Linha.CupcakeID)
+		}
+	}
+
+	cupcakes, err := comExcluidos(db, ComOpcoes, cupcakeIDs, func(c model.Cupcake) uint { return c.ID })
+	if err != nil {
+		return ResultadoRecompra{}, err
+	}
+	kits, err := comExcluidos(db, ComComponentes, kitIDs, func(k model.Kit) uint { return k.ID })
+	if err != nil {
+		return ResultadoRecompra{}, err
+	}
+
+	return ConferirRecompra(linhas, cupcakes, kits), nil
+}
@@ -0,0 +1,80 @@
+// /internal/service/recompra_test.go
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestLinhasDoPedido(t *testing.T) {
+	opcao := uint(7)
+	kitID := uint(5)
+	itens := []model.ItemOrder{
+		{CupcakeID: 1, Quantidade: 2, PrecoUnitario: 12, Opcoes: []model.ItemOrderOpcao{
+			{GrupoOpcaoID: 3, OpcaoID: &opcao, Grupo: "Tamanho", Valor: "Grande"},
+			{GrupoOpcaoID: 4, Grupo: "Topper", Valor: "Parabéns"},
+		}},
+		// Dois kits de 4 Red Velvet + 2 Limão, a R$ 50 cada
+		{CupcakeID: 1, Quantidade: 8, Subtotal: 66.67, KitID: &kitID, KitLinha: 2, KitQuantidade: 2},
+		{CupcakeID: 2, Quantidade: 4, Subtotal: 33.33, KitID: &kitID, KitLinha: 2, KitQuantidade: 2},
+	}
+
+	linhas := LinhasDoPedido(itens)
+	if len(linhas) != 2 {
+		t.Fatalf("Esperado 2 linhas, obtido %d", len(linhas))
+	}
+
+	avulso := linhas[0]
+	if avulso.Linha.Quantidade != 2 || avulso.PrecoPago != 12 || len(avulso.Linha.Opcoes) != 1 || avulso.Linha.Textos[4] != "Parabéns" {
+		t.Errorf("Linha avulsa inesperada: %+v", avulso)
+	}
+	if avulso.Linha.Chave() != (LinhaCarrinho{CupcakeID: 1, Opcoes: []uint{7}, Textos: map[uint]string{4: "Parabéns"}}).Chave() {
+		t.Error("A linha avulsa deveria cair na mesma chave das escolhas originais")
+	}
+
+	kit := linhas[1]
+	if kit.Linha.KitID != 5 || kit.Linha.Quantidade != 2 || kit.PrecoPago != 50 {
+		t.Errorf("Linha de kit inesperada: %+v", kit)
+	}
+	if kit.Linha.Caixa[1] != 4 || kit.Linha.Caixa[2] != 2 {
+		t.Errorf("Composição por kit = %v; esperado 4 Red Velvet e 2 Limão", kit.Linha.Caixa)
+	}
+}
+
+func TestConferirRecompra(t *testing.T) {
+	excluido := model.Cupcake{ID: 4, Nome: "Coco", Preco: 9, Disponivel: true, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}
+	maisCaro := redVelvet
+	maisCaro.Preco = 11
+	cupcakes := map[uint]model.Cupcake{1: maisCaro, 2: limao, 3: esgotado, 4: excluido}
+	kits := map[uint]model.Kit{5: {ID: 5, Nome: "Caixa com 6", Preco: 50, Disponivel: true, Itens: []model.KitItem{
+		{CupcakeID: 1, Cupcake: maisCaro, Quantidade: 4},
+		{CupcakeID: 2, Cupcake: limao, Quantidade: 2},
+	}}}
+
+	linhas := []LinhaRecompra{
+		{Linha: LinhaCarrinho{CupcakeID: 1, Quantidade: 2}, PrecoPago: 10},
+		{Linha: LinhaCarrinho{CupcakeID: 2, Quantidade: 1}, PrecoPago: 8},
+		{Linha: LinhaCarrinho{CupcakeID: 3, Quantidade: 1}, PrecoPago: 12},
+		{Linha: LinhaCarrinho{CupcakeID: 4, Quantidade: 1}, PrecoPago: 9},
+		{Linha: LinhaCarrinho{CupcakeID: 9, Quantidade: 1}, PrecoPago: 5},
+		{Linha: LinhaCarrinho{CupcakeID: 2, Opcoes: []uint{99}, Quantidade: 1}, PrecoPago: 9},
+		{Linha: LinhaCarrinho{KitID: 5, Caixa: map[uint]int{1: 4, 2: 2}, Quantidade: 1}, PrecoPago: 50},
+	}
+	resultado := ConferirRecompra(linhas, cupcakes, kits)
+
+	if resultado.Adicionadas != 3 || resultado.Carrinho.TotalItens() != 4 {
+		t.Errorf("Adicionadas = %d (%d itens); esperado 3 linhas e 4 itens", resultado.Adicionadas, resultado.Carrinho.TotalItens())
+	}
+	if _, ok := resultado.Carrinho["k5"]; !ok {
+		t.Error("Kit fixo deveria voltar ao carrinho sem a composição em Caixa")
+	}
+	if len(resultado.Removidos) != 4 {
+		t.Errorf("Removidos = %v; esperado indisponível, excluído, apagado e opções inválidas", resultado.Removidos)
+	}
+	if len(resultado.Alterados) != 1 || resultado.Alterados[0] != "Red Velvet: o preço mudou de R$ 10.00 para R$ 11.00." {
+		t.Errorf("Alterados = %v; esperado só o Red Velvet", resultado.Alterados)
+	}
+}
@@ -0,0 +1,40 @@
+// /internal/service/saude.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// MercadoPagoAPIURL é a API conferida pelo /readyz quando READYZ_MERCADO_PAGO está ligado.
+const MercadoPagoAPIURL = "https://api.mercadopago.com"
+
+// PingPostgres confere se o banco responde.
+func PingPostgres(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PingHTTP confere se um serviço externo responde. Qualquer resposta abaixo de 500 conta
+// como disponível: a raiz da API pode pedir autenticação ou não existir.
+func PingHTTP(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s respondeu %d", url, resp.StatusCode)
+	}
+	return nil
+}
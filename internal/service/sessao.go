@@ -0,0 +1,386 @@
+// /internal/service/sessao.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/config"
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+// ChaveUsuarioSessao é o valor da sessão com o ID do usuário logado.
+const ChaveUsuarioSessao = "userID"
+
+const (
+	// validadeSessaoNavegador vale para sessões sem MaxAge (o cookie some ao fechar o
+	// navegador, mas o registro precisa vencer em algum momento).
+	validadeSessaoNavegador = 24 * time.Hour
+	// intervaloLimpezaSessoes é de quanto em quanto tempo as sessões vencidas saem.
+	intervaloLimpezaSessoes = time.Hour
+)
+
+// ErrSessaoNaoEncontrada indica uma sessão que não existe mais ou já venceu.
+var ErrSessaoNaoEncontrada = errors.New("sessão não encontrada")
+
+// ArmazemSessoes guarda os dados das sessões no servidor. Os IDs recebidos já são o
+// hash do ID do cookie, para um vazamento do armazenamento não dar acesso às contas.
+type ArmazemSessoes interface {
+	// Ler devolve ErrSessaoNaoEncontrada se a sessão não existe ou venceu
+	Ler(ctx context.Context, id string) ([]byte, error)
+	// Gravar cria a sessão (nova) ou atualiza uma existente; usuarioID 0 é uma sessão sem
+	// login. Atualizar uma sessão que já foi apagada devolve ErrSessaoNaoEncontrada, sem
+	// recriá-la: um logout em outro dispositivo não é desfeito por uma requisição em curso.
+	Gravar(ctx context.Context, id string, usuarioID uint, dados []byte, expira time.Time, nova bool) error
+	Apagar(ctx context.Context, id string) error
+	// ApagarDoUsuario encerra todas as sessões de uma conta
+	ApagarDoUsuario(ctx context.Context, usuarioID uint) error
+	// LimparVencidas apaga as sessões vencidas, se o armazenamento não faz isso sozinho
+	LimparVencidas(ctx context.Context) (int64, error)
+}
+
+// NewArmazemSessoes escolhe o armazenamento pelo SESSION_STORE: "redis" usa o REDIS_URL;
+// "postgres" usa a tabela de sessões do banco da aplicação.
+func NewArmazemSessoes(cfg config.Sessao, db *gorm.DB) (ArmazemSessoes, error) {
+	if cfg.Store == "redis" {
+		return NewArmazemSessoesRedis(cfg.RedisURL)
+	}
+	return NewArmazemSessoesPostgres(db), nil
+}
+
+// --- Store do gorilla/sessions ---
+
+// SessaoStore implementa sessions.Store com os dados no servidor: o cookie leva só o ID
+// da sessão, assinado e criptografado com chaves derivadas de SESSION_SECRET.
+type SessaoStore struct {
+	Armazem ArmazemSessoes
+	Options *sessions.Options
+	codecs  []securecookie.Codec
+}
+
+// NewSessaoStore cria o store. O segredo atual assina os cookies novos; os anteriores
+// ainda são aceitos na leitura, para trocar o segredo sem derrubar quem está logado.
+func NewSessaoStore(armazem ArmazemSessoes, opcoes sessions.Options, segredo string, anteriores ...string) *SessaoStore {
+	var pares [][]byte
+	for _, s := range append([]string{segredo}, anteriores...) {
+		assinatura, criptografia := chavesSessao(s)
+		pares = append(pares, assinatura, criptografia)
+	}
+	codecs := securecookie.CodecsFromPairs(pares...)
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(opcoes.MaxAge)
+		}
+	}
+	return &SessaoStore{Armazem: armazem, Options: &opcoes, codecs: codecs}
+}
+
+// chavesSessao deriva do segredo a chave de assinatura (HMAC) e a de criptografia (AES-256).
+func chavesSessao(segredo string) (assinatura, criptografia []byte) {
+	derivar := func(finalidade string) []byte {
+		mac := hmac.New(sha256.New, []byte(segredo))
+		mac.Write([]byte("meu-cupcake/sessao/" + finalidade))
+		return mac.Sum(nil)
+	}
+	return derivar("assinatura"), derivar("criptografia")
+}
+
+// Get devolve a sessão da requisição, lida uma vez só por requisição.
+func (s *SessaoStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New carrega a sessão do cookie. Cookie inválido (adulterado, de um segredo retirado ou
+// de uma sessão encerrada) resulta em uma sessão nova, sem erro.
+func (s *SessaoStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opcoes := *s.Options
+	session.Options = &opcoes
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+	return session, s.ler(r.Context(), session, id)
+}
+
+// Carregar lê pelo ID uma sessão que não vem de cookie (a do cliente da API com token).
+// Se ela não existe, volta nova com esse ID, para o Gravar criá-la.
+func (s *SessaoStore) Carregar(ctx context.Context, name, id string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opcoes := *s.Options
+	session.Options = &opcoes
+	session.IsNew = true
+	if err := s.ler(ctx, session, id); err != nil {
+		return session, err
+	}
+	session.ID = id
+	return session, nil
+}
+
+// ler preenche a sessão com os dados guardados para o ID; sessão inexistente não é erro.
+func (s *SessaoStore) ler(ctx context.Context, session *sessions.Session, id string) error {
+	dados, err := s.Armazem.Ler(ctx, HashToken(id))
+	if errors.Is(err, ErrSessaoNaoEncontrada) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ler a sessão: %w", err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(dados)).Decode(&session.Values); err != nil {
+		return fmt.Errorf("decodificar a sessão: %w", err)
+	}
+	session.ID = id
+	session.IsNew = false
+	return nil
+}
+
+// Save grava a sessão e renova o cookie. MaxAge negativo apaga a sessão no servidor, o
+// que invalida o cookie mesmo que alguém tenha copiado.
+func (s *SessaoStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Armazem.Apagar(ctx, HashToken(session.ID)); err != nil {
+				return fmt.Errorf("apagar a sessão: %w", err)
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		// Visitante sem nada guardado: nem registro nem cookie
+		if len(session.Values) == 0 {
+			return nil
+		}
+		id, err := novoToken()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+		session.IsNew = true
+	}
+	err := s.Gravar(ctx, session)
+	if errors.Is(err, ErrSessaoNaoEncontrada) {
+		// A sessão foi encerrada durante a requisição (logout em outro dispositivo, acesso
+		// redefinido): o cookie sai, como no logout
+		opcoes := *session.Options
+		opcoes.MaxAge = -1
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", &opcoes))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	valor, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), valor, session.Options))
+	return nil
+}
+
+// Gravar grava os dados da sessão no servidor, sem cookie. A sessão precisa ter ID (ver
+// Carregar); Save cuida das sessões do navegador. Se a sessão já existia e foi apagada
+// nesse meio-tempo, devolve ErrSessaoNaoEncontrada.
+func (s *SessaoStore) Gravar(ctx context.Context, session *sessions.Session) error {
+	if session.ID == "" {
+		return errors.New("gravar a sessão: sessão sem ID")
+	}
+	var dados bytes.Buffer
+	if err := gob.NewEncoder(&dados).Encode(session.Values); err != nil {
+		return fmt.Errorf("codificar a sessão: %w", err)
+	}
+	validade := validadeSessaoNavegador
+	if session.Options.MaxAge > 0 {
+		validade = time.Duration(session.Options.MaxAge) * time.Second
+	}
+	usuarioID, _ := session.Values[ChaveUsuarioSessao].(uint)
+	if err := s.Armazem.Gravar(ctx, HashToken(session.ID), usuarioID, dados.Bytes(), time.Now().Add(validade), session.IsNew); err != nil {
+		return fmt.Errorf("gravar a sessão: %w", err)
+	}
+	session.IsNew = false
+	return nil
+}
+
+// Descartar apaga uma sessão pelo ID (ver Carregar).
+func (s *SessaoStore) Descartar(ctx context.Context, id string) error {
+	return s.Armazem.Apagar(ctx, HashToken(id))
+}
+
+// Regenerar descarta o ID atual da sessão mantendo os dados; o próximo Save cria outro.
+// Usado no login, para que um ID conhecido antes dele (fixação de sessão) não ganhe o acesso.
+func (s *SessaoStore) Regenerar(r *http.Request, session *sessions.Session) error {
+	if session.ID != "" {
+		if err := s.Armazem.Apagar(r.Context(), HashToken(session.ID)); err != nil {
+			return fmt.Errorf("apagar a sessão anterior: %w", err)
+		}
+	}
+	session.ID = ""
+	return nil
+}
+
+// EncerrarDoUsuario desloga a conta em todos os dispositivos.
+func (s *SessaoStore) EncerrarDoUsuario(ctx context.Context, usuarioID uint) error {
+	return s.Armazem.ApagarDoUsuario(ctx, usuarioID)
+}
+
+// ManterLimpezaSessoes apaga as sessões vencidas agora e a cada hora, até ctx ser cancelado.
+func ManterLimpezaSessoes(ctx context.Context, armazem ArmazemSessoes) {
+	ticker := time.NewTicker(intervaloLimpezaSessoes)
+	defer ticker.Stop()
+	for {
+		apagadas, err := armazem.LimparVencidas(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Erro ao apagar as sessões vencidas", "erro", err)
+		} else if apagadas > 0 {
+			slog.DebugContext(ctx, "Sessões vencidas apagadas", "apagadas", apagadas)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- Postgres ---
+
+// ArmazemSessoesPostgres guarda as sessões na tabela de model.Sessao.
+type ArmazemSessoesPostgres struct {
+	db *gorm.DB
+}
+
+func NewArmazemSessoesPostgres(db *gorm.DB) *ArmazemSessoesPostgres {
+	return &ArmazemSessoesPostgres{db: db}
+}
+
+func (a *ArmazemSessoesPostgres) Ler(ctx context.Context, id string) ([]byte, error) {
+	var sessao model.Sessao
+	err := a.db.WithContext(ctx).Where("id = ? AND expira_em > ?", id, time.Now()).First(&sessao).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessaoNaoEncontrada
+	}
+	return sessao.Dados, err
+}
+
+func (a *ArmazemSessoesPostgres) Gravar(ctx context.Context, id string, usuarioID uint, dados []byte, expira time.Time, nova bool) error {
+	sessao := model.Sessao{ID: id, Dados: dados, ExpiraEm: expira}
+	if usuarioID != 0 {
+		sessao.UsuarioID = &usuarioID
+	}
+	if nova {
+		return a.db.WithContext(ctx).Create(&sessao).Error
+	}
+	res := a.db.WithContext(ctx).Model(&model.Sessao{}).Where("id = ?", id).
+		Select("usuario_id", "dados", "expira_em").Updates(&sessao)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessaoNaoEncontrada
+	}
+	return nil
+}
+
+func (a *ArmazemSessoesPostgres) Apagar(ctx context.Context, id string) error {
+	return a.db.WithContext(ctx).Delete(&model.Sessao{}, "id = ?", id).Error
+}
+
+func (a *ArmazemSessoesPostgres) ApagarDoUsuario(ctx context.Context, usuarioID uint) error {
+	return a.db.WithContext(ctx).Where("usuario_id = ?", usuarioID).Delete(&model.Sessao{}).Error
+}
+
+func (a *ArmazemSessoesPostgres) LimparVencidas(ctx context.Context) (int64, error) {
+	res := a.db.WithContext(ctx).Where("expira_em <= ?", time.Now()).Delete(&model.Sessao{})
+	return res.RowsAffected, res.Error
+}
+
+// --- Memória ---
+
+// ArmazemSessoesMemoria guarda as sessões no processo. Serve aos testes: as sessões se
+// perdem a cada reinício e não são vistas por outras instâncias.
+type ArmazemSessoesMemoria struct {
+	mu      sync.Mutex
+	sessoes map[string]sessaoMemoria
+}
+
+type sessaoMemoria struct {
+	usuarioID uint
+	dados     []byte
+	expira    time.Time
+}
+
+func NewArmazemSessoesMemoria() *ArmazemSessoesMemoria {
+	return &ArmazemSessoesMemoria{sessoes: make(map[string]sessaoMemoria)}
+}
+
+func (a *ArmazemSessoesMemoria) Ler(_ context.Context, id string) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessoes[id]
+	if !ok || !time.Now().Before(s.expira) {
+		return nil, ErrSessaoNaoEncontrada
+	}
+	return bytes.Clone(s.dados), nil
+}
+
+func (a *ArmazemSessoesMemoria) Gravar(_ context.Context, id string, usuarioID uint, dados []byte, expira time.Time, nova bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, existe := a.sessoes[id]; !existe && !nova {
+		return ErrSessaoNaoEncontrada
+	}
+	a.sessoes[id] = sessaoMemoria{usuarioID: usuarioID, dados: bytes.Clone(dados), expira: expira}
+	return nil
+}
+
+func (a *ArmazemSessoesMemoria) Apagar(_ context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessoes, id)
+	return nil
+}
+
+func (a *ArmazemSessoesMemoria) ApagarDoUsuario(_ context.Context, usuarioID uint) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, s := range a.sessoes {
+		if s.usuarioID == usuarioID {
+			delete(a.sessoes, id)
+		}
+	}
+	return nil
+}
+
+func (a *ArmazemSessoesMemoria) LimparVencidas(context.Context) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var apagadas int64
+	agora := time.Now()
+	for id, s := range a.sessoes {
+		if !agora.Before(s.expira) {
+			delete(a.sessoes, id)
+			apagadas++
+		}
+	}
+	return apagadas, nil
+}
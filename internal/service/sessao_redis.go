@@ -0,0 +1,127 @@
+// /internal/service/sessao_redis.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tentativasTransacaoRedis é quantas vezes uma transação com WATCH é refeita quando
+// outra gravação muda as chaves observadas.
+const tentativasTransacaoRedis = 5
+
+// ArmazemSessoesRedis guarda cada sessão em uma chave com TTL, mais um conjunto com os
+// IDs de cada usuário para o "sair de todos os dispositivos".
+type ArmazemSessoesRedis struct {
+	redis *redis.Client
+}
+
+// NewArmazemSessoesRedis conecta pelo REDIS_URL (redis://[:senha@]host:6379/0; rediss:// usa TLS).
+func NewArmazemSessoesRedis(rawURL string) (*ArmazemSessoesRedis, error) {
+	opcoes, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_URL deve ser redis://[usuario:senha@]host:porta/banco: %w", err)
+	}
+	return &ArmazemSessoesRedis{redis: redis.NewClient(opcoes)}, nil
+}
+
+// Ping confere se o Redis responde, para o /readyz.
+func (a *ArmazemSessoesRedis) Ping(ctx context.Context) error {
+	return a.redis.Ping(ctx).Err()
+}
+
+func chaveSessaoRedis(id string) string { return "sessao:" + id }
+
+func chaveUsuarioRedis(usuarioID uint) string {
+	return "sessoes_usuario:" + strconv.FormatUint(uint64(usuarioID), 10)
+}
+
+func (a *ArmazemSessoesRedis) Ler(ctx context.Context, id string) ([]byte, error) {
+	dados, err := a.redis.Get(ctx, chaveSessaoRedis(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessaoNaoEncontrada
+	}
+	return dados, err
+}
+
+// scriptGravarSessaoRedis grava a sessão e a põe no conjunto do usuário de uma vez só.
+// O SET leva NX (sessão nova) ou XX (só atualiza se ainda existe); se ele não grava, o
+// conjunto fica como está. O TTL do conjunto só aumenta: ele precisa durar tanto quanto
+// a sessão mais longa do usuário, não a última gravada.
+var scriptGravarSessaoRedis = redis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2], ARGV[3]) then
+	return 0
+end
+if KEYS[2] then
+	redis.call('SADD', KEYS[2], ARGV[4])
+	if redis.call('PTTL', KEYS[2]) < tonumber(ARGV[2]) then
+		redis.call('PEXPIRE', KEYS[2], ARGV[2])
+	end
+end
+return 1
+`)
+
+func (a *ArmazemSessoesRedis) Gravar(ctx context.Context, id string, usuarioID uint, dados []byte, expira time.Time, nova bool) error {
+	ttl := max(time.Until(expira).Milliseconds(), 1)
+	condicao := "XX"
+	if nova {
+		condicao = "NX"
+	}
+	chaves := []string{chaveSessaoRedis(id)}
+	if usuarioID != 0 {
+		chaves = append(chaves, chaveUsuarioRedis(usuarioID))
+	}
+	gravou, err := scriptGravarSessaoRedis.Run(ctx, a.redis, chaves, dados, ttl, condicao, id).Bool()
+	if err != nil {
+		return err
+	}
+	if !gravou {
+		if nova {
+			return errors.New("redis: já existe uma sessão com esse ID")
+		}
+		return ErrSessaoNaoEncontrada
+	}
+	return nil
+}
+
+func (a *ArmazemSessoesRedis) Apagar(ctx context.Context, id string) error {
+	return a.redis.Del(ctx, chaveSessaoRedis(id)).Err()
+}
+
+// ApagarDoUsuario apaga as sessões do conjunto e o próprio conjunto em uma transação; se
+// uma sessão entra no conjunto entre a leitura e o DEL, a transação é refeita.
+func (a *ArmazemSessoesRedis) ApagarDoUsuario(ctx context.Context, usuarioID uint) error {
+	conjunto := chaveUsuarioRedis(usuarioID)
+	apagar := func(tx *redis.Tx) error {
+		ids, err := tx.SMembers(ctx, conjunto).Result()
+		if err != nil {
+			return err
+		}
+		chaves := []string{conjunto}
+		for _, id := range ids {
+			chaves = append(chaves, chaveSessaoRedis(id))
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, chaves...)
+			return nil
+		})
+		return err
+	}
+	for range tentativasTransacaoRedis {
+		err := a.redis.Watch(ctx, apagar, conjunto)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("apagar as sessões do usuário %d: %w", usuarioID, redis.TxFailedErr)
+}
+
+// LimparVencidas não faz nada: o Redis apaga as chaves pelo TTL.
+func (a *ArmazemSessoesRedis) LimparVencidas(context.Context) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,100 @@
+// /internal/service/sessao_redis_test.go
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestArmazemSessoesRedis(t *testing.T) {
+	servidor := miniredis.RunT(t)
+	servidor.RequireAuth("s3nh4")
+	armazem, err := NewArmazemSessoesRedis("redis://:s3nh4@" + servidor.Addr() + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	expira := time.Now().Add(time.Hour)
+
+	if err := armazem.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if _, err := armazem.Ler(ctx, "nao-existe"); !errors.Is(err, ErrSessaoNaoEncontrada) {
+		t.Errorf("Ler de sessão ausente = %v; esperado ErrSessaoNaoEncontrada", err)
+	}
+
+	for _, s := range []struct {
+		id      string
+		usuario uint
+	}{{"a", 7}, {"b", 7}, {"c", 8}, {"anonima", 0}} {
+		if err := armazem.Gravar(ctx, s.id, s.usuario, []byte("dados "+s.id+"\r\n"), expira, true); err != nil {
+			t.Fatalf("Gravar %s: %v", s.id, err)
+		}
+	}
+	dados, err := armazem.Ler(ctx, "a")
+	if err != nil || string(dados) != "dados a\r\n" {
+		t.Errorf("Ler(a) = %q, %v", dados, err)
+	}
+	servidor.Select(2)
+	if !servidor.Exists("sessao:a") || servidor.TTL("sessao:a") <= 0 {
+		t.Error("A sessão deveria ficar no banco 2 do REDIS_URL, com TTL")
+	}
+
+	// Uma sessão mais curta do mesmo usuário não encurta o conjunto dele
+	if err := armazem.Gravar(ctx, "b", 7, []byte("dados b"), time.Now().Add(time.Minute), false); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := servidor.TTL("sessoes_usuario:7"); ttl < 59*time.Minute {
+		t.Errorf("TTL do conjunto do usuário = %v; esperado o da sessão mais longa (1h)", ttl)
+	}
+
+	if err := armazem.ApagarDoUsuario(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	for id, existe := range map[string]bool{"a": false, "b": false, "c": true, "anonima": true} {
+		_, err := armazem.Ler(ctx, id)
+		if (err == nil) != existe {
+			t.Errorf("Sessão %s: erro = %v; esperado existir = %v", id, err, existe)
+		}
+	}
+
+	if err := armazem.Apagar(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := armazem.Ler(ctx, "c"); !errors.Is(err, ErrSessaoNaoEncontrada) {
+		t.Errorf("Sessão apagada ainda foi lida: %v", err)
+	}
+	if err := armazem.Gravar(ctx, "c", 8, []byte("de volta"), expira, false); !errors.Is(err, ErrSessaoNaoEncontrada) {
+		t.Errorf("Atualizar sessão apagada = %v; esperado ErrSessaoNaoEncontrada", err)
+	}
+	if _, err := armazem.Ler(ctx, "c"); err == nil {
+		t.Error("A sessão apagada não deveria ser recriada ao atualizar")
+	}
+}
+
+func TestArmazemSessoesRedisErros(t *testing.T) {
+	servidor := miniredis.RunT(t)
+	servidor.RequireAuth("s3nh4")
+
+	t.Run("Senha errada", func(t *testing.T) {
+		armazem, err := NewArmazemSessoesRedis("redis://:errada@" + servidor.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := armazem.Ping(t.Context()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Errorf("Ping com senha errada = %v; esperado WRONGPASS", err)
+		}
+	})
+
+	t.Run("URL inválida", func(t *testing.T) {
+		for _, u := range []string{"http://localhost", "redis://localhost/abc"} {
+			if _, err := NewArmazemSessoesRedis(u); err == nil {
+				t.Errorf("NewArmazemSessoesRedis(%q) deveria falhar", u)
+			}
+		}
+	})
+}
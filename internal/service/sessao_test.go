@@ -0,0 +1,227 @@
+// /internal/service/sessao_test.go
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+const nomeSessaoTeste = "meu-cupcake-session"
+
+// salvarSessao grava os valores em uma sessão (nova ou a do cookie) e devolve o cookie.
+func salvarSessao(t *testing.T, store *SessaoStore, cookie *http.Cookie, valores map[interface{}]interface{}) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	session, err := store.New(req, nomeSessaoTeste)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range valores {
+		session.Values[k] = v
+	}
+	if err := session.Save(req, w); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+	return cookies[0]
+}
+
+// lerSessao carrega a sessão do cookie; nil se ela não existe mais.
+func lerSessao(t *testing.T, store *SessaoStore, cookie *http.Cookie) *sessions.Session {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	session, err := store.New(req, nomeSessaoTeste)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.IsNew {
+		return nil
+	}
+	return session
+}
+
+func novoStoreTeste(armazem ArmazemSessoes, segredo string, anteriores ...string) *SessaoStore {
+	return NewSessaoStore(armazem, sessions.Options{Path: "/", MaxAge: 3600, HttpOnly: true}, segredo, anteriores...)
+}
+
+func TestSessaoStore(t *testing.T) {
+	t.Run("Dados ficam no servidor e o cookie leva só o ID", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		cookie := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(7), "userName": "Maria"})
+		if cookie == nil || !cookie.HttpOnly || cookie.MaxAge != 3600 {
+			t.Fatalf("Cookie inesperado: %+v", cookie)
+		}
+		if strings.Contains(cookie.Value, "Maria") {
+			t.Error("O cookie não deveria levar os dados da sessão")
+		}
+		session := lerSessao(t, store, cookie)
+		if session == nil || session.Values["userName"] != "Maria" || session.Values[ChaveUsuarioSessao] != uint(7) {
+			t.Fatalf("Sessão lida = %+v", session)
+		}
+	})
+
+	t.Run("Visitante sem dados não cria sessão", func(t *testing.T) {
+		armazem := NewArmazemSessoesMemoria()
+		if cookie := salvarSessao(t, novoStoreTeste(armazem, "segredo"), nil, nil); cookie != nil {
+			t.Errorf("Não deveria haver cookie: %+v", cookie)
+		}
+		if len(armazem.sessoes) != 0 {
+			t.Errorf("Sessões gravadas = %d; esperado 0", len(armazem.sessoes))
+		}
+	})
+
+	t.Run("Cookie adulterado vira sessão nova", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		cookie := salvarSessao(t, store, nil, map[interface{}]interface{}{"x": 1})
+		cookie.Value = strings.ToUpper(cookie.Value)
+		if lerSessao(t, store, cookie) != nil {
+			t.Error("Cookie adulterado não deveria carregar a sessão")
+		}
+	})
+
+	t.Run("Segredo anterior continua valendo durante a troca", func(t *testing.T) {
+		armazem := NewArmazemSessoesMemoria()
+		cookie := salvarSessao(t, novoStoreTeste(armazem, "antigo"), nil, map[interface{}]interface{}{"x": 1})
+
+		if lerSessao(t, novoStoreTeste(armazem, "novo", "antigo"), cookie) == nil {
+			t.Error("O cookie do segredo anterior deveria ser aceito")
+		}
+		if lerSessao(t, novoStoreTeste(armazem, "novo"), cookie) != nil {
+			t.Error("Sem o segredo anterior na lista, o cookie antigo não deveria valer")
+		}
+	})
+
+	t.Run("Regenerar troca o ID e invalida o cookie anterior", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		antes := salvarSessao(t, store, nil, map[interface{}]interface{}{"carrinho": "itens"})
+
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.AddCookie(antes)
+		w := httptest.NewRecorder()
+		session, _ := store.New(req, nomeSessaoTeste)
+		idAnterior := session.ID
+		if err := store.Regenerar(req, session); err != nil {
+			t.Fatal(err)
+		}
+		session.Values[ChaveUsuarioSessao] = uint(7)
+		if err := session.Save(req, w); err != nil {
+			t.Fatal(err)
+		}
+
+		if session.ID == idAnterior {
+			t.Error("O ID deveria mudar no login")
+		}
+		if lerSessao(t, store, antes) != nil {
+			t.Error("O cookie de antes do login não deveria valer mais")
+		}
+		depois := lerSessao(t, store, w.Result().Cookies()[0])
+		if depois == nil || depois.Values["carrinho"] != "itens" {
+			t.Errorf("Os dados deveriam seguir na sessão nova: %+v", depois)
+		}
+	})
+
+	t.Run("MaxAge negativo apaga a sessão no servidor", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		cookie := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(7)})
+
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		session, _ := store.New(req, nomeSessaoTeste)
+		session.Options.MaxAge = -1
+		if err := session.Save(req, w); err != nil {
+			t.Fatal(err)
+		}
+		if c := w.Result().Cookies(); len(c) == 0 || c[0].MaxAge >= 0 {
+			t.Errorf("O cookie deveria ser expirado: %+v", c)
+		}
+		if lerSessao(t, store, cookie) != nil {
+			t.Error("Uma cópia do cookie não deveria valer depois do logout")
+		}
+	})
+
+	t.Run("EncerrarDoUsuario derruba todas as sessões da conta", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		celular := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(7)})
+		notebook := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(7)})
+		outro := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(8)})
+
+		if err := store.EncerrarDoUsuario(t.Context(), 7); err != nil {
+			t.Fatal(err)
+		}
+		if lerSessao(t, store, celular) != nil || lerSessao(t, store, notebook) != nil {
+			t.Error("As sessões do usuário 7 deveriam ter sido encerradas")
+		}
+		if lerSessao(t, store, outro) == nil {
+			t.Error("A sessão de outro usuário não deveria ser afetada")
+		}
+	})
+	t.Run("Sessão encerrada durante a requisição não é recriada ao salvar", func(t *testing.T) {
+		armazem := NewArmazemSessoesMemoria()
+		store := novoStoreTeste(armazem, "segredo")
+		cookie := salvarSessao(t, store, nil, map[interface{}]interface{}{ChaveUsuarioSessao: uint(7)})
+
+		req := httptest.NewRequest(http.MethodPost, "/carrinho", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		session, _ := store.New(req, nomeSessaoTeste)
+		if err := store.EncerrarDoUsuario(t.Context(), 7); err != nil {
+			t.Fatal(err)
+		}
+		session.Values["carrinho"] = "itens"
+		if err := session.Save(req, w); err != nil {
+			t.Fatal(err)
+		}
+		if len(armazem.sessoes) != 0 {
+			t.Errorf("Sessões gravadas = %d; esperado 0", len(armazem.sessoes))
+		}
+		if c := w.Result().Cookies(); len(c) == 0 || c[0].MaxAge >= 0 {
+			t.Errorf("O cookie deveria ser expirado: %+v", c)
+		}
+	})
+
+	t.Run("Carregar e Gravar guardam uma sessão sem cookie", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		session, err := store.Carregar(t.Context(), nomeSessaoTeste, "api-token:3")
+		if err != nil || !session.IsNew || session.ID != "api-token:3" {
+			t.Fatalf("Sessão nova = %+v, erro %v", session, err)
+		}
+		session.Values[ChaveUsuarioSessao] = uint(7)
+		session.Values["carrinho"] = "2 cupcakes"
+		if err := store.Gravar(t.Context(), session); err != nil {
+			t.Fatal(err)
+		}
+
+		session, err = store.Carregar(t.Context(), nomeSessaoTeste, "api-token:3")
+		if err != nil || session.IsNew || session.Values["carrinho"] != "2 cupcakes" {
+			t.Fatalf("Sessão lida = %+v, erro %v", session, err)
+		}
+		if err := store.EncerrarDoUsuario(t.Context(), 7); err != nil {
+			t.Fatal(err)
+		}
+		if session, _ := store.Carregar(t.Context(), nomeSessaoTeste, "api-token:3"); !session.IsNew {
+			t.Error("A sessão deveria ter sido encerrada com as do usuário")
+		}
+	})
+
+	t.Run("Gravar exige o ID", func(t *testing.T) {
+		store := novoStoreTeste(NewArmazemSessoesMemoria(), "segredo")
+		session := sessions.NewSession(store, nomeSessaoTeste)
+		session.Options = store.Options
+		if err := store.Gravar(t.Context(), session); err == nil {
+			t.Error("Esperado erro ao gravar sessão sem ID")
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// /internal/service/tokens_api.go
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// PrefixoTokenAPI marca os tokens de acesso pessoal, para que sejam reconhecidos em
+	// logs ou em um repositório de código por engano.
+	PrefixoTokenAPI = "mc_"
+	// ValidadeTokenAPI é por quanto tempo um token de API vale depois de criado.
+	ValidadeTokenAPI = 90 * 24 * time.Hour
+	// MaxTokensAPI é quantos tokens válidos uma conta pode ter ao mesmo tempo.
+	MaxTokensAPI = 10
+	// intervaloUltimoUso evita uma escrita no banco a cada requisição com o mesmo token.
+	intervaloUltimoUso = 5 * time.Minute
+	// tamanhoNomeTokenAPI é o limite do nome dado ao token pelo cliente.
+	tamanhoNomeTokenAPI = 100
+	// tamanhoPrefixoVisivel é quanto do token fica gravado para o cliente reconhecê-lo.
+	tamanhoPrefixoVisivel = len(PrefixoTokenAPI) + 8
+)
+
+var (
+	ErrTokenAPIInvalido = errors.New("token de API inválido ou expirado")
+	ErrLimiteTokensAPI  = errors.New("limite de tokens de API atingido")
+)
+
+// formatoTokenAPI confere o formato antes de ir ao banco: prefixo e 64 dígitos hex.
+func formatoTokenAPI(token string) bool {
+	hex, ok := strings.CutPrefix(token, PrefixoTokenAPI)
+	if !ok || len(hex) != 64 {
+		return false
+	}
+	for _, c := range hex {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// novoTokenAPI gera o token e o registro a gravar (só com o hash e o prefixo visível).
+func novoTokenAPI(usuarioID uint, nome string, agora time.Time) (string, model.TokenAPI, error) {
+	aleatorio, err := novoToken()
+	if err != nil {
+		return "", model.TokenAPI{}, err
+	}
+	token := PrefixoTokenAPI + aleatorio
+	nome = strings.TrimSpace(nome)
+	if nome == "" {
+		nome = "API"
+	}
+	for utf8.RuneCountInString(nome) > tamanhoNomeTokenAPI {
+		_, tam := utf8.DecodeLastRuneInString(nome)
+		nome = nome[:len(nome)-tam]
+	}
+	return token, model.TokenAPI{
+		UsuarioID: usuarioID,
+		Nome:      nome,
+		Hash:      HashToken(token),
+		Prefixo:   token[:tamanhoPrefixoVisivel],
+		ExpiraEm:  agora.Add(ValidadeTokenAPI),
+	}, nil
+}
+
+// CriarTokenAPI cria um token de acesso pessoal e devolve o valor, que não é gravado e
+// só pode ser mostrado agora.
+func CriarTokenAPI(db *gorm.DB, usuarioID uint, nome string, agora time.Time) (string, model.TokenAPI, error) {
+	token, registro, err := novoTokenAPI(usuarioID, nome, agora)
+	if err != nil {
+		return "", registro, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var ativos int64
+		if err := tx.Model(&model.TokenAPI{}).Where("usuario_id = ? AND expira_em > ?", usuarioID, agora).Count(&ativos).Error; err != nil {
+			return err
+		}
+		if ativos >= MaxTokensAPI {
+			return ErrLimiteTokensAPI
+		}
+		return tx.Create(&registro).Error
+	})
+	if err != nil {
+		return "", registro, err
+	}
+	return token, registro, nil
+}
+
+// BuscarTokenAPI devolve o registro de um token válido e anota o último uso (no máximo
+// uma escrita a cada intervaloUltimoUso).
+func BuscarTokenAPI(db *gorm.DB, token string, agora time.Time) (model.TokenAPI, error) {
+	var registro model.TokenAPI
+	if !formatoTokenAPI(token) {
+		return registro, ErrTokenAPIInvalido
+	}
+	err := db.Where("hash = ? AND expira_em > ?", HashToken(token), agora).First(&registro).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return registro, ErrTokenAPIInvalido
+	}
+	if err != nil {
+		return registro, err
+	}
+	if registro.UltimoUsoEm == nil || agora.Sub(*registro.UltimoUsoEm) >= intervaloUltimoUso {
+		registro.UltimoUsoEm = &agora
+		// O uso não impede a requisição se a escrita falhar
+		db.Model(&registro).UpdateColumn("ultimo_uso_em", agora)
+	}
+	return registro, nil
+}
+
+// RevogarTokenAPI apaga um token da conta.
+func RevogarTokenAPI(db *gorm.DB, usuarioID, tokenID uint) error {
+	return db.Where("id = ? AND usuario_id = ?", tokenID, usuarioID).Delete(&model.TokenAPI{}).Error
+}
+
+// RevogarTokensAPIDoUsuario apaga todos os tokens da conta (senha trocada, "sair de
+// todos os dispositivos").
+func RevogarTokensAPIDoUsuario(db *gorm.DB, usuarioID uint) error {
+	return db.Where("usuario_id = ?", usuarioID).Delete(&model.TokenAPI{}).Error
+}
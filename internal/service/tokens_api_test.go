@@ -0,0 +1,71 @@
+// /internal/service/tokens_api_test.go
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAPI(t *testing.T) {
+	agora := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Token novo grava só o hash e o prefixo visível", func(t *testing.T) {
+		token, registro, err := novoTokenAPI(7, "  app do celular ", agora)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !formatoTokenAPI(token) {
+			t.Fatalf("Token %q fora do formato esperado", token)
+		}
+		if registro.Hash != HashToken(token) || strings.Contains(registro.Hash, token) {
+			t.Error("O registro deveria guardar só o hash do token")
+		}
+		if !strings.HasPrefix(token, registro.Prefixo) || len(registro.Prefixo) != len(PrefixoTokenAPI)+8 {
+			t.Errorf("Prefixo = %q; esperado o início do token", registro.Prefixo)
+		}
+		if registro.UsuarioID != 7 || registro.Nome != "app do celular" {
+			t.Errorf("Registro = %+v", registro)
+		}
+		if !registro.ExpiraEm.Equal(agora.Add(ValidadeTokenAPI)) {
+			t.Errorf("ExpiraEm = %v; esperado %v", registro.ExpiraEm, agora.Add(ValidadeTokenAPI))
+		}
+	})
+
+	t.Run("Nome vazio ou comprido demais", func(t *testing.T) {
+		_, registro, _ := novoTokenAPI(1, "   ", agora)
+		if registro.Nome != "API" {
+			t.Errorf("Nome = %q; esperado o padrão", registro.Nome)
+		}
+		_, registro, _ = novoTokenAPI(1, strings.Repeat("ç", 150), agora)
+		if n := len([]rune(registro.Nome)); n != 100 {
+			t.Errorf("Nome com %d caracteres; esperado o corte em 100", n)
+		}
+	})
+
+	t.Run("Tokens diferentes a cada chamada", func(t *testing.T) {
+		a, _, _ := novoTokenAPI(1, "", agora)
+		b, _, _ := novoTokenAPI(1, "", agora)
+		if a == b {
+			t.Error("Dois tokens iguais gerados")
+		}
+	})
+
+	t.Run("Formato", func(t *testing.T) {
+		hex := strings.Repeat("ab12", 16)
+		casos := map[string]bool{
+			PrefixoTokenAPI + hex:                  true,
+			hex:                                    false, // Sem prefixo
+			PrefixoTokenAPI + hex[:63]:             false,
+			PrefixoTokenAPI + strings.ToUpper(hex): false,
+			PrefixoTokenAPI + hex[:62] + "zz":      false,
+			"":                                     false,
+			PrefixoTokenAPI:                        false,
+		}
+		for token, esperado := range casos {
+			if got := formatoTokenAPI(token); got != esperado {
+				t.Errorf("formatoTokenAPI(%q) = %v; esperado %v", token, got, esperado)
+			}
+		}
+	})
+}
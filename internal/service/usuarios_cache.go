@@ -0,0 +1,107 @@
+// /internal/service/usuarios_cache.go
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// ValidadeCacheUsuarios é por quanto tempo um usuário lido do banco é reaproveitado.
+	// Curta de propósito: o que outra instância mudar (ex.: desativar a conta) vale
+	// aqui em no máximo esse tempo; nesta instância, Invalidar vale na hora.
+	ValidadeCacheUsuarios = 30 * time.Second
+	// capacidadeCacheUsuarios limita a memória com muitos usuários ativos ao mesmo tempo.
+	capacidadeCacheUsuarios = 5000
+)
+
+// Usuarios é o cache usado para identificar o usuário logado a cada requisição. As
+// funções deste pacote que alteram contas o invalidam.
+var Usuarios = NewCacheUsuarios(ValidadeCacheUsuarios, capacidadeCacheUsuarios)
+
+// CacheUsuarios guarda os usuários lidos do banco por pouco tempo, por ID.
+type CacheUsuarios struct {
+	validade   time.Duration
+	capacidade int
+	agora      func() time.Time
+
+	mu    sync.Mutex
+	itens map[uint]usuarioEmCache
+}
+
+type usuarioEmCache struct {
+	usuario model.Usuario
+	expira  time.Time
+}
+
+func NewCacheUsuarios(validade time.Duration, capacidade int) *CacheUsuarios {
+	if capacidade <= 0 {
+		capacidade = 1
+	}
+	return &CacheUsuarios{validade: validade, capacidade: capacidade, agora: time.Now, itens: make(map[uint]usuarioEmCache)}
+}
+
+// Buscar devolve o usuário do cache ou, se não estiver lá ou tiver vencido, do banco.
+// Usuário inexistente devolve gorm.ErrRecordNotFound e não entra no cache.
+func (c *CacheUsuarios) Buscar(db *gorm.DB, id uint) (model.Usuario, error) {
+	return c.obter(id, func() (model.Usuario, error) {
+		var usuario model.Usuario
+		err := db.First(&usuario, id).Error
+		return usuario, err
+	})
+}
+
+func (c *CacheUsuarios) obter(id uint, carregar func() (model.Usuario, error)) (model.Usuario, error) {
+	agora := c.agora()
+	c.mu.Lock()
+	item, ok := c.itens[id]
+	c.mu.Unlock()
+	if ok && agora.Before(item.expira) {
+		return item.usuario, nil
+	}
+
+	usuario, err := carregar()
+	if err != nil {
+		return model.Usuario{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, existe := c.itens[id]; !existe && len(c.itens) >= c.capacidade {
+		c.abrirEspaco(agora)
+	}
+	c.itens[id] = usuarioEmCache{usuario: usuario, expira: agora.Add(c.validade)}
+	return usuario, nil
+}
+
+// abrirEspaco tira os itens vencidos e, se não bastar, um qualquer. Chamado com mu travado.
+func (c *CacheUsuarios) abrirEspaco(agora time.Time) {
+	for id, item := range c.itens {
+		if !agora.Before(item.expira) {
+			delete(c.itens, id)
+		}
+	}
+	for id := range c.itens {
+		if len(c.itens) < c.capacidade {
+			break
+		}
+		delete(c.itens, id)
+	}
+}
+
+// Invalidar descarta o usuário do cache; a próxima busca lê o banco.
+func (c *CacheUsuarios) Invalidar(id uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.itens, id)
+}
+
+// Len retorna quantos usuários estão no cache.
+func (c *CacheUsuarios) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.itens)
+}
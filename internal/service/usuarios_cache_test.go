@@ -0,0 +1,69 @@
+// /internal/service/usuarios_cache_test.go
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ericoliveiras/meu-cupcake/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestCacheUsuarios(t *testing.T) {
+	agora := time.Date(2025, 11, 1, 10, 0, 0, 0, time.UTC)
+	novoCache := func(capacidade int) *CacheUsuarios {
+		c := NewCacheUsuarios(30*time.Second, capacidade)
+		c.agora = func() time.Time { return agora }
+		return c
+	}
+	leituras := 0
+	carregar := func(nome string) func() (model.Usuario, error) {
+		return func() (model.Usuario, error) {
+			leituras++
+			return model.Usuario{ID: 1, Nome: nome}, nil
+		}
+	}
+
+	t.Run("Reaproveita até vencer", func(t *testing.T) {
+		c := novoCache(10)
+		leituras = 0
+		c.obter(1, carregar("Ana"))
+		u, _ := c.obter(1, carregar("Ana Maria"))
+		if leituras != 1 || u.Nome != "Ana" {
+			t.Errorf("Leituras = %d, nome = %q; esperado 1 leitura e o valor do cache", leituras, u.Nome)
+		}
+		agora = agora.Add(31 * time.Second)
+		if u, _ := c.obter(1, carregar("Ana Maria")); leituras != 2 || u.Nome != "Ana Maria" {
+			t.Errorf("Depois da validade: leituras = %d, nome = %q", leituras, u.Nome)
+		}
+	})
+
+	t.Run("Invalidar força a leitura", func(t *testing.T) {
+		c := novoCache(10)
+		leituras = 0
+		c.obter(1, carregar("Ana"))
+		c.Invalidar(1)
+		if u, _ := c.obter(1, carregar("Ana Maria")); leituras != 2 || u.Nome != "Ana Maria" {
+			t.Errorf("Leituras = %d, nome = %q; esperado a versão nova", leituras, u.Nome)
+		}
+	})
+
+	t.Run("Erro não entra no cache", func(t *testing.T) {
+		c := novoCache(10)
+		_, err := c.obter(2, func() (model.Usuario, error) { return model.Usuario{}, gorm.ErrRecordNotFound })
+		if !errors.Is(err, gorm.ErrRecordNotFound) || c.Len() != 0 {
+			t.Errorf("err = %v, Len = %d", err, c.Len())
+		}
+	})
+
+	t.Run("Respeita a capacidade", func(t *testing.T) {
+		c := novoCache(2)
+		for id := uint(1); id <= 5; id++ {
+			c.obter(id, func() (model.Usuario, error) { return model.Usuario{ID: id}, nil })
+		}
+		if c.Len() != 2 {
+			t.Errorf("Len = %d; esperado 2", c.Len())
+		}
+	})
+}
@@ -0,0 +1,13 @@
+// /internal/view/view.go
+package view
+
+import "embed"
+
+// Templates são as páginas e partials HTML, compilados junto com o binário: o servidor
+// não depende da pasta internal/view/templates existir no disco.
+//
+//go:embed templates/*.html
+var Templates embed.FS
+
+// PadraoTemplates é o padrão dos arquivos dentro de Templates.
+const PadraoTemplates = "templates/*.html"
@@ -0,0 +1,52 @@
+// Preenche automaticamente os campos de endereço a partir do CEP usando /api/cep/:cep.
+// Uso: cepAutofill({ cep: "#cep", rua: "#rua", bairro: "#bairro", cidade: "#cidade", estado: "#estado", foco: "#numero" })
+function cepAutofill(ids) {
+  const campo = (id) => (id ? document.querySelector(id) : null);
+  const cepInput = campo(ids.cep);
+  const ruaInput = campo(ids.rua);
+  const bairroInput = campo(ids.bairro);
+  const cidadeInput = campo(ids.cidade);
+  const estadoInput = campo(ids.estado);
+  const focoInput = campo(ids.foco);
+
+  if (!cepInput) return;
+
+  function preenche(rua, bairro, cidade, estado) {
+    if (ruaInput) ruaInput.value = rua;
+    if (bairroInput) bairroInput.value = bairro;
+    if (cidadeInput) cidadeInput.value = cidade;
+    if (estadoInput) estadoInput.value = estado;
+  }
+
+  function buscaCEP() {
+    const cep = cepInput.value.replace(/\D/g, ""); // Remove caracteres não numéricos
+
+    if (cep.length !== 8) {
+      if (cep.length > 0) preenche("", "", "", "");
+      return;
+    }
+
+    preenche("Buscando...", "Buscando...", "Buscando...", "...");
+
+    fetch(`/api/cep/${cep}`)
+      .then((response) => response.json())
+      .then((data) => {
+        if (!data.success) {
+          preenche("", "", "", "");
+          alert(data.error || "CEP não encontrado. Por favor, verifique.");
+          return;
+        }
+        const e = data.endereco;
+        cepInput.value = e.cep;
+        preenche(e.rua, e.bairro, e.cidade, e.estado);
+        if (focoInput) focoInput.focus();
+      })
+      .catch((error) => {
+        console.error("Erro ao buscar CEP:", error);
+        preenche("", "", "", "");
+        alert("Não foi possível buscar o CEP. Tente novamente.");
+      });
+  }
+
+  cepInput.addEventListener("blur", buscaCEP);
+}